@@ -1,12 +1,24 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
 	_ "my-fullstack-app/backend/docs" // Import generated swagger docs
 	"my-fullstack-app/backend/internal/api"
 	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/fx"
+	"my-fullstack-app/backend/internal/history"
 	"my-fullstack-app/backend/internal/logger"
 	"my-fullstack-app/backend/internal/market"
+	"my-fullstack-app/backend/internal/outbox"
+	"my-fullstack-app/backend/internal/portfolio"
+	"my-fullstack-app/backend/internal/transfer"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -29,7 +41,37 @@ import (
 // @BasePath  /api
 
 // @securityDefinitions.basic  BasicAuth
+
+// @securityDefinitions.apikey  AdminToken
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer " followed by the ADMIN_TOKEN of the server
 func main() {
+	// "server migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// "server export ..." and "server import ..." copy tracked data to and from files
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		run := runExport
+		if os.Args[1] == "import" {
+			run = runImport
+		}
+		if err := run(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	autoMigrate := flag.Bool("auto-migrate", os.Getenv("AUTO_MIGRATE") == "true",
+		"apply pending database migrations before serving (default from AUTO_MIGRATE)")
+	flag.Parse()
+
 	// Initialize logger
 	logger.Init(logger.InfoLevel, true)
 
@@ -37,33 +79,129 @@ func main() {
 	r := mux.NewRouter()
 	apiRouter := r.PathPrefix("/api").Subrouter()
 
+	// Identify the caller of every request for the audit log. Behind a proxy that sets
+	// X-Forwarded-For, TRUST_PROXY_HEADERS=true records the client address from it.
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	apiRouter.Use(api.RequestContext(os.Getenv("TRUST_PROXY_HEADERS") == "true"), api.IdentifyAdmin(adminToken))
+
+	// Admin endpoints, enabled by setting ADMIN_TOKEN
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(api.RequireAdminToken(adminToken))
+
 	// Initialize API handlers
 	// Note: This is kept for backward compatibility and health checks
 	if err := api.InitEthClient(); err != nil {
 		logger.Warn().Msgf("Failed to initialize legacy Ethereum client: %v", err)
 	}
 
-	// Initialize blockchain handlers
-	blockchainHandler, err := blockchain.NewHandler()
+	// Open the database pool shared by all handlers. The database may still be starting,
+	// so an unreachable database is reported by the health check rather than fatal.
+	dbConfig, err := database.ConfigFromEnv()
 	if err != nil {
-		logger.Warn().Msgf("Failed to initialize blockchain handler: %v", err)
+		logger.Fatal().Msgf("Invalid database configuration: %v", err)
+	}
+	dbCluster, err := database.OpenCluster(dbConfig)
+	if err != nil {
+		logger.Fatal().Msgf("Could not open database: %v", err)
 	}
+	defer dbCluster.Close()
+	db := dbCluster.Primary()
+	if n := len(dbConfig.ReplicaURLs); n > 0 {
+		logger.Info().Int("replicas", n).Dur("max_lag", dbConfig.MaxReplicaLag).Msg("Routing reads to database replicas")
+		go dbCluster.Run(context.Background())
+	}
+	if err := database.Ping(context.Background(), db, 5*time.Second); err != nil {
+		logger.Warn().Msgf("Database not reachable yet: %v", err)
+	}
+	// A local SQLite database has no separate migration step, so it is always migrated
+	if *autoMigrate || dbConfig.Driver == database.SQLite {
+		if err := database.RunMigrations(context.Background(), db, embeddedMigrations(dbConfig.Driver)); err != nil {
+			logger.Fatal().Msgf("Could not migrate database: %v", err)
+		}
+	}
+	api.InitDatabaseCluster(dbCluster)
+
+	// Keep balance_records partitioned by month and apply the raw data retention. SQLite
+	// tables are not partitioned.
+	if dbConfig.Driver == database.Postgres {
+		retention, err := database.RetentionConfigFromEnv()
+		if err != nil {
+			logger.Fatal().Msgf("Invalid balance retention configuration: %v", err)
+		}
+		go database.NewPartitionManager(db, retention).Run(context.Background())
+	}
+
+	// Initialize fiat exchange rates, preferring a local ECB-style CSV when configured
+	var rateProvider fx.Provider = fx.NewECBProvider()
+	if path := os.Getenv("FX_RATES_CSV"); path != "" {
+		rateProvider = fx.NewCSVFileProvider(path)
+	}
+	rates := fx.NewService(rateProvider, fx.NewDatabaseStore(db))
 
 	// Initialize market data handlers
-	marketHandler, err := market.NewHandler()
+	marketHandler, err := market.NewHandler(rates, market.NewDatabaseAccountStore(db))
 	if err != nil {
 		logger.Warn().Msgf("Failed to initialize market data handler: %v", err)
 	}
 
+	// Map ERC20 tokens to market prices so token balances carry a USD value
+	var tokenPricer *blockchain.TokenPricer
+	tokenRegistry, err := blockchain.NewTokenRegistryFromEnv()
+	if err != nil {
+		logger.Warn().Msgf("Failed to load token mappings: %v", err)
+	} else {
+		// Imported mappings are stored and override the built-in and file mappings
+		if n, err := transfer.LoadTokenMappings(db, tokenRegistry); err != nil {
+			logger.Warn().Msgf("Failed to load stored token mappings: %v", err)
+		} else if n > 0 {
+			logger.Info().Int("mappings", n).Msg("Loaded stored token mappings")
+		}
+	}
+	if tokenRegistry != nil && marketHandler != nil {
+		tokenPricer = blockchain.NewTokenPricer(tokenRegistry, marketHandler.Client(), rates)
+	}
+
+	// Initialize blockchain handlers
+	repo := database.NewClusterRepository(dbCluster)
+	storageMode, err := database.ParseStorageMode(os.Getenv("BALANCE_STORAGE_MODE"))
+	if err != nil {
+		logger.Fatal().Msgf("Invalid balance storage mode: %v", err)
+	}
+	repo.SetStorageMode(storageMode)
+
+	// Balance writes leave change events in the outbox; publish them when a webhook is set.
+	// Without one nothing would deliver or purge them, so none are written.
+	outboxConfig, err := outbox.ConfigFromEnv()
+	if err != nil {
+		logger.Fatal().Msgf("Invalid outbox configuration: %v", err)
+	}
+	repo.SetOutboxEnabled(outboxConfig.WebhookURL != "")
+	if outboxConfig.WebhookURL != "" {
+		publisher := outbox.NewWebhookPublisher(outboxConfig.WebhookURL, outboxConfig.WebhookSecret)
+		go outbox.NewRelay(db, publisher, outboxConfig).Run(context.Background())
+	}
+	var blockchainHandler *blockchain.Handler
+	if ethClient, err := blockchain.NewClient(); err != nil {
+		logger.Warn().Msgf("Failed to initialize blockchain handler: %v", err)
+	} else {
+		blockchainHandler = blockchain.NewHandler(ethClient, repo, repo, tokenPricer)
+	}
+
 	// Register API routes
 	apiRouter.HandleFunc("/health", api.HealthCheckHandler).Methods("GET")
 
+	// Stored balance history only needs the database
+	historyHandler := history.NewHandler(repo)
+	apiRouter.HandleFunc("/addresses/{address}/history", historyHandler.GetHistoryHandler).Methods("GET")
+
 	// Register blockchain routes if handler was initialized
 	if blockchainHandler != nil {
 		apiRouter.HandleFunc("/eth/block", blockchainHandler.BlockNumberHandler).Methods("GET")
 		apiRouter.HandleFunc("/eth/balance", blockchainHandler.GetBalanceHandler).Methods("GET")
 		apiRouter.HandleFunc("/eth/store-balance", blockchainHandler.StoreBalanceHandler).Methods("GET")
 		apiRouter.HandleFunc("/eth/get-token-balances", blockchainHandler.GetTokenBalancesHandler).Methods("GET")
+		apiRouter.HandleFunc("/eth/token-balances", blockchainHandler.GetCommonTokenBalancesHandler).Methods("GET")
+		apiRouter.HandleFunc("/eth/store-token-balances", blockchainHandler.StoreTokenBalancesHandler).Methods("POST")
 
 		// You can add the ERC20 token handlers here
 		// apiRouter.HandleFunc("/eth/token-balance", blockchainHandler.GetTokenBalanceHandler).Methods("GET")
@@ -72,9 +210,54 @@ func main() {
 	if marketHandler != nil {
 		apiRouter.HandleFunc("/market/price", marketHandler.GetCurrentPriceHandler).Methods("GET")
 		apiRouter.HandleFunc("/market/historical", marketHandler.GetHistoricalPriceHandler).Methods("GET")
+		apiRouter.HandleFunc("/market/indicators", marketHandler.GetIndicatorsHandler).Methods("GET")
+		apiRouter.HandleFunc("/market/analytics", marketHandler.GetAnalyticsHandler).Methods("GET")
+		apiRouter.HandleFunc("/market/slippage", marketHandler.GetSlippageHandler).Methods("GET")
+
+		// The operator's exchange account is only shown to administrators
+		adminRouter.HandleFunc("/account/balances", marketHandler.GetAccountBalancesHandler).Methods("GET")
+		adminRouter.HandleFunc("/account/store-balances", marketHandler.StoreAccountBalancesHandler).Methods("POST")
+		adminRouter.HandleFunc("/account/transfers", marketHandler.GetAccountTransfersHandler).Methods("GET")
 		logger.Info().Msg("Registered market data endpoints")
 	}
 
+	// Monitor stablecoin pegs in the background, adding on-chain pool prices when enabled
+	if marketHandler != nil {
+		var dex market.DEXPriceReader
+		if blockchainHandler != nil && os.Getenv("PEG_MONITOR_DEX") == "true" {
+			dex = blockchain.NewUniswapPegReader(blockchainHandler.Client(), blockchain.DefaultUniswapPools)
+		}
+		pegMonitor := market.NewPegMonitor(
+			marketHandler.Client(),
+			market.DefaultPegTargets,
+			dex,
+			market.NewDatabasePegStore(db),
+			market.PegMonitorConfigFromEnv(),
+		)
+		go pegMonitor.Run(context.Background())
+
+		pegHandler := market.NewPegHandler(pegMonitor)
+		apiRouter.HandleFunc("/market/pegs", pegHandler.GetPegsHandler).Methods("GET")
+	}
+
+	// Portfolio valuation needs both on-chain balances and market data
+	if blockchainHandler != nil && marketHandler != nil {
+		portfolioHandler := portfolio.NewHandler(
+			portfolio.NewService(blockchainHandler.Client(), marketHandler.Client(), marketHandler.Accounts()),
+			rates,
+		)
+		apiRouter.HandleFunc("/portfolio", portfolioHandler.GetPortfolioHandler).Methods("GET")
+	}
+
+	// Exports, imports, the audit log and metrics for administrators
+	transferHandler := transfer.NewHandler(transfer.NewService(dbCluster, tokenRegistry))
+	adminRouter.HandleFunc("/export/{dataset}", transferHandler.ExportHandler).Methods("GET")
+	adminRouter.HandleFunc("/import/{dataset}", transferHandler.ImportHandler).Methods("POST")
+	adminRouter.HandleFunc("/audit", api.AuditHandler).Methods("GET")
+
+	// expvar metrics such as the Binance limiter state, which include the command line
+	adminRouter.Handle("/metrics", expvar.Handler()).Methods("GET")
+
 	// Swagger documentation endpoint
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
@@ -86,7 +269,8 @@ func main() {
 			"http://frontend:3000",  // Container name if accessed within Docker network
 		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Authorization", "X-Request-ID"},
+		ExposedHeaders:   []string{"Cache-Status", "X-Request-ID"},
 		AllowCredentials: true,
 		Debug:            false,
 	})
@@ -94,12 +278,9 @@ func main() {
 	// Apply CORS middleware to our router
 	corsHandler := corsMiddleware.Handler(r)
 
-	// Set the main router with CORS handling
-	http.Handle("/", corsHandler)
-
-	// Start the server
+	// Serve only the router, not the default mux, which holds /debug/vars
 	logger.Info().Msg("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
+	if err := http.ListenAndServe(":8080", corsHandler); err != nil {
 		logger.Fatal().Msgf("Could not start server: %s", err)
 	}
 }
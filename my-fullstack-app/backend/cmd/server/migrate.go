@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/migrations"
+)
+
+// embeddedMigrations returns the migrations embedded in the binary for a database dialect
+func embeddedMigrations(dialect database.Dialect) fs.FS {
+	if dialect == database.SQLite {
+		return migrations.SQLiteFS
+	}
+	return migrations.FS
+}
+
+// runMigrate implements the migrate subcommand:
+//
+//	server migrate [-path dir] up | down VERSION | status | version
+//
+// It uses the migrations embedded in the binary unless -path points at a directory.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	path := flags.String("path", "", "directory of migration files to use instead of the embedded ones")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s migrate [-path dir] up | down VERSION | status | version\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		flags.Usage()
+		return fmt.Errorf("missing command")
+	}
+
+	cfg, err := database.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	source := embeddedMigrations(cfg.Driver)
+	if *path != "" {
+		source = os.DirFS(*path)
+	}
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := database.Ping(ctx, db, 10*time.Second); err != nil {
+		return err
+	}
+
+	migrator, err := database.NewMigrator(db, source)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied %d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		return err
+
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf("down needs the version to roll back to, e.g. down 3")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		rolledBack, err := migrator.DownTo(ctx, version)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if errors.Is(err, database.ErrNoMigrationHistory) {
+			fmt.Println("No migration history: no migrations have been applied")
+		} else if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+			}
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			if s.Missing {
+				state = "missing"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if errors.Is(err, database.ErrNoMigrationHistory) {
+			fmt.Fprintln(os.Stderr, "No migration history")
+		} else if err != nil {
+			return err
+		}
+		fmt.Println(version)
+		return nil
+	}
+
+	flags.Usage()
+	return fmt.Errorf("unknown command %q", args[0])
+}
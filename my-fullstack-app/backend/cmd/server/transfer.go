@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
+	"my-fullstack-app/backend/internal/transfer"
+)
+
+// runExport implements the export subcommand:
+//
+//	server export [-format ndjson|csv|parquet] [-out file] DATASET
+//
+// It writes to standard output unless -out is given. The format defaults to the extension
+// of the output file, or NDJSON.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := flags.String("format", "", "ndjson, csv or parquet (default from the -out extension, else ndjson)")
+	out := flags.String("out", "", "file to write instead of standard output")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [-format ndjson|csv|parquet] [-out file] balances | token_balances | watchlists | token_mappings\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	dataset, format, err := parseTransferArgs(flags, args, out)
+	if err != nil {
+		return err
+	}
+	if *formatName != "" {
+		if format, err = transfer.ParseFormat(*formatName); err != nil {
+			return err
+		}
+	}
+
+	service, closeDB, err := openTransferService()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	var w io.Writer = os.Stdout
+	if *out != "" && *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	rows, err := service.Export(context.Background(), dataset, format, w)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d %s rows\n", rows, dataset)
+	return nil
+}
+
+// runImport implements the import subcommand:
+//
+//	server import [-format ndjson|csv|parquet] [-in file] DATASET
+//
+// It reads standard input unless -in is given. The format defaults to the extension of the
+// input file, or NDJSON.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", "", "ndjson, csv or parquet (default from the -in extension, else ndjson)")
+	in := flags.String("in", "", "file to read instead of standard input")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import [-format ndjson|csv|parquet] [-in file] balances | token_balances | watchlists | token_mappings\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+
+	dataset, format, err := parseTransferArgs(flags, args, in)
+	if err != nil {
+		return err
+	}
+	if *formatName != "" {
+		if format, err = transfer.ParseFormat(*formatName); err != nil {
+			return err
+		}
+	}
+
+	service, closeDB, err := openTransferService()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	r := os.Stdin
+	if *in != "" && *in != "-" {
+		if r, err = os.Open(*in); err != nil {
+			return err
+		}
+		defer r.Close()
+	}
+
+	ctx := database.WithAudit(context.Background(), models.AuditEvent{Actor: models.ActorCLI, SourceIP: cliSource()})
+	rows, err := service.Import(ctx, dataset, format, r)
+	if err != nil {
+		// Rows before the failure stay imported
+		if rows > 0 {
+			result := transfer.ImportResult{Dataset: dataset, Format: format, Rows: rows, Error: err.Error()}
+			if err := service.RecordImport(ctx, result); err != nil {
+				return fmt.Errorf("imported %d rows, but could not record the import in the audit log: %w", rows, err)
+			}
+		}
+		return fmt.Errorf("imported %d rows, then: %w", rows, err)
+	}
+	result := transfer.ImportResult{Dataset: dataset, Format: format, Rows: rows}
+	if err := service.RecordImport(ctx, result); err != nil {
+		return fmt.Errorf("imported %d rows, but could not record the import in the audit log: %w", rows, err)
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d %s rows\n", rows, dataset)
+	return nil
+}
+
+// cliSource identifies the operator running a subcommand in the audit log, as user@host
+func cliSource() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
+
+// parseTransferArgs parses the flags and dataset of export and import, and returns the
+// format named by the extension of the file flag
+func parseTransferArgs(flags *flag.FlagSet, args []string, file *string) (transfer.Dataset, transfer.Format, error) {
+	if err := flags.Parse(args); err != nil {
+		return "", "", err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return "", "", fmt.Errorf("missing dataset")
+	}
+	dataset, err := transfer.ParseDataset(flags.Arg(0))
+	if err != nil {
+		return "", "", err
+	}
+	return dataset, transfer.FormatOfFile(*file), nil
+}
+
+// openTransferService connects to the database configured like the server's, with the token
+// registry the server would load, and returns a function that closes the connections
+func openTransferService() (*transfer.Service, func(), error) {
+	cfg, err := database.ConfigFromEnv()
+	if err != nil {
+		return nil, nil, err
+	}
+	cluster, err := database.OpenCluster(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	closeDB := func() { cluster.Close() }
+
+	ctx := context.Background()
+	if err := database.Ping(ctx, cluster.Primary(), 10*time.Second); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	// A local SQLite file is migrated on every start of the server, so it may not exist yet
+	if cfg.Driver == database.SQLite {
+		if err := database.RunMigrations(ctx, cluster.Primary(), embeddedMigrations(cfg.Driver)); err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+	}
+
+	registry, err := blockchain.NewTokenRegistryFromEnv()
+	if err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	if _, err := transfer.LoadTokenMappings(cluster.Primary(), registry); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+	return transfer.NewService(cluster, registry), closeDB, nil
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// RequireAdminToken only lets requests through that send the token as a bearer token in
+// the Authorization header. With an empty token every request is refused, so admin
+// endpoints stay closed until a token is configured. Requests let through are audited as
+// the admin actor.
+func RequireAdminToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				RespondWithError(w, http.StatusForbidden, "Admin endpoints are disabled. Set ADMIN_TOKEN to enable them")
+				return
+			}
+
+			if !hasAdminToken(r, token) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				RespondWithError(w, http.StatusUnauthorized, "Invalid or missing admin token")
+				return
+			}
+			next.ServeHTTP(w, withActor(r, models.ActorAdmin))
+		})
+	}
+}
+
+// IdentifyAdmin attributes requests that send the admin token to the admin actor and lets
+// every request through, for public endpoints that show more to administrators
+func IdentifyAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" && hasAdminToken(r, token) {
+				r = withActor(r, models.ActorAdmin)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsAdmin reports whether a request was authenticated with the admin token
+func IsAdmin(r *http.Request) bool {
+	return RequestInfoFrom(r).Actor == models.ActorAdmin
+}
+
+func hasAdminToken(r *http.Request, token string) bool {
+	sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdentifyAdmin(t *testing.T) {
+	var admin bool
+	handler := RequestContext(false)(IdentifyAdmin("secret")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin = IsAdmin(r)
+	})))
+
+	for authorization, want := range map[string]bool{"": false, "Bearer wrong": false, "Bearer secret": true} {
+		req := httptest.NewRequest("GET", "/api/portfolio", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || admin != want {
+			t.Errorf("Authorization %q: expected admin %v and 200, got %v and %d", authorization, want, admin, rr.Code)
+		}
+	}
+
+	// Without a configured token nobody is an administrator
+	disabled := IdentifyAdmin("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		admin = IsAdmin(r)
+	}))
+	req := httptest.NewRequest("GET", "/api/portfolio", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	disabled.ServeHTTP(httptest.NewRecorder(), req)
+	if admin {
+		t.Error("Expected no administrator without a token")
+	}
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+
+	// maxRequestIDLength bounds a request ID sent by the client
+	maxRequestIDLength = 128
+)
+
+// RequestInfo identifies the caller of a request for the audit log
+type RequestInfo struct {
+	ID       string // X-Request-ID of the request, or a generated one
+	SourceIP string
+	Actor    string
+}
+
+type requestInfoKey struct{}
+
+// RequestContext gives every request an ID, echoed in the X-Request-ID response header,
+// and records its source IP and actor for the audit log. An X-Request-ID sent by the client
+// is kept. The source IP is the remote address, or with trustProxy the first address of
+// X-Forwarded-For, which only a proxy that overwrites the header makes trustworthy.
+func RequestContext(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := RequestInfo{
+				ID:       r.Header.Get("X-Request-ID"),
+				SourceIP: sourceIP(r, trustProxy),
+				Actor:    models.ActorAnonymous,
+			}
+			if !validRequestID(info.ID) {
+				info.ID = newRequestID()
+			}
+			w.Header().Set("X-Request-ID", info.ID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+		})
+	}
+}
+
+// RequestInfoFrom returns the caller of a request. Requests that did not pass through
+// RequestContext have no ID and are attributed to their remote address.
+func RequestInfoFrom(r *http.Request) RequestInfo {
+	if info, ok := r.Context().Value(requestInfoKey{}).(RequestInfo); ok {
+		return info
+	}
+	return RequestInfo{SourceIP: sourceIP(r, false), Actor: models.ActorAnonymous}
+}
+
+// withActor attributes a request to an authenticated actor
+func withActor(r *http.Request, actor string) *http.Request {
+	info := RequestInfoFrom(r)
+	info.Actor = actor
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+}
+
+func sourceIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AuditContext returns the context of a request, with its writes attributed to the caller
+// of the request in the audit log. Stores record the write in its own transaction, so a
+// write that cannot be recorded fails.
+func AuditContext(r *http.Request) context.Context {
+	info := RequestInfoFrom(r)
+	return database.WithAudit(r.Context(), models.AuditEvent{
+		Actor:     info.Actor,
+		RequestID: info.ID,
+		SourceIP:  info.SourceIP,
+	})
+}
+
+// AuditPage is one page of the audit log
+type AuditPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextBefore int64               `json:"next_before,omitempty"` // Pass as before for the next page; zero on the last page
+}
+
+// AuditHandler returns the audit log, newest first
+// @Summary Get the audit log
+// @Description Returns the writes made through the API and the import subcommand, newest first, with the actor, request ID, source IP and the state of the target before and after each write. Pass next_before back as before, with the same filters, to get the next page. Requires the admin token.
+// @Tags admin
+// @Produce json
+// @Param actor query string false "admin, anonymous or cli"
+// @Param action query string false "balance.store, token_balances.store, account_balances.store or data.import"
+// @Param target query string false "Address, exchange account or dataset written"
+// @Param request_id query string false "X-Request-ID of the request"
+// @Param from query string false "Start of the range, inclusive (RFC3339)"
+// @Param to query string false "End of the range, exclusive (RFC3339)"
+// @Param limit query int false "Events per page (default 100, max 1000)"
+// @Param before query int false "next_before of the previous page"
+// @Success 200 {object} Response{data=AuditPage}
+// @Failure 400 {object} Response
+// @Failure 401 {object} Response
+// @Failure 500 {object} Response
+// @Security AdminToken
+// @Router /admin/audit [get]
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := database.AuditQuery{
+		Actor:     q.Get("actor"),
+		Action:    q.Get("action"),
+		Target:    q.Get("target"),
+		RequestID: q.Get("request_id"),
+		Limit:     defaultAuditPageSize,
+	}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if query.From, err = time.Parse(time.RFC3339, v); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid from. Use RFC3339")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if query.To, err = time.Parse(time.RFC3339, v); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid to. Use RFC3339")
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditPageSize {
+			RespondWithError(w, http.StatusBadRequest, "Invalid limit. Use 1 to 1000")
+			return
+		}
+		query.Limit = limit
+	}
+	if v := q.Get("before"); v != "" {
+		if query.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || query.BeforeID < 1 {
+			RespondWithError(w, http.StatusBadRequest, "Invalid before")
+			return
+		}
+	}
+
+	if cluster == nil {
+		RespondWithError(w, http.StatusServiceUnavailable, "Database not configured")
+		return
+	}
+
+	// The audit log is read from the primary so it includes the latest writes. One extra
+	// event tells whether there is a next page.
+	pageSize := query.Limit
+	query.Limit++
+	events, err := database.GetAuditEvents(cluster.Primary(), query)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get audit events")
+		RespondWithError(w, http.StatusInternalServerError, "Failed to get audit events")
+		return
+	}
+
+	page := AuditPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextBefore = page.Events[pageSize-1].ID
+	}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+
+	RespondWithJSON(w, http.StatusOK, Response{
+		Success: true,
+		Message: "Audit events retrieved successfully",
+		Data:    page,
+	})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/database/dbtest"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const auditAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+// newAuditDB migrates a fresh SQLite file and uses it as the API's database
+func newAuditDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.SQLite(t)
+	previous := cluster
+	InitDatabase(db)
+	t.Cleanup(func() { cluster = previous })
+	return db
+}
+
+func getAuditPage(t *testing.T, handler http.Handler, query string) AuditPage {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/api/admin/audit?"+query, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for %q, got %d: %s", query, rr.Code, rr.Body)
+	}
+
+	var response struct {
+		Data AuditPage `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return response.Data
+}
+
+func TestAuditContext(t *testing.T) {
+	db := newAuditDB(t)
+
+	// A public write and an admin write, as routed by the server
+	write := func(action, target string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := database.RecordAudit(AuditContext(r), db, action, target, map[string]string{"balance": "1"}, map[string]string{"balance": "2"})
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+	requestContext := RequestContext(true)
+	public := requestContext(write(models.AuditBalanceStored, auditAddress))
+	admin := requestContext(RequireAdminToken("secret")(write(models.AuditDataImported, "balances")))
+
+	req := httptest.NewRequest("GET", "/api/eth/store-balance", nil)
+	req.RemoteAddr = "10.0.0.2:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	req.Header.Set("X-Request-ID", "req-1")
+	rr := httptest.NewRecorder()
+	public.ServeHTTP(rr, req)
+	if got := rr.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("Expected the request ID echoed, got %q", got)
+	}
+
+	req = httptest.NewRequest("POST", "/api/admin/import/balances", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, req)
+	generatedID := rr.Header().Get("X-Request-ID")
+	if len(generatedID) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", generatedID)
+	}
+
+	audit := requestContext(RequireAdminToken("secret")(http.HandlerFunc(AuditHandler)))
+	page := getAuditPage(t, audit, "")
+	if len(page.Events) != 2 || page.NextBefore != 0 {
+		t.Fatalf("Expected 2 events on one page, got %+v", page)
+	}
+	imported, stored := page.Events[0], page.Events[1]
+	if stored.Actor != models.ActorAnonymous || stored.SourceIP != "203.0.113.7" || stored.RequestID != "req-1" ||
+		stored.Target != "0x742d35cc6634c0532925a3b844bc454e4438f44e" || string(stored.Before) != `{"balance":"1"}` {
+		t.Errorf("Expected the public write attributed to the client, got %+v", stored)
+	}
+	if imported.Actor != models.ActorAdmin || imported.RequestID != generatedID || imported.SourceIP != "192.0.2.1" {
+		t.Errorf("Expected the import attributed to the admin, got %+v", imported)
+	}
+
+	// Filters, with addresses matched in any case
+	if page := getAuditPage(t, audit, "target="+auditAddress); len(page.Events) != 1 || page.Events[0].ID != stored.ID {
+		t.Errorf("Expected the event of the address, got %+v", page)
+	}
+	if page := getAuditPage(t, audit, "actor=admin&action=data.import"); len(page.Events) != 1 || page.Events[0].ID != imported.ID {
+		t.Errorf("Expected the admin import, got %+v", page)
+	}
+	if page := getAuditPage(t, audit, "request_id=unknown"); len(page.Events) != 0 {
+		t.Errorf("Expected no events, got %+v", page)
+	}
+
+	// Pages, newest first
+	first := getAuditPage(t, audit, "limit=1")
+	if len(first.Events) != 1 || first.Events[0].ID != imported.ID || first.NextBefore != imported.ID {
+		t.Fatalf("Expected the import on the first page, got %+v", first)
+	}
+	if next := getAuditPage(t, audit, "limit=1&before=1"); len(next.Events) != 0 {
+		t.Errorf("Expected nothing before the first event, got %+v", next)
+	}
+}
+
+func TestAuditHandlerValidation(t *testing.T) {
+	newAuditDB(t)
+
+	for _, query := range []string{"from=yesterday", "to=1700000000", "limit=0", "limit=1001", "before=abc"} {
+		rr := httptest.NewRecorder()
+		AuditHandler(rr, httptest.NewRequest("GET", "/api/admin/audit?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %q, got %d", query, rr.Code)
+		}
+	}
+}
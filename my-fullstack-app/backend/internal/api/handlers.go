@@ -2,9 +2,11 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"os"
+	"time"
 
 	"my-fullstack-app/backend/internal/database"
 
@@ -20,8 +22,26 @@ type Response struct {
 	Error   string      `json:"error,omitempty"`
 }
 
+// blockNumberReader is the part of the Ethereum client the health check uses
+type blockNumberReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
 // Global ethclient
-var ethClient *ethclient.Client
+var ethClient blockNumberReader
+
+// Shared database pools, set by InitDatabase or InitDatabaseCluster
+var cluster *database.Cluster
+
+// InitDatabase sets the connection pool the health check pings
+func InitDatabase(pool *sql.DB) {
+	InitDatabaseCluster(database.NewCluster(pool))
+}
+
+// InitDatabaseCluster sets the primary and replica pools the health check reports on
+func InitDatabaseCluster(c *database.Cluster) {
+	cluster = c
+}
 
 // InitEthClient initializes the Ethereum client connection
 func InitEthClient() error {
@@ -44,7 +64,7 @@ func InitEthClient() error {
 
 // HealthCheckHandler handles the /health endpoint
 // @Summary      Check API health status
-// @Description  Returns health status of the API, including Ethereum client and database connectivity
+// @Description  Returns health status of the API, including Ethereum client and database connectivity, and the usage and replication lag of each database pool
 // @Tags         system
 // @Accept       json
 // @Produce      json
@@ -78,21 +98,19 @@ func HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 		healthDetails["ethereum"] = "connected"
 	}
 
-	// Check database connection
-	db, err := database.Connect()
-	if err != nil {
+	// Check database connection. Reads fall back to the primary, so only the primary
+	// decides the status; every pool is listed with its usage.
+	if cluster == nil {
 		healthDetails["database"] = "disconnected"
 		status = "degraded"
 	} else {
-		// Test the database with a simple ping
-		err = db.Ping()
-		if err != nil {
+		if err := database.Ping(r.Context(), cluster.Primary(), 2*time.Second); err != nil {
 			healthDetails["database"] = "error: " + err.Error()
 			status = "degraded"
 		} else {
 			healthDetails["database"] = "connected"
 		}
-		db.Close()
+		healthDetails["database_pools"] = cluster.Stats(r.Context())
 	}
 
 	response := Response{
@@ -1,13 +1,32 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+
+	"my-fullstack-app/backend/internal/database/dbtest"
 )
 
-func TestHealthCheckHandler(t *testing.T) {
+// connectedEthClient stands in for a connected Ethereum client
+type connectedEthClient struct{}
+
+func (connectedEthClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return 19000000, nil
+}
+
+// checkHealth runs the health check against a database pool and a connected Ethereum client
+func checkHealth(t *testing.T, pool *sql.DB) (int, Response) {
+	t.Helper()
+
+	previousCluster, previousEth := cluster, ethClient
+	InitDatabase(pool)
+	ethClient = connectedEthClient{}
+	t.Cleanup(func() { cluster, ethClient = previousCluster, previousEth })
+
 	// Create a request
 	req, err := http.NewRequest("GET", "/api/health", nil)
 	if err != nil {
@@ -21,20 +40,35 @@ func TestHealthCheckHandler(t *testing.T) {
 	// Call the handler
 	handler.ServeHTTP(rr, req)
 
-	// Check the status code
-	if status := rr.Code; status != http.StatusOK {
-		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
-	}
-
 	// Check the response body
 	var response Response
-	err = json.Unmarshal(rr.Body.Bytes(), &response)
-	if err != nil {
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
 		t.Errorf("could not parse response body: %v", err)
 	}
+	return rr.Code, response
+}
+
+func TestHealthCheckHandler(t *testing.T) {
+	status, response := checkHealth(t, dbtest.SQLite(t))
+
+	// Check the status code
+	if status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
 
 	expected := "OK"
 	if response.Message != expected {
 		t.Errorf("handler returned unexpected message: got %v want %v", response.Message, expected)
 	}
 }
+
+func TestHealthCheckHandlerDegraded(t *testing.T) {
+	// A database that cannot be reached
+	pool := dbtest.Open(t)
+	pool.Close()
+
+	status, response := checkHealth(t, pool)
+	if status != http.StatusServiceUnavailable || response.Message != "degraded" {
+		t.Errorf("Expected 503 degraded without a database, got %d %q", status, response.Message)
+	}
+}
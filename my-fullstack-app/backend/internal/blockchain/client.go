@@ -9,6 +9,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 
+	"my-fullstack-app/backend/internal/database"
 	"my-fullstack-app/backend/internal/logger"
 	"my-fullstack-app/backend/internal/models"
 )
@@ -31,14 +32,23 @@ func NewClient() (*Client, error) {
 	}
 
 	// Connect to Infura
-	infuraURL := "https://mainnet.infura.io/v3/" + infuraKey
-	client, err := ethclient.Dial(infuraURL)
+	client, err := NewClientWithURL("https://mainnet.infura.io/v3/" + infuraKey)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to connect to Infura")
 		return nil, err
 	}
 
 	logger.Info().Msg("Successfully connected to Infura")
+	return client, nil
+}
+
+// NewClientWithURL creates a blockchain client talking to the JSON-RPC endpoint at rpcURL
+func NewClientWithURL(rpcURL string) (*Client, error) {
+	client, err := ethclient.Dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Client{
 		ethClient: client,
 	}, nil
@@ -99,26 +109,37 @@ func (c *Client) GetBalanceInEth(address string) (*big.Int, *big.Float, error) {
 	return balance, ethBalance, nil
 }
 
-// CreateBalanceRecord creates a balance record from address and balance
+// CreateBalanceRecord creates a balance record from the balance at the latest block
 func (c *Client) CreateBalanceRecord(address string) (models.BalanceRecord, error) {
 	// Validate address
 	if !common.IsHexAddress(address) {
 		return models.BalanceRecord{}, ErrInvalidAddress
 	}
 
-	// Get balances
-	balance, ethBalance, err := c.GetBalanceInEth(address)
+	// Pin the read to a block so repeated writes of the same block are recognised
+	ctx := context.Background()
+	blockNumber, err := c.ethClient.BlockNumber(ctx)
+	if err != nil {
+		logger.Error().Err(err).Str("address", address).Msg("Failed to create balance record")
+		return models.BalanceRecord{}, err
+	}
+
+	balance, err := c.ethClient.BalanceAt(ctx, common.HexToAddress(address), new(big.Int).SetUint64(blockNumber))
 	if err != nil {
 		logger.Error().Err(err).Str("address", address).Msg("Failed to create balance record")
 		return models.BalanceRecord{}, err
 	}
 
 	// Create record
+	now := time.Now()
 	balanceRecord := models.BalanceRecord{
-		Address:    address,
-		Balance:    balance.String(),
-		BalanceETH: ethBalance.Text('f', 18),
-		FetchedAt:  time.Now(),
+		Chain:       models.ChainEthereum,
+		Address:     address,
+		Balance:     balance.String(),
+		BalanceETH:  database.FormatUnits(balance.String(), 18),
+		BlockNumber: &blockNumber,
+		FetchedAt:   now,
+		LastSeenAt:  now,
 	}
 
 	logger.Info().Str("address", address).Msg("Created balance record successfully")
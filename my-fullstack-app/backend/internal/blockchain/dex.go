@@ -0,0 +1,114 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"my-fullstack-app/backend/internal/market"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Uniswap V2 pair ABI for reading pool reserves
+const uniswapV2PairABIJson = `[
+    {
+        "constant": true,
+        "inputs": [],
+        "name": "getReserves",
+        "outputs": [
+            {"name": "reserve0", "type": "uint112"},
+            {"name": "reserve1", "type": "uint112"},
+            {"name": "blockTimestampLast", "type": "uint32"}
+        ],
+        "type": "function"
+    }
+]`
+
+// UniswapPool is a Uniswap V2 pair pricing a stablecoin in another stablecoin that is
+// assumed to hold its peg
+type UniswapPool struct {
+	Address string
+	Token   TokenInfo // Stablecoin being priced
+	Quote   TokenInfo // Stablecoin it is priced in
+}
+
+// DefaultUniswapPools are the deepest Uniswap V2 mainnet pools of the common stablecoins
+var DefaultUniswapPools = map[string]UniswapPool{
+	"USDC": {Address: "0x3041CbD36888bECc7bbCBc0045E3B1f144466f5f", Token: CommonTokens["USDC"], Quote: CommonTokens["USDT"]},
+	"USDT": {Address: "0x3041CbD36888bECc7bbCBc0045E3B1f144466f5f", Token: CommonTokens["USDT"], Quote: CommonTokens["USDC"]},
+	"DAI":  {Address: "0xAE461cA67B15dc8dc81CE7615e0320dA1A9aB8D5", Token: CommonTokens["DAI"], Quote: CommonTokens["USDC"]},
+}
+
+// UniswapPegReader prices stablecoins from Uniswap V2 pool reserves
+type UniswapPegReader struct {
+	client *Client
+	pools  map[string]UniswapPool
+}
+
+// NewUniswapPegReader creates a reader of the given pools, keyed by stablecoin symbol
+func NewUniswapPegReader(client *Client, pools map[string]UniswapPool) *UniswapPegReader {
+	return &UniswapPegReader{
+		client: client,
+		pools:  pools,
+	}
+}
+
+// DEXPrice returns the price of a stablecoin in its pool's quote stablecoin
+func (r *UniswapPegReader) DEXPrice(ctx context.Context, asset string) (float64, string, error) {
+	pool, ok := r.pools[strings.ToUpper(asset)]
+	if !ok {
+		return 0, "", market.ErrNoDEXPool
+	}
+	source := "uniswap-v2:" + pool.Token.Symbol + pool.Quote.Symbol
+
+	parsedAbi, err := abi.JSON(strings.NewReader(uniswapV2PairABIJson))
+	if err != nil {
+		return 0, source, err
+	}
+	contract := bind.NewBoundContract(common.HexToAddress(pool.Address), parsedAbi, r.client.ethClient, r.client.ethClient, r.client.ethClient)
+
+	var result []interface{}
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &result, "getReserves"); err != nil {
+		return 0, source, fmt.Errorf("failed to read %s pool reserves: %w", source, err)
+	}
+	if len(result) < 2 {
+		return 0, source, ErrTokenContract
+	}
+	reserve0, ok0 := result[0].(*big.Int)
+	reserve1, ok1 := result[1].(*big.Int)
+	if !ok0 || !ok1 {
+		return 0, source, ErrTokenContract
+	}
+
+	// Uniswap orders a pair's tokens by address
+	tokenReserve, quoteReserve := reserve0, reserve1
+	if strings.ToLower(pool.Token.Address) > strings.ToLower(pool.Quote.Address) {
+		tokenReserve, quoteReserve = reserve1, reserve0
+	}
+
+	price, err := reservePrice(tokenReserve, quoteReserve, pool.Token.Decimals, pool.Quote.Decimals)
+	if err != nil {
+		return 0, source, fmt.Errorf("%s: %w", source, err)
+	}
+	return price, source, nil
+}
+
+// reservePrice is the spot price of a pool's token in its quote token, from raw reserves
+func reservePrice(tokenReserve, quoteReserve *big.Int, tokenDecimals, quoteDecimals uint8) (float64, error) {
+	if tokenReserve.Sign() <= 0 || quoteReserve.Sign() <= 0 {
+		return 0, fmt.Errorf("pool has no liquidity")
+	}
+
+	tokens := new(big.Float).Quo(new(big.Float).SetInt(tokenReserve), pow10(tokenDecimals))
+	quotes := new(big.Float).Quo(new(big.Float).SetInt(quoteReserve), pow10(quoteDecimals))
+	price, _ := new(big.Float).Quo(quotes, tokens).Float64()
+	return price, nil
+}
+
+func pow10(decimals uint8) *big.Float {
+	return new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+}
@@ -0,0 +1,42 @@
+package blockchain
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestReservePrice(t *testing.T) {
+	million6, _ := new(big.Int).SetString("1000000000000", 10)              // 1,000,000 with 6 decimals
+	million18, _ := new(big.Int).SetString("1000000000000000000000000", 10) // 1,000,000 with 18 decimals
+	ninetyEight18, _ := new(big.Int).SetString("980000000000000000000000", 10)
+
+	testCases := []struct {
+		name         string
+		token, quote *big.Int
+		tokenDec     uint8
+		quoteDec     uint8
+		want         float64
+	}{
+		{name: "Balanced pool with equal decimals", token: million6, quote: million6, tokenDec: 6, quoteDec: 6, want: 1},
+		{name: "Balanced pool with different decimals", token: million18, quote: million6, tokenDec: 18, quoteDec: 6, want: 1},
+		// The pool holds more of the token than of its quote, so the token trades below the quote
+		{name: "Token in surplus", token: million18, quote: ninetyEight18, tokenDec: 18, quoteDec: 18, want: 0.98},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := reservePrice(tc.token, tc.quote, tc.tokenDec, tc.quoteDec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if math.Abs(got-tc.want) > 1e-12 {
+				t.Errorf("Expected %f, got %f", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := reservePrice(big.NewInt(0), million6, 6, 6); err == nil {
+		t.Errorf("Expected an error for an empty pool")
+	}
+}
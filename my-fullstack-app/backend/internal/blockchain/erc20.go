@@ -3,9 +3,12 @@ package blockchain
 import (
 	"context"
 	"math/big"
+	"sort"
 	"strings"
 	"time"
 
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
 	"my-fullstack-app/backend/internal/models"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -153,6 +156,11 @@ func (c *Client) NewERC20(tokenAddress string) (*ERC20, error) {
 
 // GetERC20Balance returns the balance of an ERC20 token for an address
 func (e *ERC20) GetBalance(address string) (*big.Int, error) {
+	return e.balanceAt(address, nil)
+}
+
+// balanceAt returns the balance of an address at a block, or at the latest block when nil
+func (e *ERC20) balanceAt(address string, blockNumber *big.Int) (*big.Int, error) {
 	// Validate address
 	if !common.IsHexAddress(address) {
 		return nil, ErrInvalidAddress
@@ -162,7 +170,7 @@ func (e *ERC20) GetBalance(address string) (*big.Int, error) {
 	ownerAddress := common.HexToAddress(address)
 	var balance big.Int
 
-	callOpts := &bind.CallOpts{Context: context.Background()}
+	callOpts := &bind.CallOpts{Context: context.Background(), BlockNumber: blockNumber}
 	var result []interface{}
 	err := e.contract.Call(callOpts, &result, "balanceOf", ownerAddress)
 	if err != nil || len(result) == 0 {
@@ -196,25 +204,34 @@ func (e *ERC20) GetFormattedBalance(address string) (*big.Int, *big.Float, error
 	return balance, tokenBalance, nil
 }
 
-// CreateTokenBalanceRecord creates a token balance record
+// CreateTokenBalanceRecord creates a token balance record from the balance at the latest block
 func (e *ERC20) CreateTokenBalanceRecord(address string) (models.TokenBalanceRecord, error) {
 	// Validate address
 	if !common.IsHexAddress(address) {
 		return models.TokenBalanceRecord{}, ErrInvalidAddress
 	}
 
-	// Get balances
-	rawBalance, formattedBalance, err := e.GetFormattedBalance(address)
+	// Pin the read to a block so the record says which block it reflects
+	blockNumber, err := e.client.ethClient.BlockNumber(context.Background())
+	if err != nil {
+		return models.TokenBalanceRecord{}, err
+	}
+
+	rawBalance, err := e.balanceAt(address, new(big.Int).SetUint64(blockNumber))
 	if err != nil {
 		return models.TokenBalanceRecord{}, err
 	}
 
 	// Create record
 	balanceRecord := models.TokenBalanceRecord{
-		Address:    address,
-		Balance:    rawBalance.String(),
-		BalanceETH: formattedBalance.Text('f', int(e.tokenInfo.Decimals)),
-		FetchedAt:  time.Now(),
+		Chain:        models.ChainEthereum,
+		Address:      address,
+		TokenAddress: e.tokenInfo.Address,
+		Balance:      rawBalance.String(),
+		BalanceETH:   database.FormatUnits(rawBalance.String(), e.tokenInfo.Decimals),
+		Decimals:     e.tokenInfo.Decimals,
+		BlockNumber:  &blockNumber,
+		FetchedAt:    time.Now(),
 	}
 
 	return balanceRecord, nil
@@ -259,3 +276,47 @@ func (c *Client) GetCommonTokenBalances(address string) ([]models.TokenBalanceRe
 
 	return c.GetMultipleTokenBalances(address, tokenAddresses)
 }
+
+// GetCommonTokenHoldings fetches the non-zero balances of the common tokens, ordered by symbol
+func (c *Client) GetCommonTokenHoldings(address string) ([]TokenBalance, error) {
+	// Validate address
+	if !common.IsHexAddress(address) {
+		return nil, ErrInvalidAddress
+	}
+
+	var holdings []TokenBalance
+	for _, token := range CommonTokens {
+		erc20, err := c.NewERC20(token.Address)
+		if err != nil {
+			continue // Skip tokens with errors
+		}
+		// The known token list is more reliable than what the contract reports
+		erc20.tokenInfo = token
+
+		weiBalance, balance, err := erc20.GetFormattedBalance(address)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Str("token", token.Symbol).
+				Str("address", address).
+				Msg("Failed to fetch token balance")
+			continue
+		}
+
+		if weiBalance.Sign() == 0 {
+			continue
+		}
+
+		holdings = append(holdings, TokenBalance{
+			Token:      token,
+			WeiBalance: weiBalance,
+			Balance:    balance,
+		})
+	}
+
+	sort.Slice(holdings, func(i, j int) bool {
+		return holdings[i].Token.Symbol < holdings[j].Token.Symbol
+	})
+
+	return holdings, nil
+}
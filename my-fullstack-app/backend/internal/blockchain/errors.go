@@ -9,4 +9,8 @@ var (
 	ErrInvalidAddress      = errors.New("invalid ethereum address format")
 	ErrInvalidTokenAddress = errors.New("invalid token contract address")
 	ErrTokenContract       = errors.New("error interacting with token contract")
+	// ErrInvalidTokenMapping is returned for token mappings without exactly one price source
+	ErrInvalidTokenMapping = errors.New("invalid token mapping")
+	// ErrUnmappedToken is returned when no price source is known for a token
+	ErrUnmappedToken = errors.New("no price mapping for token")
 )
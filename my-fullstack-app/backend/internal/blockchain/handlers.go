@@ -1,30 +1,39 @@
 package blockchain
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
 	"my-fullstack-app/backend/internal/api"
 	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
 // Handler handles blockchain-related HTTP requests
 type Handler struct {
-	client *Client
+	client   *Client
+	balances database.BalanceRepository
+	tokens   database.TokenBalanceRepository
+	pricer   *TokenPricer
 }
 
-// NewHandler creates a new blockchain handler
-func NewHandler() (*Handler, error) {
-	client, err := NewClient()
-	if err != nil {
-		return nil, err
+// NewHandler creates a new blockchain handler storing balances in the given repositories.
+// Pricer may be nil, in which case token balances are returned and stored unpriced.
+func NewHandler(client *Client, balances database.BalanceRepository, tokens database.TokenBalanceRepository, pricer *TokenPricer) *Handler {
+	return &Handler{
+		client:   client,
+		balances: balances,
+		tokens:   tokens,
+		pricer:   pricer,
 	}
+}
 
-	return &Handler{
-		client: client,
-	}, nil
+// Client returns the Ethereum client used by the handler
+func (h *Handler) Client() *Client {
+	return h.client
 }
 
 // BlockNumberHandler returns the latest Ethereum block number
@@ -126,16 +135,8 @@ func (h *Handler) StoreBalanceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Connect to the database
-	db, err := database.Connect()
-	if err != nil {
-		http.Error(w, "Database connection failed", http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
-	// Store the balance in the database
-	balanceID, err := database.StoreBalance(db, balanceRecord)
+	// Store the balance in the database, with its audit event
+	balanceID, err := h.balances.StoreBalance(api.AuditContext(r), balanceRecord)
 	if err != nil {
 		http.Error(w, "Failed to store balance in database", http.StatusInternalServerError)
 		return
@@ -181,16 +182,8 @@ func (h *Handler) GetTokenBalancesHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Connect to the database
-	db, err := database.Connect()
-	if err != nil {
-		http.Error(w, "Database connection failed", http.StatusInternalServerError)
-		return
-	}
-	defer db.Close()
-
 	// Retrieve token balances
-	balances, err := database.GetTokenBalances(db, tokenAddress)
+	balances, err := h.tokens.TokenBalances(r.Context(), tokenAddress)
 	if err != nil {
 		http.Error(w, "Failed to retrieve token balances", http.StatusInternalServerError)
 		return
@@ -211,3 +204,103 @@ func (h *Handler) GetTokenBalancesHandler(w http.ResponseWriter, r *http.Request
 	}
 	json.NewEncoder(w).Encode(response)
 }
+
+// commonTokenBalances fetches and values the common token balances of an address
+func (h *Handler) commonTokenBalances(ctx context.Context, address string) ([]models.TokenBalanceRecord, error) {
+	records, err := h.client.GetCommonTokenBalances(address)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range records {
+		if h.pricer != nil {
+			h.pricer.ValueRecord(ctx, &records[i])
+		} else {
+			records[i].PriceSource = PriceSourceUnpriced
+		}
+	}
+
+	return records, nil
+}
+
+// GetCommonTokenBalancesHandler returns the common token balances of an address with their USD value
+// @Summary      Get common token balances
+// @Description  Returns the balances of the common ERC20 tokens held by an address, each with its USD value and the price source used
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        address  query     string  true  "Ethereum address (0x format)"
+// @Success      200      {object}  api.Response
+// @Failure      400      {object}  api.Response
+// @Failure      500      {object}  api.Response
+// @Router       /eth/token-balances [get]
+func (h *Handler) GetCommonTokenBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.commonTokenBalances(r.Context(), address)
+	if err == ErrInvalidAddress {
+		http.Error(w, "Invalid Ethereum address format", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get token balances", http.StatusInternalServerError)
+		return
+	}
+
+	response := api.Response{
+		Message: "Token balances retrieved",
+		Data:    records,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// StoreTokenBalancesHandler retrieves the common token balances of an address and stores them
+// @Summary      Store common token balances
+// @Description  Retrieves the balances of the common ERC20 tokens held by an address and stores them with their USD value and price source
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        address  query     string  true  "Ethereum address (0x format)"
+// @Success      200      {object}  api.Response
+// @Failure      400      {object}  api.Response
+// @Failure      500      {object}  api.Response
+// @Router       /eth/store-token-balances [post]
+func (h *Handler) StoreTokenBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		http.Error(w, "Address parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	records, err := h.commonTokenBalances(r.Context(), address)
+	if err == ErrInvalidAddress {
+		http.Error(w, "Invalid Ethereum address format", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get token balances", http.StatusInternalServerError)
+		return
+	}
+
+	// Store the balances together, with their audit event
+	ids, err := h.tokens.StoreTokenBalances(api.AuditContext(r), records)
+	if err != nil {
+		http.Error(w, "Failed to store token balances in database", http.StatusInternalServerError)
+		return
+	}
+	for i, id := range ids {
+		records[i].ID = id
+	}
+
+	response := api.Response{
+		Message: "Token balances retrieved and stored",
+		Data:    records,
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,215 @@
+package blockchain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const testAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+// ERC20 method selectors
+const (
+	selectorBalanceOf = "70a08231"
+	selectorDecimals  = "313ce567"
+)
+
+// newStubNode serves the JSON-RPC calls the handlers make: an ETH balance of 1.5 and a
+// balance of 10 whole units in every token contract
+func newStubNode(t *testing.T) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Invalid JSON-RPC request: %v", err)
+			return
+		}
+
+		reply := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_blockNumber":
+			reply["result"] = "0x12d687" // 1234567
+		case "eth_getBalance":
+			reply["result"] = "0x14d1120d7b160000" // 1.5 ETH in wei
+		case "eth_call":
+			var call struct {
+				To    string `json:"to"`
+				Input string `json:"input"`
+				Data  string `json:"data"`
+			}
+			json.Unmarshal(req.Params[0], &call)
+			input := strings.TrimPrefix(call.Input+call.Data, "0x")
+
+			decimals := tokenDecimals(call.To)
+			switch {
+			case strings.HasPrefix(input, selectorDecimals):
+				reply["result"] = fmt.Sprintf("0x%064x", decimals)
+			case strings.HasPrefix(input, selectorBalanceOf):
+				balance := new(big.Int).Mul(big.NewInt(10), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+				reply["result"] = fmt.Sprintf("0x%064x", balance)
+			default:
+				reply["error"] = map[string]interface{}{"code": -32000, "message": "execution reverted"}
+			}
+		default:
+			reply["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reply)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClientWithURL(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+// tokenDecimals returns the decimals of a common token, by contract address
+func tokenDecimals(address string) uint8 {
+	for _, token := range CommonTokens {
+		if strings.EqualFold(token.Address, address) {
+			return token.Decimals
+		}
+	}
+	return 18
+}
+
+func newTestHandler(t *testing.T) (*Handler, *database.MemoryRepository) {
+	registry, err := NewTokenRegistry(DefaultTokenMappings())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pricer := NewTokenPricer(registry, fakeFeed{prices: map[string]float64{"LINKUSDT": 15}}, nil)
+
+	repo := database.NewMemoryRepository()
+	return NewHandler(newStubNode(t), repo, repo, pricer), repo
+}
+
+// serve calls a handler and decodes its response
+func serve(t *testing.T, handler http.HandlerFunc, target string, data interface{}) int {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if rr.Code == http.StatusOK {
+		response := api.Response{Data: data}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Could not parse response body: %v", err)
+		}
+	}
+	return rr.Code
+}
+
+func TestGetBalanceHandler(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	testCases := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "Valid address", query: "?address=" + testAddress, wantStatus: http.StatusOK},
+		{name: "Missing address", query: "", wantStatus: http.StatusBadRequest},
+		{name: "Invalid address", query: "?address=0x123", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var data map[string]string
+			status := serve(t, h.GetBalanceHandler, "/api/eth/balance"+tc.query, &data)
+			if status != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tc.wantStatus, status)
+			}
+			if status == http.StatusOK && (data["wei"] != "1500000000000000000" || !strings.HasPrefix(data["eth"], "1.5")) {
+				t.Errorf("Expected a balance of 1.5 ETH, got %v", data)
+			}
+		})
+	}
+}
+
+func TestStoreBalanceHandler(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	var data map[string]interface{}
+	if status := serve(t, h.StoreBalanceHandler, "/api/eth/store-balance?address="+testAddress, &data); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+
+	stored, err := repo.Balances(context.Background(), testAddress, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stored) != 1 || stored[0].Balance != "1500000000000000000" {
+		t.Fatalf("Expected one stored balance of 1.5 ETH, got %+v", stored)
+	}
+	if id, _ := data["id"].(float64); int(id) != stored[0].ID {
+		t.Errorf("Expected response id %d, got %v", stored[0].ID, data["id"])
+	}
+}
+
+func TestTokenBalanceHandlers(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	var stored []models.TokenBalanceRecord
+	if status := serve(t, h.StoreTokenBalancesHandler, "/api/eth/store-token-balances?address="+testAddress, &stored); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(stored) != len(CommonTokens) {
+		t.Fatalf("Expected %d token balances, got %d", len(CommonTokens), len(stored))
+	}
+
+	latest, err := repo.LatestTokenBalances(context.Background(), testAddress)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(latest) != len(CommonTokens) {
+		t.Fatalf("Expected %d stored token balances, got %d", len(CommonTokens), len(latest))
+	}
+
+	link := CommonTokens["LINK"].Address
+	var records []models.TokenBalanceRecord
+	if status := serve(t, h.GetTokenBalancesHandler, "/api/eth/get-token-balances?token_address="+link, &records); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Expected one LINK record, got %+v", records)
+	}
+	if r := records[0]; r.PriceSource != "binance:LINKUSDT" || r.ValueUSD == nil || *r.ValueUSD != 150 {
+		t.Errorf("Expected LINK worth 150 USD from LINKUSDT, got %+v", r)
+	}
+	if r := records[0]; r.BalanceETH != "10.000000000000000000" || r.Decimals != 18 || r.BlockNumber == nil || *r.BlockNumber != 1234567 {
+		t.Errorf("Expected 10 LINK read at block 1234567, got %+v", r)
+	}
+
+	// Stablecoins are valued at their peg
+	var live []models.TokenBalanceRecord
+	if status := serve(t, h.GetCommonTokenBalancesHandler, "/api/eth/token-balances?address="+testAddress, &live); status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	for _, r := range live {
+		if strings.EqualFold(r.TokenAddress, CommonTokens["USDC"].Address) && (r.PriceSource != "peg:USD" || r.ValueUSD == nil || *r.ValueUSD != 10) {
+			t.Errorf("Expected USDC worth 10 USD at its peg, got %+v", r)
+		}
+	}
+
+	if status := serve(t, h.GetTokenBalancesHandler, "/api/eth/get-token-balances?token_address=nope", nil); status != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid token address, got %d", status)
+	}
+}
@@ -0,0 +1,132 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/market"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	// PriceSourceUnpriced marks token balances no USD value could be found for
+	PriceSourceUnpriced = "unpriced"
+
+	// usdPeg is the peg that needs no conversion
+	usdPeg = "USD"
+)
+
+// PriceFeed provides exchange prices, converted to USD when a pair is not USD-quoted
+type PriceFeed interface {
+	GetCurrentPrice(ctx context.Context, symbol string) (*market.PriceData, error)
+	ConvertToUSD(ctx context.Context, symbol string, price float64, date *time.Time) (float64, error)
+}
+
+// FiatRates provides fiat exchange rates against the US dollar for non-USD pegs
+type FiatRates interface {
+	Rate(ctx context.Context, currency string, date time.Time) (models.FXRate, error)
+}
+
+// TokenQuote is the USD price of one token unit and where it came from
+type TokenQuote struct {
+	PriceUSD float64 `json:"price_usd"`
+	Source   string  `json:"source"` // e.g. peg:USD or binance:LINKUSDT
+}
+
+// TokenPricer prices ERC20 tokens in USD through the token registry
+type TokenPricer struct {
+	registry *TokenRegistry
+	feed     PriceFeed
+	rates    FiatRates
+}
+
+// NewTokenPricer creates a token pricer. Rates may be nil, which leaves tokens pegged to
+// currencies other than USD unpriced.
+func NewTokenPricer(registry *TokenRegistry, feed PriceFeed, rates FiatRates) *TokenPricer {
+	return &TokenPricer{
+		registry: registry,
+		feed:     feed,
+		rates:    rates,
+	}
+}
+
+// Registry returns the token mappings the pricer resolves tokens with
+func (p *TokenPricer) Registry() *TokenRegistry {
+	return p.registry
+}
+
+// Quote returns the current USD price of one unit of a token
+func (p *TokenPricer) Quote(ctx context.Context, tokenAddress string) (TokenQuote, error) {
+	mapping, ok := p.registry.Resolve(tokenAddress)
+	if !ok {
+		return TokenQuote{}, fmt.Errorf("%w: %s", ErrUnmappedToken, tokenAddress)
+	}
+
+	if mapping.Peg != "" {
+		quote := TokenQuote{PriceUSD: 1, Source: "peg:" + mapping.Peg}
+		if mapping.Peg == usdPeg {
+			return quote, nil
+		}
+		if p.rates == nil {
+			return TokenQuote{}, fmt.Errorf("%w: no exchange rates for %s peg", ErrUnmappedToken, mapping.Peg)
+		}
+
+		rate, err := p.rates.Rate(ctx, mapping.Peg, time.Now())
+		if err != nil {
+			return TokenQuote{}, fmt.Errorf("failed to get %s rate: %w", mapping.Peg, err)
+		}
+		// Rates are units of currency per 1 USD
+		quote.PriceUSD = 1 / rate.Rate
+		return quote, nil
+	}
+
+	price, err := p.feed.GetCurrentPrice(ctx, mapping.Pair)
+	if err != nil {
+		return TokenQuote{}, err
+	}
+	usdPrice, err := p.feed.ConvertToUSD(ctx, mapping.Pair, price.Price, nil)
+	if err != nil {
+		return TokenQuote{}, err
+	}
+
+	return TokenQuote{PriceUSD: usdPrice, Source: "binance:" + mapping.Pair}, nil
+}
+
+// ValueRecord sets the USD value and price source of a token balance record. Tokens that
+// cannot be priced keep a nil value and are marked PriceSourceUnpriced.
+func (p *TokenPricer) ValueRecord(ctx context.Context, record *models.TokenBalanceRecord) {
+	record.ValueUSD = nil
+	record.PriceSource = PriceSourceUnpriced
+
+	balance, err := strconv.ParseFloat(record.BalanceETH, 64)
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("token", record.TokenAddress).
+			Msg("Failed to parse token balance for valuation")
+		return
+	}
+
+	quote, err := p.Quote(ctx, record.TokenAddress)
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("token", record.TokenAddress).
+			Msg("Failed to price token")
+		return
+	}
+
+	value := balance * quote.PriceUSD
+	record.ValueUSD = &value
+	record.PriceSource = quote.Source
+}
+
+// ValueRecords values each record in place
+func (p *TokenPricer) ValueRecords(ctx context.Context, records []models.TokenBalanceRecord) {
+	for i := range records {
+		p.ValueRecord(ctx, &records[i])
+	}
+}
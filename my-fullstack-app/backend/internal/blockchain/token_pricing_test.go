@@ -0,0 +1,152 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/market"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// fakeFeed serves fixed pair prices; pairs quoted in BTC are converted at btcUSD
+type fakeFeed struct {
+	prices map[string]float64
+	btcUSD float64
+}
+
+func (f fakeFeed) GetCurrentPrice(ctx context.Context, symbol string) (*market.PriceData, error) {
+	price, ok := f.prices[symbol]
+	if !ok {
+		return nil, errors.New("invalid symbol")
+	}
+	return &market.PriceData{Symbol: symbol, Price: price}, nil
+}
+
+func (f fakeFeed) ConvertToUSD(ctx context.Context, symbol string, price float64, date *time.Time) (float64, error) {
+	if strings.HasSuffix(symbol, "BTC") {
+		return price * f.btcUSD, nil
+	}
+	return price, nil
+}
+
+// fakeRates serves fixed fiat rates per USD
+type fakeRates map[string]float64
+
+func (f fakeRates) Rate(ctx context.Context, currency string, date time.Time) (models.FXRate, error) {
+	return models.FXRate{Currency: currency, Rate: f[currency]}, nil
+}
+
+func TestTokenRegistry(t *testing.T) {
+	registry, err := NewTokenRegistry(DefaultTokenMappings())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every common token is mapped, and addresses match regardless of case
+	for symbol, token := range CommonTokens {
+		m, ok := registry.Resolve(strings.ToLower(token.Address))
+		if !ok || m.Symbol != symbol {
+			t.Errorf("Expected %s to be mapped, got %+v", symbol, m)
+		}
+	}
+	if m, _ := registry.Resolve(CommonTokens["LINK"].Address); m.Pair != "LINKUSDT" {
+		t.Errorf("Expected LINK to be priced from LINKUSDT, got %+v", m)
+	}
+	if m, _ := registry.Resolve(CommonTokens["DAI"].Address); m.Peg != "USD" {
+		t.Errorf("Expected DAI to be pegged to USD, got %+v", m)
+	}
+
+	invalid := []TokenMapping{
+		{Address: "0x123", Symbol: "BAD", Pair: "BADUSDT"},
+		{Address: CommonTokens["LINK"].Address, Symbol: "LINK"},
+		{Address: CommonTokens["LINK"].Address, Symbol: "LINK", Pair: "LINKUSDT", Peg: "USD"},
+	}
+	for _, m := range invalid {
+		if err := registry.Set(m); err == nil {
+			t.Errorf("Expected %+v to be rejected", m)
+		}
+	}
+}
+
+func TestLoadTokenMappings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	content := `[
+		{"address": "0x514910771AF9Ca656af840dff83E8264EcF986CA", "symbol": "LINK", "pair": "linkbtc"},
+		{"address": "0x1aBaEA1f7C830bD89Acc67eC4af516284b1bC33c", "symbol": "EURC", "peg": "eur"}
+	]`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write mappings: %v", err)
+	}
+
+	t.Setenv("TOKEN_MAPPINGS_FILE", path)
+	registry, err := NewTokenRegistryFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// File mappings override the defaults and add new tokens
+	if m, _ := registry.Resolve(CommonTokens["LINK"].Address); m.Pair != "LINKBTC" {
+		t.Errorf("Expected the file to override LINK, got %+v", m)
+	}
+	if len(registry.Mappings()) != len(CommonTokens)+1 {
+		t.Errorf("Expected %d mappings, got %d", len(CommonTokens)+1, len(registry.Mappings()))
+	}
+}
+
+func TestTokenPricer(t *testing.T) {
+	eurc := TokenMapping{Address: "0x1aBaEA1f7C830bD89Acc67eC4af516284b1bC33c", Symbol: "EURC", Peg: "EUR"}
+	uni := TokenMapping{Address: "0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984", Symbol: "UNI", Pair: "UNIBTC"}
+	registry, err := NewTokenRegistry(append(DefaultTokenMappings(), eurc, uni))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	feed := fakeFeed{prices: map[string]float64{"LINKUSDT": 15, "UNIBTC": 0.0001}, btcUSD: 60000}
+	pricer := NewTokenPricer(registry, feed, fakeRates{"EUR": 0.8})
+
+	testCases := []struct {
+		name       string
+		token      string
+		balance    string
+		wantValue  float64
+		wantSource string
+	}{
+		{name: "Pair quoted in USDT", token: CommonTokens["LINK"].Address, balance: "10", wantValue: 150, wantSource: "binance:LINKUSDT"},
+		{name: "Pair converted through BTC", token: uni.Address, balance: "2", wantValue: 12, wantSource: "binance:UNIBTC"},
+		{name: "USD stablecoin", token: CommonTokens["USDC"].Address, balance: "250.5", wantValue: 250.5, wantSource: "peg:USD"},
+		{name: "Stablecoin with a fiat peg", token: eurc.Address, balance: "8", wantValue: 10, wantSource: "peg:EUR"},
+		{name: "Unmapped token", token: "0x0000000000000000000000000000000000000001", balance: "1", wantSource: PriceSourceUnpriced},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := models.TokenBalanceRecord{TokenAddress: tc.token, BalanceETH: tc.balance}
+			pricer.ValueRecord(context.Background(), &record)
+
+			if record.PriceSource != tc.wantSource {
+				t.Errorf("Expected source %s, got %s", tc.wantSource, record.PriceSource)
+			}
+			if tc.wantSource == PriceSourceUnpriced {
+				if record.ValueUSD != nil {
+					t.Errorf("Expected no value, got %f", *record.ValueUSD)
+				}
+				return
+			}
+			if record.ValueUSD == nil || math.Abs(*record.ValueUSD-tc.wantValue) > 1e-9 {
+				t.Errorf("Expected value %f, got %v", tc.wantValue, record.ValueUSD)
+			}
+		})
+	}
+
+	// Without exchange rates only USD pegs can be priced
+	pricer = NewTokenPricer(registry, feed, nil)
+	if _, err := pricer.Quote(context.Background(), eurc.Address); !errors.Is(err, ErrUnmappedToken) {
+		t.Errorf("Expected %v, got %v", ErrUnmappedToken, err)
+	}
+}
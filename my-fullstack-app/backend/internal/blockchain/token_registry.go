@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"my-fullstack-app/backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TokenMapping bridges an ERC20 contract to the market it is priced from
+type TokenMapping = models.TokenMapping
+
+// ValidateTokenMapping normalizes a mapping and checks that it can be priced
+func ValidateTokenMapping(m *TokenMapping) error {
+	if !common.IsHexAddress(m.Address) {
+		return fmt.Errorf("%w: %q", ErrInvalidTokenAddress, m.Address)
+	}
+	m.Pair = strings.ToUpper(strings.TrimSpace(m.Pair))
+	m.Peg = strings.ToUpper(strings.TrimSpace(m.Peg))
+	if (m.Pair == "") == (m.Peg == "") {
+		return fmt.Errorf("%w: %s needs either a pair or a peg", ErrInvalidTokenMapping, m.Address)
+	}
+	return nil
+}
+
+// DefaultTokenMappings maps CommonTokens: stablecoins to their USD peg, others to their USDT pair
+func DefaultTokenMappings() []TokenMapping {
+	pegs := map[string]string{"USDT": "USD", "USDC": "USD", "DAI": "USD"}
+
+	mappings := make([]TokenMapping, 0, len(CommonTokens))
+	for symbol, token := range CommonTokens {
+		mapping := TokenMapping{Address: token.Address, Symbol: symbol}
+		if peg, ok := pegs[symbol]; ok {
+			mapping.Peg = peg
+		} else {
+			mapping.Pair = symbol + "USDT"
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
+// LoadTokenMappings reads a JSON array of mappings from a file
+func LoadTokenMappings(path string) ([]TokenMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token mappings: %w", err)
+	}
+
+	var mappings []TokenMapping
+	if err := json.Unmarshal(content, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse token mappings %s: %w", path, err)
+	}
+	return mappings, nil
+}
+
+// TokenRegistry resolves token contracts to their mappings
+type TokenRegistry struct {
+	mu        sync.RWMutex
+	byAddress map[string]TokenMapping
+}
+
+// NewTokenRegistry creates a registry; later mappings of the same address replace earlier ones
+func NewTokenRegistry(mappings []TokenMapping) (*TokenRegistry, error) {
+	r := &TokenRegistry{byAddress: make(map[string]TokenMapping, len(mappings))}
+	for _, m := range mappings {
+		if err := r.Set(m); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// NewTokenRegistryFromEnv creates a registry seeded with DefaultTokenMappings, extended and
+// overridden by the file named in TOKEN_MAPPINGS_FILE if set
+func NewTokenRegistryFromEnv() (*TokenRegistry, error) {
+	mappings := DefaultTokenMappings()
+	if path := os.Getenv("TOKEN_MAPPINGS_FILE"); path != "" {
+		extra, err := LoadTokenMappings(path)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, extra...)
+	}
+	return NewTokenRegistry(mappings)
+}
+
+// Set adds or replaces the mapping of a token
+func (r *TokenRegistry) Set(m TokenMapping) error {
+	if err := ValidateTokenMapping(&m); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byAddress[strings.ToLower(m.Address)] = m
+	return nil
+}
+
+// Resolve returns the mapping of a token contract address, matched case-insensitively
+func (r *TokenRegistry) Resolve(address string) (TokenMapping, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.byAddress[strings.ToLower(address)]
+	return m, ok
+}
+
+// Mappings lists all mappings ordered by symbol
+func (r *TokenRegistry) Mappings() []TokenMapping {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	mappings := make([]TokenMapping, 0, len(r.byAddress))
+	for _, m := range r.byAddress {
+		mappings = append(mappings, m)
+	}
+	sort.Slice(mappings, func(i, j int) bool { return mappings[i].Symbol < mappings[j].Symbol })
+	return mappings
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// AuditQuery filters audit events. Empty fields match every event.
+type AuditQuery struct {
+	Actor     string
+	Action    string
+	Target    string
+	RequestID string
+	From      time.Time // Inclusive; zero for no lower bound
+	To        time.Time // Exclusive; zero for no upper bound
+	BeforeID  int64     // Only events older than this ID, for the next page; zero for the first
+	Limit     int
+}
+
+type auditCallerKey struct{}
+
+// WithAudit makes the writes made with ctx record themselves in the audit log, attributed to
+// the caller: the actor, request ID and source IP of the event. Stores that take a context
+// write their event in the transaction of the change, with the state of the target before
+// and after it read in that transaction, so a write is stored only if its event is. A store
+// that changes nothing, such as storing a block again, records no event.
+func WithAudit(ctx context.Context, caller models.AuditEvent) context.Context {
+	return context.WithValue(ctx, auditCallerKey{}, caller)
+}
+
+// auditCaller returns the caller the writes made with ctx are attributed to, if they are audited
+func auditCaller(ctx context.Context) (models.AuditEvent, bool) {
+	caller, ok := ctx.Value(auditCallerKey{}).(models.AuditEvent)
+	return caller, ok
+}
+
+// RecordAudit appends a write made with ctx to the audit log, for writes that span several
+// transactions such as an import. It does nothing unless ctx comes from WithAudit. Before
+// and after are marshalled to JSON and may be nil.
+func RecordAudit(ctx context.Context, db *sql.DB, action, target string, before, after interface{}) error {
+	return recordAudit(ctx, db, action, target, before, after)
+}
+
+// recordAudit appends a write made with ctx to the audit log on q, which is the transaction
+// of the write when there is one
+func recordAudit(ctx context.Context, q queryExecer, action, target string, before, after interface{}) error {
+	event, ok := auditCaller(ctx)
+	if !ok {
+		return nil
+	}
+	event.OccurredAt = time.Time{}
+	event.Action = action
+	event.Target = target
+
+	var err error
+	if event.Before, err = auditPayload(before); err != nil {
+		return err
+	}
+	if event.After, err = auditPayload(after); err != nil {
+		return err
+	}
+	_, err = recordAuditEvent(ctx, q, event)
+	return err
+}
+
+func auditPayload(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+	content, err := json.Marshal(state)
+	if err != nil || string(content) == "null" || string(content) == "[]" {
+		return nil, err
+	}
+	return content, nil
+}
+
+// RecordAuditEvent appends an event to the audit log and returns its ID. The target is
+// stored lowercase so addresses match however they were written.
+func RecordAuditEvent(db *sql.DB, event models.AuditEvent) (int64, error) {
+	return recordAuditEvent(context.Background(), db, event)
+}
+
+func recordAuditEvent(ctx context.Context, q queryExecer, event models.AuditEvent) (int64, error) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	var id int64
+	err := q.QueryRowContext(ctx, `
+        INSERT INTO audit_events (occurred_at, actor, action, target, request_id, source_ip, before, after)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `, event.OccurredAt.UTC(), event.Actor, event.Action, strings.ToLower(event.Target), event.RequestID,
+		event.SourceIP, nullJSON(event.Before), nullJSON(event.After)).Scan(&id)
+	if err != nil {
+		log.Printf("Error recording %s audit event: %v", event.Action, err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetAuditEvents returns up to query.Limit events matching the query, newest first
+func GetAuditEvents(db *sql.DB, query AuditQuery) ([]models.AuditEvent, error) {
+	dialect := DialectOf(db)
+
+	rows, err := db.Query(`
+        SELECT id, occurred_at, actor, action, target, request_id, source_ip, before, after
+        FROM audit_events
+        WHERE ($1 = '' OR actor = $1)
+          AND ($2 = '' OR action = $2)
+          AND ($3 = '' OR target = $3)
+          AND ($4 = '' OR request_id = $4)
+          AND (`+dialect.timestampParam(5)+` IS NULL OR occurred_at >= $5)
+          AND (`+dialect.timestampParam(6)+` IS NULL OR occurred_at < $6)
+          AND ($7 = 0 OR id < $7)
+        ORDER BY id DESC
+        LIMIT $8
+    `, query.Actor, query.Action, strings.ToLower(query.Target), query.RequestID,
+		nullTime(query.From), nullTime(query.To), query.BeforeID, query.Limit)
+	if err != nil {
+		log.Printf("Error retrieving audit events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var before, after []byte
+		if err := rows.Scan(&event.ID, &event.OccurredAt, &event.Actor, &event.Action, &event.Target,
+			&event.RequestID, &event.SourceIP, &before, &after); err != nil {
+			return nil, err
+		}
+		event.OccurredAt = event.OccurredAt.UTC()
+		if before != nil {
+			event.Before = before
+		}
+		if after != nil {
+			event.After = after
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// nullJSON stores an empty JSON document as NULL
+func nullJSON(content []byte) sql.NullString {
+	return sql.NullString{String: string(content), Valid: len(content) > 0}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// StoreCEXBalanceSnapshot inserts all asset balances of one exchange snapshot in a single
+// transaction. The balances are of one exchange account. With a ctx from WithAudit the
+// snapshot is recorded in the audit log in the same transaction, after the account's
+// previous snapshot.
+func StoreCEXBalanceSnapshot(ctx context.Context, db *sql.DB, balances []models.CEXBalanceSnapshot) error {
+	if len(balances) == 0 {
+		return nil
+	}
+	exchange, account := balances[0].Exchange, balances[0].Account
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, audited := auditCaller(ctx)
+	var before []models.CEXBalanceSnapshot
+	if audited {
+		if before, err = latestCEXBalances(ctx, tx, exchange, account); err != nil {
+			return err
+		}
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO cex_balance_snapshots (exchange, account, asset, free, locked, taken_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (exchange, account, asset, taken_at) DO NOTHING
+    `)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, b := range balances {
+		_, err := stmt.ExecContext(ctx, b.Exchange, b.Account, b.Asset, b.Free, b.Locked, b.TakenAt)
+		if err != nil {
+			log.Printf("Error storing %s balance snapshot of %s: %v", b.Exchange, b.Asset, err)
+			return err
+		}
+	}
+
+	if audited {
+		after, err := latestCEXBalances(ctx, tx, exchange, account)
+		if err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, models.AuditAccountBalancesStored, exchange+":"+account, before, after); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetLatestCEXBalances retrieves the most recent snapshot of an exchange account
+func GetLatestCEXBalances(db *sql.DB, exchange, account string) ([]models.CEXBalanceSnapshot, error) {
+	return latestCEXBalances(context.Background(), db, exchange, account)
+}
+
+func latestCEXBalances(ctx context.Context, q queryExecer, exchange, account string) ([]models.CEXBalanceSnapshot, error) {
+	query := `
+        SELECT id, exchange, account, asset, free, locked, taken_at
+        FROM cex_balance_snapshots
+        WHERE exchange = $1 AND account = $2
+          AND taken_at = (
+              SELECT MAX(taken_at) FROM cex_balance_snapshots
+              WHERE exchange = $1 AND account = $2
+          )
+        ORDER BY asset
+    `
+
+	rows, err := q.QueryContext(ctx, query, exchange, account)
+	if err != nil {
+		log.Printf("Error retrieving %s balance snapshot: %v", exchange, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.CEXBalanceSnapshot
+	for rows.Next() {
+		var b models.CEXBalanceSnapshot
+		if err := rows.Scan(&b.ID, &b.Exchange, &b.Account, &b.Asset, &b.Free, &b.Locked, &b.TakenAt); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+
+	return balances, rows.Err()
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaCheckInterval is the time between measurements of the replicas' lag
+const replicaCheckInterval = 5 * time.Second
+
+// replicaCheckTimeout bounds a lag check
+const replicaCheckTimeout = time.Second
+
+// replicaCheckExpiry is how long a measured lag is trusted. A replica whose lag has not been
+// measured for longer, such as while checks are stuck, serves no reads.
+const replicaCheckExpiry = 3 * replicaCheckInterval
+
+// Cluster is the primary connection pool and the pools of any read replicas. Writes go to
+// the primary; reads that tolerate slightly stale data go to a replica that is reachable and
+// within the allowed lag, or to the primary when none is.
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	maxLag   time.Duration
+
+	next         atomic.Uint64 // Round-robin position over the replicas
+	primaryReads atomic.Uint64
+
+	now         func() time.Time
+	walPosition func(ctx context.Context, primary *sql.DB) (string, error)
+	measureLag  func(ctx context.Context, replica *sql.DB, primaryLSN string) (time.Duration, error)
+}
+
+type replica struct {
+	name  string
+	db    *sql.DB
+	reads atomic.Uint64
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lag       time.Duration
+	err       error
+}
+
+// PoolStats describes one connection pool of a cluster, for the health check
+type PoolStats struct {
+	Name               string   `json:"name"`
+	Role               string   `json:"role"` // primary or replica
+	OpenConnections    int      `json:"open_connections"`
+	InUse              int      `json:"in_use"`
+	Idle               int      `json:"idle"`
+	MaxOpenConnections int      `json:"max_open_connections"`
+	WaitCount          int64    `json:"wait_count"`
+	WaitDurationMs     int64    `json:"wait_duration_ms"`
+	Reads              uint64   `json:"reads"`                 // Reads routed to this pool
+	LagSeconds         *float64 `json:"lag_seconds,omitempty"` // Replicas only
+	Usable             bool     `json:"usable"`                // Whether the pool currently serves reads
+	Error              string   `json:"error,omitempty"`
+}
+
+// OpenCluster opens the primary pool and a pool per replica in cfg.ReplicaURLs, all with
+// the pool limits of cfg. Like Open, it does not connect. Replicas serve no reads until Run
+// has measured their lag.
+func OpenCluster(cfg Config) (*Cluster, error) {
+	if cfg.Driver == SQLite && len(cfg.ReplicaURLs) > 0 {
+		return nil, fmt.Errorf("read replicas need the postgres driver")
+	}
+
+	primary, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas []*sql.DB
+	for i, url := range cfg.ReplicaURLs {
+		replicaCfg := cfg
+		replicaCfg.URL = url
+		db, err := Open(replicaCfg)
+		if err != nil {
+			primary.Close()
+			for _, r := range replicas {
+				r.Close()
+			}
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		replicas = append(replicas, db)
+	}
+
+	cluster := NewCluster(primary, replicas...)
+	cluster.maxLag = cfg.MaxReplicaLag
+	return cluster, nil
+}
+
+// NewCluster creates a cluster from open pools. Replicas are named replica1, replica2 and
+// so on in order, and may lag by up to DefaultConfig().MaxReplicaLag.
+func NewCluster(primary *sql.DB, replicas ...*sql.DB) *Cluster {
+	c := &Cluster{
+		primary:     primary,
+		maxLag:      DefaultConfig().MaxReplicaLag,
+		now:         time.Now,
+		walPosition: walPosition,
+		measureLag:  replicationLag,
+	}
+	for i, db := range replicas {
+		c.replicas = append(c.replicas, &replica{name: "replica" + strconv.Itoa(i+1), db: db})
+	}
+	return c
+}
+
+// Primary returns the pool of the primary, for writes and reads that must see them
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// Reader returns the pool to run a read-only query on: the next replica in turn that is
+// reachable and within the allowed lag, or the primary
+func (c *Cluster) Reader(ctx context.Context) *sql.DB {
+	if n := len(c.replicas); n > 0 {
+		start := int(c.next.Add(1) % uint64(n))
+		for i := 0; i < n; i++ {
+			r := c.replicas[(start+i)%n]
+			if c.usable(r) {
+				r.reads.Add(1)
+				return r.db
+			}
+		}
+	}
+
+	c.primaryReads.Add(1)
+	return c.primary
+}
+
+// usable reports whether a replica may serve reads by its last measured lag
+func (c *Cluster) usable(r *replica) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return !r.checkedAt.IsZero() && c.now().Sub(r.checkedAt) < replicaCheckExpiry &&
+		r.err == nil && r.lag <= c.maxLag
+}
+
+// Run measures the lag of every replica every replicaCheckInterval until ctx is cancelled.
+// Reads only use the measurements, so a slow replica never delays them.
+func (c *Cluster) Run(ctx context.Context) {
+	if len(c.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(replicaCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		c.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh measures the lag of every replica against the primary's current WAL position
+func (c *Cluster) refresh(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+	primaryLSN, primaryErr := c.walPosition(checkCtx, c.primary)
+	cancel()
+	if primaryErr != nil {
+		primaryErr = fmt.Errorf("could not read the primary's WAL position: %w", primaryErr)
+	}
+
+	for _, r := range c.replicas {
+		lag, err := time.Duration(0), primaryErr
+		if err == nil {
+			checkCtx, cancel := context.WithTimeout(ctx, replicaCheckTimeout)
+			lag, err = c.measureLag(checkCtx, r.db, primaryLSN)
+			cancel()
+		}
+
+		r.mu.Lock()
+		r.lag, r.err = lag, err
+		r.checkedAt = c.now()
+		r.mu.Unlock()
+	}
+}
+
+// Stats describes every pool, primary first, with the last measured lag of each replica
+func (c *Cluster) Stats(ctx context.Context) []PoolStats {
+	stats := []PoolStats{poolStats("primary", "primary", c.primary, c.primaryReads.Load())}
+	stats[0].Usable = true
+
+	for _, r := range c.replicas {
+		s := poolStats(r.name, "replica", r.db, r.reads.Load())
+		s.Usable = c.usable(r)
+
+		r.mu.Lock()
+		if r.checkedAt.IsZero() {
+			s.Error = "lag not measured yet"
+		} else if r.err != nil {
+			s.Error = r.err.Error()
+		} else {
+			lag := r.lag.Seconds()
+			s.LagSeconds = &lag
+		}
+		r.mu.Unlock()
+
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Close closes every pool
+func (c *Cluster) Close() error {
+	errs := []error{c.primary.Close()}
+	for _, r := range c.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func poolStats(name, role string, db *sql.DB, reads uint64) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		Name:               name,
+		Role:               role,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		MaxOpenConnections: s.MaxOpenConnections,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+		Reads:              reads,
+	}
+}
+
+// walPosition returns the current WAL write position of the primary
+func walPosition(ctx context.Context, primary *sql.DB) (string, error) {
+	var lsn string
+	err := primary.QueryRowContext(ctx, `SELECT pg_current_wal_lsn()::TEXT`).Scan(&lsn)
+	return lsn, err
+}
+
+// replicationLag returns how far a replica's replayed data is behind the primary, given the
+// primary's WAL position. A replica that has replayed up to that position is current, even
+// if the primary has been idle since. Otherwise the lag is the age of the last transaction it
+// replayed, which keeps growing while the replica is disconnected from the primary. A server
+// that is not in recovery has no lag.
+func replicationLag(ctx context.Context, replica *sql.DB, primaryLSN string) (time.Duration, error) {
+	var seconds sql.NullFloat64
+	err := replica.QueryRowContext(ctx, `
+        SELECT CASE
+            WHEN NOT pg_is_in_recovery() OR pg_last_wal_replay_lsn() >= $1::pg_lsn THEN 0
+            ELSE EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp())
+        END
+    `, primaryLSN).Scan(&seconds)
+	if err != nil {
+		return 0, err
+	}
+	if !seconds.Valid {
+		return 0, errors.New("replica has not replayed any transaction from the primary")
+	}
+	return time.Duration(seconds.Float64 * float64(time.Second)), nil
+}
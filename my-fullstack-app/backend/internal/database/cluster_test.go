@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func openUnreachable(t *testing.T) *sql.DB {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 1
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestClusterReader(t *testing.T) {
+	ctx := context.Background()
+	primary, fresh, behind := openUnreachable(t), openUnreachable(t), openUnreachable(t)
+
+	lags := map[*sql.DB]time.Duration{fresh: time.Second, behind: time.Minute}
+	var errs map[*sql.DB]error
+	var primaryErr error
+	now := time.Now()
+
+	cluster := NewCluster(primary, fresh, behind)
+	cluster.now = func() time.Time { return now }
+	cluster.walPosition = func(ctx context.Context, db *sql.DB) (string, error) {
+		return "0/3000000", primaryErr
+	}
+	cluster.measureLag = func(ctx context.Context, db *sql.DB, primaryLSN string) (time.Duration, error) {
+		if primaryLSN != "0/3000000" {
+			t.Errorf("Expected the lag measured against the primary's position, got %q", primaryLSN)
+		}
+		return lags[db], errs[db]
+	}
+
+	// Replicas serve no reads until their lag has been measured
+	if cluster.Reader(ctx) != primary {
+		t.Errorf("Expected the primary before the replicas are measured")
+	}
+
+	// Only the replica within the allowed lag serves reads
+	cluster.refresh(ctx)
+	for i := 0; i < 4; i++ {
+		if cluster.Reader(ctx) != fresh {
+			t.Fatalf("Expected read %d on the replica within the allowed lag", i)
+		}
+	}
+
+	// A measurement that is not refreshed in time is not trusted
+	now = now.Add(replicaCheckExpiry)
+	if cluster.Reader(ctx) != primary {
+		t.Errorf("Expected the primary once the measurements expired")
+	}
+
+	// Without a usable replica, reads fall back to the primary
+	errs = map[*sql.DB]error{fresh: errors.New("connection refused")}
+	cluster.refresh(ctx)
+	if cluster.Reader(ctx) != primary {
+		t.Errorf("Expected the primary when no replica is usable")
+	}
+
+	stats := cluster.Stats(ctx)
+	if len(stats) != 3 || stats[0].Role != "primary" || stats[0].Reads != 3 {
+		t.Fatalf("Expected the primary first with its fallback reads, got %+v", stats)
+	}
+	if stats[1].Name != "replica1" || stats[1].Usable || stats[1].Reads != 4 || stats[1].Error == "" {
+		t.Errorf("Expected replica1 unusable after its error, got %+v", stats[1])
+	}
+	if stats[2].Usable || stats[2].LagSeconds == nil || *stats[2].LagSeconds != 60 {
+		t.Errorf("Expected replica2 unusable with its lag, got %+v", stats[2])
+	}
+
+	// Without the primary's position no replica can be shown to be current
+	errs = nil
+	primaryErr = errors.New("connection refused")
+	cluster.refresh(ctx)
+	if cluster.Reader(ctx) != primary {
+		t.Errorf("Expected the primary when its WAL position is unknown")
+	}
+}
+
+func TestClusterWithoutReplicas(t *testing.T) {
+	primary := openUnreachable(t)
+	cluster := NewCluster(primary)
+
+	if cluster.Reader(context.Background()) != primary || cluster.Primary() != primary {
+		t.Errorf("Expected every query on the primary")
+	}
+	if stats := cluster.Stats(context.Background()); len(stats) != 1 || !stats[0].Usable {
+		t.Errorf("Expected only the primary, got %+v", stats)
+	}
+}
@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes how to reach the database and how large the connection pool may grow
+type Config struct {
+	Driver Dialect // DB_DRIVER; postgres, or sqlite for a local file database
+	Path   string  // DB_PATH; the SQLite database file
+
+	URL      string // DATABASE_URL; when set it replaces the individual connection settings
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ReplicaURLs are connection strings of read replicas, each pooled with the same limits
+	ReplicaURLs []string
+	// MaxReplicaLag is how far behind the primary a replica may be and still serve reads
+	MaxReplicaLag time.Duration
+}
+
+// DefaultConfig returns the settings used for anything not set in the environment
+func DefaultConfig() Config {
+	return Config{
+		Driver:          Postgres,
+		Path:            "tracker.db",
+		Host:            "db",
+		Port:            5432,
+		User:            "app",
+		Name:            "appdb",
+		SSLMode:         "disable",
+		MaxOpenConns:    25,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		MaxReplicaLag:   10 * time.Second,
+	}
+}
+
+// ConfigFromEnv builds the configuration from DATABASE_URL or DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD, DB_NAME and DB_SSLMODE, with pool limits from DB_MAX_OPEN_CONNS,
+// DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME and DB_CONN_MAX_IDLE_TIME. Read replicas are
+// listed comma-separated in DB_REPLICA_URLS, with their allowed lag in DB_MAX_REPLICA_LAG.
+// DB_DRIVER=sqlite uses the SQLite file DB_PATH instead.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	driver, err := ParseDialect(os.Getenv("DB_DRIVER"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Driver = driver
+	setString(&cfg.Path, "DB_PATH")
+
+	cfg.URL = os.Getenv("DATABASE_URL")
+	setString(&cfg.Host, "DB_HOST")
+	setString(&cfg.User, "DB_USER")
+	setString(&cfg.Password, "DB_PASSWORD")
+	setString(&cfg.Name, "DB_NAME")
+	setString(&cfg.SSLMode, "DB_SSLMODE")
+
+	for _, url := range strings.Split(os.Getenv("DB_REPLICA_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			cfg.ReplicaURLs = append(cfg.ReplicaURLs, url)
+		}
+	}
+
+	for _, setting := range []struct {
+		name string
+		dest *int
+	}{
+		{"DB_PORT", &cfg.Port},
+		{"DB_MAX_OPEN_CONNS", &cfg.MaxOpenConns},
+		{"DB_MAX_IDLE_CONNS", &cfg.MaxIdleConns},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q: must be a non-negative integer", setting.name, v)
+			}
+			*setting.dest = n
+		}
+	}
+
+	for _, setting := range []struct {
+		name string
+		dest *time.Duration
+	}{
+		{"DB_CONN_MAX_LIFETIME", &cfg.ConnMaxLifetime},
+		{"DB_CONN_MAX_IDLE_TIME", &cfg.ConnMaxIdleTime},
+		{"DB_MAX_REPLICA_LAG", &cfg.MaxReplicaLag},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return Config{}, fmt.Errorf("invalid %s %q: must be a duration such as 5m", setting.name, v)
+			}
+			*setting.dest = d
+		}
+	}
+
+	return cfg, nil
+}
+
+func setString(dest *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dest = v
+	}
+}
+
+// DSN returns the connection string for the database driver
+func (c Config) DSN() string {
+	if c.Driver == SQLite {
+		// Wait for the write lock rather than failing, and take it when a transaction starts
+		// so transactions that read before writing cannot deadlock
+		return "file:" + c.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)" +
+			"&_time_format=sqlite&_txlock=immediate"
+	}
+	if c.URL != "" {
+		return c.URL
+	}
+
+	parts := []string{
+		"host=" + quoteDSNValue(c.Host),
+		"port=" + strconv.Itoa(c.Port),
+		"user=" + quoteDSNValue(c.User),
+		"dbname=" + quoteDSNValue(c.Name),
+		"sslmode=" + quoteDSNValue(c.SSLMode),
+	}
+	if c.Password != "" {
+		parts = append(parts, "password="+quoteDSNValue(c.Password))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a key/value connection string value so spaces and quotes survive
+func quoteDSNValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// Open creates the connection pool shared by the whole server. Connections are made
+// lazily, so an unreachable database is reported by Ping or the first query, not here.
+func Open(cfg Config) (*sql.DB, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = Postgres
+	}
+	db, err := sql.Open(string(driver), cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// Ping checks that the database can be reached, giving up after timeout
+func Ping(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return nil
+}
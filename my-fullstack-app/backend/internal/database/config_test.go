@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "postgres.internal")
+	t.Setenv("DB_PORT", "6432")
+	t.Setenv("DB_USER", "tracker")
+	t.Setenv("DB_PASSWORD", "it's secret")
+	t.Setenv("DB_NAME", "balances")
+	t.Setenv("DB_MAX_OPEN_CONNS", "40")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+	t.Setenv("DB_REPLICA_URLS", "postgres://replica-a/appdb, postgres://replica-b/appdb")
+
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if cfg.MaxOpenConns != 40 || cfg.ConnMaxLifetime != time.Hour {
+		t.Errorf("Expected pool limits from the environment, got %+v", cfg)
+	}
+	if cfg.MaxIdleConns != DefaultConfig().MaxIdleConns {
+		t.Errorf("Expected the default idle limit, got %d", cfg.MaxIdleConns)
+	}
+	if len(cfg.ReplicaURLs) != 2 || cfg.ReplicaURLs[1] != "postgres://replica-b/appdb" || cfg.MaxReplicaLag != 10*time.Second {
+		t.Errorf("Expected 2 replicas with the default lag, got %+v", cfg)
+	}
+
+	want := `host='postgres.internal' port=6432 user='tracker' dbname='balances' sslmode='disable' password='it\'s secret'`
+	if dsn := cfg.DSN(); dsn != want {
+		t.Errorf("Expected DSN %s, got %s", want, dsn)
+	}
+
+	// A URL replaces the individual settings
+	t.Setenv("DATABASE_URL", "postgres://app:pw@db:5432/appdb?sslmode=disable")
+	cfg, err = ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.DSN() != "postgres://app:pw@db:5432/appdb?sslmode=disable" {
+		t.Errorf("Expected the URL as DSN, got %s", cfg.DSN())
+	}
+}
+
+func TestConfigFromEnvInvalid(t *testing.T) {
+	testCases := []struct {
+		name, key, value string
+	}{
+		{name: "Port not a number", key: "DB_PORT", value: "postgres"},
+		{name: "Negative pool size", key: "DB_MAX_OPEN_CONNS", value: "-1"},
+		{name: "Lifetime without unit", key: "DB_CONN_MAX_LIFETIME", value: "30"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(tc.key, tc.value)
+			if _, err := ConfigFromEnv(); err == nil {
+				t.Errorf("Expected %s=%s to be rejected", tc.key, tc.value)
+			}
+		})
+	}
+}
+
+func TestOpenDoesNotConnect(t *testing.T) {
+	// Nothing listens here; opening the pool must still succeed and leave the error to Ping
+	cfg := DefaultConfig()
+	cfg.Host = "127.0.0.1"
+	cfg.Port = 1
+
+	db, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	if db.Stats().MaxOpenConnections != cfg.MaxOpenConns {
+		t.Errorf("Expected max open connections %d, got %d", cfg.MaxOpenConns, db.Stats().MaxOpenConnections)
+	}
+}
@@ -0,0 +1,104 @@
+// Package dbtest provides fresh, migrated databases for tests.
+//
+// SQLite databases are files in the test's temporary directory. Postgres databases are a
+// schema of their own on the server in TEST_DATABASE_URL, so tests can share one server;
+// without TEST_DATABASE_URL the tests that need Postgres are skipped.
+package dbtest
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/migrations"
+)
+
+// Open opens an empty SQLite file, closed when the test ends
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	cfg := database.DefaultConfig()
+	cfg.Driver = database.SQLite
+	cfg.Path = filepath.Join(t.TempDir(), "tracker.db")
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// SQLite migrates a fresh SQLite file
+func SQLite(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db := Open(t)
+	if err := database.RunMigrations(context.Background(), db, migrations.SQLiteFS); err != nil {
+		t.Fatalf("Could not migrate: %v", err)
+	}
+	return db
+}
+
+// Postgres migrates a fresh schema on the Postgres server in TEST_DATABASE_URL and returns
+// a pool that uses it. The schema is dropped when the test ends. The test is skipped when
+// TEST_DATABASE_URL is not set.
+func Postgres(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("Set TEST_DATABASE_URL to run tests against Postgres")
+	}
+
+	cfg := database.DefaultConfig()
+	cfg.URL = dsn
+	admin, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	schema := "test_" + hex.EncodeToString(b)
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("Could not create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("Could not drop schema %s: %v", schema, err)
+		}
+	})
+
+	cfg.URL = withSearchPath(dsn, schema)
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(context.Background(), db, migrations.FS); err != nil {
+		t.Fatalf("Could not migrate: %v", err)
+	}
+	return db
+}
+
+// withSearchPath adds a search_path run-time parameter to a URL or key=value connection string
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("search_path", schema)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
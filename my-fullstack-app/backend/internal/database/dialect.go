@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Dialect is the SQL flavour of a database. Queries are written for Postgres and use
+// dialect-specific SQL only where Postgres features such as DISTINCT ON, advisory locks or
+// NUMERIC arithmetic are needed.
+type Dialect string
+
+const (
+	// Postgres is the production database
+	Postgres Dialect = "postgres"
+	// SQLite is a local file database, for running the server as a single binary
+	SQLite Dialect = "sqlite"
+)
+
+// ParseDialect parses a DB_DRIVER value. An empty value means Postgres.
+func ParseDialect(value string) (Dialect, error) {
+	switch Dialect(value) {
+	case "", Postgres:
+		return Postgres, nil
+	case SQLite:
+		return SQLite, nil
+	}
+	return "", fmt.Errorf("unknown database driver %q: use postgres or sqlite", value)
+}
+
+// DialectOf returns the dialect of an open pool
+func DialectOf(db *sql.DB) Dialect {
+	if _, ok := db.Driver().(*sqlite.Driver); ok {
+		return SQLite
+	}
+	return Postgres
+}
+
+// timestampParam returns the placeholder of a timestamp parameter that may be NULL.
+// Postgres cannot infer the type of a parameter only compared with NULL.
+func (d Dialect) timestampParam(n int) string {
+	if d == SQLite {
+		return fmt.Sprintf("$%d", n)
+	}
+	return fmt.Sprintf("$%d::TIMESTAMPTZ", n)
+}
+
+// scanTime scans a timestamp that SQLite may return as text, such as one computed by a
+// function rather than read from a DATETIME column
+type scanTime struct {
+	t *time.Time
+}
+
+// Scan implements sql.Scanner
+func (s scanTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*s.t = v
+		return nil
+	case string:
+		return s.parse(v)
+	case []byte:
+		return s.parse(string(v))
+	}
+	return fmt.Errorf("cannot scan %T into a timestamp", value)
+}
+
+func (s scanTime) parse(value string) error {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			*s.t = t
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", value)
+}
@@ -0,0 +1,8 @@
+package database
+
+import "time"
+
+// SetNow replaces the clock of a partition manager, for the tests of package database_test
+func (m *PartitionManager) SetNow(now func() time.Time) {
+	m.now = now
+}
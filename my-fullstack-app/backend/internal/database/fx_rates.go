@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// StoreFXRates inserts or updates daily exchange rates in a single transaction
+func StoreFXRates(db *sql.DB, rates []models.FXRate) error {
+	query := `
+        INSERT INTO fx_rates (currency, rate_date, rate, source, fetched_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (currency, rate_date)
+        DO UPDATE SET rate = EXCLUDED.rate, source = EXCLUDED.source, fetched_at = EXCLUDED.fetched_at
+    `
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		_, err := stmt.Exec(rate.Currency, rate.Date, rate.Rate, rate.Source, rate.FetchedAt)
+		if err != nil {
+			log.Printf("Error storing fx rate %s on %s: %v", rate.Currency, rate.Date.Format("2006-01-02"), err)
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetFXRate retrieves the most recent rate for a currency on or before a date,
+// looking back at most maxAge to cover weekends and bank holidays
+func GetFXRate(db *sql.DB, currency string, date time.Time, maxAge time.Duration) (models.FXRate, error) {
+	query := `
+        SELECT currency, rate_date, rate, source, fetched_at
+        FROM fx_rates
+        WHERE currency = $1 AND rate_date <= $2 AND rate_date >= $3
+        ORDER BY rate_date DESC
+        LIMIT 1
+    `
+
+	var rate models.FXRate
+	err := db.QueryRow(query, currency, date, date.Add(-maxAge)).Scan(
+		&rate.Currency,
+		&rate.Date,
+		&rate.Rate,
+		&rate.Source,
+		&rate.FetchedAt,
+	)
+	if err != nil {
+		return models.FXRate{}, err
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// HistoryBucket is the width of the buckets balance history is downsampled into
+type HistoryBucket string
+
+const (
+	BucketNone HistoryBucket = ""   // Every stored record
+	BucketHour HistoryBucket = "1h" // Last record of each UTC hour
+	BucketDay  HistoryBucket = "1d" // Last record of each UTC day
+	BucketWeek HistoryBucket = "1w" // Last record of each ISO week, starting Monday UTC
+)
+
+// ParseHistoryBucket parses 1h, 1d or 1w; an empty string means no downsampling
+func ParseHistoryBucket(s string) (HistoryBucket, error) {
+	switch b := HistoryBucket(s); b {
+	case BucketNone, BucketHour, BucketDay, BucketWeek:
+		return b, nil
+	}
+	return BucketNone, fmt.Errorf("invalid bucket %q: use 1h, 1d or 1w", s)
+}
+
+// Start returns the start of the bucket containing t, or t itself without bucketing
+func (b HistoryBucket) Start(t time.Time) time.Time {
+	t = t.UTC()
+	switch b {
+	case BucketHour:
+		return t.Truncate(time.Hour)
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return t
+}
+
+// startExpr is the SQL for the start of the bucket containing the timestamp column
+func (b HistoryBucket) startExpr(d Dialect, column string) string {
+	if d == SQLite {
+		// Timestamps are UTC text, and so are bucket starts
+		switch b {
+		case BucketHour:
+			return "strftime('%Y-%m-%d %H:00:00+00:00', " + column + ")"
+		case BucketDay:
+			return "strftime('%Y-%m-%d 00:00:00+00:00', " + column + ")"
+		case BucketWeek:
+			return "date(" + column + ", '-6 days', 'weekday 1') || ' 00:00:00+00:00'"
+		}
+		return column
+	}
+
+	field := map[HistoryBucket]string{BucketHour: "hour", BucketDay: "day", BucketWeek: "week"}[b]
+	if field == "" {
+		return column
+	}
+	return fmt.Sprintf("date_trunc('%s', %s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'", field, column)
+}
+
+// HistoryCursor is the position of the last point of a page; the next page starts after it
+type HistoryCursor struct {
+	Time time.Time
+	ID   int
+}
+
+// HistoryQuery selects a page of the balance history of an address
+type HistoryQuery struct {
+	Address    string
+	From       time.Time // Inclusive; zero for no lower bound
+	To         time.Time // Exclusive; zero for no upper bound
+	Bucket     HistoryBucket
+	Descending bool           // Newest first
+	After      *HistoryCursor // Nil for the first page
+	Limit      int
+}
+
+// includes reports whether a point at (t, id) belongs on the page after the cursor
+func (q HistoryQuery) includes(t time.Time, id int) bool {
+	if q.After == nil {
+		return true
+	}
+	if !t.Equal(q.After.Time) {
+		return t.After(q.After.Time) != q.Descending
+	}
+	return (id > q.After.ID) != q.Descending
+}
+
+// GetBalanceHistory retrieves a page of the balance history of an address. With a bucket,
+// each point is the last record of its bucket and is timed at the bucket start. Days whose
+// raw records were dropped by the retention policy contribute their daily rollup, which
+// stands for the last record of the day.
+func GetBalanceHistory(db *sql.DB, query HistoryQuery) ([]models.BalancePoint, error) {
+	dialect := DialectOf(db)
+
+	records := fmt.Sprintf(`
+        SELECT %s AS point_time, id, CAST(balance AS TEXT) AS balance, CAST(balance_eth AS TEXT) AS balance_eth, fetched_at
+        FROM (
+            SELECT address, id, balance, balance_eth, fetched_at
+            FROM balance_records
+            WHERE carried_from IS NULL
+            UNION ALL
+            SELECT address, close_record_id, close_balance, close_balance_eth, last_fetched_at
+            FROM balance_daily_rollups
+        ) AS all_records
+        WHERE address = $1
+          AND (%[2]s IS NULL OR fetched_at >= $2)
+          AND (%[3]s IS NULL OR fetched_at < $3)
+    `, query.Bucket.startExpr(dialect, "fetched_at"), dialect.timestampParam(2), dialect.timestampParam(3))
+
+	// Without a bucket every record is its own point; with one, keep the last of each bucket
+	pointsQuery := records
+	if query.Bucket != BucketNone {
+		pointsQuery = `
+            SELECT DISTINCT ON (point_time) *
+            FROM (` + records + `) AS records
+            ORDER BY point_time, fetched_at DESC, id DESC
+        `
+		if dialect == SQLite {
+			pointsQuery = `
+                SELECT point_time, id, balance, balance_eth, fetched_at
+                FROM (
+                    SELECT *, ROW_NUMBER() OVER (PARTITION BY point_time ORDER BY fetched_at DESC, id DESC) AS position
+                    FROM (` + records + `) AS records
+                ) AS ranked
+                WHERE position = 1
+            `
+		}
+	}
+
+	order, compare := "ASC", ">"
+	if query.Descending {
+		order, compare = "DESC", "<"
+	}
+
+	statement := fmt.Sprintf(`
+        SELECT point_time, id, balance, balance_eth, fetched_at
+        FROM (%s) AS points
+        WHERE %s IS NULL OR (point_time, id) %s ($4, $5)
+        ORDER BY point_time %[4]s, id %[4]s
+        LIMIT $6
+    `, pointsQuery, dialect.timestampParam(4), compare, order)
+
+	var after sql.NullTime
+	var afterID int
+	if query.After != nil {
+		after = sql.NullTime{Time: query.After.Time.UTC(), Valid: true}
+		afterID = query.After.ID
+	}
+
+	rows, err := db.Query(statement, query.Address, nullTime(query.From), nullTime(query.To), after, afterID, query.Limit)
+	if err != nil {
+		log.Printf("Error retrieving balance history: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []models.BalancePoint
+	for rows.Next() {
+		var p models.BalancePoint
+		if err := rows.Scan(scanTime{&p.Time}, &p.RecordID, &p.Balance, &p.BalanceETH, scanTime{&p.FetchedAt}); err != nil {
+			return nil, err
+		}
+		p.Time, p.FetchedAt = p.Time.UTC(), p.FetchedAt.UTC()
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
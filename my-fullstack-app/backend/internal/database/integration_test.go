@@ -0,0 +1,539 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/database/dbtest"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	addressA = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	addressB = "0x0000000000000000000000000000000000000001"
+)
+
+// Status and Version only read, so they leave a fresh database untouched
+func TestStatusIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t)
+
+	migrator, err := database.NewMigrator(db, fstest.MapFS{
+		"000001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"000001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if !errors.Is(err, database.ErrNoMigrationHistory) || len(statuses) != 1 || statuses[0].Applied {
+		t.Fatalf("Expected one pending migration and no history, got %+v, %v", statuses, err)
+	}
+	if _, err := migrator.Version(ctx); !errors.Is(err, database.ErrNoMigrationHistory) {
+		t.Errorf("Expected no history, got %v", err)
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'migration_history'`).Scan(&tables); err != nil || tables != 0 {
+		t.Fatalf("Expected Status not to create migration_history, got %d tables, %v", tables, err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	statuses, err = migrator.Status(ctx)
+	if err != nil || !statuses[0].Applied || statuses[0].AppliedAt == nil {
+		t.Errorf("Expected the migration applied, got %+v, %v", statuses, err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != 1 {
+		t.Errorf("Expected version 1, got %d, %v", version, err)
+	}
+}
+
+func TestFindBalanceDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Open(t)
+
+	// Like the partitioned Postgres table, without the unique constraints
+	_, err := db.Exec(`
+        CREATE TABLE balance_records (
+            id INTEGER PRIMARY KEY AUTOINCREMENT,
+            chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+            address VARCHAR(42) NOT NULL,
+            block_number BIGINT,
+            valid_to TIMESTAMP
+        );
+        INSERT INTO balance_records (address, block_number, valid_to) VALUES
+            ('0xa', 1, '2024-01-01'), ('0xa', 2, NULL), ('0xb', NULL, '2024-01-01'), ('0xb', NULL, NULL);
+    `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	found, err := database.FindBalanceDuplicates(ctx, db)
+	if err != nil || found != (database.BalanceDuplicates{}) {
+		t.Fatalf("Expected no duplicates, got %+v, %v", found, err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO balance_records (address, block_number, valid_to) VALUES ('0xa', 2, NULL), ('0xb', 3, NULL)`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	found, err = database.FindBalanceDuplicates(ctx, db)
+	if err != nil || found != (database.BalanceDuplicates{Blocks: 1, Current: 2}) {
+		t.Errorf("Expected a duplicate block and two addresses with two current records, got %+v, %v", found, err)
+	}
+}
+
+// storeOutboxEvents writes two events of addressA, then one of addressB
+func storeOutboxEvents(t *testing.T, db *sql.DB) (keyA, keyB string) {
+	t.Helper()
+
+	start := time.Now().Add(-time.Hour)
+	for i, record := range []models.BalanceRecord{
+		{Address: addressA, Balance: "1", FetchedAt: start},
+		{Address: addressA, Balance: "2", FetchedAt: start.Add(time.Minute)},
+		{Address: addressB, Balance: "5", FetchedAt: start},
+	} {
+		if _, err := database.StoreBalance(db, record, database.StoreChanges); err != nil {
+			t.Fatalf("Unexpected error storing record %d: %v", i, err)
+		}
+	}
+	return database.OutboxKey(models.ChainEthereum, addressA), database.OutboxKey(models.ChainEthereum, addressB)
+}
+
+// processOutbox publishes the claimed events successfully and returns their keys
+func processOutbox(t *testing.T, db *sql.DB, limit int, now time.Time) []string {
+	t.Helper()
+
+	var keys []string
+	_, err := database.ProcessOutbox(context.Background(), db, limit, time.Minute, now, func(event models.OutboxEvent) database.OutboxResult {
+		keys = append(keys, event.Key)
+		return database.OutboxResult{}
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return keys
+}
+
+// testOutboxLease checks that a claimed event is skipped by other relays while it is
+// published, and claimed again once the lease of a relay that died has ended
+func testOutboxLease(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	keyA, keyB := storeOutboxEvents(t, db)
+	now := time.Now()
+
+	var first, concurrent []string
+	n, err := database.ProcessOutbox(ctx, db, 1, time.Minute, now, func(event models.OutboxEvent) database.OutboxResult {
+		first = append(first, event.Key)
+		concurrent = processOutbox(t, db, 10, now)
+		return database.OutboxResult{}
+	})
+	if err != nil || n != 1 || len(first) != 1 || first[0] != keyA {
+		t.Fatalf("Expected the first event of %s, got %v, %d, %v", keyA, first, n, err)
+	}
+	if len(concurrent) != 1 || concurrent[0] != keyB {
+		t.Errorf("Expected the concurrent relay to skip the claimed key and get %s, got %v", keyB, concurrent)
+	}
+
+	// A relay that dies after claiming the second event of addressA leaves it claimed
+	if _, err := db.Exec(`UPDATE outbox SET claimed_until = $1 WHERE delivered_at IS NULL`, now.Add(time.Minute).UTC()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if keys := processOutbox(t, db, 10, now.Add(30*time.Second)); len(keys) != 0 {
+		t.Errorf("Expected nothing claimable during the lease, got %v", keys)
+	}
+	if keys := processOutbox(t, db, 10, now.Add(time.Minute)); len(keys) != 1 || keys[0] != keyA {
+		t.Errorf("Expected the second event of %s once the lease ended, got %v", keyA, keys)
+	}
+}
+
+func TestOutboxLease(t *testing.T) {
+	testOutboxLease(t, dbtest.SQLite(t))
+}
+
+func TestPostgresOutboxLease(t *testing.T) {
+	testOutboxLease(t, dbtest.Postgres(t))
+}
+
+// A repository without a relay stores balances without leaving events in the outbox
+func TestOutboxDisabled(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.SQLite(t)
+	repo := database.NewPostgresRepository(db)
+	repo.SetOutboxEnabled(false)
+
+	if _, err := repo.StoreBalance(ctx, models.BalanceRecord{Address: addressA, Balance: "1", FetchedAt: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records := []models.TokenBalanceRecord{{Address: addressA, TokenAddress: addressB, Balance: "1", Decimals: 18, FetchedAt: time.Now()}}
+	if _, err := repo.StoreTokenBalances(ctx, records); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pending, err := database.PendingOutboxEvents(db); err != nil || pending != 0 {
+		t.Errorf("Expected no outbox events, got %d, %v", pending, err)
+	}
+
+	repo.SetOutboxEnabled(true)
+	if _, err := repo.StoreBalance(ctx, models.BalanceRecord{Address: addressA, Balance: "2", FetchedAt: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pending, err := database.PendingOutboxEvents(db); err != nil || pending != 1 {
+		t.Errorf("Expected the change in the outbox, got %d, %v", pending, err)
+	}
+}
+
+// Events locked by a relay that is still claiming them are skipped rather than waited for
+func TestPostgresOutboxSkipsLockedEvents(t *testing.T) {
+	db := dbtest.Postgres(t)
+	keyA, keyB := storeOutboxEvents(t, db)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT id FROM outbox WHERE event_key = $1 FOR UPDATE`, keyA); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if keys := processOutbox(t, db, 10, time.Now()); len(keys) != 1 || keys[0] != keyB {
+		t.Errorf("Expected only the unlocked event of %s, got %v", keyB, keys)
+	}
+}
+
+// Exports read Postgres through a cursor, in batches
+func TestPostgresStreamBalances(t *testing.T) {
+	db := dbtest.Postgres(t)
+
+	const records = 2500
+	_, err := db.Exec(`
+        INSERT INTO balance_records (address, balance, block_number, fetched_at, last_seen_at)
+        SELECT $1, n, n, date_trunc('month', NOW()) + n * INTERVAL '1 second', date_trunc('month', NOW()) + n * INTERVAL '1 second'
+        FROM generate_series(1, $2) AS n
+    `, addressA, records)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var streamed []string
+	err = database.StreamBalances(context.Background(), db, func(r models.BalanceRecord) error {
+		streamed = append(streamed, r.Balance)
+		return nil
+	})
+	if err != nil || len(streamed) != records {
+		t.Fatalf("Expected %d records, got %d, %v", records, len(streamed), err)
+	}
+	if streamed[0] != "1" || streamed[1000] != "1001" || streamed[records-1] != "2500" {
+		t.Errorf("Expected the records in time order across batches, got %s, %s, %s", streamed[0], streamed[1000], streamed[records-1])
+	}
+}
+
+// Importing old records creates their partition, which retention then rolls up and drops
+func TestPostgresImportAndRetention(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.Postgres(t)
+
+	day := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	for i, balance := range []string{"1", "3", "2"} {
+		block := uint64(i + 1)
+		fetchedAt := day.Add(time.Duration(i) * 12 * time.Hour)
+		record := models.BalanceRecord{Address: addressA, Balance: balance, BlockNumber: &block, FetchedAt: fetchedAt, LastSeenAt: fetchedAt}
+		if _, err := database.ImportBalance(db, record); err != nil {
+			t.Fatalf("Unexpected error importing record %d: %v", i, err)
+		}
+	}
+	partitionExists := func(name string) bool {
+		var exists bool
+		if err := db.QueryRow(`SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return exists
+	}
+	if !partitionExists("balance_records_p202001") {
+		t.Fatal("Expected the import to create the partition of January 2020")
+	}
+
+	manager := database.NewPartitionManager(db, database.RetentionConfig{RetentionDays: 30, Interval: time.Hour})
+	manager.SetNow(func() time.Time { return time.Date(2020, 3, 15, 0, 0, 0, 0, time.UTC) })
+	if err := manager.Maintain(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partitionExists("balance_records_p202001") || !partitionExists("balance_records_p202002") {
+		t.Fatal("Expected January dropped and February created")
+	}
+
+	rows, err := db.Query(`
+        SELECT day, CAST(open_balance AS TEXT), CAST(close_balance AS TEXT), CAST(min_balance AS TEXT), CAST(max_balance AS TEXT), records
+        FROM balance_daily_rollups ORDER BY day
+    `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer rows.Close()
+	var rollups []string
+	for rows.Next() {
+		var d time.Time
+		var open, close, min, max string
+		var n int
+		if err := rows.Scan(&d, &open, &close, &min, &max, &n); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		rollups = append(rollups, fmt.Sprintf("%s %s %s %s %s %d", d.Format("2006-01-02"), open, close, min, max, n))
+	}
+	if len(rollups) != 2 || rollups[0] != "2020-01-10 1 3 1 3 2" || rollups[1] != "2020-01-11 2 2 2 2 1" {
+		t.Errorf("Expected a rollup per day, got %v", rollups)
+	}
+
+	// The current balance is carried into February rather than dropped
+	var balance string
+	var fetchedAt time.Time
+	err = db.QueryRow(`SELECT CAST(balance AS TEXT), fetched_at FROM balance_records WHERE valid_to IS NULL`).Scan(&balance, &fetchedAt)
+	if err != nil || balance != "2" || !fetchedAt.Equal(time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the current balance moved to February, got %s at %s, %v", balance, fetchedAt, err)
+	}
+	// It keeps the time it was read
+	balances, err := database.NewPostgresRepository(db).Balances(ctx, addressA, 10)
+	if err != nil || len(balances) != 1 || !balances[0].FetchedAt.Equal(day.Add(24*time.Hour)) {
+		t.Errorf("Expected the carried balance read on January 11, got %+v, %v", balances, err)
+	}
+
+	// History has each balance once, from the rollups of the dropped months and the raw records
+	block := uint64(4)
+	march := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	record := models.BalanceRecord{Address: addressA, Balance: "5", BlockNumber: &block, FetchedAt: march, LastSeenAt: march}
+	if _, err := database.ImportBalance(db, record); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"2020-01-10T12:00:00Z 3", "2020-01-11T00:00:00Z 2", "2020-03-01T00:00:00Z 5"}
+	checkHistory := func(when string) {
+		t.Helper()
+		points, err := database.GetBalanceHistory(db, database.HistoryQuery{Address: addressA, Limit: 10})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var got []string
+		for _, p := range points {
+			got = append(got, p.FetchedAt.Format(time.RFC3339)+" "+p.Balance)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Expected history %v %s, got %v", want, when, got)
+		}
+	}
+	checkHistory("after January was dropped")
+
+	// The carried record, and March with its current balance, are dropped in turn
+	manager.SetNow(func() time.Time { return time.Date(2020, 5, 15, 0, 0, 0, 0, time.UTC) })
+	if err := manager.Maintain(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if partitionExists("balance_records_p202002") || partitionExists("balance_records_p202003") {
+		t.Fatal("Expected February and March dropped")
+	}
+	checkHistory("after March was dropped")
+}
+
+func auditEvents(t *testing.T, db *sql.DB) []models.AuditEvent {
+	t.Helper()
+
+	events, err := database.GetAuditEvents(db, database.AuditQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return events
+}
+
+// A store made with an audited context records its event in the same transaction
+func TestStoreBalanceAudit(t *testing.T) {
+	db := dbtest.SQLite(t)
+	repo := database.NewPostgresRepository(db)
+	ctx := database.WithAudit(context.Background(), models.AuditEvent{Actor: models.ActorAnonymous, RequestID: "req-1", SourceIP: "203.0.113.7"})
+
+	block := uint64(100)
+	first := models.BalanceRecord{Address: addressA, Balance: "1", BlockNumber: &block, FetchedAt: time.Now().Add(-time.Minute)}
+	if _, err := repo.StoreBalance(ctx, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	next := block + 1
+	second := models.BalanceRecord{Address: addressA, Balance: "2", BlockNumber: &next, FetchedAt: time.Now()}
+	id, err := repo.StoreBalance(ctx, second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	events := auditEvents(t, db)
+	if len(events) != 2 {
+		t.Fatalf("Expected an event per store, got %+v", events)
+	}
+	var before, after models.BalanceRecord
+	if err := json.Unmarshal(events[0].Before, &before); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := json.Unmarshal(events[0].After, &after); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if events[0].Action != models.AuditBalanceStored || events[0].RequestID != "req-1" || before.Balance != "1" ||
+		after.ID != id || after.Balance != "2" {
+		t.Errorf("Expected the second store with the balance before and after it, got %+v", events[0])
+	}
+	if events[1].Before != nil {
+		t.Errorf("Expected no balance before the first store, got %s", events[1].Before)
+	}
+
+	// Storing the same block again changes nothing, so it records nothing
+	if _, err := repo.StoreBalance(ctx, second); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if events := auditEvents(t, db); len(events) != 2 {
+		t.Errorf("Expected no event for a block already stored, got %+v", events)
+	}
+
+	// Without an audited context nothing is recorded
+	third := block + 2
+	if _, err := repo.StoreBalance(context.Background(), models.BalanceRecord{Address: addressB, Balance: "5", BlockNumber: &third, FetchedAt: time.Now()}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if events := auditEvents(t, db); len(events) != 2 {
+		t.Errorf("Expected no event without a caller, got %+v", events)
+	}
+}
+
+// A write whose audit event cannot be recorded is not stored
+func TestStoreFailsWithoutAudit(t *testing.T) {
+	db := dbtest.SQLite(t)
+	repo := database.NewPostgresRepository(db)
+	ctx := database.WithAudit(context.Background(), models.AuditEvent{Actor: models.ActorAdmin})
+
+	_, err := db.Exec(`
+        CREATE TRIGGER audit_events_unavailable BEFORE INSERT ON audit_events
+        BEGIN
+            SELECT RAISE(ABORT, 'audit log unavailable');
+        END;
+    `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := repo.StoreBalance(ctx, models.BalanceRecord{Address: addressA, Balance: "1", FetchedAt: time.Now()}); err == nil {
+		t.Error("Expected the balance store to fail")
+	}
+	if balances, err := repo.Balances(ctx, addressA, 10); err != nil || len(balances) != 0 {
+		t.Errorf("Expected no balance stored, got %+v, %v", balances, err)
+	}
+
+	records := []models.TokenBalanceRecord{{Address: addressA, TokenAddress: addressB, Balance: "1", Decimals: 18, FetchedAt: time.Now()}}
+	if _, err := repo.StoreTokenBalances(ctx, records); err == nil {
+		t.Error("Expected the token balance store to fail")
+	}
+	if balances, err := repo.LatestTokenBalances(ctx, addressA); err != nil || len(balances) != 0 {
+		t.Errorf("Expected no token balance stored, got %+v, %v", balances, err)
+	}
+}
+
+func TestStoreTokenBalancesAudit(t *testing.T) {
+	db := dbtest.SQLite(t)
+	repo := database.NewPostgresRepository(db)
+	ctx := database.WithAudit(context.Background(), models.AuditEvent{Actor: models.ActorAnonymous})
+
+	now := time.Now()
+	records := []models.TokenBalanceRecord{
+		{Address: addressA, TokenAddress: addressB, Balance: "1", Decimals: 18, FetchedAt: now},
+		{Address: addressA, TokenAddress: "0x0000000000000000000000000000000000000002", Balance: "2", Decimals: 6, FetchedAt: now},
+	}
+	if _, err := repo.StoreTokenBalances(ctx, records); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	events := auditEvents(t, db)
+	var after []models.TokenBalanceRecord
+	if len(events) != 1 || events[0].Before != nil || json.Unmarshal(events[0].After, &after) != nil || len(after) != 2 {
+		t.Errorf("Expected one event with both balances after it, got %+v", events)
+	}
+
+	records[1].Address = addressB
+	if _, err := repo.StoreTokenBalances(ctx, records); err == nil {
+		t.Error("Expected balances of two holders to be rejected")
+	}
+}
+
+// testAuditAppendOnly checks that audit events cannot be changed or removed
+func testAuditAppendOnly(t *testing.T, db *sql.DB) {
+	id, err := database.RecordAuditEvent(db, models.AuditEvent{Actor: models.ActorAdmin, Action: models.AuditDataImported, Target: "balances"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := db.Exec(`UPDATE audit_events SET actor = 'someone' WHERE id = $1`, id); err == nil {
+		t.Error("Expected updating an audit event to fail")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_events`); err == nil {
+		t.Error("Expected deleting audit events to fail")
+	}
+	if database.DialectOf(db) == database.Postgres {
+		if _, err := db.Exec(`TRUNCATE audit_events`); err == nil {
+			t.Error("Expected truncating audit events to fail")
+		}
+	}
+
+	events := auditEvents(t, db)
+	if len(events) != 1 || events[0].Actor != models.ActorAdmin || events[0].Before != nil {
+		t.Errorf("Expected the event unchanged, got %+v", events)
+	}
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	testAuditAppendOnly(t, dbtest.SQLite(t))
+}
+
+// The triggers reject changes even from the owner of the table, who keeps no privilege
+// to make them
+func TestPostgresAuditEventsAppendOnly(t *testing.T) {
+	db := dbtest.Postgres(t)
+	testAuditAppendOnly(t, db)
+
+	var update, truncate bool
+	err := db.QueryRow(`SELECT has_table_privilege('audit_events', 'UPDATE'), has_table_privilege('audit_events', 'TRUNCATE')`).
+		Scan(&update, &truncate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if update || truncate {
+		t.Errorf("Expected UPDATE and TRUNCATE revoked, got %v and %v", update, truncate)
+	}
+}
+
+// Each partition rejects a second record of a block and a second current record, including
+// partitions created after the migrations
+func TestPostgresBalancePartitionsRejectDuplicates(t *testing.T) {
+	db := dbtest.Postgres(t)
+
+	months := map[string]time.Time{addressA: time.Now(), addressB: time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)}
+	for address, fetchedAt := range months {
+		block := uint64(fetchedAt.Unix())
+		record := models.BalanceRecord{Address: address, Balance: "1", BlockNumber: &block, FetchedAt: fetchedAt, LastSeenAt: fetchedAt}
+		if _, err := database.ImportBalance(db, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		_, err := db.Exec(`
+            INSERT INTO balance_records (address, balance, block_number, fetched_at, last_seen_at, valid_to)
+            VALUES ($1, 2, $2, $3, $3, $3)
+        `, address, block, fetchedAt.UTC())
+		if err == nil {
+			t.Errorf("Expected a second record of block %d in %s to be rejected", block, fetchedAt.Format("2006-01"))
+		}
+		_, err = db.Exec(`
+            INSERT INTO balance_records (address, balance, block_number, fetched_at, last_seen_at)
+            VALUES ($1, 2, $2, $3, $3)
+        `, address, block+1, fetchedAt.UTC())
+		if err == nil {
+			t.Errorf("Expected a second current record in %s to be rejected", fetchedAt.Format("2006-01"))
+		}
+	}
+}
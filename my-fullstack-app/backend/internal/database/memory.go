@@ -0,0 +1,387 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// MemoryRepository implements Repository in memory, for tests and running without Postgres.
+// It assigns IDs and orders results the same way the Postgres implementation does. It keeps
+// no audit log, so writes made with a ctx from WithAudit are not recorded.
+type MemoryRepository struct {
+	mu         sync.RWMutex
+	mode       StorageMode
+	nextID     int
+	balances   []models.BalanceRecord
+	tokens     []models.TokenBalanceRecord
+	watchlists map[string]models.Watchlist
+}
+
+// NewMemoryRepository creates an empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		mode:       StoreAll,
+		nextID:     1,
+		watchlists: make(map[string]models.Watchlist),
+	}
+}
+
+// SetStorageMode sets how native balances are stored, as PostgresRepository.SetStorageMode does
+func (r *MemoryRepository) SetStorageMode(mode StorageMode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mode = mode
+}
+
+// StoreBalance stores a native balance record and returns the ID of the record covering it,
+// following the same rules as the Postgres StoreBalance. Like Postgres, it rejects balances
+// that are not a whole number of wei and derives the ETH value.
+func (r *MemoryRepository) StoreBalance(ctx context.Context, record models.BalanceRecord) (int, error) {
+	wei, ok := new(big.Int).SetString(record.Balance, 10)
+	if !ok || wei.Sign() < 0 {
+		return 0, fmt.Errorf("invalid wei balance %q", record.Balance)
+	}
+	if record.Chain == "" {
+		record.Chain = models.ChainEthereum
+	}
+	if record.FetchedAt.IsZero() {
+		record.FetchedAt = time.Now()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A block that is already stored is not stored again
+	previous := -1
+	var validTo *time.Time
+	for i, b := range r.balances {
+		if b.Chain != record.Chain || b.Address != record.Address {
+			continue
+		}
+		if record.BlockNumber != nil && b.BlockNumber != nil &&
+			*record.BlockNumber >= *b.BlockNumber && *record.BlockNumber <= lastSeenBlock(b) {
+			return b.ID, nil
+		}
+		if !b.FetchedAt.After(record.FetchedAt) {
+			if previous < 0 || !b.FetchedAt.Before(r.balances[previous].FetchedAt) {
+				previous = i
+			}
+		} else if validTo == nil || b.FetchedAt.Before(*validTo) {
+			fetchedAt := b.FetchedAt
+			validTo = &fetchedAt
+		}
+	}
+
+	if previous >= 0 && r.mode == StoreChanges && r.balances[previous].Balance == wei.String() {
+		b := &r.balances[previous]
+		if record.FetchedAt.After(b.LastSeenAt) {
+			b.LastSeenAt = record.FetchedAt
+		}
+		if record.BlockNumber != nil && (b.BlockNumber == nil || *record.BlockNumber > lastSeenBlock(*b)) {
+			block := *record.BlockNumber
+			b.LastSeenBlock = &block
+		}
+		return b.ID, nil
+	}
+
+	// Close the records this one replaces
+	for i := range r.balances {
+		b := &r.balances[i]
+		if b.Chain == record.Chain && b.Address == record.Address && !b.FetchedAt.After(record.FetchedAt) &&
+			(b.ValidTo == nil || b.ValidTo.After(record.FetchedAt)) {
+			fetchedAt := record.FetchedAt
+			b.ValidTo = &fetchedAt
+		}
+	}
+
+	record.ID = r.nextID
+	r.nextID++
+	record.Balance = wei.String()
+	record.BalanceETH = FormatUnits(record.Balance, 18)
+	record.LastSeenAt = record.FetchedAt
+	record.ValidTo = validTo
+	if record.BlockNumber != nil {
+		block := *record.BlockNumber
+		record.BlockNumber, record.LastSeenBlock = &block, &block
+	} else {
+		record.LastSeenBlock = nil
+	}
+	r.balances = append(r.balances, record)
+	return record.ID, nil
+}
+
+// lastSeenBlock returns the latest block a record covers, which must have a block number
+func lastSeenBlock(record models.BalanceRecord) uint64 {
+	if record.LastSeenBlock != nil {
+		return *record.LastSeenBlock
+	}
+	return *record.BlockNumber
+}
+
+// Balances returns up to limit native balance records of an address, newest first
+func (r *MemoryRepository) Balances(ctx context.Context, address string, limit int) ([]models.BalanceRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Walk newest first so records fetched at the same time keep the newest ID first
+	var records []models.BalanceRecord
+	for i := len(r.balances) - 1; i >= 0; i-- {
+		if r.balances[i].Address == address {
+			records = append(records, r.balances[i])
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].FetchedAt.After(records[j].FetchedAt) })
+
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+// LatestBalancesAbove returns the latest record of every address holding more than minWei, largest first
+func (r *MemoryRepository) LatestBalancesAbove(ctx context.Context, minWei *big.Int) ([]models.BalanceRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var records []models.BalanceRecord
+	for _, b := range r.latestBalances() {
+		if wei(b).Cmp(minWei) > 0 {
+			records = append(records, b)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if c := wei(records[i]).Cmp(wei(records[j])); c != 0 {
+			return c > 0
+		}
+		return records[i].Address < records[j].Address
+	})
+	return records, nil
+}
+
+// BalanceHistory returns a page of up to query.Limit points of an address's history
+func (r *MemoryRepository) BalanceHistory(ctx context.Context, query HistoryQuery) ([]models.BalancePoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Keep the last record of each bucket, or every record without bucketing
+	byTime := make(map[int64]models.BalanceRecord)
+	var raw []models.BalancePoint
+	for _, b := range r.balances {
+		if b.Address != query.Address ||
+			(!query.From.IsZero() && b.FetchedAt.Before(query.From)) ||
+			(!query.To.IsZero() && !b.FetchedAt.Before(query.To)) {
+			continue
+		}
+		if query.Bucket == BucketNone {
+			raw = append(raw, balancePoint(b.FetchedAt, b))
+			continue
+		}
+		key := query.Bucket.Start(b.FetchedAt).UnixNano()
+		if current, ok := byTime[key]; !ok || !b.FetchedAt.Before(current.FetchedAt) {
+			byTime[key] = b
+		}
+	}
+	for key, b := range byTime {
+		raw = append(raw, balancePoint(time.Unix(0, key).UTC(), b))
+	}
+
+	var points []models.BalancePoint
+	for _, p := range raw {
+		if query.includes(p.Time, p.RecordID) {
+			points = append(points, p)
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		before := points[i].Time.Before(points[j].Time) ||
+			(points[i].Time.Equal(points[j].Time) && points[i].RecordID < points[j].RecordID)
+		return before != query.Descending
+	})
+
+	if len(points) > query.Limit {
+		points = points[:query.Limit]
+	}
+	return points, nil
+}
+
+func balancePoint(t time.Time, b models.BalanceRecord) models.BalancePoint {
+	return models.BalancePoint{
+		Time:       t.UTC(),
+		Balance:    b.Balance,
+		BalanceETH: b.BalanceETH,
+		RecordID:   b.ID,
+		FetchedAt:  b.FetchedAt,
+	}
+}
+
+// latestBalances returns the current record of each address. The caller must hold the lock.
+func (r *MemoryRepository) latestBalances() map[string]models.BalanceRecord {
+	latest := make(map[string]models.BalanceRecord)
+	for _, b := range r.balances {
+		if b.ValidTo == nil {
+			latest[b.Address] = b
+		}
+	}
+	return latest
+}
+
+// wei parses the balance of a record, which StoreBalance has already validated
+func wei(record models.BalanceRecord) *big.Int {
+	amount, _ := new(big.Int).SetString(record.Balance, 10)
+	return amount
+}
+
+// StoreTokenBalance stores a token balance record and returns its ID
+func (r *MemoryRepository) StoreTokenBalance(ctx context.Context, record models.TokenBalanceRecord) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record.ID = r.nextID
+	r.nextID++
+	if record.Chain == "" {
+		record.Chain = models.ChainEthereum
+	}
+	record.TokenAddress = strings.ToLower(record.TokenAddress)
+	record.BalanceETH = FormatUnits(record.Balance, record.Decimals)
+	if record.ValueUSD != nil {
+		value := *record.ValueUSD
+		record.ValueUSD = &value
+	}
+	r.tokens = append(r.tokens, record)
+	return record.ID, nil
+}
+
+// StoreTokenBalances stores token balance records and returns their IDs
+func (r *MemoryRepository) StoreTokenBalances(ctx context.Context, records []models.TokenBalanceRecord) ([]int, error) {
+	ids := make([]int, len(records))
+	for i, record := range records {
+		ids[i], _ = r.StoreTokenBalance(ctx, record)
+	}
+	return ids, nil
+}
+
+// LatestTokenBalances returns the newest record of each token held by an address, ordered by chain and token
+func (r *MemoryRepository) LatestTokenBalances(ctx context.Context, address string) ([]models.TokenBalanceRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	latest := make(map[string]models.TokenBalanceRecord)
+	for _, t := range r.tokens {
+		if t.Address != address {
+			continue
+		}
+		key := t.Chain + "|" + t.TokenAddress
+		if current, ok := latest[key]; !ok || !t.FetchedAt.Before(current.FetchedAt) {
+			latest[key] = t
+		}
+	}
+
+	records := make([]models.TokenBalanceRecord, 0, len(latest))
+	for _, t := range latest {
+		records = append(records, withPriceSource(t))
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Chain != records[j].Chain {
+			return records[i].Chain < records[j].Chain
+		}
+		return records[i].TokenAddress < records[j].TokenAddress
+	})
+	return records, nil
+}
+
+// TokenBalances returns all records of a token contract, newest first
+func (r *MemoryRepository) TokenBalances(ctx context.Context, tokenAddress string) ([]models.TokenBalanceRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Walk newest first so records fetched at the same time keep the newest ID first
+	var records []models.TokenBalanceRecord
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		if r.tokens[i].TokenAddress == strings.ToLower(tokenAddress) {
+			records = append(records, withPriceSource(r.tokens[i]))
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].FetchedAt.After(records[j].FetchedAt) })
+	return records, nil
+}
+
+// withPriceSource fills in the source of records stored unpriced, as scanTokenBalance does
+func withPriceSource(record models.TokenBalanceRecord) models.TokenBalanceRecord {
+	if record.PriceSource == "" {
+		record.PriceSource = "unpriced"
+	}
+	return record
+}
+
+// SaveWatchlist creates a watchlist or replaces the addresses of an existing one
+func (r *MemoryRepository) SaveWatchlist(ctx context.Context, name string, addresses []string) (models.Watchlist, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	watchlist, ok := r.watchlists[name]
+	if !ok {
+		watchlist = models.Watchlist{ID: len(r.watchlists) + 1, Name: name, CreatedAt: time.Now()}
+	}
+
+	seen := make(map[string]bool)
+	watchlist.Addresses = []string{}
+	for _, address := range addresses {
+		if !seen[address] {
+			seen[address] = true
+			watchlist.Addresses = append(watchlist.Addresses, address)
+		}
+	}
+	sort.Strings(watchlist.Addresses)
+
+	r.watchlists[name] = watchlist
+	return copyWatchlist(watchlist), nil
+}
+
+// Watchlist returns a saved watchlist with its addresses in order
+func (r *MemoryRepository) Watchlist(ctx context.Context, name string) (models.Watchlist, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	watchlist, ok := r.watchlists[name]
+	if !ok {
+		return models.Watchlist{Name: name, Addresses: []string{}}, ErrWatchlistNotFound
+	}
+	return copyWatchlist(watchlist), nil
+}
+
+// WatchlistTotal sums the latest stored balance of every address on a watchlist
+func (r *MemoryRepository) WatchlistTotal(ctx context.Context, name string) (models.BalanceTotal, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	watchlist, ok := r.watchlists[name]
+	if !ok {
+		return models.BalanceTotal{}, ErrWatchlistNotFound
+	}
+
+	latest := r.latestBalances()
+	total := models.BalanceTotal{}
+	sum := new(big.Int)
+	for _, address := range watchlist.Addresses {
+		if b, ok := latest[address]; ok {
+			total.Addresses++
+			sum.Add(sum, wei(b))
+		}
+	}
+
+	total.Balance = sum.String()
+	total.BalanceETH = FormatUnits(total.Balance, 18)
+	return total, nil
+}
+
+func copyWatchlist(watchlist models.Watchlist) models.Watchlist {
+	watchlist.Addresses = append([]string{}, watchlist.Addresses...)
+	return watchlist
+}
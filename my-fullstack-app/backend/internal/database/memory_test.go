@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	now := time.Now()
+
+	for i, balance := range []string{"1", "2", "3"} {
+		record := models.BalanceRecord{Address: "0xabc", Balance: balance, FetchedAt: now.Add(time.Duration(i) * time.Minute)}
+		if _, err := repo.StoreBalance(ctx, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	repo.StoreBalance(ctx, models.BalanceRecord{Address: "0xdef", Balance: "9", FetchedAt: now})
+
+	balances, err := repo.Balances(ctx, "0xabc", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(balances) != 2 || balances[0].Balance != "3" || balances[1].Balance != "2" {
+		t.Errorf("Expected the 2 newest balances, got %+v", balances)
+	}
+
+	value := 5.0
+	tokens := []models.TokenBalanceRecord{
+		{Address: "0xabc", TokenAddress: "0xToken2", Balance: "1", FetchedAt: now},
+		{Address: "0xabc", TokenAddress: "0xToken1", Balance: "1", FetchedAt: now},
+		{Address: "0xabc", TokenAddress: "0xToken1", Balance: "2000000", Decimals: 6, FetchedAt: now.Add(time.Minute), ValueUSD: &value, PriceSource: "peg:USD"},
+	}
+	for _, record := range tokens {
+		if _, err := repo.StoreTokenBalance(ctx, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	latest, err := repo.LatestTokenBalances(ctx, "0xabc")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(latest) != 2 || latest[0].TokenAddress != "0xtoken1" || latest[0].BalanceETH != "2.000000" || latest[0].Chain != "ethereum" {
+		t.Fatalf("Expected the newest record of each token, got %+v", latest)
+	}
+	if latest[1].PriceSource != "unpriced" {
+		t.Errorf("Expected unpriced source for a record without one, got %q", latest[1].PriceSource)
+	}
+
+	history, err := repo.TokenBalances(ctx, "0xtoken1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(history) != 2 || history[0].ValueUSD == nil || *history[0].ValueUSD != 5 {
+		t.Errorf("Expected 2 records newest first, matched case-insensitively, got %+v", history)
+	}
+}
+
+func TestMemoryRepositoryAggregates(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	now := time.Now()
+
+	balances := []models.BalanceRecord{
+		{Address: "0xa", Balance: "5000000000000000000", FetchedAt: now},
+		{Address: "0xa", Balance: "1500000000000000000", FetchedAt: now.Add(time.Minute)},
+		{Address: "0xb", Balance: "3000000000000000000", FetchedAt: now},
+		{Address: "0xc", Balance: "100", FetchedAt: now},
+	}
+	for _, record := range balances {
+		if _, err := repo.StoreBalance(ctx, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, err := repo.StoreBalance(ctx, models.BalanceRecord{Address: "0xd", Balance: "1.5"}); err == nil {
+		t.Errorf("Expected an error for a fractional wei balance")
+	}
+
+	// Only the latest balance of each address counts
+	above, err := repo.LatestBalancesAbove(ctx, big.NewInt(1e18))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(above) != 2 || above[0].Address != "0xb" || above[1].Address != "0xa" || above[1].BalanceETH != "1.500000000000000000" {
+		t.Errorf("Expected 0xb then 0xa above 1 ETH, got %+v", above)
+	}
+
+	if _, err := repo.WatchlistTotal(ctx, "missing"); err != ErrWatchlistNotFound {
+		t.Errorf("Expected ErrWatchlistNotFound, got %v", err)
+	}
+
+	watchlist, err := repo.SaveWatchlist(ctx, "team", []string{"0xb", "0xa", "0xa", "0xe"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(watchlist.Addresses) != 3 || watchlist.Addresses[0] != "0xa" {
+		t.Errorf("Expected 3 sorted unique addresses, got %v", watchlist.Addresses)
+	}
+
+	total, err := repo.WatchlistTotal(ctx, "team")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total.Addresses != 2 || total.Balance != "4500000000000000000" || total.BalanceETH != "4.500000000000000000" {
+		t.Errorf("Expected 4.5 ETH across 2 addresses, got %+v", total)
+	}
+}
+
+func TestMemoryRepositoryStoreChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	repo.SetStorageMode(StoreChanges)
+	now := time.Now()
+
+	store := func(balance string, block uint64, minutes int) int {
+		t.Helper()
+		id, err := repo.StoreBalance(ctx, models.BalanceRecord{
+			Address:     "0xabc",
+			Balance:     balance,
+			BlockNumber: &block,
+			FetchedAt:   now.Add(time.Duration(minutes) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return id
+	}
+
+	first := store("1", 100, 0)
+	if id := store("1", 105, 1); id != first {
+		t.Errorf("Expected an unchanged balance to extend record %d, got %d", first, id)
+	}
+	if id := store("1", 103, 5); id != first {
+		t.Errorf("Expected a block already covered to return record %d, got %d", first, id)
+	}
+	second := store("2", 110, 2)
+	if second == first || store("2", 110, 3) != second {
+		t.Errorf("Expected a changed balance to add a record that replays idempotently")
+	}
+
+	balances, err := repo.Balances(ctx, "0xabc", 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(balances) != 2 {
+		t.Fatalf("Expected one record per change, got %+v", balances)
+	}
+	current, previous := balances[0], balances[1]
+	if current.ValidTo != nil || current.Chain != models.ChainEthereum {
+		t.Errorf("Expected the newest record to be current, got %+v", current)
+	}
+	if *previous.LastSeenBlock != 105 || !previous.LastSeenAt.Equal(now.Add(time.Minute)) ||
+		previous.ValidTo == nil || !previous.ValidTo.Equal(current.FetchedAt) {
+		t.Errorf("Expected the first record seen until block 105 and closed by the change, got %+v", previous)
+	}
+
+	// Every read is a record in the default mode
+	all := NewMemoryRepository()
+	for i := 0; i < 3; i++ {
+		all.StoreBalance(ctx, models.BalanceRecord{Address: "0xabc", Balance: "1", FetchedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	if balances, _ := all.Balances(ctx, "0xabc", 10); len(balances) != 3 || balances[1].ValidTo == nil {
+		t.Errorf("Expected 3 records with the older ones closed, got %+v", balances)
+	}
+}
+
+func TestFormatUnits(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		decimals uint8
+		want     string
+	}{
+		{raw: "1500000000000000000", decimals: 18, want: "1.500000000000000000"},
+		{raw: "42", decimals: 6, want: "0.000042"},
+		{raw: "0", decimals: 2, want: "0.00"},
+		{raw: "123", decimals: 0, want: "123"},
+		{raw: "-15", decimals: 1, want: "-1.5"},
+		{raw: "not a number", decimals: 6, want: "not a number"},
+	}
+
+	for _, tc := range testCases {
+		if got := FormatUnits(tc.raw, tc.decimals); got != tc.want {
+			t.Errorf("FormatUnits(%q, %d) = %q, want %q", tc.raw, tc.decimals, got, tc.want)
+		}
+	}
+}
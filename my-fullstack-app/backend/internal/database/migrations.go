@@ -1,103 +1,532 @@
 package database
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"log"
-	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// RunMigrations applies all migrations in the specified directory
-func RunMigrations(db *sql.DB, migrationsDir string) error {
-	// Create migrations table if it doesn't exist
-	_, err := db.Exec(`
-        CREATE TABLE IF NOT EXISTS migrations (
-            id SERIAL PRIMARY KEY,
+// migrationLockKey identifies the Postgres advisory lock held while migrating, so replicas
+// starting together apply each migration once
+const migrationLockKey int64 = 0x6d6967726174696f // "migratio"
+
+var (
+	// ErrMigrationChecksum is returned when an applied migration's file has been edited
+	ErrMigrationChecksum = errors.New("applied migration has been modified")
+	// ErrMigrationMissing is returned when an applied migration has no file
+	ErrMigrationMissing = errors.New("applied migration file is missing")
+	// ErrNoDownMigration is returned when rolling back a migration without a down file
+	ErrNoDownMigration = errors.New("migration has no down file")
+	// ErrDirtyMigration is returned when golang-migrate left the schema half migrated
+	ErrDirtyMigration = errors.New("schema_migrations is dirty")
+	// ErrNoMigrationHistory is returned by Status and Version for a database no migration
+	// has been applied to
+	ErrNoMigrationHistory = errors.New("no migration history")
+)
+
+// migrationFileName matches golang-migrate file names, e.g. 000002_fx_rates.up.sql
+var migrationFileName = regexp.MustCompile(`^([0-9]+)_([A-Za-z0-9_-]+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change with its up and optional down script
+type Migration struct {
+	Version  uint64
+	Name     string
+	Up       string
+	Down     string // Empty when the migration cannot be rolled back
+	Checksum string // SHA-256 of the up script
+}
+
+// MigrationStatus describes a migration found in the files or the history table
+type MigrationStatus struct {
+	Version   uint64     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"` // The up file changed since it was applied
+	Missing   bool       `json:"missing"`  // Applied, but no longer in the files
+}
+
+// appliedMigration is a row of the migration_history table
+type appliedMigration struct {
+	Version   uint64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// LoadMigrations reads the migrations of a directory named the golang-migrate way,
+// ordered by version. Other files are ignored, but misnamed .sql files are an error so
+// they are not silently skipped.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q: want VERSION_NAME.up.sql or VERSION_NAME.down.sql", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			m.Checksum = checksum(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Checksum == "" {
+			return nil, fmt.Errorf("migration %d_%s has a down file but no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrator applies and rolls back migrations, recording them in the migration_history table
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator loads the migrations of fsys for the database. The migrations must be
+// written in the dialect of db, such as migrations.FS for Postgres or migrations.SQLiteFS.
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: DialectOf(db), migrations: migrations}, nil
+}
+
+// Migrations returns the loaded migrations, ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration{}, m.migrations...)
+}
+
+// Up applies every pending migration in order and returns the ones applied. It refuses to
+// run if an applied migration has been edited or removed.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		history, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verifyHistory(m.migrations, history); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+
+	return applied, err
+}
+
+// DownTo rolls back every applied migration above version, newest first, and returns the
+// ones rolled back. DownTo(ctx, 0) rolls back everything.
+func (m *Migrator) DownTo(ctx context.Context, version uint64) ([]Migration, error) {
+	var rolledBack []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		history, err := m.history(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := verifyHistory(m.migrations, history); err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if migration.Version <= version {
+				break
+			}
+			if _, ok := history[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Status lists every migration in the files or the history, ordered by version. It only
+// reads the database, so it neither waits for a running migration nor creates the history
+// table. A database without history gets every migration as pending and
+// ErrNoMigrationHistory.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	history, err := m.readOnlyHistory(ctx)
+	if errors.Is(err, ErrNoMigrationHistory) {
+		return buildStatus(m.migrations, nil), err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buildStatus(m.migrations, history), nil
+}
+
+// Version returns the highest applied migration version, or 0 when none is applied. Like
+// Status it only reads the database, and returns ErrNoMigrationHistory without history.
+func (m *Migrator) Version(ctx context.Context) (uint64, error) {
+	history, err := m.readOnlyHistory(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var version uint64
+	for v := range history {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// withLock runs fn on one connection while holding the migration advisory lock. SQLite has
+// no advisory locks; its transactions already take the database's single write lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a database connection: %w", err)
+	}
+	defer conn.Close()
+
+	if m.dialect == SQLite {
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer func() {
+		// The lock is also released when the connection closes, so a failure here is harmless
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey); err != nil {
+			log.Printf("Error releasing the migration lock: %v", err)
+		}
+	}()
+
+	return fn(conn)
+}
+
+// history creates the history table if needed and returns the applied migrations by
+// version. The first time it runs on a database migrated by golang-migrate, it records the
+// migrations up to that version as applied.
+func (m *Migrator) history(ctx context.Context, conn *sql.Conn) (map[uint64]appliedMigration, error) {
+	appliedAt := "TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()"
+	if m.dialect == SQLite {
+		appliedAt = "DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP"
+	}
+	_, err := conn.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS migration_history (
+            version BIGINT PRIMARY KEY,
             name VARCHAR(255) NOT NULL,
-            applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            checksum CHAR(64) NOT NULL,
+            applied_at `+appliedAt+`
         )
     `)
 	if err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
+		return nil, fmt.Errorf("failed to create migration_history table: %w", err)
 	}
 
-	// Get list of applied migrations
-	appliedMigrations := make(map[string]bool)
-	rows, err := db.Query("SELECT name FROM migrations")
+	history, err := readHistory(ctx, conn)
 	if err != nil {
-		return fmt.Errorf("failed to query migrations: %w", err)
+		return nil, err
+	}
+	if len(history) > 0 || m.dialect == SQLite {
+		return history, nil
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return fmt.Errorf("failed to scan migration row: %w", err)
-		}
-		appliedMigrations[name] = true
+	if err := m.adoptGolangMigrate(ctx, conn); err != nil {
+		return nil, err
 	}
+	return readHistory(ctx, conn)
+}
 
-	// Read migration files
-	files, err := ioutil.ReadDir(migrationsDir)
+// readOnlyHistory returns the applied migrations without creating the history table. A
+// database migrated by golang-migrate that has not been adopted yet reports the migrations
+// up to its version, with no applied time.
+func (m *Migrator) readOnlyHistory(ctx context.Context) (map[uint64]appliedMigration, error) {
+	exists, err := m.tableExists(ctx, "migration_history")
 	if err != nil {
-		return fmt.Errorf("failed to read migrations directory: %w", err)
+		return nil, err
+	}
+	if exists {
+		return readHistory(ctx, m.db)
+	}
+	if m.dialect == SQLite {
+		return nil, ErrNoMigrationHistory
 	}
 
-	// Filter and sort SQL files
-	var migrationFiles []string
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".sql") {
-			migrationFiles = append(migrationFiles, file.Name())
+	version, ok, err := golangMigrateVersion(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoMigrationHistory
+	}
+	history := make(map[uint64]appliedMigration)
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
 		}
+		history[migration.Version] = appliedMigration{Version: migration.Version, Name: migration.Name, Checksum: migration.Checksum}
+	}
+	return history, nil
+}
+
+func (m *Migrator) tableExists(ctx context.Context, name string) (bool, error) {
+	query := `SELECT to_regclass($1) IS NOT NULL`
+	if m.dialect == SQLite {
+		query = `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = $1`
+	}
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, query, name).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to look for the %s table: %w", name, err)
 	}
-	sort.Strings(migrationFiles)
+	return exists, nil
+}
 
-	// Apply each migration
-	for _, filename := range migrationFiles {
-		if appliedMigrations[filename] {
-			log.Printf("Migration %s already applied, skipping", filename)
-			continue
+func readHistory(ctx context.Context, conn queryExecer) (map[uint64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM migration_history`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migration_history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make(map[uint64]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration_history row: %w", err)
 		}
+		history[a.Version] = a
+	}
 
-		log.Printf("Applying migration: %s", filename)
+	return history, rows.Err()
+}
 
-		// Read migration file
-		filePath := filepath.Join(migrationsDir, filename)
-		content, err := ioutil.ReadFile(filePath)
+// adoptGolangMigrate records the migrations golang-migrate applied, taking the current
+// files as their content
+func (m *Migrator) adoptGolangMigrate(ctx context.Context, conn *sql.Conn) error {
+	version, ok, err := golangMigrateVersion(ctx, conn)
+	if err != nil || !ok {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			break
+		}
+		_, err := conn.ExecContext(ctx,
+			`INSERT INTO migration_history (version, name, checksum) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, migration.Checksum)
 		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", filename, err)
+			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
 		}
+	}
+
+	log.Printf("Adopted golang-migrate schema at version %d", version)
+	return nil
+}
 
-		// Begin transaction
-		tx, err := db.Begin()
+// golangMigrateVersion reads the version golang-migrate left in schema_migrations. It
+// reports false when the table is missing or empty.
+func golangMigrateVersion(ctx context.Context, q queryExecer) (uint64, bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+
+	var version int64
+	var dirty bool
+	err = q.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if dirty {
+		return 0, false, fmt.Errorf("%w at version %d: fix the schema by hand before migrating", ErrDirtyMigration, version)
+	}
+	return uint64(version), true, nil
+}
+
+// apply runs an up script and records it in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	log.Printf("Applying migration %d_%s", migration.Version, migration.Name)
+
+	return inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO migration_history (version, name, checksum) VALUES ($1, $2, $3)`,
+			migration.Version, migration.Name, migration.Checksum)
 		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
+			return fmt.Errorf("failed to record migration %d_%s: %w", migration.Version, migration.Name, err)
 		}
+		return nil
+	})
+}
 
-		// Execute the migration
-		_, err = tx.Exec(string(content))
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to execute migration %s: %w", filename, err)
+// revert runs a down script and removes its record in one transaction
+func (m *Migrator) revert(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	log.Printf("Rolling back migration %d_%s", migration.Version, migration.Name)
+
+	return inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
 		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM migration_history WHERE version = $1`, migration.Version); err != nil {
+			return fmt.Errorf("failed to unrecord migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		return nil
+	})
+}
 
-		// Record the migration
-		_, err = tx.Exec("INSERT INTO migrations (name) VALUES ($1)", filename)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %s: %w", filename, err)
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// verifyHistory checks that every applied migration still has an unchanged up file
+func verifyHistory(migrations []Migration, history map[uint64]appliedMigration) error {
+	for _, status := range buildStatus(migrations, history) {
+		switch {
+		case status.Missing:
+			return fmt.Errorf("%w: %d_%s", ErrMigrationMissing, status.Version, status.Name)
+		case status.Modified:
+			return fmt.Errorf("%w: %d_%s", ErrMigrationChecksum, status.Version, status.Name)
 		}
+	}
+	return nil
+}
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction: %w", err)
+// buildStatus merges the migration files with the history, ordered by version
+func buildStatus(migrations []Migration, history map[uint64]appliedMigration) []MigrationStatus {
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	known := make(map[uint64]bool)
+
+	for _, migration := range migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := history[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = appliedTime(a)
+			status.Modified = a.Checksum != migration.Checksum
 		}
+		statuses = append(statuses, status)
+	}
 
-		log.Printf("Successfully applied migration: %s", filename)
+	for version, a := range history {
+		if known[version] {
+			continue
+		}
+		statuses = append(statuses, MigrationStatus{
+			Version:   version,
+			Name:      a.Name,
+			Applied:   true,
+			AppliedAt: appliedTime(a),
+			Missing:   true,
+		})
 	}
 
-	return nil
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses
+}
+
+// appliedTime is nil for migrations golang-migrate applied that are not adopted yet
+func appliedTime(a appliedMigration) *time.Time {
+	if a.AppliedAt.IsZero() {
+		return nil
+	}
+	appliedAt := a.AppliedAt
+	return &appliedAt
+}
+
+// RunMigrations applies all pending migrations of fsys, such as the embedded migrations.FS
+func RunMigrations(ctx context.Context, db *sql.DB, fsys fs.FS) error {
+	migrator, err := NewMigrator(db, fsys)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err == nil {
+		log.Printf("Database schema up to date, %d migrations applied", len(applied))
+	}
+	return err
 }
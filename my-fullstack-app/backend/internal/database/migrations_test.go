@@ -0,0 +1,108 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	testCases := []struct {
+		name      string
+		files     fstest.MapFS
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name: "Up and down files",
+			files: fstest.MapFS{
+				"000002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+				"000001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+				"000001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+				"README.md":              {Data: []byte("not a migration")},
+				"000003_third.up.sql":    {Data: []byte("SELECT 1;")},
+				"000003_third.down.sql":  {Data: []byte("SELECT 1;")},
+				"000002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+			},
+			wantCount: 3,
+		},
+		{
+			name:    "Misnamed file",
+			files:   fstest.MapFS{"000001_initial.up..sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name:    "Down without up",
+			files:   fstest.MapFS{"000001_first.down.sql": {Data: []byte("SELECT 1;")}},
+			wantErr: true,
+		},
+		{
+			name: "Version used twice",
+			files: fstest.MapFS{
+				"000001_first.up.sql": {Data: []byte("SELECT 1;")},
+				"000001_other.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tc.files)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", migrations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(migrations) != tc.wantCount {
+				t.Fatalf("Expected %d migrations, got %d", tc.wantCount, len(migrations))
+			}
+			for i, m := range migrations {
+				if m.Version != uint64(i+1) || m.Up == "" || m.Down == "" || len(m.Checksum) != 64 {
+					t.Errorf("Unexpected migration %d: %+v", i, m)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildStatus(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "first", Checksum: "a"},
+		{Version: 2, Name: "second", Checksum: "b"},
+		{Version: 3, Name: "third", Checksum: "c"},
+	}
+	now := time.Now()
+
+	history := map[uint64]appliedMigration{
+		1: {Version: 1, Name: "first", Checksum: "a", AppliedAt: now},
+		2: {Version: 2, Name: "second", Checksum: "b", AppliedAt: now},
+	}
+	statuses := buildStatus(migrations, history)
+	if len(statuses) != 3 || !statuses[1].Applied || statuses[2].Applied || statuses[0].Modified {
+		t.Errorf("Unexpected statuses: %+v", statuses)
+	}
+	if err := verifyHistory(migrations, history); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	history[2] = appliedMigration{Version: 2, Name: "second", Checksum: "edited", AppliedAt: now}
+	if err := verifyHistory(migrations, history); !errors.Is(err, ErrMigrationChecksum) {
+		t.Errorf("Expected ErrMigrationChecksum, got %v", err)
+	}
+
+	history[2] = appliedMigration{Version: 2, Name: "second", Checksum: "b", AppliedAt: now}
+	history[4] = appliedMigration{Version: 4, Name: "removed", Checksum: "d", AppliedAt: now}
+	statuses = buildStatus(migrations, history)
+	if len(statuses) != 4 || !statuses[3].Missing || statuses[3].Name != "removed" {
+		t.Errorf("Expected the removed migration last, got %+v", statuses)
+	}
+	if err := verifyHistory(migrations, history); !errors.Is(err, ErrMigrationMissing) {
+		t.Errorf("Expected ErrMigrationMissing, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// queryExecer is the part of *sql.DB, *sql.Conn and *sql.Tx that statements run on
+type queryExecer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// OutboxKey is the key that orders the events of an address
+func OutboxKey(chain, address string) string {
+	return chain + ":" + strings.ToLower(address)
+}
+
+// enqueueEvent writes an event to the outbox in the transaction of the change it reports
+func enqueueEvent(tx *sql.Tx, key, eventType string, payload interface{}) error {
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+        INSERT INTO outbox (event_key, event_type, payload, created_at, next_attempt_at)
+        VALUES ($1, $2, $3, $4, $4)
+    `, key, eventType, string(content), time.Now().UTC())
+	if err != nil {
+		log.Printf("Error writing %s event: %v", eventType, err)
+	}
+	return err
+}
+
+// OutboxResult is the outcome of publishing an event
+type OutboxResult struct {
+	Err        error         // Nil when the event was published
+	RetryAfter time.Duration // Delay before a failed event is tried again
+}
+
+// ProcessOutbox claims up to limit events that are due and next in line for their key, calls
+// publish with each in ID order, and marks them delivered or schedules a retry. It returns
+// the number of events processed.
+//
+// Only the oldest undelivered event of a key is claimed, so the events of an address are
+// published in order, and one that keeps failing holds back the later ones. The batch is
+// claimed for the lease in a short transaction, FOR UPDATE SKIP LOCKED on Postgres, and no
+// transaction is held open while publishing, so several relays share the outbox. Each event
+// is marked as soon as it is published. Events of a relay that dies are claimed again once
+// its lease ends; so that a slow batch is not published twice, the rest of a batch is left
+// to be claimed again when the lease ends before it is published.
+func ProcessOutbox(ctx context.Context, db *sql.DB, limit int, lease time.Duration, now time.Time,
+	publish func(models.OutboxEvent) OutboxResult) (int, error) {
+	now = now.UTC()
+	deadline := time.Now().Add(lease)
+	events, err := claimOutboxEvents(ctx, db, limit, now, now.Add(lease))
+	if err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		if time.Now().After(deadline) {
+			return i, nil
+		}
+
+		result := publish(event)
+		if result.Err == nil {
+			_, err = db.ExecContext(ctx, `
+                UPDATE outbox SET delivered_at = $2, attempts = attempts + 1, last_error = NULL, claimed_until = NULL
+                WHERE id = $1
+            `, event.ID, now)
+		} else {
+			_, err = db.ExecContext(ctx, `
+                UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3, claimed_until = NULL
+                WHERE id = $1
+            `, event.ID, result.Err.Error(), now.Add(result.RetryAfter).UTC())
+		}
+		if err != nil {
+			log.Printf("Error marking outbox event %d: %v", event.ID, err)
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// claimOutboxEvents claims the events ProcessOutbox publishes until the given time
+func claimOutboxEvents(ctx context.Context, db *sql.DB, limit int, now, until time.Time) ([]models.OutboxEvent, error) {
+	query := `
+        SELECT id, event_key, event_type, payload, created_at, attempts
+        FROM outbox o
+        WHERE delivered_at IS NULL AND next_attempt_at <= $2
+          AND (claimed_until IS NULL OR claimed_until <= $2)
+          AND NOT EXISTS (
+              SELECT 1 FROM outbox earlier
+              WHERE earlier.event_key = o.event_key AND earlier.delivered_at IS NULL AND earlier.id < o.id
+          )
+        ORDER BY id
+        LIMIT $1
+    `
+	if DialectOf(db) == Postgres {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, limit, now)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Key, &event.Type, &payload, &event.CreatedAt, &event.Attempts); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		if _, err := tx.ExecContext(ctx, `UPDATE outbox SET claimed_until = $2 WHERE id = $1`, event.ID, until); err != nil {
+			return nil, err
+		}
+	}
+	return events, tx.Commit()
+}
+
+// PendingOutboxEvents counts the events not yet delivered
+func PendingOutboxEvents(db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM outbox WHERE delivered_at IS NULL`).Scan(&n)
+	return n, err
+}
+
+// PurgeOutbox deletes events delivered before a time and returns how many were deleted
+func PurgeOutbox(db *sql.DB, deliveredBefore time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM outbox WHERE delivered_at < $1`, deliveredBefore.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,354 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partitionLockKey identifies the Postgres advisory lock held while changing balance_records
+// partitions, so replicas do not create or drop the same partition at once
+const partitionLockKey int64 = 0x7061727469746e73 // "partitns"
+
+// partitionPrefix starts the name of every monthly balance_records partition, followed by
+// the month as YYYYMM
+const partitionPrefix = "balance_records_p"
+
+// RetentionConfig controls the monthly partitions of balance_records
+type RetentionConfig struct {
+	RetentionDays int           // Raw records older than this are rolled up and dropped; 0 keeps them forever
+	PremakeMonths int           // Months ahead of the current one to create partitions for
+	Interval      time.Duration // Time between maintenance runs
+}
+
+// DefaultRetentionConfig keeps raw records forever and prepares the next two months hourly
+func DefaultRetentionConfig() RetentionConfig {
+	return RetentionConfig{
+		PremakeMonths: 2,
+		Interval:      time.Hour,
+	}
+}
+
+// RetentionConfigFromEnv builds the configuration from BALANCE_RETENTION_DAYS,
+// BALANCE_PARTITION_PREMAKE_MONTHS and BALANCE_PARTITION_INTERVAL
+func RetentionConfigFromEnv() (RetentionConfig, error) {
+	cfg := DefaultRetentionConfig()
+
+	for _, setting := range []struct {
+		name string
+		dest *int
+	}{
+		{"BALANCE_RETENTION_DAYS", &cfg.RetentionDays},
+		{"BALANCE_PARTITION_PREMAKE_MONTHS", &cfg.PremakeMonths},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return RetentionConfig{}, fmt.Errorf("invalid %s %q: must be a non-negative integer", setting.name, v)
+			}
+			*setting.dest = n
+		}
+	}
+
+	if v := os.Getenv("BALANCE_PARTITION_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return RetentionConfig{}, fmt.Errorf("invalid BALANCE_PARTITION_INTERVAL %q: must be a duration such as 1h", v)
+		}
+		cfg.Interval = d
+	}
+
+	return cfg, nil
+}
+
+// PartitionManager creates the monthly partitions of balance_records ahead of time and,
+// with a retention period, rolls expired months up into balance_daily_rollups and drops them
+type PartitionManager struct {
+	db     *sql.DB
+	config RetentionConfig
+	now    func() time.Time
+}
+
+// NewPartitionManager creates a partition manager using the given connection pool
+func NewPartitionManager(db *sql.DB, config RetentionConfig) *PartitionManager {
+	return &PartitionManager{db: db, config: config, now: time.Now}
+}
+
+// Run maintains the partitions every interval until ctx is cancelled
+func (m *PartitionManager) Run(ctx context.Context) {
+	log.Printf("Starting balance partition maintenance every %s, retention %d days", m.config.Interval, m.config.RetentionDays)
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Maintain(ctx); err != nil {
+			log.Printf("Balance partition maintenance failed: %v", err)
+		}
+		m.checkDuplicates(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Maintain creates the partitions of the current and upcoming months, then rolls up and
+// drops every partition that ended before the retention period, oldest first
+func (m *PartitionManager) Maintain(ctx context.Context) error {
+	now := m.now().UTC()
+	current := monthStart(now)
+	for i := 0; i <= m.config.PremakeMonths; i++ {
+		if err := m.createPartition(ctx, current.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+
+	if m.config.RetentionDays == 0 {
+		return nil
+	}
+
+	names, err := m.partitions(ctx)
+	if err != nil {
+		return err
+	}
+	cutoff := now.AddDate(0, 0, -m.config.RetentionDays)
+	for _, month := range expiredPartitions(names, cutoff) {
+		if err := m.rollUpAndDrop(ctx, month); err != nil {
+			return err
+		}
+		log.Printf("Rolled up and dropped balance partition %s", partitionName(month))
+	}
+	return nil
+}
+
+// BalanceDuplicates counts the balance records that break the invariants the partitioned
+// table can no longer enforce with unique constraints
+type BalanceDuplicates struct {
+	Blocks  int // (chain, address, block) stored in more than one record
+	Current int // Addresses with more than one current record
+}
+
+// FindBalanceDuplicates checks balance_records for a block stored twice for an address and
+// for addresses with more than one current record. StoreBalance prevents both; a non-zero
+// count means a write bypassed it.
+func FindBalanceDuplicates(ctx context.Context, db queryExecer) (BalanceDuplicates, error) {
+	var found BalanceDuplicates
+	err := db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM (
+            SELECT 1 FROM balance_records
+            WHERE block_number IS NOT NULL
+            GROUP BY chain, address, block_number
+            HAVING COUNT(*) > 1
+        ) AS duplicates
+    `).Scan(&found.Blocks)
+	if err != nil {
+		return found, fmt.Errorf("failed to check for duplicate blocks: %w", err)
+	}
+	err = db.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM (
+            SELECT 1 FROM balance_records
+            WHERE valid_to IS NULL
+            GROUP BY chain, address
+            HAVING COUNT(*) > 1
+        ) AS duplicates
+    `).Scan(&found.Current)
+	if err != nil {
+		return found, fmt.Errorf("failed to check for duplicate current records: %w", err)
+	}
+	return found, nil
+}
+
+// checkDuplicates logs balance records that break the invariants of FindBalanceDuplicates
+func (m *PartitionManager) checkDuplicates(ctx context.Context) {
+	found, err := FindBalanceDuplicates(ctx, m.db)
+	if err != nil {
+		log.Printf("Balance duplicate check failed: %v", err)
+		return
+	}
+	if found.Blocks > 0 || found.Current > 0 {
+		log.Printf("WARNING: balance_records has %d blocks stored more than once and %d addresses with more than one current record",
+			found.Blocks, found.Current)
+	}
+}
+
+// createPartition creates the partition of a month unless it exists. The partitioned table
+// cannot have unique constraints without fetched_at, so each partition gets its own unique
+// indexes: one record per block and one current record per address.
+func (m *PartitionManager) createPartition(ctx context.Context, month time.Time) error {
+	name := partitionName(month)
+	return m.withLock(ctx, func(tx *sql.Tx) error {
+		for _, statement := range []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF balance_records FOR VALUES FROM ('%s') TO ('%s')`,
+				name, month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339)),
+			fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s_block_key ON %s (chain, address, block_number)`, name, name),
+			fmt.Sprintf(`CREATE UNIQUE INDEX IF NOT EXISTS %s_current_key ON %s (chain, address) WHERE valid_to IS NULL`, name, name),
+		} {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return fmt.Errorf("failed to create partition %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ensurePartition creates the partition of the month of t unless it exists
+func ensurePartition(ctx context.Context, db *sql.DB, t time.Time) error {
+	month := monthStart(t)
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, partitionName(month)).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return NewPartitionManager(db, DefaultRetentionConfig()).createPartition(ctx, month)
+}
+
+// partitions lists the monthly partitions of balance_records
+func (m *PartitionManager) partitions(ctx context.Context) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, `
+        SELECT c.relname
+        FROM pg_inherits i
+        JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'balance_records'::regclass
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list balance partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// rollUpAndDrop writes the daily rollups of a month and drops its partition in one
+// transaction. Records still current are moved to the start of the next month first, so
+// an address whose balance has not changed keeps its current balance. A moved record keeps
+// the time it was read in carried_from; it is already in the rollup of that day, so it is
+// left out of later rollups and of history.
+func (m *PartitionManager) rollUpAndDrop(ctx context.Context, month time.Time) error {
+	end := month.AddDate(0, 1, 0)
+	if err := m.createPartition(ctx, end); err != nil {
+		return err
+	}
+
+	return m.withLock(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO balance_daily_rollups (
+                chain, address, day, open_balance, close_balance, min_balance, max_balance,
+                records, close_record_id, first_fetched_at, last_fetched_at
+            )
+            SELECT chain, address, day,
+                (array_agg(balance ORDER BY fetched_at, id))[1],
+                (array_agg(balance ORDER BY fetched_at DESC, id DESC))[1],
+                MIN(balance), MAX(balance), COUNT(*),
+                (array_agg(id ORDER BY fetched_at DESC, id DESC))[1],
+                MIN(fetched_at), MAX(fetched_at)
+            FROM (
+                SELECT *, (fetched_at AT TIME ZONE 'UTC')::DATE AS day
+                FROM balance_records
+                WHERE fetched_at >= $1 AND fetched_at < $2 AND carried_from IS NULL
+            ) AS records
+            GROUP BY chain, address, day
+            ON CONFLICT (chain, address, day) DO UPDATE SET
+                open_balance = EXCLUDED.open_balance,
+                close_balance = EXCLUDED.close_balance,
+                min_balance = EXCLUDED.min_balance,
+                max_balance = EXCLUDED.max_balance,
+                records = EXCLUDED.records,
+                close_record_id = EXCLUDED.close_record_id,
+                first_fetched_at = EXCLUDED.first_fetched_at,
+                last_fetched_at = EXCLUDED.last_fetched_at
+        `, month, end)
+		if err != nil {
+			return fmt.Errorf("failed to roll up %s: %w", partitionName(month), err)
+		}
+
+		// Current balances move to the next month, keeping when they were read
+		_, err = tx.ExecContext(ctx, `
+            UPDATE balance_records
+            SET carried_from = COALESCE(carried_from, fetched_at), fetched_at = $2
+            WHERE fetched_at >= $1 AND fetched_at < $2 AND valid_to IS NULL
+        `, month, end)
+		if err != nil {
+			return fmt.Errorf("failed to carry current balances out of %s: %w", partitionName(month), err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "DROP TABLE "+partitionName(month)); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", partitionName(month), err)
+		}
+		return nil
+	})
+}
+
+// withLock runs fn in a transaction holding the partition lock
+func (m *PartitionManager) withLock(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, partitionLockKey); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// monthStart returns the first instant of the UTC month of t
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName returns the name of the partition holding a month
+func partitionName(month time.Time) string {
+	return partitionPrefix + month.UTC().Format("200601")
+}
+
+// parsePartitionName returns the month of a partition name, or false for tables that are
+// not monthly partitions
+func parsePartitionName(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, partitionPrefix)
+	if !ok || len(suffix) != 6 {
+		return time.Time{}, false
+	}
+	month, err := time.Parse("200601", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// expiredPartitions returns the months of the named partitions that ended at or before
+// cutoff, oldest first
+func expiredPartitions(names []string, cutoff time.Time) []time.Time {
+	var months []time.Time
+	for _, name := range names {
+		month, ok := parsePartitionName(name)
+		if ok && !month.AddDate(0, 1, 0).After(cutoff) {
+			months = append(months, month)
+		}
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	return months
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPartitionNames(t *testing.T) {
+	month := monthStart(time.Date(2024, 2, 29, 23, 59, 0, 0, time.FixedZone("UTC-5", -5*3600)))
+	if name := partitionName(month); name != "balance_records_p202403" {
+		t.Errorf("Expected the UTC month of the time, got %s", name)
+	}
+
+	parsed, ok := parsePartitionName("balance_records_p202403")
+	if !ok || !parsed.Equal(month) {
+		t.Errorf("Expected to parse March 2024, got %s %v", parsed, ok)
+	}
+	for _, name := range []string{"balance_records", "balance_records_p2024", "balance_records_p202413", "token_balance_records_p202401"} {
+		if _, ok := parsePartitionName(name); ok {
+			t.Errorf("Expected %s not to be a monthly partition", name)
+		}
+	}
+}
+
+func TestExpiredPartitions(t *testing.T) {
+	names := []string{"balance_records_p202403", "balance_records_p202401", "balance_records_p202402", "balance_records_default"}
+
+	// A partition expires once its whole month is older than the cutoff
+	expired := expiredPartitions(names, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if len(expired) != 2 || expired[0].Month() != time.January || expired[1].Month() != time.February {
+		t.Errorf("Expected January then February, got %v", expired)
+	}
+	if expired := expiredPartitions(names, time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)); len(expired) != 0 {
+		t.Errorf("Expected no partition to expire mid-month, got %v", expired)
+	}
+}
+
+func TestRetentionConfigFromEnv(t *testing.T) {
+	t.Setenv("BALANCE_RETENTION_DAYS", "90")
+	t.Setenv("BALANCE_PARTITION_INTERVAL", "15m")
+
+	cfg, err := RetentionConfigFromEnv()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.RetentionDays != 90 || cfg.Interval != 15*time.Minute || cfg.PremakeMonths != DefaultRetentionConfig().PremakeMonths {
+		t.Errorf("Expected retention settings from the environment, got %+v", cfg)
+	}
+
+	t.Setenv("BALANCE_RETENTION_DAYS", "-1")
+	if _, err := RetentionConfigFromEnv(); err == nil {
+		t.Errorf("Expected a negative retention to be rejected")
+	}
+}
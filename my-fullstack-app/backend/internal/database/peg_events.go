@@ -0,0 +1,109 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// pegEventColumns are the peg_deviation_events columns in scan order
+const pegEventColumns = `id, asset, peg, source, started_at, ended_at, last_seen_at, start_price, last_price, max_deviation_bps`
+
+// SavePegDeviationEvent inserts a new event or updates an existing one, setting its ID when inserted
+func SavePegDeviationEvent(db *sql.DB, event *models.PegDeviationEvent) error {
+	if event.ID == 0 {
+		query := `
+            INSERT INTO peg_deviation_events (
+                asset, peg, source, started_at, ended_at, last_seen_at, start_price, last_price, max_deviation_bps
+            )
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+            RETURNING id
+        `
+		err := db.QueryRow(
+			query,
+			event.Asset,
+			event.Peg,
+			event.Source,
+			event.StartedAt,
+			event.EndedAt,
+			event.LastSeenAt,
+			event.StartPrice,
+			event.LastPrice,
+			event.MaxDeviationBps,
+		).Scan(&event.ID)
+		if err != nil {
+			log.Printf("Error storing %s peg deviation event: %v", event.Asset, err)
+		}
+		return err
+	}
+
+	query := `
+        UPDATE peg_deviation_events
+        SET ended_at = $2, last_seen_at = $3, last_price = $4, max_deviation_bps = $5
+        WHERE id = $1
+    `
+	_, err := db.Exec(query, event.ID, event.EndedAt, event.LastSeenAt, event.LastPrice, event.MaxDeviationBps)
+	if err != nil {
+		log.Printf("Error updating peg deviation event %d: %v", event.ID, err)
+	}
+	return err
+}
+
+// GetOpenPegDeviationEvents retrieves the events that have not ended yet
+func GetOpenPegDeviationEvents(db *sql.DB) ([]models.PegDeviationEvent, error) {
+	query := `SELECT ` + pegEventColumns + `
+        FROM peg_deviation_events
+        WHERE ended_at IS NULL
+        ORDER BY started_at
+    `
+	return queryPegDeviationEvents(db, query)
+}
+
+// GetRecentPegDeviationEvents retrieves events that were ongoing at or after since, newest first
+func GetRecentPegDeviationEvents(db *sql.DB, since time.Time, limit int) ([]models.PegDeviationEvent, error) {
+	query := `SELECT ` + pegEventColumns + `
+        FROM peg_deviation_events
+        WHERE last_seen_at >= $1 OR ended_at IS NULL
+        ORDER BY started_at DESC
+        LIMIT $2
+    `
+	return queryPegDeviationEvents(db, query, since, limit)
+}
+
+func queryPegDeviationEvents(db *sql.DB, query string, args ...interface{}) ([]models.PegDeviationEvent, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error retrieving peg deviation events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.PegDeviationEvent
+	for rows.Next() {
+		var e models.PegDeviationEvent
+		var endedAt sql.NullTime
+		err := rows.Scan(
+			&e.ID,
+			&e.Asset,
+			&e.Peg,
+			&e.Source,
+			&e.StartedAt,
+			&endedAt,
+			&e.LastSeenAt,
+			&e.StartPrice,
+			&e.LastPrice,
+			&e.MaxDeviationBps,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if endedAt.Valid {
+			e.EndedAt = &endedAt.Time
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -1,65 +1,366 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"math/big"
+	"time"
 
 	"my-fullstack-app/backend/internal/models"
 
 	_ "github.com/lib/pq"
 )
 
-const (
-	host     = "db"
-	port     = 5432
-	user     = "app"
-	password = "yourpassword"
-	dbname   = "appdb"
-)
-
+// Connect opens a connection pool configured from the environment and checks that the
+// database is reachable. The server shares one pool from Open; Connect suits tests and tools.
 func Connect() (*sql.DB, error) {
-	psqlInfo := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-	db, err := sql.Open("postgres", psqlInfo)
+	cfg, err := ConfigFromEnv()
 	if err != nil {
-		log.Fatal("Error opening database: ", err)
 		return nil, err
 	}
 
-	err = db.Ping()
+	db, err := Open(cfg)
 	if err != nil {
-		log.Fatal("Error connecting to database: ", err)
 		return nil, err
 	}
 
-	log.Println("Successfully connected to the  database")
+	if err := Ping(context.Background(), db, 5*time.Second); err != nil {
+		log.Printf("Error connecting to database: %v", err)
+		db.Close()
+		return nil, err
+	}
+
+	log.Println("Successfully connected to the database")
 	return db, nil
 }
 
-// StoreBalance stores an Ethereum balance record in the database
-func StoreBalance(db *sql.DB, record models.BalanceRecord) (int, error) {
-	// SQL query to insert a balance record
-	query := `
-        INSERT INTO balance_records (address, balance, balance_eth, fetched_at)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id
-    `
+// balanceColumns are the balance_records columns of a balance, in scan order. The numeric
+// columns are read as text so no precision is lost.
+const balanceColumns = `id, chain, address, CAST(balance AS TEXT), CAST(balance_eth AS TEXT), block_number, last_seen_block, fetched_at, last_seen_at, valid_to, carried_from`
 
-	// Execute the query
+// StoreBalance stores an Ethereum balance record in the database and returns its ID. The ETH
+// value is derived from the wei balance by the database.
+//
+// A record read at a block that is already stored is not stored again; the ID of the record
+// covering that block is returned instead. With StoreChanges, a balance equal to the one it
+// follows extends that record's last_seen_at and last_seen_block rather than adding a row.
+// Either way the record it follows is closed by setting its valid_to. A stored balance that
+// differs from the one it follows also writes an EventBalanceChanged to the outbox.
+func StoreBalance(db *sql.DB, record models.BalanceRecord, mode StorageMode) (int, error) {
+	return storeBalance(context.Background(), db, record, mode, true)
+}
+
+// storeBalance stores a balance record like StoreBalance, writing a change event only with
+// events. A record stored with storeImported is also matched by its fetch time when it has
+// no block, and extends the record it matches to its own last_seen_at and last_seen_block.
+// With a ctx from WithAudit, a stored or extended record is recorded in the audit log with
+// the address's current record before and after the write.
+func storeBalance(ctx context.Context, db *sql.DB, record models.BalanceRecord, mode StorageMode, events bool) (int, error) {
+	imported := mode == storeImported
+	wei, ok := new(big.Int).SetString(record.Balance, 10)
+	if !ok || wei.Sign() < 0 {
+		return 0, fmt.Errorf("invalid wei balance %q", record.Balance)
+	}
+	if record.Chain == "" {
+		record.Chain = models.ChainEthereum
+	}
+	if record.FetchedAt.IsZero() {
+		record.FetchedAt = time.Now()
+	}
+	// SQLite compares timestamps as text, which only follows time order within one zone
+	record.FetchedAt = record.FetchedAt.UTC()
+
+	dialect := DialectOf(db)
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Writes of one address are serialised so only one record is ever current. A SQLite
+	// transaction already holds the database's write lock.
+	forUpdate := ""
+	if dialect == Postgres {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2))`, record.Chain, record.Address); err != nil {
+			return 0, err
+		}
+		forUpdate = "FOR UPDATE"
+	}
+
+	block := nullBlock(record.BlockNumber)
 	var id int
-	err := db.QueryRow(
-		query,
-		record.Address,
-		record.Balance,
-		record.BalanceETH,
-		record.FetchedAt,
-	).Scan(&id)
+	if block.Valid || imported {
+		if block.Valid {
+			err = tx.QueryRow(`
+                SELECT id FROM balance_records
+                WHERE chain = $1 AND address = $2
+                  AND $3 BETWEEN block_number AND COALESCE(last_seen_block, block_number)
+                ORDER BY id
+                LIMIT 1
+            `, record.Chain, record.Address, block).Scan(&id)
+		} else {
+			err = tx.QueryRow(`
+                SELECT id FROM balance_records
+                WHERE chain = $1 AND address = $2 AND fetched_at = $3
+                ORDER BY id
+                LIMIT 1
+            `, record.Chain, record.Address, record.FetchedAt).Scan(&id)
+		}
+		if err == nil {
+			if imported {
+				if err := extendImportedBalance(tx, id, record); err != nil {
+					return 0, err
+				}
+			}
+			return id, tx.Commit()
+		}
+		if err != sql.ErrNoRows {
+			log.Printf("Error looking up balance record: %v", err)
+			return 0, err
+		}
+	}
 
+	// The audit log gets the current record before and after the write
+	_, audited := auditCaller(ctx)
+	var before *models.BalanceRecord
+	if audited {
+		if before, err = currentBalance(ctx, tx, record.Chain, record.Address); err != nil {
+			return 0, err
+		}
+	}
+	commit := func(id int) (int, error) {
+		if imported {
+			if err := extendImportedBalance(tx, id, record); err != nil {
+				return 0, err
+			}
+		}
+		if audited {
+			after, err := currentBalance(ctx, tx, record.Chain, record.Address)
+			if err != nil {
+				return 0, err
+			}
+			if err := recordAudit(ctx, tx, models.AuditBalanceStored, record.Address, before, after); err != nil {
+				return 0, err
+			}
+		}
+		return id, tx.Commit()
+	}
+
+	// The record this one follows, which is the current record unless it arrives late
+	var previousBalance string
+	var previousSeenAt time.Time
+	var previousBlock, previousSeenBlock sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT id, CAST(balance AS TEXT), last_seen_at, block_number, last_seen_block
+        FROM balance_records
+        WHERE chain = $1 AND address = $2 AND fetched_at <= $3
+        ORDER BY fetched_at DESC, id DESC
+        LIMIT 1
+        `+forUpdate, record.Chain, record.Address, record.FetchedAt).
+		Scan(&id, &previousBalance, &previousSeenAt, &previousBlock, &previousSeenBlock)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error retrieving previous balance record: %v", err)
+		return 0, err
+	}
+	hasPrevious := err == nil
+
+	if hasPrevious && mode == StoreChanges && previousBalance == wei.String() {
+		seenAt := previousSeenAt
+		if record.FetchedAt.After(seenAt) {
+			seenAt = record.FetchedAt
+		}
+		seenBlock := previousSeenBlock
+		if !seenBlock.Valid {
+			seenBlock = previousBlock
+		}
+		if block.Valid && (!seenBlock.Valid || block.Int64 > seenBlock.Int64) {
+			seenBlock = block
+		}
+
+		_, err := tx.Exec(`
+            UPDATE balance_records SET last_seen_at = $2, last_seen_block = $3 WHERE id = $1
+        `, id, seenAt.UTC(), seenBlock)
+		if err != nil {
+			log.Printf("Error extending balance record: %v", err)
+			return 0, err
+		}
+		return commit(id)
+	}
+
+	// A late record is replaced by the first record fetched after it
+	var validTo sql.NullTime
+	err = tx.QueryRow(`
+        SELECT fetched_at FROM balance_records
+        WHERE chain = $1 AND address = $2 AND fetched_at > $3
+        ORDER BY fetched_at
+        LIMIT 1
+    `, record.Chain, record.Address, record.FetchedAt).Scan(&validTo)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+        UPDATE balance_records
+        SET valid_to = $3
+        WHERE chain = $1 AND address = $2 AND fetched_at <= $3 AND (valid_to IS NULL OR valid_to > $3)
+    `, record.Chain, record.Address, record.FetchedAt)
+	if err != nil {
+		log.Printf("Error closing balance record: %v", err)
+		return 0, err
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO balance_records (chain, address, balance, block_number, last_seen_block, fetched_at, last_seen_at, valid_to)
+        VALUES ($1, $2, $3, $4, $4, $5, $5, $6)
+        RETURNING id
+    `, record.Chain, record.Address, wei.String(), block, record.FetchedAt, validTo).Scan(&id)
 	if err != nil {
 		log.Printf("Error storing balance record: %v", err)
 		return 0, err
 	}
 
-	return id, nil
+	if events && (!hasPrevious || previousBalance != wei.String()) {
+		change := models.BalanceChange{
+			Chain:       record.Chain,
+			Address:     record.Address,
+			RecordID:    id,
+			Balance:     wei.String(),
+			BlockNumber: record.BlockNumber,
+			FetchedAt:   record.FetchedAt,
+		}
+		if hasPrevious {
+			change.PreviousBalance = &previousBalance
+		}
+		if err := enqueueEvent(tx, OutboxKey(record.Chain, record.Address), models.EventBalanceChanged, change); err != nil {
+			return 0, err
+		}
+	}
+
+	return commit(id)
+}
+
+// currentBalance returns the newest record of an address, or nil when it has none
+func currentBalance(ctx context.Context, q queryExecer, chain, address string) (*models.BalanceRecord, error) {
+	rows, err := q.QueryContext(ctx, `
+        SELECT `+balanceColumns+`
+        FROM balance_records
+        WHERE chain = $1 AND address = $2
+        ORDER BY fetched_at DESC, id DESC
+        LIMIT 1
+    `, chain, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records, err := scanBalances(rows)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// GetBalances retrieves the most recent native balance records of an address, newest first
+func GetBalances(db *sql.DB, address string, limit int) ([]models.BalanceRecord, error) {
+	query := `
+        SELECT ` + balanceColumns + `
+        FROM balance_records
+        WHERE address = $1
+        ORDER BY fetched_at DESC, id DESC
+        LIMIT $2
+    `
+
+	rows, err := db.Query(query, address, limit)
+	if err != nil {
+		log.Printf("Error retrieving balance records: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBalances(rows)
+}
+
+// GetLatestBalancesAbove retrieves the latest balance of every address holding more than
+// minWei, largest first
+func GetLatestBalancesAbove(db *sql.DB, minWei *big.Int) ([]models.BalanceRecord, error) {
+	query := `
+        SELECT ` + balanceColumns + `
+        FROM balance_records
+        WHERE valid_to IS NULL AND balance > $1::NUMERIC
+        ORDER BY balance DESC, address
+    `
+	if DialectOf(db) == SQLite {
+		// Wei amounts are text without leading zeros, so a longer amount is a larger one
+		query = `
+            SELECT ` + balanceColumns + `
+            FROM balance_records
+            WHERE valid_to IS NULL
+              AND (length(balance) > length($1) OR (length(balance) = length($1) AND balance > $1))
+            ORDER BY length(balance) DESC, balance DESC, address
+        `
+	}
+
+	rows, err := db.Query(query, minWei.String())
+	if err != nil {
+		log.Printf("Error retrieving balances above threshold: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBalances(rows)
+}
+
+// scanBalances reads rows selected with balanceColumns
+func scanBalances(rows *sql.Rows) ([]models.BalanceRecord, error) {
+	var records []models.BalanceRecord
+	for rows.Next() {
+		record, err := scanBalance(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// scanBalance reads the current row of rows selected with balanceColumns. A record carried
+// out of a dropped partition is read with the time it was fetched, not the time it was moved.
+func scanBalance(rows *sql.Rows) (models.BalanceRecord, error) {
+	var record models.BalanceRecord
+	var block, lastSeenBlock sql.NullInt64
+	var validTo, carriedFrom sql.NullTime
+	err := rows.Scan(&record.ID, &record.Chain, &record.Address, &record.Balance, &record.BalanceETH,
+		&block, &lastSeenBlock, &record.FetchedAt, &record.LastSeenAt, &validTo, &carriedFrom)
+	if err != nil {
+		return record, err
+	}
+	if carriedFrom.Valid {
+		record.FetchedAt = carriedFrom.Time
+	}
+	record.BlockNumber = blockPtr(block)
+	record.LastSeenBlock = blockPtr(lastSeenBlock)
+	if validTo.Valid {
+		record.ValidTo = &validTo.Time
+	}
+	return record, nil
+}
+
+// nullBlock converts an optional block number into a query argument
+func nullBlock(block *uint64) sql.NullInt64 {
+	if block == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*block), Valid: true}
+}
+
+// blockPtr converts a scanned block number back into an optional one
+func blockPtr(block sql.NullInt64) *uint64 {
+	if !block.Valid {
+		return nil
+	}
+	n := uint64(block.Int64)
+	return &n
 }
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// BalanceRepository stores native ETH balance records
+type BalanceRepository interface {
+	// StoreBalance returns the ID of the record covering the balance, which is an existing
+	// record when the same block was stored before or when only changes are stored
+	StoreBalance(ctx context.Context, record models.BalanceRecord) (int, error)
+	// Balances returns up to limit records of an address, newest first
+	Balances(ctx context.Context, address string, limit int) ([]models.BalanceRecord, error)
+	// LatestBalancesAbove returns the latest record of every address holding more than minWei, largest first
+	LatestBalancesAbove(ctx context.Context, minWei *big.Int) ([]models.BalanceRecord, error)
+	// BalanceHistory returns a page of up to query.Limit points of an address's history
+	BalanceHistory(ctx context.Context, query HistoryQuery) ([]models.BalancePoint, error)
+}
+
+// TokenBalanceRepository stores ERC20 token balance records
+type TokenBalanceRepository interface {
+	StoreTokenBalance(ctx context.Context, record models.TokenBalanceRecord) (int, error)
+	// StoreTokenBalances stores records of one holder together and returns their IDs
+	StoreTokenBalances(ctx context.Context, records []models.TokenBalanceRecord) ([]int, error)
+	// LatestTokenBalances returns the newest record of each token held by an address
+	LatestTokenBalances(ctx context.Context, address string) ([]models.TokenBalanceRecord, error)
+	// TokenBalances returns all records of a token contract, newest first
+	TokenBalances(ctx context.Context, tokenAddress string) ([]models.TokenBalanceRecord, error)
+}
+
+// WatchlistRepository stores named groups of addresses and totals their balances
+type WatchlistRepository interface {
+	// SaveWatchlist creates a watchlist or replaces the addresses of an existing one
+	SaveWatchlist(ctx context.Context, name string, addresses []string) (models.Watchlist, error)
+	// Watchlist returns ErrWatchlistNotFound for a name that has not been saved
+	Watchlist(ctx context.Context, name string) (models.Watchlist, error)
+	// WatchlistTotal sums the latest stored balance of every address on a watchlist
+	WatchlistTotal(ctx context.Context, name string) (models.BalanceTotal, error)
+}
+
+// Repository groups the balance repositories backed by one store
+type Repository interface {
+	BalanceRepository
+	TokenBalanceRepository
+	WatchlistRepository
+}
+
+// StorageMode decides whether every native balance read is stored or only changes
+type StorageMode string
+
+const (
+	// StoreAll adds a record for every read
+	StoreAll StorageMode = "all"
+	// StoreChanges adds a record only when the balance changes, and otherwise extends the
+	// period of the current record
+	StoreChanges StorageMode = "changes"
+
+	// storeImported adds a record like StoreAll, and extends a record of the same fetch
+	// time or block to the last_seen_at and last_seen_block of an imported one
+	storeImported StorageMode = "imported"
+)
+
+// ParseStorageMode parses a BALANCE_STORAGE_MODE value. An empty value means StoreAll.
+func ParseStorageMode(value string) (StorageMode, error) {
+	switch StorageMode(value) {
+	case "", StoreAll:
+		return StoreAll, nil
+	case StoreChanges:
+		return StoreChanges, nil
+	}
+	return "", fmt.Errorf("unknown balance storage mode %q", value)
+}
+
+// PostgresRepository implements Repository on the balance, token balance and watchlist tables.
+// Writes go to the primary and reads to a replica when the cluster has a usable one.
+type PostgresRepository struct {
+	cluster *Cluster
+	mode    StorageMode
+	events  bool
+}
+
+// NewPostgresRepository creates a repository using the given connection pool. It stores
+// every balance read until SetStorageMode is called.
+func NewPostgresRepository(db *sql.DB) *PostgresRepository {
+	return NewClusterRepository(NewCluster(db))
+}
+
+// NewClusterRepository creates a repository that reads from the replicas of a cluster
+func NewClusterRepository(cluster *Cluster) *PostgresRepository {
+	return &PostgresRepository{cluster: cluster, mode: StoreAll, events: true}
+}
+
+// SetStorageMode sets how native balances are stored. Call it before the repository is used.
+func (r *PostgresRepository) SetStorageMode(mode StorageMode) {
+	r.mode = mode
+}
+
+// SetOutboxEnabled sets whether balance changes are written to the outbox. Disable it when
+// no relay publishes the events, so they do not pile up. Call it before the repository is
+// used.
+func (r *PostgresRepository) SetOutboxEnabled(enabled bool) {
+	r.events = enabled
+}
+
+// StoreBalance stores a native balance record and returns its ID. With a ctx from WithAudit
+// the write is recorded in the audit log in the same transaction.
+func (r *PostgresRepository) StoreBalance(ctx context.Context, record models.BalanceRecord) (int, error) {
+	return storeBalance(ctx, r.cluster.Primary(), record, r.mode, r.events)
+}
+
+// Balances returns up to limit native balance records of an address, newest first
+func (r *PostgresRepository) Balances(ctx context.Context, address string, limit int) ([]models.BalanceRecord, error) {
+	return GetBalances(r.cluster.Reader(ctx), address, limit)
+}
+
+// LatestBalancesAbove returns the latest record of every address holding more than minWei, largest first
+func (r *PostgresRepository) LatestBalancesAbove(ctx context.Context, minWei *big.Int) ([]models.BalanceRecord, error) {
+	return GetLatestBalancesAbove(r.cluster.Reader(ctx), minWei)
+}
+
+// BalanceHistory returns a page of up to query.Limit points of an address's history
+func (r *PostgresRepository) BalanceHistory(ctx context.Context, query HistoryQuery) ([]models.BalancePoint, error) {
+	return GetBalanceHistory(r.cluster.Reader(ctx), query)
+}
+
+// StoreTokenBalance stores a token balance record and returns its ID
+func (r *PostgresRepository) StoreTokenBalance(ctx context.Context, record models.TokenBalanceRecord) (int, error) {
+	ids, err := r.StoreTokenBalances(ctx, []models.TokenBalanceRecord{record})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// StoreTokenBalances stores token balance records of one holder in one transaction and
+// returns their IDs. With a ctx from WithAudit the write is recorded in the audit log in
+// the same transaction.
+func (r *PostgresRepository) StoreTokenBalances(ctx context.Context, records []models.TokenBalanceRecord) ([]int, error) {
+	return storeTokenBalances(ctx, r.cluster.Primary(), records, r.events)
+}
+
+// LatestTokenBalances returns the newest record of each token held by an address
+func (r *PostgresRepository) LatestTokenBalances(ctx context.Context, address string) ([]models.TokenBalanceRecord, error) {
+	return GetLatestTokenBalances(r.cluster.Reader(ctx), address)
+}
+
+// TokenBalances returns all records of a token contract, newest first
+func (r *PostgresRepository) TokenBalances(ctx context.Context, tokenAddress string) ([]models.TokenBalanceRecord, error) {
+	return GetTokenBalances(r.cluster.Reader(ctx), tokenAddress)
+}
+
+// SaveWatchlist creates a watchlist or replaces the addresses of an existing one
+func (r *PostgresRepository) SaveWatchlist(ctx context.Context, name string, addresses []string) (models.Watchlist, error) {
+	return SaveWatchlist(r.cluster.Primary(), name, addresses)
+}
+
+// Watchlist returns a saved watchlist with its addresses. It reads from the primary so a
+// watchlist can be used as soon as it is saved.
+func (r *PostgresRepository) Watchlist(ctx context.Context, name string) (models.Watchlist, error) {
+	return GetWatchlist(r.cluster.Primary(), name)
+}
+
+// WatchlistTotal sums the latest stored balance of every address on a watchlist. Like
+// Watchlist, it reads from the primary.
+func (r *PostgresRepository) WatchlistTotal(ctx context.Context, name string) (models.BalanceTotal, error) {
+	return GetWatchlistTotal(r.cluster.Primary(), name)
+}
@@ -0,0 +1,98 @@
+package database_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/database/dbtest"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// newSQLiteRepository returns a repository on a freshly migrated SQLite file
+func newSQLiteRepository(t *testing.T) *database.PostgresRepository {
+	t.Helper()
+	return database.NewPostgresRepository(dbtest.SQLite(t))
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := newSQLiteRepository(t)
+	repo.SetStorageMode(database.StoreChanges)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const a, b = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", "0x0000000000000000000000000000000000000001"
+
+	for i, balance := range []string{"1500000000000000000", "1500000000000000000", "25000000000000000000", "7"} {
+		block := uint64(100 + i)
+		record := models.BalanceRecord{Address: a, Balance: balance, BlockNumber: &block, FetchedAt: start.Add(time.Duration(i) * 6 * time.Hour)}
+		if _, err := repo.StoreBalance(ctx, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	repo.StoreBalance(ctx, models.BalanceRecord{Address: b, Balance: "3000000000000000000", FetchedAt: start})
+
+	balances, err := repo.Balances(ctx, a, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(balances) != 3 || balances[0].Balance != "7" || balances[0].ValidTo != nil || balances[0].BalanceETH != "0.000000000000000007" {
+		t.Fatalf("Expected one record per change, newest first, got %+v", balances)
+	}
+	if first := balances[2]; *first.LastSeenBlock != 101 || first.BalanceETH != "1.500000000000000000" || first.ValidTo == nil {
+		t.Errorf("Expected the first record extended to block 101 and closed, got %+v", first)
+	}
+
+	// A longer wei amount is a larger one, even though it sorts first as text
+	above, err := repo.LatestBalancesAbove(ctx, big.NewInt(5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(above) != 2 || above[0].Address != b || above[1].Address != a {
+		t.Errorf("Expected %s then %s, got %+v", b, a, above)
+	}
+
+	points, err := repo.BalanceHistory(ctx, database.HistoryQuery{Address: a, Bucket: database.BucketDay, Limit: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != 1 || !points[0].Time.Equal(start) || points[0].Balance != "7" {
+		t.Errorf("Expected the last balance of the day at its start, got %+v", points)
+	}
+	points, err = repo.BalanceHistory(ctx, database.HistoryQuery{Address: a, Descending: true, After: &database.HistoryCursor{Time: start.Add(18 * time.Hour), ID: balances[0].ID}, Limit: 10})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(points) != 2 || points[0].Balance != "25000000000000000000" {
+		t.Errorf("Expected the 2 records before the cursor, newest first, got %+v", points)
+	}
+
+	value := 2.5
+	for _, record := range []models.TokenBalanceRecord{
+		{Address: a, TokenAddress: "0xToken", Balance: "1000000", Decimals: 6, FetchedAt: start},
+		{Address: a, TokenAddress: "0xToken", Balance: "2500000", Decimals: 6, FetchedAt: start.Add(time.Hour), ValueUSD: &value},
+	} {
+		if _, err := repo.StoreTokenBalance(ctx, record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	tokens, err := repo.LatestTokenBalances(ctx, a)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].BalanceETH != "2.500000" || tokens[0].TokenAddress != "0xtoken" {
+		t.Errorf("Expected the newest token balance, got %+v", tokens)
+	}
+
+	if _, err := repo.SaveWatchlist(ctx, "team", []string{a, b}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	total, err := repo.WatchlistTotal(ctx, "team")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total.Addresses != 2 || total.Balance != "3000000000000000007" {
+		t.Errorf("Expected the current balances summed exactly, got %+v", total)
+	}
+}
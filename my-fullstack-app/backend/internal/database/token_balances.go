@@ -1,52 +1,188 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
+	"math/big"
 	"my-fullstack-app/backend/internal/models"
-	"time"
+	"strings"
 )
 
-// StoreTokenBalance stores an ERC20 token balance record in the database
+// tokenBalanceColumns are the token_balance_records columns of a token balance, in scan order
+const tokenBalanceColumns = `id, chain, holder_address, token_address, raw_amount, decimals, block_number, value_usd, price_source, fetched_at`
+
+// StoreTokenBalance stores an ERC20 token balance record in the database. A balance that
+// differs from the holder's previous balance of the token also writes an
+// EventTokenBalanceChanged to the outbox in the same transaction.
 func StoreTokenBalance(db *sql.DB, record models.TokenBalanceRecord) (int, error) {
-	// SQL query to insert a token balance record
-	query := `
-        INSERT INTO balance_records (
-            address, balance, balance_eth, fetched_at
-        )
-        VALUES ($1, $2, $3, $4)
-        RETURNING id
-    `
+	ids, err := storeTokenBalances(context.Background(), db, []models.TokenBalanceRecord{record}, true)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// storeTokenBalances stores token balance records of one holder in one transaction like
+// StoreTokenBalance, writing change events only with events, and returns their IDs. With a
+// ctx from WithAudit the write is recorded as one audit event, with the holder's latest
+// token balances before and after it.
+func storeTokenBalances(ctx context.Context, db *sql.DB, records []models.TokenBalanceRecord, events bool) ([]int, error) {
+	if len(records) == 0 {
+		return nil, nil
+	}
+	holder := records[0].Address
+	for _, record := range records {
+		if !strings.EqualFold(record.Address, holder) {
+			return nil, errors.New("token balances of more than one holder")
+		}
+	}
+
+	dialect := DialectOf(db)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, audited := auditCaller(ctx)
+	var before []models.TokenBalanceRecord
+	if audited {
+		if before, err = latestTokenBalances(ctx, tx, dialect, holder); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]int, len(records))
+	for i, record := range records {
+		if ids[i], err = storeTokenBalance(tx, dialect, record, events); err != nil {
+			return nil, err
+		}
+	}
+
+	if audited {
+		after, err := latestTokenBalances(ctx, tx, dialect, holder)
+		if err != nil {
+			return nil, err
+		}
+		if err := recordAudit(ctx, tx, models.AuditTokenBalancesStored, holder, before, after); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// storeTokenBalance stores a token balance record in a transaction
+func storeTokenBalance(tx *sql.Tx, dialect Dialect, record models.TokenBalanceRecord, events bool) (int, error) {
+	if record.Chain == "" {
+		record.Chain = models.ChainEthereum
+	}
+	record.TokenAddress = strings.ToLower(record.TokenAddress)
+	record.FetchedAt = record.FetchedAt.UTC()
+	// Compared with the stored amount, which the database normalizes
+	if amount, ok := new(big.Int).SetString(record.Balance, 10); ok {
+		record.Balance = amount.String()
+	}
+
+	var valueUSD sql.NullFloat64
+	if record.ValueUSD != nil {
+		valueUSD = sql.NullFloat64{Float64: *record.ValueUSD, Valid: true}
+	}
+
+	// Writes of one holder's token are serialised so each sees the balance before it
+	if dialect == Postgres {
+		_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1 || ':' || $2 || ':' || $3))`,
+			record.Chain, record.Address, record.TokenAddress)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var previous string
+	err := tx.QueryRow(`
+        SELECT CAST(raw_amount AS TEXT) FROM token_balance_records
+        WHERE chain = $1 AND holder_address = $2 AND token_address = $3 AND fetched_at <= $4
+        ORDER BY fetched_at DESC, id DESC
+        LIMIT 1
+    `, record.Chain, record.Address, record.TokenAddress, record.FetchedAt).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error retrieving previous token balance record: %v", err)
+		return 0, err
+	}
+	hasPrevious := err == nil
 
-	// Execute the query
 	var id int
-	err := db.QueryRow(
-		query,
+	err = tx.QueryRow(`
+        INSERT INTO token_balance_records (
+            chain, holder_address, token_address, raw_amount, decimals, block_number, value_usd, price_source, fetched_at
+        )
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id
+    `,
+		record.Chain,
 		record.Address,
+		record.TokenAddress,
 		record.Balance,
-		record.BalanceETH,
+		record.Decimals,
+		nullBlock(record.BlockNumber),
+		valueUSD,
+		record.PriceSource,
 		record.FetchedAt,
 	).Scan(&id)
-
 	if err != nil {
 		log.Printf("Error storing token balance record: %v", err)
 		return 0, err
 	}
 
+	if events && (!hasPrevious || previous != record.Balance) {
+		change := models.TokenBalanceChange{
+			Chain:        record.Chain,
+			Address:      record.Address,
+			TokenAddress: record.TokenAddress,
+			RecordID:     id,
+			Balance:      record.Balance,
+			Decimals:     record.Decimals,
+			BlockNumber:  record.BlockNumber,
+			FetchedAt:    record.FetchedAt,
+		}
+		if hasPrevious {
+			change.PreviousBalance = &previous
+		}
+		if err := enqueueEvent(tx, OutboxKey(record.Chain, record.Address), models.EventTokenBalanceChanged, change); err != nil {
+			return 0, err
+		}
+	}
+
 	return id, nil
 }
 
-// GetLatestTokenBalances retrieves latest token balances for an address
+// GetLatestTokenBalances retrieves the latest balance of each token held by an address
 func GetLatestTokenBalances(db *sql.DB, address string) ([]models.TokenBalanceRecord, error) {
+	return latestTokenBalances(context.Background(), db, DialectOf(db), address)
+}
+
+func latestTokenBalances(ctx context.Context, q queryExecer, dialect Dialect, address string) ([]models.TokenBalanceRecord, error) {
 	query := `
-        SELECT DISTINCT ON (token_address) 
-            id, address, balance, balance_eth, fetched_at
-        FROM balance_records
-        WHERE address = $1
-        ORDER BY address, fetched_at DESC
+        SELECT DISTINCT ON (chain, token_address) ` + tokenBalanceColumns + `
+        FROM token_balance_records
+        WHERE holder_address = $1
+        ORDER BY chain, token_address, fetched_at DESC, id DESC
     `
+	if dialect == SQLite {
+		query = `
+            SELECT ` + tokenBalanceColumns + `
+            FROM (
+                SELECT *, ROW_NUMBER() OVER (PARTITION BY chain, token_address ORDER BY fetched_at DESC, id DESC) AS position
+                FROM token_balance_records
+                WHERE holder_address = $1
+            ) AS ranked
+            WHERE position = 1
+            ORDER BY chain, token_address
+        `
+	}
 
-	rows, err := db.Query(query, address)
+	rows, err := q.QueryContext(ctx, query, address)
 	if err != nil {
 		return nil, err
 	}
@@ -55,14 +191,7 @@ func GetLatestTokenBalances(db *sql.DB, address string) ([]models.TokenBalanceRe
 	var records []models.TokenBalanceRecord
 
 	for rows.Next() {
-		var record models.TokenBalanceRecord
-		err := rows.Scan(
-			&record.ID,
-			&record.Address,
-			&record.Balance,
-			&record.BalanceETH,
-			&record.FetchedAt,
-		)
+		record, err := scanTokenBalance(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -70,15 +199,16 @@ func GetLatestTokenBalances(db *sql.DB, address string) ([]models.TokenBalanceRe
 		records = append(records, record)
 	}
 
-	return records, nil
+	return records, rows.Err()
 }
 
 // GetTokenBalances retrieves all token balance records for a specific token address
 func GetTokenBalances(db *sql.DB, tokenAddress string) ([]models.TokenBalanceRecord, error) {
 	// SQL query to retrieve token balances
-	query := `SELECT id, address, balance, balance_eth, fetched_at 
-	FROM balance_records 
-	WHERE address = $1
+	query := `SELECT ` + tokenBalanceColumns + `
+	FROM token_balance_records
+	WHERE token_address = LOWER($1)
+	ORDER BY fetched_at DESC, id DESC
     `
 
 	// Execute the query
@@ -92,22 +222,12 @@ func GetTokenBalances(db *sql.DB, tokenAddress string) ([]models.TokenBalanceRec
 	// Parse results
 	var balances []models.TokenBalanceRecord
 	for rows.Next() {
-		var balance models.TokenBalanceRecord
-		var fetchedAt time.Time
-
-		err := rows.Scan(
-			&balance.ID,
-			&balance.Address,
-			&balance.Balance,
-			&balance.BalanceETH,
-			&fetchedAt,
-		)
+		balance, err := scanTokenBalance(rows)
 		if err != nil {
 			log.Printf("Error scanning token balance record: %v", err)
 			continue
 		}
 
-		balance.FetchedAt = fetchedAt
 		balances = append(balances, balance)
 	}
 
@@ -119,3 +239,63 @@ func GetTokenBalances(db *sql.DB, tokenAddress string) ([]models.TokenBalanceRec
 
 	return balances, nil
 }
+
+// scanTokenBalance reads a row selected with tokenBalanceColumns
+func scanTokenBalance(rows *sql.Rows) (models.TokenBalanceRecord, error) {
+	var record models.TokenBalanceRecord
+	var blockNumber sql.NullInt64
+	var valueUSD sql.NullFloat64
+	var priceSource sql.NullString
+
+	err := rows.Scan(
+		&record.ID,
+		&record.Chain,
+		&record.Address,
+		&record.TokenAddress,
+		&record.Balance,
+		&record.Decimals,
+		&blockNumber,
+		&valueUSD,
+		&priceSource,
+		&record.FetchedAt,
+	)
+	if err != nil {
+		return record, err
+	}
+
+	record.BalanceETH = FormatUnits(record.Balance, record.Decimals)
+	if blockNumber.Valid {
+		block := uint64(blockNumber.Int64)
+		record.BlockNumber = &block
+	}
+	if valueUSD.Valid {
+		record.ValueUSD = &valueUSD.Float64
+	}
+	// Records stored before pricing was introduced have no source
+	record.PriceSource = priceSource.String
+	if record.PriceSource == "" {
+		record.PriceSource = "unpriced"
+	}
+
+	return record, nil
+}
+
+// FormatUnits formats a raw integer amount with the given number of decimals, keeping every
+// fraction digit. Amounts that are not integers are returned unchanged.
+func FormatUnits(raw string, decimals uint8) string {
+	amount, ok := new(big.Int).SetString(raw, 10)
+	if !ok || decimals == 0 {
+		return raw
+	}
+
+	digits := new(big.Int).Abs(amount).String()
+	if pad := int(decimals) + 1 - len(digits); pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+
+	formatted := digits[:len(digits)-int(decimals)] + "." + digits[len(digits)-int(decimals):]
+	if amount.Sign() < 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
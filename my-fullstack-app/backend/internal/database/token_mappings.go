@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// SaveTokenMapping creates or replaces the stored mapping of a token contract. The mapping
+// must already be validated; the address is stored lowercase.
+func SaveTokenMapping(db *sql.DB, mapping models.TokenMapping) error {
+	_, err := db.Exec(`
+        INSERT INTO token_mappings (address, symbol, pair, peg)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (address) DO UPDATE SET
+            symbol = EXCLUDED.symbol,
+            pair = EXCLUDED.pair,
+            peg = EXCLUDED.peg,
+            updated_at = CURRENT_TIMESTAMP
+    `,
+		strings.ToLower(mapping.Address),
+		mapping.Symbol,
+		sql.NullString{String: mapping.Pair, Valid: mapping.Pair != ""},
+		sql.NullString{String: mapping.Peg, Valid: mapping.Peg != ""},
+	)
+	if err != nil {
+		log.Printf("Error saving token mapping %s: %v", mapping.Address, err)
+	}
+	return err
+}
+
+// GetTokenMappings retrieves all stored token mappings ordered by address
+func GetTokenMappings(db *sql.DB) ([]models.TokenMapping, error) {
+	rows, err := db.Query(`
+        SELECT address, symbol, COALESCE(pair, ''), COALESCE(peg, '')
+        FROM token_mappings
+        ORDER BY address
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mappings []models.TokenMapping
+	for rows.Next() {
+		var m models.TokenMapping
+		if err := rows.Scan(&m.Address, &m.Symbol, &m.Pair, &m.Peg); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
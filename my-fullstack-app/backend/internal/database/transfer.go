@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// cursorBatchSize is how many rows an export fetches from a Postgres cursor at a time
+const cursorBatchSize = 1000
+
+// streamQuery runs a query without parameters and calls fn with each row. On Postgres the
+// rows are read through a server-side cursor, so a table of any size is streamed without
+// the driver buffering the whole result. SQLite already reads rows as they are scanned.
+func streamQuery(ctx context.Context, db *sql.DB, query string, fn func(*sql.Rows) error) error {
+	if DialectOf(db) == SQLite {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			if err := fn(rows); err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DECLARE export_cursor NO SCROLL CURSOR FOR `+query); err != nil {
+		return err
+	}
+	fetch := `FETCH ` + strconv.Itoa(cursorBatchSize) + ` FROM export_cursor`
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for rows.Next() {
+			n++
+			if err := fn(rows); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if n < cursorBatchSize {
+			break
+		}
+	}
+	return tx.Commit()
+}
+
+// StreamBalances calls fn with every native balance record, ordered by address and time
+func StreamBalances(ctx context.Context, db *sql.DB, fn func(models.BalanceRecord) error) error {
+	return streamQuery(ctx, db, `
+        SELECT `+balanceColumns+`
+        FROM balance_records
+        ORDER BY chain, address, fetched_at, id
+    `, func(rows *sql.Rows) error {
+		record, err := scanBalance(rows)
+		if err != nil {
+			return err
+		}
+		return fn(record)
+	})
+}
+
+// StreamTokenBalances calls fn with every token balance record, ordered by holder, token and time
+func StreamTokenBalances(ctx context.Context, db *sql.DB, fn func(models.TokenBalanceRecord) error) error {
+	return streamQuery(ctx, db, `
+        SELECT `+tokenBalanceColumns+`
+        FROM token_balance_records
+        ORDER BY chain, holder_address, token_address, fetched_at, id
+    `, func(rows *sql.Rows) error {
+		record, err := scanTokenBalance(rows)
+		if err != nil {
+			return err
+		}
+		return fn(record)
+	})
+}
+
+// StreamWatchlists calls fn with every watchlist and each of its addresses, ordered by name
+// and address. A watchlist without addresses is passed once with an empty address.
+func StreamWatchlists(ctx context.Context, db *sql.DB, fn func(name, address string, createdAt time.Time) error) error {
+	return streamQuery(ctx, db, `
+        SELECT w.name, COALESCE(wa.address, ''), w.created_at
+        FROM watchlists w
+        LEFT JOIN watchlist_addresses wa ON wa.watchlist_id = w.id
+        ORDER BY w.name, wa.address
+    `, func(rows *sql.Rows) error {
+		var name, address string
+		var createdAt time.Time
+		if err := rows.Scan(&name, &address, &createdAt); err != nil {
+			return err
+		}
+		return fn(name, address, createdAt)
+	})
+}
+
+// ImportBalance stores a native balance record from an export and returns its ID. A record
+// of the same address that covers the same block, or was fetched at the same time when it
+// has no block, is kept and only extended to the record's last_seen_at and last_seen_block,
+// so importing the same file again changes nothing. The lookup, the store and the extension
+// run in one transaction holding the address's lock, like StoreBalance. valid_to is
+// recomputed as records are stored rather than imported. Imported records write no change
+// events to the outbox, as they restore history rather than report new balances. On
+// Postgres the partition of the record's month is created if it does not exist, as records
+// may be older than the partitions kept ahead of time.
+func ImportBalance(db *sql.DB, record models.BalanceRecord) (int, error) {
+	if DialectOf(db) == Postgres {
+		if err := ensurePartition(context.Background(), db, record.FetchedAt.UTC()); err != nil {
+			return 0, err
+		}
+	}
+	return storeBalance(context.Background(), db, record, storeImported, false)
+}
+
+// extendImportedBalance extends a stored record to the last_seen_at and last_seen_block of
+// an imported one
+func extendImportedBalance(tx *sql.Tx, id int, record models.BalanceRecord) error {
+	lastSeenAt := record.LastSeenAt.UTC()
+	if lastSeenAt.Before(record.FetchedAt) {
+		lastSeenAt = record.FetchedAt
+	}
+	lastSeenBlock := nullBlock(record.LastSeenBlock)
+	if !lastSeenBlock.Valid {
+		lastSeenBlock = nullBlock(record.BlockNumber)
+	}
+	_, err := tx.Exec(`
+        UPDATE balance_records SET
+            last_seen_at = CASE WHEN last_seen_at < $2 THEN $2 ELSE last_seen_at END,
+            last_seen_block = CASE
+                WHEN CAST($3 AS BIGINT) IS NOT NULL
+                    AND (last_seen_block IS NULL OR last_seen_block < CAST($3 AS BIGINT)) THEN CAST($3 AS BIGINT)
+                ELSE last_seen_block
+            END
+        WHERE id = $1
+    `, id, lastSeenAt, lastSeenBlock)
+	if err != nil {
+		log.Printf("Error extending imported balance record: %v", err)
+	}
+	return err
+}
+
+// ImportTokenBalance stores a token balance record from an export and returns its ID. A
+// record of the same holder and token fetched at the same time is updated instead, so
+// importing the same file again changes nothing. Like ImportBalance, it writes no change
+// events to the outbox.
+func ImportTokenBalance(db *sql.DB, record models.TokenBalanceRecord) (int, error) {
+	if record.Chain == "" {
+		record.Chain = models.ChainEthereum
+	}
+	record.TokenAddress = strings.ToLower(record.TokenAddress)
+	record.FetchedAt = record.FetchedAt.UTC()
+
+	var valueUSD sql.NullFloat64
+	if record.ValueUSD != nil {
+		valueUSD = sql.NullFloat64{Float64: *record.ValueUSD, Valid: true}
+	}
+
+	var id int
+	err := db.QueryRow(`
+        UPDATE token_balance_records SET
+            raw_amount = $5, decimals = $6, block_number = $7, value_usd = $8, price_source = $9
+        WHERE chain = $1 AND holder_address = $2 AND token_address = $3 AND fetched_at = $4
+        RETURNING id
+    `, record.Chain, record.Address, record.TokenAddress, record.FetchedAt,
+		record.Balance, record.Decimals, nullBlock(record.BlockNumber), valueUSD, record.PriceSource,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		ids, err := storeTokenBalances(context.Background(), db, []models.TokenBalanceRecord{record}, false)
+		if err != nil {
+			return 0, err
+		}
+		return ids[0], nil
+	}
+	if err != nil {
+		log.Printf("Error updating imported token balance record: %v", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// AddWatchlistAddress adds an address to a watchlist, creating the watchlist with the given
+// creation time if it has not been saved. An empty address only creates the watchlist, and
+// a zero time means now.
+func AddWatchlistAddress(db *sql.DB, name, address string, createdAt time.Time) error {
+	var id int
+	err := db.QueryRow(`
+        INSERT INTO watchlists (name, created_at) VALUES ($1, COALESCE($2, CURRENT_TIMESTAMP))
+        ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+        RETURNING id
+    `, name, nullTime(createdAt.UTC())).Scan(&id)
+	if err != nil {
+		log.Printf("Error saving watchlist %s: %v", name, err)
+		return err
+	}
+	if address == "" {
+		return nil
+	}
+
+	_, err = db.Exec(`
+        INSERT INTO watchlist_addresses (watchlist_id, address) VALUES ($1, $2)
+        ON CONFLICT (watchlist_id, address) DO NOTHING
+    `, id, address)
+	if err != nil {
+		log.Printf("Error adding %s to watchlist %s: %v", address, name, err)
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// ErrWatchlistNotFound is returned for a watchlist name that has not been saved
+var ErrWatchlistNotFound = errors.New("watchlist not found")
+
+// SaveWatchlist creates a watchlist or replaces the addresses of an existing one
+func SaveWatchlist(db *sql.DB, name string, addresses []string) (models.Watchlist, error) {
+	watchlist := models.Watchlist{Name: name}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return watchlist, err
+	}
+
+	err = tx.QueryRow(`
+        INSERT INTO watchlists (name) VALUES ($1)
+        ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+        RETURNING id, created_at
+    `, name).Scan(&watchlist.ID, scanTime{&watchlist.CreatedAt})
+	if err != nil {
+		log.Printf("Error saving watchlist %s: %v", name, err)
+		tx.Rollback()
+		return watchlist, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM watchlist_addresses WHERE watchlist_id = $1`, watchlist.ID); err != nil {
+		tx.Rollback()
+		return watchlist, err
+	}
+
+	stmt, err := tx.Prepare(`
+        INSERT INTO watchlist_addresses (watchlist_id, address) VALUES ($1, $2)
+        ON CONFLICT (watchlist_id, address) DO NOTHING
+    `)
+	if err != nil {
+		tx.Rollback()
+		return watchlist, err
+	}
+	defer stmt.Close()
+
+	for _, address := range addresses {
+		if _, err := stmt.Exec(watchlist.ID, address); err != nil {
+			log.Printf("Error adding %s to watchlist %s: %v", address, name, err)
+			tx.Rollback()
+			return watchlist, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return watchlist, err
+	}
+
+	return GetWatchlist(db, name)
+}
+
+// GetWatchlist retrieves a watchlist with its addresses in order
+func GetWatchlist(db *sql.DB, name string) (models.Watchlist, error) {
+	watchlist := models.Watchlist{Name: name, Addresses: []string{}}
+
+	err := db.QueryRow(`SELECT id, created_at FROM watchlists WHERE name = $1`, name).
+		Scan(&watchlist.ID, &watchlist.CreatedAt)
+	if err == sql.ErrNoRows {
+		return watchlist, ErrWatchlistNotFound
+	}
+	if err != nil {
+		return watchlist, err
+	}
+
+	rows, err := db.Query(`
+        SELECT address FROM watchlist_addresses
+        WHERE watchlist_id = $1
+        ORDER BY address
+    `, watchlist.ID)
+	if err != nil {
+		return watchlist, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return watchlist, err
+		}
+		watchlist.Addresses = append(watchlist.Addresses, address)
+	}
+
+	return watchlist, rows.Err()
+}
+
+// GetWatchlistTotal sums the latest stored balance of every address on a watchlist
+func GetWatchlistTotal(db *sql.DB, name string) (models.BalanceTotal, error) {
+	var total models.BalanceTotal
+
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM watchlists WHERE name = $1)`, name).Scan(&exists); err != nil {
+		return total, err
+	}
+	if !exists {
+		return total, ErrWatchlistNotFound
+	}
+
+	if DialectOf(db) == SQLite {
+		return sumWatchlistBalances(db, name)
+	}
+
+	query := `
+        SELECT COUNT(*), COALESCE(SUM(balance), 0)::TEXT, COALESCE(SUM(balance_eth), 0)::NUMERIC(78, 18)::TEXT
+        FROM (
+            SELECT b.balance, b.balance_eth
+            FROM balance_records b
+            JOIN watchlist_addresses wa ON wa.address = b.address
+            JOIN watchlists w ON w.id = wa.watchlist_id
+            WHERE w.name = $1 AND b.valid_to IS NULL
+        ) AS latest
+    `
+
+	err := db.QueryRow(query, name).Scan(&total.Addresses, &total.Balance, &total.BalanceETH)
+	if err != nil {
+		log.Printf("Error totalling watchlist %s: %v", name, err)
+	}
+	return total, err
+}
+
+// sumWatchlistBalances totals a watchlist in Go, for SQLite, which cannot sum wei amounts
+// without losing precision
+func sumWatchlistBalances(db *sql.DB, name string) (models.BalanceTotal, error) {
+	total := models.BalanceTotal{}
+
+	rows, err := db.Query(`
+        SELECT CAST(b.balance AS TEXT)
+        FROM balance_records b
+        JOIN watchlist_addresses wa ON wa.address = b.address
+        JOIN watchlists w ON w.id = wa.watchlist_id
+        WHERE w.name = $1 AND b.valid_to IS NULL
+    `, name)
+	if err != nil {
+		log.Printf("Error totalling watchlist %s: %v", name, err)
+		return total, err
+	}
+	defer rows.Close()
+
+	sum := new(big.Int)
+	for rows.Next() {
+		var balance string
+		if err := rows.Scan(&balance); err != nil {
+			return total, err
+		}
+		wei, ok := new(big.Int).SetString(balance, 10)
+		if !ok {
+			return total, fmt.Errorf("invalid wei balance %q", balance)
+		}
+		sum.Add(sum, wei)
+		total.Addresses++
+	}
+	if err := rows.Err(); err != nil {
+		return total, err
+	}
+
+	total.Balance = sum.String()
+	total.BalanceETH = FormatUnits(total.Balance, 18)
+	return total, nil
+}
@@ -0,0 +1,228 @@
+package fx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	// ECB reference rates history, a zipped CSV of EUR-based rates since 1999
+	ecbHistoryURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.zip"
+
+	ecbDateFormat = "2006-01-02"
+)
+
+// ECBProvider fetches daily reference rates published by the European Central Bank
+type ECBProvider struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewECBProvider creates a provider backed by the ECB reference rate history
+func NewECBProvider() *ECBProvider {
+	return &ECBProvider{
+		url:        ecbHistoryURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider identifier stored alongside its rates
+func (p *ECBProvider) Name() string {
+	return "ecb"
+}
+
+// Rates downloads the ECB history and returns USD-based rates between from and to
+func (p *ECBProvider) Rates(ctx context.Context, from, to time.Time) ([]models.FXRate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download ECB rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download ECB rates: unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ECB rates: %w", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ECB rates archive: %w", err)
+	}
+
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".csv") {
+			continue
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		rates, err := ParseECBCSV(f, p.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		return filterRates(rates, from, to), nil
+	}
+
+	return nil, fmt.Errorf("no CSV file found in ECB rates archive")
+}
+
+// CSVFileProvider serves rates from a local ECB-style CSV file, for offline use
+type CSVFileProvider struct {
+	path string
+}
+
+// NewCSVFileProvider creates a provider reading rates from an ECB-style CSV file
+func NewCSVFileProvider(path string) *CSVFileProvider {
+	return &CSVFileProvider{path: path}
+}
+
+// Name returns the provider identifier stored alongside its rates
+func (p *CSVFileProvider) Name() string {
+	return "csv"
+}
+
+// Rates reads the CSV file and returns USD-based rates between from and to
+func (p *CSVFileProvider) Rates(ctx context.Context, from, to time.Time) ([]models.FXRate, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fx rates file: %w", err)
+	}
+	defer f.Close()
+
+	rates, err := ParseECBCSV(f, p.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	return filterRates(rates, from, to), nil
+}
+
+// ParseECBCSV parses ECB reference rates in CSV form and rebases them on USD.
+// The expected layout is a header row "Date,USD,JPY,..." followed by one row per day,
+// where each value is the amount of that currency per 1 EUR and "N/A" marks a gap.
+func ParseECBCSV(r io.Reader, source string) ([]models.FXRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	if len(header) == 0 || !strings.EqualFold(strings.TrimSpace(header[0]), "Date") {
+		return nil, fmt.Errorf("unexpected CSV header: first column must be Date")
+	}
+
+	usdColumn := -1
+	for i, name := range header {
+		header[i] = strings.ToUpper(strings.TrimSpace(name))
+		if header[i] == USD {
+			usdColumn = i
+		}
+	}
+
+	if usdColumn < 0 {
+		return nil, fmt.Errorf("CSV has no USD column to rebase rates on")
+	}
+
+	fetchedAt := time.Now()
+	var rates []models.FXRate
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+
+		date, err := time.Parse(ecbDateFormat, strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date on CSV line %d: %w", line, err)
+		}
+
+		if usdColumn >= len(record) {
+			continue
+		}
+		eurUSD, err := strconv.ParseFloat(strings.TrimSpace(record[usdColumn]), 64)
+		if err != nil || eurUSD <= 0 {
+			// No USD fixing that day, so nothing can be rebased
+			continue
+		}
+
+		// EUR itself is the implicit base of the file
+		rates = append(rates, models.FXRate{
+			Currency:  "EUR",
+			Date:      date,
+			Rate:      1 / eurUSD,
+			Source:    source,
+			FetchedAt: fetchedAt,
+		})
+
+		for i := 1; i < len(record) && i < len(header); i++ {
+			if i == usdColumn || header[i] == "" {
+				continue
+			}
+
+			eurRate, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64)
+			if err != nil || eurRate <= 0 {
+				continue
+			}
+
+			rates = append(rates, models.FXRate{
+				Currency:  header[i],
+				Date:      date,
+				Rate:      eurRate / eurUSD,
+				Source:    source,
+				FetchedAt: fetchedAt,
+			})
+		}
+	}
+
+	logger.Debug().
+		Int("rates", len(rates)).
+		Str("source", source).
+		Msg("Parsed ECB-style fx rates")
+
+	return rates, nil
+}
+
+// filterRates keeps the rates dated within [from, to]
+func filterRates(rates []models.FXRate, from, to time.Time) []models.FXRate {
+	var filtered []models.FXRate
+	for _, rate := range rates {
+		if rate.Date.Before(from) || rate.Date.After(to) {
+			continue
+		}
+		filtered = append(filtered, rate)
+	}
+	return filtered
+}
@@ -0,0 +1,241 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	// USD is the currency all market valuations are denominated in
+	USD = "USD"
+
+	// How far back to look for a published rate, covering weekends and bank holidays
+	maxRateAge = 7 * 24 * time.Hour
+
+	// How long a rate from an earlier day stands in for a recent day that has no rate yet
+	fallbackRateTTL = time.Hour
+
+	// Age after which a day without a rate will not get one, so its fallback is final
+	rateSettleAge = 48 * time.Hour
+)
+
+var (
+	// ErrInvalidCurrency is returned for currency codes that are not ISO 4217 shaped
+	ErrInvalidCurrency = errors.New("invalid currency code")
+	// ErrRateNotFound is returned when no rate is available for a currency and date
+	ErrRateNotFound = errors.New("exchange rate not found")
+
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Provider is a source of daily USD-based exchange rates
+type Provider interface {
+	// Name identifies the provider in stored rates
+	Name() string
+	// Rates returns all rates published between from and to, inclusive
+	Rates(ctx context.Context, from, to time.Time) ([]models.FXRate, error)
+}
+
+// RateStore persists daily exchange rates
+type RateStore interface {
+	SaveRates(ctx context.Context, rates []models.FXRate) error
+	// LatestRate returns the most recent rate on or before date, no older than maxAge
+	LatestRate(ctx context.Context, currency string, date time.Time, maxAge time.Duration) (models.FXRate, error)
+}
+
+// Conversion describes a USD amount converted into another currency
+type Conversion struct {
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	Rate     float64   `json:"rate"`     // Units of currency per 1 USD
+	RateDate time.Time `json:"rateDate"` // Day of the rate that was applied
+	Source   string    `json:"source,omitempty"`
+}
+
+// Service converts USD values into other fiat currencies using historical daily rates
+type Service struct {
+	provider Provider
+	store    RateStore
+	now      func() time.Time
+
+	mu    sync.RWMutex
+	cache map[string]cachedRate
+}
+
+// cachedRate is a rate kept in memory, until expires unless that is zero
+type cachedRate struct {
+	rate    models.FXRate
+	expires time.Time
+}
+
+// NewService creates a conversion service. The store may be nil, in which case
+// rates fetched from the provider are only cached in memory.
+func NewService(provider Provider, store RateStore) *Service {
+	logger.Info().
+		Str("provider", provider.Name()).
+		Msg("FX rate service initialized")
+
+	return &Service{
+		provider: provider,
+		store:    store,
+		now:      time.Now,
+		cache:    make(map[string]cachedRate),
+	}
+}
+
+// NormalizeCurrency upper-cases and validates a currency code
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if !currencyPattern.MatchString(currency) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	return currency, nil
+}
+
+// Rate returns the rate applicable to a currency on a given day
+func (s *Service) Rate(ctx context.Context, currency string, date time.Time) (models.FXRate, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return models.FXRate{}, err
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	if currency == USD {
+		return models.FXRate{Currency: USD, Date: day, Rate: 1, Source: "identity"}, nil
+	}
+
+	key := currency + "|" + day.Format(ecbDateFormat)
+
+	now := s.now()
+	s.mu.RLock()
+	cached, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok && (cached.expires.IsZero() || now.Before(cached.expires)) {
+		return cached.rate, nil
+	}
+
+	rate, err := s.lookup(ctx, currency, day)
+	if err != nil {
+		return models.FXRate{}, err
+	}
+
+	// A fallback rate from an earlier day is only kept for a while when the day is recent,
+	// so a later publication is picked up without fetching the rates on every request
+	cached = cachedRate{rate: rate}
+	if !rate.Date.Equal(day) && now.Sub(day) < rateSettleAge {
+		cached.expires = now.Add(fallbackRateTTL)
+	}
+	s.mu.Lock()
+	s.cache[key] = cached
+	s.mu.Unlock()
+
+	return rate, nil
+}
+
+// lookup checks the store first and falls back to the provider, persisting what it fetches.
+// A stored rate of an earlier day than a recent one only stands in for it: the provider is
+// asked for a later publication, and the stored rate is used if there is none.
+func (s *Service) lookup(ctx context.Context, currency string, day time.Time) (models.FXRate, error) {
+	var stored *models.FXRate
+	if s.store != nil {
+		rate, err := s.store.LatestRate(ctx, currency, day, maxRateAge)
+		if err == nil {
+			if rate.Date.Equal(day) || s.now().Sub(day) >= rateSettleAge {
+				return rate, nil
+			}
+			stored = &rate
+		} else {
+			logger.Debug().
+				Err(err).
+				Str("currency", currency).
+				Time("date", day).
+				Msg("FX rate not in store, fetching from provider")
+		}
+	}
+
+	rates, err := s.provider.Rates(ctx, day.Add(-maxRateAge), day)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("provider", s.provider.Name()).
+			Msg("Failed to fetch fx rates")
+		if stored != nil {
+			return *stored, nil
+		}
+		return models.FXRate{}, fmt.Errorf("failed to fetch fx rates: %w", err)
+	}
+
+	if s.store != nil && len(rates) > 0 {
+		if err := s.store.SaveRates(ctx, rates); err != nil {
+			// Not critical, the rates are still usable for this request
+			logger.Warn().Err(err).Msg("Failed to persist fx rates")
+		}
+	}
+
+	best := stored
+	for i := range rates {
+		if rates[i].Currency != currency || rates[i].Date.After(day) {
+			continue
+		}
+		if best == nil || rates[i].Date.After(best.Date) {
+			best = &rates[i]
+		}
+	}
+
+	if best == nil {
+		return models.FXRate{}, fmt.Errorf("%w: %s on %s", ErrRateNotFound, currency, day.Format(ecbDateFormat))
+	}
+
+	return *best, nil
+}
+
+// Convert converts a USD amount into currency at the rate applicable on date
+func (s *Service) Convert(ctx context.Context, amountUSD float64, currency string, date time.Time) (*Conversion, error) {
+	rate, err := s.Rate(ctx, currency, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conversion{
+		Currency: rate.Currency,
+		Amount:   amountUSD * rate.Rate,
+		Rate:     rate.Rate,
+		RateDate: rate.Date,
+		Source:   rate.Source,
+	}, nil
+}
+
+// ImportCSV loads ECB-style CSV rates into the store
+func (s *Service) ImportCSV(ctx context.Context, r io.Reader) (int, error) {
+	if s.store == nil {
+		return 0, errors.New("no rate store configured")
+	}
+
+	rates, err := ParseECBCSV(r, "csv")
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.store.SaveRates(ctx, rates); err != nil {
+		return 0, fmt.Errorf("failed to store imported rates: %w", err)
+	}
+
+	// Imported rates may supersede cached ones
+	s.mu.Lock()
+	s.cache = make(map[string]cachedRate)
+	s.mu.Unlock()
+
+	logger.Info().Int("rates", len(rates)).Msg("Imported fx rates from CSV")
+
+	return len(rates), nil
+}
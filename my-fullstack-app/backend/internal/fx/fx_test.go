@@ -0,0 +1,242 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+const ecbFixture = `Date,USD,JPY,GBP,CYP,
+2024-01-05,1.0921,158.47,0.86053,N/A,
+2024-01-04,1.0953,158.23,0.86168,N/A,
+`
+
+// staticProvider serves a fixed set of rates and counts how often it is asked
+type staticProvider struct {
+	rates []models.FXRate
+	calls int
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) Rates(ctx context.Context, from, to time.Time) ([]models.FXRate, error) {
+	p.calls++
+	return filterRates(p.rates, from, to), nil
+}
+
+func TestParseECBCSV(t *testing.T) {
+	rates, err := ParseECBCSV(strings.NewReader(ecbFixture), "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// EUR, JPY and GBP for each of the two days; CYP has no values
+	if len(rates) != 6 {
+		t.Fatalf("Expected 6 rates, got %d: %+v", len(rates), rates)
+	}
+
+	testCases := []struct {
+		currency string
+		want     float64
+	}{
+		{currency: "EUR", want: 1 / 1.0921},
+		{currency: "JPY", want: 158.47 / 1.0921},
+		{currency: "GBP", want: 0.86053 / 1.0921},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.currency, func(t *testing.T) {
+			for _, rate := range rates {
+				if rate.Currency != tc.currency || rate.Date.Format(ecbDateFormat) != "2024-01-05" {
+					continue
+				}
+				if math.Abs(rate.Rate-tc.want) > 1e-12 {
+					t.Errorf("Expected rate %f, got %f", tc.want, rate.Rate)
+				}
+				return
+			}
+			t.Errorf("No rate found for %s", tc.currency)
+		})
+	}
+}
+
+func TestParseECBCSVRequiresUSD(t *testing.T) {
+	_, err := ParseECBCSV(strings.NewReader("Date,JPY\n2024-01-05,158.47\n"), "test")
+	if err == nil {
+		t.Error("Expected error for CSV without a USD column")
+	}
+}
+
+// memoryStore keeps saved rates in memory, like the database store
+type memoryStore struct {
+	rates []models.FXRate
+}
+
+func (s *memoryStore) SaveRates(ctx context.Context, rates []models.FXRate) error {
+	s.rates = append(s.rates, rates...)
+	return nil
+}
+
+func (s *memoryStore) LatestRate(ctx context.Context, currency string, date time.Time, maxAge time.Duration) (models.FXRate, error) {
+	var best models.FXRate
+	for _, rate := range filterRates(s.rates, date.Add(-maxAge), date) {
+		if rate.Currency == currency && rate.Date.After(best.Date) {
+			best = rate
+		}
+	}
+	if best.Currency == "" {
+		return models.FXRate{}, ErrRateNotFound
+	}
+	return best, nil
+}
+
+func TestServiceConvert(t *testing.T) {
+	rates, err := ParseECBCSV(strings.NewReader(ecbFixture), "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	provider := &staticProvider{rates: rates}
+	service := NewService(provider, nil)
+	ctx := context.Background()
+
+	friday := time.Date(2024, 1, 5, 15, 30, 0, 0, time.UTC)
+	sunday := time.Date(2024, 1, 7, 9, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		currency string
+		date     time.Time
+		wantRate float64
+		wantDay  string
+		wantErr  error
+	}{
+		{name: "USD is identity", currency: "usd", date: friday, wantRate: 1, wantDay: "2024-01-05"},
+		{name: "Same day rate", currency: "EUR", date: friday, wantRate: 1 / 1.0921, wantDay: "2024-01-05"},
+		{name: "Weekend uses last fixing", currency: "GBP", date: sunday, wantRate: 0.86053 / 1.0921, wantDay: "2024-01-05"},
+		{name: "Unknown currency", currency: "CHF", date: friday, wantErr: ErrRateNotFound},
+		{name: "Malformed currency", currency: "EURO", date: friday, wantErr: ErrInvalidCurrency},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conversion, err := service.Convert(ctx, 100, tc.currency, tc.date)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if math.Abs(conversion.Rate-tc.wantRate) > 1e-12 {
+				t.Errorf("Expected rate %f, got %f", tc.wantRate, conversion.Rate)
+			}
+			if math.Abs(conversion.Amount-100*tc.wantRate) > 1e-9 {
+				t.Errorf("Expected amount %f, got %f", 100*tc.wantRate, conversion.Amount)
+			}
+			if got := conversion.RateDate.Format(ecbDateFormat); got != tc.wantDay {
+				t.Errorf("Expected rate date %s, got %s", tc.wantDay, got)
+			}
+		})
+	}
+
+	// Exact-day rates are cached after the first lookup
+	calls := provider.calls
+	if _, err := service.Rate(ctx, "EUR", friday); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if provider.calls != calls {
+		t.Errorf("Expected cached rate, provider was called again")
+	}
+}
+
+func TestServiceCachesFallbackRates(t *testing.T) {
+	rates, err := ParseECBCSV(strings.NewReader(ecbFixture), "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	provider := &staticProvider{rates: rates}
+	service := NewService(provider, nil)
+	ctx := context.Background()
+
+	// A recent weekend reuses Friday's rate for a while, then checks for a publication
+	sunday := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	now := sunday.Add(9 * time.Hour)
+	service.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := service.Rate(ctx, "GBP", sunday); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected one fetch for the recent fallback rate, got %d", provider.calls)
+	}
+	now = now.Add(fallbackRateTTL)
+	service.Rate(ctx, "GBP", sunday)
+	if provider.calls != 2 {
+		t.Errorf("Expected the fallback rate fetched again after it expired, got %d fetches", provider.calls)
+	}
+
+	// The fallback of a settled day is kept
+	now = sunday.Add(30 * 24 * time.Hour)
+	saturday := sunday.Add(-24 * time.Hour)
+	service.Rate(ctx, "GBP", saturday)
+	service.Rate(ctx, "GBP", saturday)
+	if provider.calls != 3 {
+		t.Errorf("Expected one fetch for a settled fallback rate, got %d", provider.calls-2)
+	}
+}
+
+// A stored fallback rate does not stop a recent day from getting its own rate
+func TestServiceRefreshesStoredFallbackRates(t *testing.T) {
+	rates, err := ParseECBCSV(strings.NewReader(ecbFixture), "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	provider := &staticProvider{rates: rates}
+	store := &memoryStore{rates: rates}
+	service := NewService(provider, store)
+	ctx := context.Background()
+
+	sunday := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	now := sunday.Add(9 * time.Hour)
+	service.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		rate, err := service.Rate(ctx, "GBP", sunday)
+		if err != nil || rate.Date.Format(ecbDateFormat) != "2024-01-05" {
+			t.Fatalf("Expected Friday's rate, got %+v, %v", rate, err)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected one fetch for the stored fallback rate, got %d", provider.calls)
+	}
+
+	// A rate published for the day replaces the fallback once it expires
+	provider.rates = append(provider.rates,
+		models.FXRate{Currency: "GBP", Date: sunday, Rate: 0.8, Source: "test"})
+	now = now.Add(fallbackRateTTL)
+	rate, err := service.Rate(ctx, "GBP", sunday)
+	if err != nil || !rate.Date.Equal(sunday) || rate.Rate != 0.8 {
+		t.Errorf("Expected the published rate, got %+v, %v", rate, err)
+	}
+
+	// The stored fallback of a settled day is used without asking the provider
+	calls := provider.calls
+	now = sunday.Add(30 * 24 * time.Hour)
+	rate, err = service.Rate(ctx, "GBP", sunday.Add(-24*time.Hour))
+	if err != nil || rate.Date.Format(ecbDateFormat) != "2024-01-05" {
+		t.Errorf("Expected Friday's rate, got %+v, %v", rate, err)
+	}
+	if provider.calls != calls {
+		t.Errorf("Expected the settled fallback served from the store, got %d fetches", provider.calls-calls)
+	}
+}
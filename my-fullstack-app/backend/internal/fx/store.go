@@ -0,0 +1,30 @@
+package fx
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// DatabaseStore persists rates in the fx_rates Postgres table
+type DatabaseStore struct {
+	db *sql.DB
+}
+
+// NewDatabaseStore creates a rate store backed by Postgres
+func NewDatabaseStore(db *sql.DB) *DatabaseStore {
+	return &DatabaseStore{db: db}
+}
+
+// SaveRates upserts rates into the database
+func (s *DatabaseStore) SaveRates(ctx context.Context, rates []models.FXRate) error {
+	return database.StoreFXRates(s.db, rates)
+}
+
+// LatestRate returns the most recent stored rate on or before date
+func (s *DatabaseStore) LatestRate(ctx context.Context, currency string, date time.Time, maxAge time.Duration) (models.FXRate, error) {
+	return database.GetFXRate(s.db, currency, date, maxAge)
+}
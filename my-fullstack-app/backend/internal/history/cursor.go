@@ -0,0 +1,42 @@
+package history
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor makes an opaque, URL-safe cursor from the position of a page's last point
+func encodeCursor(c database.HistoryCursor) string {
+	raw := strconv.FormatInt(c.Time.UnixNano(), 10) + "." + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor reverses encodeCursor
+func decodeCursor(s string) (database.HistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return database.HistoryCursor{}, errInvalidCursor
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return database.HistoryCursor{}, errInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return database.HistoryCursor{}, errInvalidCursor
+	}
+	recordID, err := strconv.Atoi(id)
+	if err != nil {
+		return database.HistoryCursor{}, errInvalidCursor
+	}
+
+	return database.HistoryCursor{Time: time.Unix(0, n).UTC(), ID: recordID}, nil
+}
@@ -0,0 +1,177 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultPageSize = 500
+	maxPageSize     = 1000
+)
+
+// Point is one point of a balance history, with the ETH value as a number for charting.
+// BalanceETH stays the exact value.
+type Point struct {
+	models.BalancePoint
+	ETH float64 `json:"eth"`
+}
+
+// Page is one page of an address's balance history
+type Page struct {
+	Address    string  `json:"address"`
+	Bucket     string  `json:"bucket,omitempty"`
+	Order      string  `json:"order"`
+	Points     []Point `json:"points"`
+	NextCursor string  `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// Handler serves the stored balance history of addresses
+type Handler struct {
+	balances database.BalanceRepository
+}
+
+// NewHandler creates a balance history handler
+func NewHandler(balances database.BalanceRepository) *Handler {
+	logger.Info().Msg("Balance history handler initialized")
+
+	return &Handler{
+		balances: balances,
+	}
+}
+
+// GetHistoryHandler returns a page of the stored balance history of an address
+// @Summary Get address balance history
+// @Description Returns the stored ETH balances of an address in time order, one page at a time. With a bucket, each point is the last balance of its hour, day or week (UTC, weeks start on Monday) and is timed at the bucket start. Pass next_cursor back as cursor, with the same other parameters, to get the next page.
+// @Tags history
+// @Accept json
+// @Produce json
+// @Param address path string true "Ethereum address (0x format)"
+// @Param from query string false "Start of the range, inclusive (RFC3339 or unix seconds)"
+// @Param to query string false "End of the range, exclusive (RFC3339 or unix seconds)"
+// @Param bucket query string false "Downsample to the last value per bucket: 1h, 1d or 1w"
+// @Param order query string false "asc (default) or desc"
+// @Param limit query int false "Points per page (default 500, max 1000)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Router /addresses/{address}/history [get]
+func (h *Handler) GetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address := mux.Vars(r)["address"]
+	if !common.IsHexAddress(address) {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Ethereum address format")
+		return
+	}
+
+	q := r.URL.Query()
+	query := database.HistoryQuery{Address: address, Limit: defaultPageSize}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if query.From, err = parseTimestamp(v); err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid from. Use RFC3339 or unix seconds")
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if query.To, err = parseTimestamp(v); err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid to. Use RFC3339 or unix seconds")
+			return
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		api.RespondWithError(w, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	if query.Bucket, err = database.ParseHistoryBucket(q.Get("bucket")); err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid bucket. Use 1h, 1d or 1w")
+		return
+	}
+
+	order := q.Get("order")
+	switch order {
+	case "", "asc":
+		order = "asc"
+	case "desc":
+		query.Descending = true
+	default:
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid order. Use asc or desc")
+		return
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid limit. Use 1 to 1000")
+			return
+		}
+		query.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		query.After = &cursor
+	}
+
+	// One extra point tells whether there is a next page
+	pageSize := query.Limit
+	query.Limit++
+	points, err := h.balances.BalanceHistory(r.Context(), query)
+	if err != nil {
+		logger.Error().Err(err).Str("address", address).Msg("Failed to get balance history")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to get balance history")
+		return
+	}
+
+	page := Page{
+		Address: address,
+		Bucket:  string(query.Bucket),
+		Order:   order,
+		Points:  make([]Point, 0, len(points)),
+	}
+	if len(points) > pageSize {
+		points = points[:pageSize]
+		last := points[len(points)-1]
+		page.NextCursor = encodeCursor(database.HistoryCursor{Time: last.Time, ID: last.RecordID})
+	}
+	for _, p := range points {
+		eth, _ := strconv.ParseFloat(p.BalanceETH, 64)
+		page.Points = append(page.Points, Point{BalancePoint: p, ETH: eth})
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Balance history retrieved successfully",
+		Data:    page,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// parseTimestamp parses an RFC3339 timestamp or a unix time in seconds
+func parseTimestamp(value string) (time.Time, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
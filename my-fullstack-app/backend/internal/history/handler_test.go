@@ -0,0 +1,136 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+const testAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+// newTestRouter serves the history of testAddress: one ETH more every 6 hours for 3 days,
+// starting on Monday 2024-01-01
+func newTestRouter(t *testing.T) *mux.Router {
+	repo := database.NewMemoryRepository()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		record := models.BalanceRecord{
+			Address:   testAddress,
+			Balance:   strconv.Itoa(i+1) + "000000000000000000",
+			FetchedAt: start.Add(time.Duration(i) * 6 * time.Hour),
+		}
+		if _, err := repo.StoreBalance(context.Background(), record); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/addresses/{address}/history", NewHandler(repo).GetHistoryHandler)
+	return router
+}
+
+func getPage(t *testing.T, router *mux.Router, target string) (int, Page) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+
+	var page Page
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &api.Response{Data: &page}); err != nil {
+			t.Fatalf("Could not parse response body: %v", err)
+		}
+	}
+	return rr.Code, page
+}
+
+func TestGetHistoryPagination(t *testing.T) {
+	router := newTestRouter(t)
+	base := "/api/addresses/" + testAddress + "/history?limit=5"
+
+	var all []Point
+	target := base
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Expected pagination to end")
+		}
+		status, page := getPage(t, router, target)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", status)
+		}
+		all = append(all, page.Points...)
+		if page.NextCursor == "" {
+			break
+		}
+		target = base + "&cursor=" + page.NextCursor
+	}
+
+	if len(all) != 12 {
+		t.Fatalf("Expected 12 points over 3 pages, got %d", len(all))
+	}
+	for i, p := range all {
+		if p.ETH != float64(i+1) {
+			t.Errorf("Expected point %d worth %d ETH, got %+v", i, i+1, p)
+		}
+	}
+
+	// Newest first within a range
+	status, page := getPage(t, router, "/api/addresses/"+testAddress+"/history?order=desc&from=2024-01-02T00:00:00Z&to=2024-01-03T00:00:00Z")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(page.Points) != 4 || page.Points[0].ETH != 8 || page.Points[3].ETH != 5 || page.NextCursor != "" {
+		t.Errorf("Expected day 2 newest first, got %+v", page)
+	}
+}
+
+func TestGetHistoryBuckets(t *testing.T) {
+	router := newTestRouter(t)
+
+	status, page := getPage(t, router, "/api/addresses/"+testAddress+"/history?bucket=1d")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", status)
+	}
+	if len(page.Points) != 3 {
+		t.Fatalf("Expected one point per day, got %+v", page.Points)
+	}
+	for i, p := range page.Points {
+		day := time.Date(2024, 1, 1+i, 0, 0, 0, 0, time.UTC)
+		if !p.Time.Equal(day) || p.ETH != float64(4*(i+1)) {
+			t.Errorf("Expected the last balance of %s at its start, got %+v", day.Format("2006-01-02"), p)
+		}
+	}
+
+	_, page = getPage(t, router, "/api/addresses/"+testAddress+"/history?bucket=1w&limit=1")
+	if len(page.Points) != 1 || page.Points[0].ETH != 12 || page.NextCursor != "" {
+		t.Errorf("Expected a single weekly point with the latest balance, got %+v", page)
+	}
+}
+
+func TestGetHistoryValidation(t *testing.T) {
+	router := newTestRouter(t)
+
+	for _, target := range []string{
+		"/api/addresses/0x123/history",
+		"/api/addresses/" + testAddress + "/history?bucket=1m",
+		"/api/addresses/" + testAddress + "/history?order=up",
+		"/api/addresses/" + testAddress + "/history?limit=0",
+		"/api/addresses/" + testAddress + "/history?from=yesterday",
+		"/api/addresses/" + testAddress + "/history?from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z",
+		"/api/addresses/" + testAddress + "/history?cursor=!!",
+	} {
+		if status, _ := getPage(t, router, target); status != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", target, status)
+		}
+	}
+}
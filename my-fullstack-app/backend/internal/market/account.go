@@ -0,0 +1,208 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+
+	"github.com/adshao/go-binance/v2/common"
+)
+
+const (
+	// Transfer types
+	TransferDeposit    = "deposit"
+	TransferWithdrawal = "withdrawal"
+
+	// Binance only serves deposit and withdrawal history in windows of up to 90 days
+	maxTransferWindow = 90 * 24 * time.Hour
+
+	// Layout of withdrawal apply times, in UTC
+	withdrawTimeFormat = "2006-01-02 15:04:05"
+)
+
+var (
+	// ErrNoCredentials is returned for account requests when no API key is configured
+	ErrNoCredentials = errors.New("binance api key and secret are not configured")
+	// ErrPermissionDenied is returned when the API key may not access an endpoint
+	ErrPermissionDenied = errors.New("binance api key lacks permission")
+)
+
+// Binance error codes for rejected keys, signatures and permissions
+var permissionErrorCodes = map[int64]bool{
+	-2014: true, // API-key format invalid
+	-2015: true, // Invalid API-key, IP, or permissions for action
+	-1022: true, // Signature for this request is not valid
+}
+
+// AccountBalance is the spot balance of one asset
+type AccountBalance struct {
+	Asset  string  `json:"asset"`
+	Free   string  `json:"free"`   // Exact decimal as reported by Binance
+	Locked string  `json:"locked"` // Held in open orders
+	Total  float64 `json:"total"`
+}
+
+// Transfer is a deposit to or withdrawal from the exchange account
+type Transfer struct {
+	Type    string    `json:"type"`
+	Asset   string    `json:"asset"`
+	Amount  string    `json:"amount"`
+	Fee     string    `json:"fee,omitempty"`
+	Network string    `json:"network,omitempty"`
+	Address string    `json:"address,omitempty"`
+	TxID    string    `json:"txId,omitempty"`
+	Status  int       `json:"status"` // Binance status code for the transfer type
+	Time    time.Time `json:"time"`
+}
+
+// hasCredentials reports whether signed endpoints can be called
+func (c *Client) hasCredentials() bool {
+	return c.binanceClient.APIKey != "" && c.binanceClient.SecretKey != ""
+}
+
+// accountError maps rejected keys and permissions onto ErrPermissionDenied
+func accountError(err error, action string) error {
+	var apiErr *common.APIError
+	if errors.As(err, &apiErr) && (permissionErrorCodes[apiErr.Code] || apiErr.Code == http.StatusUnauthorized) {
+		return fmt.Errorf("%w: %s: %s", ErrPermissionDenied, action, apiErr.Message)
+	}
+	return fmt.Errorf("failed to get %s: %w", action, err)
+}
+
+// GetAccountBalances returns the non-zero spot balances of the account, ordered by asset
+func (c *Client) GetAccountBalances(ctx context.Context) ([]AccountBalance, error) {
+	if !c.hasCredentials() {
+		return nil, ErrNoCredentials
+	}
+
+	account, err := c.binanceClient.NewGetAccountService().OmitZeroBalances(true).Do(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get account balances")
+		return nil, accountError(err, "account balances")
+	}
+
+	balances := make([]AccountBalance, 0, len(account.Balances))
+	for _, b := range account.Balances {
+		free, err := strconv.ParseFloat(b.Free, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse free balance of %s: %w", b.Asset, err)
+		}
+		locked, err := strconv.ParseFloat(b.Locked, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse locked balance of %s: %w", b.Asset, err)
+		}
+
+		// Older API versions ignore omitZeroBalances
+		if free+locked == 0 {
+			continue
+		}
+
+		balances = append(balances, AccountBalance{
+			Asset:  b.Asset,
+			Free:   b.Free,
+			Locked: b.Locked,
+			Total:  free + locked,
+		})
+	}
+
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Asset < balances[j].Asset })
+
+	logger.Info().
+		Int("assets", len(balances)).
+		Msg("Retrieved account balances")
+
+	return balances, nil
+}
+
+// validateTransferWindow checks a history range against what Binance will serve
+func validateTransferWindow(start, end time.Time) error {
+	if !start.Before(end) {
+		return fmt.Errorf("%w: start must be before end", ErrInvalidWindow)
+	}
+	if end.Sub(start) > maxTransferWindow {
+		return fmt.Errorf("%w: transfer history ranges are limited to 90 days", ErrInvalidWindow)
+	}
+	return nil
+}
+
+// GetDeposits returns deposits into the account between start and end
+func (c *Client) GetDeposits(ctx context.Context, start, end time.Time) ([]Transfer, error) {
+	if !c.hasCredentials() {
+		return nil, ErrNoCredentials
+	}
+	if err := validateTransferWindow(start, end); err != nil {
+		return nil, err
+	}
+
+	deposits, err := c.binanceClient.NewListDepositsService().
+		StartTime(start.UnixMilli()).
+		EndTime(end.UnixMilli()).
+		Do(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get deposit history")
+		return nil, accountError(err, "deposit history")
+	}
+
+	transfers := make([]Transfer, 0, len(deposits))
+	for _, d := range deposits {
+		transfers = append(transfers, Transfer{
+			Type:    TransferDeposit,
+			Asset:   d.Coin,
+			Amount:  d.Amount,
+			Network: d.Network,
+			Address: d.Address,
+			TxID:    d.TxID,
+			Status:  d.Status,
+			Time:    time.UnixMilli(d.InsertTime).UTC(),
+		})
+	}
+
+	return transfers, nil
+}
+
+// GetWithdrawals returns withdrawals from the account between start and end
+func (c *Client) GetWithdrawals(ctx context.Context, start, end time.Time) ([]Transfer, error) {
+	if !c.hasCredentials() {
+		return nil, ErrNoCredentials
+	}
+	if err := validateTransferWindow(start, end); err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := c.binanceClient.NewListWithdrawsService().
+		StartTime(start.UnixMilli()).
+		EndTime(end.UnixMilli()).
+		Do(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get withdrawal history")
+		return nil, accountError(err, "withdrawal history")
+	}
+
+	transfers := make([]Transfer, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		applied, err := time.Parse(withdrawTimeFormat, w.ApplyTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse withdrawal time %q: %w", w.ApplyTime, err)
+		}
+
+		transfers = append(transfers, Transfer{
+			Type:    TransferWithdrawal,
+			Asset:   w.Coin,
+			Amount:  w.Amount,
+			Fee:     w.TransactionFee,
+			Network: w.Network,
+			Address: w.Address,
+			TxID:    w.TxID,
+			Status:  w.Status,
+			Time:    applied.UTC(),
+		})
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,166 @@
+package market
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// signedRoute rejects requests that are not signed with the stub client's credentials, the way
+// Binance does, before passing them on to next
+func signedRoute(t *testing.T, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The signature covers the query string up to the signature parameter
+		payload, signature, found := strings.Cut(r.URL.RawQuery, "&signature=")
+		if !found {
+			w.WriteHeader(http.StatusBadRequest)
+			writeStubJSON(w, map[string]interface{}{"code": -1102, "msg": "Mandatory parameter 'signature' was not sent."})
+			return
+		}
+
+		mac := hmac.New(sha256.New, []byte("stub-secret-key"))
+		mac.Write([]byte(payload))
+		if r.Header.Get("X-MBX-APIKEY") != "stub-api-key" || signature != hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeStubJSON(w, map[string]interface{}{"code": -1022, "msg": "Signature for this request is not valid."})
+			return
+		}
+		if r.URL.Query().Get("timestamp") == "" {
+			t.Errorf("Expected signed request to carry a timestamp")
+		}
+
+		next(w, r)
+	}
+}
+
+// memoryAccountStore keeps snapshots in memory
+type memoryAccountStore struct {
+	saved []models.CEXBalanceSnapshot
+}
+
+func (s *memoryAccountStore) SaveSnapshot(ctx context.Context, balances []models.CEXBalanceSnapshot) error {
+	s.saved = append(s.saved, balances...)
+	return nil
+}
+
+func (s *memoryAccountStore) LatestSnapshot(ctx context.Context, exchange, account string) ([]models.CEXBalanceSnapshot, error) {
+	var latest []models.CEXBalanceSnapshot
+	for _, b := range s.saved {
+		if b.Exchange == exchange && b.Account == account {
+			latest = append(latest, b)
+		}
+	}
+	return latest, nil
+}
+
+func newAccountStubClient(t *testing.T) *Client {
+	return newStubClient(t, nil, map[string]http.HandlerFunc{
+		"/api/v3/account": signedRoute(t, func(w http.ResponseWriter, r *http.Request) {
+			writeStubJSON(w, map[string]interface{}{
+				"balances": []map[string]string{
+					{"asset": "USDT", "free": "250.50000000", "locked": "49.50000000"},
+					{"asset": "BTC", "free": "0.01000000", "locked": "0.00000000"},
+					{"asset": "DUST", "free": "0.00000000", "locked": "0.00000000"},
+				},
+			})
+		}),
+		"/sapi/v1/capital/deposit/hisrec": signedRoute(t, func(w http.ResponseWriter, r *http.Request) {
+			writeStubJSON(w, []map[string]interface{}{
+				{"coin": "BTC", "amount": "0.01", "network": "BTC", "status": 1, "txId": "abc", "insertTime": 1709294400000},
+			})
+		}),
+		"/sapi/v1/capital/withdraw/history": signedRoute(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeStubJSON(w, map[string]interface{}{"code": -2015, "msg": "Invalid API-key, IP, or permissions for action."})
+		}),
+	})
+}
+
+func TestGetAccountBalances(t *testing.T) {
+	client := newAccountStubClient(t)
+
+	balances, err := client.GetAccountBalances(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Zero balances are dropped and assets are ordered
+	if len(balances) != 2 || balances[0].Asset != "BTC" || balances[1].Asset != "USDT" {
+		t.Fatalf("Expected BTC and USDT balances, got %+v", balances)
+	}
+	if balances[1].Total != 300 || balances[1].Free != "250.50000000" {
+		t.Errorf("Expected USDT total 300 with exact free balance, got %+v", balances[1])
+	}
+
+	// A client with the wrong secret is rejected by the signature check
+	client.binanceClient.SecretKey = "wrong-secret"
+	if _, err := client.GetAccountBalances(context.Background()); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+
+	// Without keys no request is made
+	client.binanceClient.APIKey, client.binanceClient.SecretKey = "", ""
+	if _, err := client.GetAccountBalances(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected %v, got %v", ErrNoCredentials, err)
+	}
+}
+
+func TestGetTransfers(t *testing.T) {
+	client := newAccountStubClient(t)
+	end := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, -30)
+
+	deposits, err := client.GetDeposits(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(deposits) != 1 || deposits[0].Type != TransferDeposit || deposits[0].Asset != "BTC" {
+		t.Fatalf("Expected one BTC deposit, got %+v", deposits)
+	}
+	if want := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC); !deposits[0].Time.Equal(want) {
+		t.Errorf("Expected deposit time %s, got %s", want, deposits[0].Time)
+	}
+
+	// Keys without withdrawal permission are reported as such
+	if _, err := client.GetWithdrawals(context.Background(), start, end); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("Expected %v, got %v", ErrPermissionDenied, err)
+	}
+
+	if _, err := client.GetDeposits(context.Background(), end.AddDate(0, 0, -91), end); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("Expected %v for a window over 90 days, got %v", ErrInvalidWindow, err)
+	}
+}
+
+func TestAccountTrackerSnapshot(t *testing.T) {
+	store := &memoryAccountStore{}
+	tracker := NewAccountTracker(newAccountStubClient(t), store, "main")
+
+	snapshot, err := tracker.Snapshot(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshot) != 2 || len(store.saved) != 2 {
+		t.Fatalf("Expected 2 stored balances, got %d", len(store.saved))
+	}
+	for _, b := range snapshot {
+		if b.Exchange != exchangeName || b.Account != "main" || !b.TakenAt.Equal(snapshot[0].TakenAt) {
+			t.Errorf("Expected balances of one binance/main snapshot, got %+v", b)
+		}
+	}
+
+	latest, err := tracker.LatestCEXBalances(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(latest) != 2 || latest[1].Asset != "USDT" || latest[1].Locked != "49.50000000" {
+		t.Errorf("Expected the stored snapshot back, got %+v", latest)
+	}
+}
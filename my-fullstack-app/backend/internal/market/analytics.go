@@ -0,0 +1,286 @@
+package market
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+)
+
+const (
+	// BenchmarkSymbol is the pair betas are measured against
+	BenchmarkSymbol = "BTCUSDT"
+
+	// Window bounds in days
+	defaultAnalyticsWindow = 30
+	minAnalyticsWindow     = 3
+	maxAnalyticsWindow     = 365
+
+	// Most symbols a single analytics request may cover
+	maxAnalyticsSymbols = 20
+
+	// Crypto markets trade every day of the year
+	tradingDaysPerYear = 365
+)
+
+// AssetRisk holds the risk metrics of one symbol over the analytics window
+type AssetRisk struct {
+	Symbol       string   `json:"symbol"`
+	Observations int      `json:"observations"`         // Daily closes in the window
+	Return       float64  `json:"return"`               // Simple return from first to last close
+	Volatility   *float64 `json:"volatility,omitempty"` // Annualized standard deviation of daily log returns
+	MaxDrawdown  float64  `json:"maxDrawdown"`          // Largest peak to trough fall, as a fraction of the peak
+	Beta         *float64 `json:"beta,omitempty"`       // Sensitivity of daily returns to the benchmark
+}
+
+// CorrelationMatrix holds pairwise correlations of daily log returns, in Symbols order.
+// Pairs without enough overlapping days are null.
+type CorrelationMatrix struct {
+	Symbols []string     `json:"symbols"`
+	Values  [][]*float64 `json:"values"`
+}
+
+// AnalyticsReport is the result of a risk analytics request
+type AnalyticsReport struct {
+	From         time.Time         `json:"from"`
+	To           time.Time         `json:"to"`
+	Window       int               `json:"window"` // Days
+	Benchmark    string            `json:"benchmark"`
+	Assets       []AssetRisk       `json:"assets"`
+	Correlations CorrelationMatrix `json:"correlations"`
+}
+
+// dailyReturns maps the open time of a day to the log return from the previous close
+type dailyReturns map[int64]float64
+
+// GetAnalytics computes volatility, drawdown, beta to BTC and pairwise correlations
+// from the daily candles of the window days ending with end, exclusive
+func (c *Client) GetAnalytics(ctx context.Context, symbols []string, window int, end time.Time) (*AnalyticsReport, error) {
+	if window == 0 {
+		window = defaultAnalyticsWindow
+	}
+	if window < minAnalyticsWindow || window > maxAnalyticsWindow {
+		return nil, fmt.Errorf("%w: window must be between %d and %d days", ErrInvalidWindow, minAnalyticsWindow, maxAnalyticsWindow)
+	}
+	if len(symbols) == 0 || len(symbols) > maxAnalyticsSymbols {
+		return nil, fmt.Errorf("%w: between 1 and %d symbols are required", ErrInvalidWindow, maxAnalyticsSymbols)
+	}
+
+	// Whole UTC days, matching Binance daily candles
+	to := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -window)
+
+	logger.Debug().
+		Strs("symbols", symbols).
+		Int("window", window).
+		Time("from", from).
+		Time("to", to).
+		Msg("Computing market analytics")
+
+	seen := make(map[string]bool)
+	var requested []string
+	for _, symbol := range symbols {
+		info, err := c.ResolveSymbol(ctx, strings.TrimSpace(symbol))
+		if err != nil {
+			return nil, err
+		}
+		if !seen[info.Symbol] {
+			seen[info.Symbol] = true
+			requested = append(requested, info.Symbol)
+		}
+	}
+
+	// The benchmark is needed for betas even when it was not requested
+	resolved := append([]string{}, requested...)
+	if !seen[BenchmarkSymbol] {
+		resolved = append(resolved, BenchmarkSymbol)
+	}
+
+	closes := make(map[string][]candle, len(resolved))
+	returns := make(map[string]dailyReturns, len(resolved))
+	for _, symbol := range resolved {
+		candles, err := c.getKlines(ctx, symbol, defaultInterval, from, to.Add(-time.Millisecond), window)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("symbol", symbol).
+				Msg("Failed to get daily klines for analytics")
+			return nil, fmt.Errorf("failed to get klines for %s: %w", symbol, err)
+		}
+		closes[symbol] = candles
+		returns[symbol] = logReturns(candles)
+	}
+
+	report := &AnalyticsReport{
+		From:      from,
+		To:        to,
+		Window:    window,
+		Benchmark: BenchmarkSymbol,
+	}
+
+	for _, symbol := range requested {
+		candles := closes[symbol]
+		risk := AssetRisk{
+			Symbol:       symbol,
+			Observations: len(candles),
+			MaxDrawdown:  maxDrawdown(candles),
+		}
+		if len(candles) > 0 && candles[0].close > 0 {
+			risk.Return = candles[len(candles)-1].close/candles[0].close - 1
+		}
+		if vol, ok := stdDev(returnValues(returns[symbol])); ok {
+			vol *= math.Sqrt(tradingDaysPerYear)
+			risk.Volatility = &vol
+		}
+		if b, ok := beta(returns[symbol], returns[BenchmarkSymbol]); ok {
+			risk.Beta = &b
+		}
+		report.Assets = append(report.Assets, risk)
+	}
+
+	report.Correlations = CorrelationMatrix{
+		Symbols: requested,
+		Values:  make([][]*float64, len(requested)),
+	}
+	for i, a := range requested {
+		report.Correlations.Values[i] = make([]*float64, len(requested))
+		for j, b := range requested {
+			if corr, ok := correlation(returns[a], returns[b]); ok {
+				report.Correlations.Values[i][j] = &corr
+			}
+		}
+	}
+
+	logger.Info().
+		Strs("symbols", requested).
+		Int("window", window).
+		Msg("Successfully computed market analytics")
+
+	return report, nil
+}
+
+// logReturns computes daily log returns between consecutive candles
+func logReturns(candles []candle) dailyReturns {
+	returns := make(dailyReturns, len(candles))
+	for i := 1; i < len(candles); i++ {
+		prev, cur := candles[i-1].close, candles[i].close
+		if prev <= 0 || cur <= 0 {
+			continue
+		}
+		returns[candles[i].openTime.UnixMilli()] = math.Log(cur / prev)
+	}
+	return returns
+}
+
+// sortedDays lists the days of a return series in order
+func sortedDays(returns dailyReturns) []int64 {
+	days := make([]int64, 0, len(returns))
+	for day := range returns {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i] < days[j] })
+	return days
+}
+
+// returnValues lists returns in day order
+func returnValues(returns dailyReturns) []float64 {
+	days := sortedDays(returns)
+	values := make([]float64, len(days))
+	for i, day := range days {
+		values[i] = returns[day]
+	}
+	return values
+}
+
+// alignReturns pairs up returns of two series, in day order, on the days both have one
+func alignReturns(a, b dailyReturns) ([]float64, []float64) {
+	var xs, ys []float64
+	for _, day := range sortedDays(a) {
+		if y, ok := b[day]; ok {
+			xs = append(xs, a[day])
+			ys = append(ys, y)
+		}
+	}
+	return xs, ys
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdDev is the sample standard deviation; it needs at least two values
+func stdDev(values []float64) (float64, bool) {
+	if len(values) < 2 {
+		return 0, false
+	}
+	variance, _ := covariance(values, values)
+	return math.Sqrt(variance), true
+}
+
+// covariance is the sample covariance of two equally long series
+func covariance(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0, false
+	}
+
+	mx, my := mean(xs), mean(ys)
+	var sum float64
+	for i := range xs {
+		sum += (xs[i] - mx) * (ys[i] - my)
+	}
+	return sum / float64(len(xs)-1), true
+}
+
+// beta is the covariance of asset and benchmark returns over the benchmark variance
+func beta(asset, benchmark dailyReturns) (float64, bool) {
+	xs, ys := alignReturns(asset, benchmark)
+	cov, ok := covariance(xs, ys)
+	if !ok {
+		return 0, false
+	}
+	variance, _ := covariance(ys, ys)
+	if variance == 0 {
+		return 0, false
+	}
+	return cov / variance, true
+}
+
+// correlation is the Pearson correlation of two return series on their common days
+func correlation(a, b dailyReturns) (float64, bool) {
+	xs, ys := alignReturns(a, b)
+	cov, ok := covariance(xs, ys)
+	if !ok {
+		return 0, false
+	}
+	sx, _ := stdDev(xs)
+	sy, _ := stdDev(ys)
+	if sx == 0 || sy == 0 {
+		return 0, false
+	}
+	// Clamp rounding error so identical series report exactly 1
+	return math.Max(-1, math.Min(1, cov/(sx*sy))), true
+}
+
+// maxDrawdown is the largest fall from a running peak close, as a fraction of that peak
+func maxDrawdown(candles []candle) float64 {
+	var peak, worst float64
+	for _, k := range candles {
+		if k.close > peak {
+			peak = k.close
+		}
+		if peak > 0 {
+			if dd := (peak - k.close) / peak; dd > worst {
+				worst = dd
+			}
+		}
+	}
+	return worst
+}
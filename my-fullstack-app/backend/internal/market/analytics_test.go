@@ -0,0 +1,175 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// dailyFixture builds daily stub candles with the given closes
+func dailyFixture(start time.Time, closes []float64) []stubKline {
+	klines := make([]stubKline, len(closes))
+	for i, c := range closes {
+		price := strconv.FormatFloat(c, 'f', -1, 64)
+		klines[i] = stubKline{
+			openTime: start.AddDate(0, 0, i),
+			interval: 24 * time.Hour,
+			open:     price, high: price, low: price, close: price, volume: "1",
+		}
+	}
+	return klines
+}
+
+func TestMaxDrawdown(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	volumes := []float64{1, 1, 1, 1, 1, 1}
+
+	testCases := []struct {
+		name   string
+		closes []float64
+		want   float64
+	}{
+		{name: "Deepest fall from the running peak", closes: []float64{100, 120, 90, 110, 60, 80}, want: 0.5},
+		{name: "Only rising", closes: []float64{1, 2, 3, 4, 5, 6}, want: 0},
+		{name: "Recovery does not reset the worst fall", closes: []float64{100, 75, 150, 140, 200, 190}, want: 0.25},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := maxDrawdown(fixtureCandles(start, tc.closes, volumes))
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Expected %f, got %f", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReturnStatistics(t *testing.T) {
+	sd, ok := stdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if !ok || math.Abs(sd-math.Sqrt(32.0/7)) > 1e-9 {
+		t.Errorf("Expected sample standard deviation %f, got %f", math.Sqrt(32.0/7), sd)
+	}
+	if _, ok := stdDev([]float64{1}); ok {
+		t.Errorf("Expected no standard deviation for a single value")
+	}
+
+	benchmark := dailyReturns{1: 0.01, 2: -0.02, 3: 0.03, 4: 0.005}
+	doubled := dailyReturns{1: 0.02, 2: -0.04, 3: 0.06, 4: 0.01}
+	inverse := dailyReturns{1: -0.01, 2: 0.02, 3: -0.03, 4: -0.005}
+	flat := dailyReturns{1: 0, 2: 0, 3: 0, 4: 0}
+	partial := dailyReturns{3: 0.03, 9: 0.1}
+
+	testCases := []struct {
+		name     string
+		fn       func(a, b dailyReturns) (float64, bool)
+		a, b     dailyReturns
+		want     float64
+		wantNone bool
+	}{
+		{name: "Beta of a doubled series", fn: beta, a: doubled, b: benchmark, want: 2},
+		{name: "Beta of an inverse series", fn: beta, a: inverse, b: benchmark, want: -1},
+		{name: "Beta against a flat benchmark", fn: beta, a: doubled, b: flat, wantNone: true},
+		{name: "Correlation with itself", fn: correlation, a: benchmark, b: benchmark, want: 1},
+		{name: "Correlation of a scaled series", fn: correlation, a: doubled, b: benchmark, want: 1},
+		{name: "Correlation of an inverse series", fn: correlation, a: inverse, b: benchmark, want: -1},
+		{name: "Correlation with a flat series", fn: correlation, a: flat, b: benchmark, wantNone: true},
+		{name: "Too few common days", fn: correlation, a: partial, b: benchmark, wantNone: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.fn(tc.a, tc.b)
+			if tc.wantNone {
+				if ok {
+					t.Errorf("Expected no value, got %f", got)
+				}
+				return
+			}
+			if !ok || math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Expected %f, got %f (ok=%v)", tc.want, got, ok)
+			}
+		})
+	}
+}
+
+func TestGetAnalytics(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	btc := []float64{100, 110, 99, 108.9, 120}
+
+	// ETH tracks the square of BTC, so its log returns are exactly twice as large
+	eth := make([]float64, len(btc))
+	for i, c := range btc {
+		eth[i] = c * c / 100
+	}
+
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT"},
+		{symbol: "ETHUSDT", base: "ETH", quote: "USDT"},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/klines": func(w http.ResponseWriter, r *http.Request) {
+			series := map[string][]stubKline{
+				"BTCUSDT": dailyFixture(start, btc),
+				"ETHUSDT": dailyFixture(start, eth),
+			}
+			klinesRoute(map[string][]stubKline{"1d": series[r.URL.Query().Get("symbol")]})(w, r)
+		},
+	})
+
+	report, err := client.GetAnalytics(context.Background(), []string{"ethusdt"}, 5, start.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !report.From.Equal(start) || report.Window != 5 || report.Benchmark != BenchmarkSymbol {
+		t.Errorf("Unexpected report window: %+v", report)
+	}
+
+	// The benchmark is fetched for betas but only requested symbols are reported
+	if len(report.Assets) != 1 || report.Assets[0].Symbol != "ETHUSDT" {
+		t.Fatalf("Expected only ETHUSDT in the report, got %+v", report.Assets)
+	}
+
+	asset := report.Assets[0]
+	if asset.Observations != 5 {
+		t.Errorf("Expected 5 observations, got %d", asset.Observations)
+	}
+	if math.Abs(asset.Return-0.44) > 1e-9 {
+		t.Errorf("Expected return 0.44, got %f", asset.Return)
+	}
+	if math.Abs(asset.MaxDrawdown-(1-98.01/121)) > 1e-9 {
+		t.Errorf("Expected max drawdown %f, got %f", 1-98.01/121, asset.MaxDrawdown)
+	}
+	if asset.Beta == nil || math.Abs(*asset.Beta-2) > 1e-9 {
+		t.Errorf("Expected beta 2, got %v", asset.Beta)
+	}
+	if asset.Volatility == nil || *asset.Volatility <= 0 {
+		t.Errorf("Expected a positive volatility, got %v", asset.Volatility)
+	}
+
+	matrix := report.Correlations
+	if len(matrix.Symbols) != 1 || matrix.Values[0][0] == nil || math.Abs(*matrix.Values[0][0]-1) > 1e-9 {
+		t.Errorf("Expected a 1x1 matrix with self-correlation 1, got %+v", matrix)
+	}
+
+	// Pairwise correlation of both assets
+	report, err = client.GetAnalytics(context.Background(), []string{"BTCUSDT", "ETHUSDT"}, 5, start.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if corr := report.Correlations.Values[0][1]; corr == nil || math.Abs(*corr-1) > 1e-9 {
+		t.Errorf("Expected BTC/ETH correlation 1, got %v", corr)
+	}
+
+	_, err = client.GetAnalytics(context.Background(), []string{"ETHUSDT"}, 1000, start)
+	if !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("Expected %v, got %v", ErrInvalidWindow, err)
+	}
+	_, err = client.GetAnalytics(context.Background(), []string{"NOPEUSDT"}, 5, start)
+	if !errors.Is(err, ErrUnknownSymbol) {
+		t.Errorf("Expected %v, got %v", ErrUnknownSymbol, err)
+	}
+}
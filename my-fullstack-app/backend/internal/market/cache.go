@@ -0,0 +1,281 @@
+package market
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// How long a current price is served from memory
+	currentPriceTTL = 5 * time.Second
+
+	// How long a fetch shared by concurrent callers may take, since no caller can cancel it
+	sharedFetchTimeout = 30 * time.Second
+
+	// Upper bound on cached closed candle series
+	maxCandleEntries = 50000
+
+	// Cache name reported in the Cache-Status response header
+	cacheStatusName = "tracker-market"
+)
+
+// cacheStats counts cache outcomes, served at /api/admin/metrics
+var cacheStats = expvar.NewMap("market_cache")
+
+// priceCache keeps current prices for a short TTL and closed candles indefinitely,
+// and coalesces concurrent fetches of the same data into a single Binance call
+type priceCache struct {
+	group singleflight.Group
+
+	mu          sync.Mutex
+	current     map[string]cachedPrice
+	candles     map[string][]candle
+	candleOrder []string
+	ttl         time.Duration
+	timeout     time.Duration // Of a shared fetch
+}
+
+// cachedPrice is a current price and when it stops being served
+type cachedPrice struct {
+	data    PriceData
+	expires time.Time
+}
+
+func newPriceCache(ttl time.Duration) *priceCache {
+	return &priceCache{
+		current: make(map[string]cachedPrice),
+		candles: make(map[string][]candle),
+		ttl:     ttl,
+		timeout: sharedFetchTimeout,
+	}
+}
+
+// sharedContext returns the context of a fetch shared by concurrent callers. It is not
+// cancelled by whichever caller started the fetch, but it times out so a hung request
+// does not block every caller waiting for it.
+func (pc *priceCache) sharedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), pc.timeout)
+}
+
+// getCurrent returns a copy of a fresh cached price
+func (pc *priceCache) getCurrent(symbol string) (*PriceData, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	entry, ok := pc.current[symbol]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	data := entry.data
+	return &data, true
+}
+
+// putCurrent stores a copy of a current price
+func (pc *priceCache) putCurrent(symbol string, data *PriceData) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.current[symbol] = cachedPrice{data: *data, expires: time.Now().Add(pc.ttl)}
+}
+
+// getCandles returns a cached candle series
+func (pc *priceCache) getCandles(key string) ([]candle, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	candles, ok := pc.candles[key]
+	return candles, ok
+}
+
+// putCandles stores a candle series that can no longer change
+func (pc *priceCache) putCandles(key string, candles []candle) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if _, exists := pc.candles[key]; !exists {
+		pc.candleOrder = append(pc.candleOrder, key)
+		if len(pc.candleOrder) > maxCandleEntries {
+			delete(pc.candles, pc.candleOrder[0])
+			pc.candleOrder = pc.candleOrder[1:]
+		}
+	}
+	pc.candles[key] = candles
+}
+
+// cachedCurrentPrice serves a current price from cache, or fetches it once for all concurrent callers
+func (c *Client) cachedCurrentPrice(ctx context.Context, symbol string, fetch func(ctx context.Context) (*PriceData, error)) (*PriceData, error) {
+	symbol = strings.ToUpper(symbol)
+
+	if data, ok := c.cache.getCurrent(symbol); ok {
+		recordCacheHit(ctx)
+		return data, nil
+	}
+
+	v, err, shared := c.cache.group.Do("price|"+symbol, func() (interface{}, error) {
+		ctx, cancel := c.cache.sharedContext(ctx)
+		defer cancel()
+		data, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.cache.putCurrent(symbol, data)
+		return data, nil
+	})
+	recordCacheMiss(ctx, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	data := *v.(*PriceData)
+	return &data, nil
+}
+
+// getKlines fetches candles, serving series made only of closed candles from the permanent cache.
+// A zero end time leaves the end of the range open.
+func (c *Client) getKlines(ctx context.Context, symbol, interval string, start, end time.Time, limit int) ([]candle, error) {
+	key := fmt.Sprintf("klines|%s|%s|%d|%d|%d", symbol, interval, start.UnixMilli(), end.UnixMilli(), limit)
+
+	if candles, ok := c.cache.getCandles(key); ok {
+		recordCacheHit(ctx)
+		return candles, nil
+	}
+
+	v, err, shared := c.cache.group.Do(key, func() (interface{}, error) {
+		service := c.binanceClient.NewKlinesService().
+			Symbol(symbol).
+			Interval(interval).
+			StartTime(start.UnixMilli()).
+			Limit(limit)
+		if !end.IsZero() {
+			service = service.EndTime(end.UnixMilli())
+		}
+
+		ctx, cancel := c.cache.sharedContext(ctx)
+		defer cancel()
+		klines, err := service.Do(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		candles := make([]candle, 0, len(klines))
+		for _, kl := range klines {
+			k, err := parseKline(kl)
+			if err != nil {
+				return nil, err
+			}
+			candles = append(candles, k)
+		}
+
+		if seriesClosed(candles, end) {
+			c.cache.putCandles(key, candles)
+		}
+		return candles, nil
+	})
+	recordCacheMiss(ctx, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]candle), nil
+}
+
+// seriesClosed reports whether a candle series is final: every candle has closed and
+// no later candle could still fall into the requested range
+func seriesClosed(candles []candle, end time.Time) bool {
+	if len(candles) == 0 {
+		return false
+	}
+
+	now := time.Now()
+	if !end.IsZero() && !end.Before(now) {
+		return false
+	}
+	for _, k := range candles {
+		if !k.closeTime.Before(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheRecorderKey is the context key of a request's cacheRecorder
+type cacheRecorderKey struct{}
+
+// cacheRecorder collects cache outcomes of all lookups made while serving a request
+type cacheRecorder struct {
+	mu        sync.Mutex
+	hits      int
+	misses    int
+	collapsed bool
+	stale     bool
+}
+
+// withCacheRecorder attaches a recorder to the context for reporting in Cache-Status
+func withCacheRecorder(ctx context.Context) (context.Context, *cacheRecorder) {
+	rec := &cacheRecorder{}
+	return context.WithValue(ctx, cacheRecorderKey{}, rec), rec
+}
+
+func recorderFrom(ctx context.Context) *cacheRecorder {
+	rec, _ := ctx.Value(cacheRecorderKey{}).(*cacheRecorder)
+	return rec
+}
+
+func recordCacheHit(ctx context.Context) {
+	cacheStats.Add("hits", 1)
+
+	if rec := recorderFrom(ctx); rec != nil {
+		rec.mu.Lock()
+		rec.hits++
+		rec.mu.Unlock()
+	}
+}
+
+func recordCacheMiss(ctx context.Context, collapsed bool) {
+	cacheStats.Add("misses", 1)
+	if collapsed {
+		cacheStats.Add("collapsed", 1)
+	}
+
+	if rec := recorderFrom(ctx); rec != nil {
+		rec.mu.Lock()
+		rec.misses++
+		rec.collapsed = rec.collapsed || collapsed
+		rec.mu.Unlock()
+	}
+}
+
+// recordStaleServed notes that data came from the rate limiter's fallback copy
+func recordStaleServed(ctx context.Context) {
+	cacheStats.Add("stale", 1)
+
+	if rec := recorderFrom(ctx); rec != nil {
+		rec.mu.Lock()
+		rec.stale = true
+		rec.mu.Unlock()
+	}
+}
+
+// Value formats the outcome as an RFC 9211 Cache-Status header value
+func (rec *cacheRecorder) Value() string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	switch {
+	case rec.stale:
+		return cacheStatusName + "; hit; detail=stale-rate-limited"
+	case rec.misses > 0 && rec.collapsed:
+		return cacheStatusName + "; fwd=miss; collapsed"
+	case rec.misses > 0 || rec.hits == 0:
+		return cacheStatusName + "; fwd=miss"
+	default:
+		return cacheStatusName + "; hit"
+	}
+}
@@ -0,0 +1,217 @@
+package market
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countCalls wraps the stub transport behind the client's limiter and counts requests to path.
+// If gate is not nil, matching requests wait for it to close.
+func countCalls(client *Client, path string, gate chan struct{}) *int64 {
+	var calls int64
+	next := client.limiter.next
+	client.limiter.next = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == path {
+			atomic.AddInt64(&calls, 1)
+			if gate != nil {
+				<-gate
+			}
+		}
+		return next.RoundTrip(req)
+	})
+	return &calls
+}
+
+func TestCurrentPriceCoalescesConcurrentRequests(t *testing.T) {
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT", price: "65000"},
+	}, nil)
+
+	// Load exchange info up front so only the price lookups race
+	if _, err := client.ResolveSymbol(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	gate := make(chan struct{})
+	calls := countCalls(client, "/api/v3/ticker/price", gate)
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			price, err := client.GetCurrentPrice(context.Background(), "BTCUSDT")
+			if err == nil && price.Price != 65000 {
+				t.Errorf("Expected price 65000, got %f", price.Price)
+			}
+			errs <- err
+		}()
+	}
+
+	// Give every caller time to join the in-flight fetch before it completes
+	time.Sleep(100 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt64(calls); got != 1 {
+		t.Errorf("Expected 1 upstream price request, got %d", got)
+	}
+}
+
+// A hung shared fetch times out instead of blocking its callers
+func TestSharedFetchTimesOut(t *testing.T) {
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT", price: "65000"},
+	}, nil)
+	if _, err := client.ResolveSymbol(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	client.cache.timeout = 50 * time.Millisecond
+	next := client.limiter.next
+	client.limiter.next = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/api/v3/ticker/price" {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return next.RoundTrip(req)
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.GetCurrentPrice(context.Background(), "BTCUSDT")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Expected the hung fetch to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the shared fetch to time out")
+	}
+}
+
+func TestCurrentPriceServedFromCache(t *testing.T) {
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "ETHUSDT", base: "ETH", quote: "USDT", price: "3000"},
+	}, nil)
+	calls := countCalls(client, "/api/v3/ticker/price", nil)
+
+	ctx, first := withCacheRecorder(context.Background())
+	if _, err := client.GetCurrentPrice(ctx, "ETHUSDT"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, second := withCacheRecorder(context.Background())
+	price, err := client.GetCurrentPrice(ctx, "ethusdt")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Callers get their own copy, so changing one must not affect the cache
+	price.Price = 0
+	if cached, _ := client.GetCurrentPrice(context.Background(), "ETHUSDT"); cached.Price != 3000 {
+		t.Errorf("Expected cached price 3000, got %f", cached.Price)
+	}
+
+	if got := atomic.LoadInt64(calls); got != 1 {
+		t.Errorf("Expected 1 upstream price request, got %d", got)
+	}
+	if got := first.Value(); got != "tracker-market; fwd=miss" {
+		t.Errorf("Expected miss on first lookup, got %q", got)
+	}
+	if got := second.Value(); got != "tracker-market; hit" {
+		t.Errorf("Expected hit on second lookup, got %q", got)
+	}
+}
+
+func TestClosedCandlesCachedPermanently(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT"},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/klines": klinesRoute(map[string][]stubKline{
+			"1d": {{openTime: day, interval: 24 * time.Hour, open: "61000", high: "63000", low: "60000", close: "62000", volume: "10", trades: 100}},
+		}),
+	})
+	calls := countCalls(client, "/api/v3/klines", nil)
+
+	for i := 0; i < 3; i++ {
+		price, err := client.GetHistoricalPrice(context.Background(), "BTCUSDT", day)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if price.Price != 62000 {
+			t.Errorf("Expected price 62000, got %f", price.Price)
+		}
+	}
+
+	if got := atomic.LoadInt64(calls); got != 1 {
+		t.Errorf("Expected 1 upstream klines request, got %d", got)
+	}
+}
+
+func TestSeriesClosed(t *testing.T) {
+	now := time.Now()
+	closed := candle{openTime: now.Add(-2 * time.Hour), closeTime: now.Add(-time.Hour)}
+	open := candle{openTime: now.Add(-time.Minute), closeTime: now.Add(time.Minute)}
+
+	testCases := []struct {
+		name    string
+		candles []candle
+		end     time.Time
+		want    bool
+	}{
+		{name: "Closed candle and past end", candles: []candle{closed}, end: now.Add(-time.Hour), want: true},
+		{name: "Open-ended range", candles: []candle{closed}, want: true},
+		{name: "Candle still open", candles: []candle{closed, open}, end: now.Add(-time.Second), want: false},
+		{name: "End in the future", candles: []candle{closed}, end: now.Add(time.Hour), want: false},
+		{name: "No candles", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := seriesClosed(tc.candles, tc.end); got != tc.want {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCacheStatusValue(t *testing.T) {
+	testCases := []struct {
+		name   string
+		record func(ctx context.Context)
+		want   string
+	}{
+		{name: "Hit", record: recordCacheHit, want: "tracker-market; hit"},
+		{name: "Miss", record: func(ctx context.Context) { recordCacheMiss(ctx, false) }, want: "tracker-market; fwd=miss"},
+		{name: "Collapsed", record: func(ctx context.Context) { recordCacheMiss(ctx, true) }, want: "tracker-market; fwd=miss; collapsed"},
+		{name: "Hit and miss", record: func(ctx context.Context) { recordCacheHit(ctx); recordCacheMiss(ctx, false) }, want: "tracker-market; fwd=miss"},
+		{name: "Stale", record: func(ctx context.Context) { recordCacheMiss(ctx, false); recordStaleServed(ctx) }, want: "tracker-market; hit; detail=stale-rate-limited"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, rec := withCacheRecorder(context.Background())
+			tc.record(ctx)
+			if got := rec.Value(); got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
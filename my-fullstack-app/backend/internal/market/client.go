@@ -3,9 +3,11 @@ package market
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
+	"my-fullstack-app/backend/internal/fx"
 	"my-fullstack-app/backend/internal/logger"
 
 	"github.com/adshao/go-binance/v2"
@@ -20,40 +22,80 @@ const (
 // Client represents a market data client
 type Client struct {
 	binanceClient *binance.Client
+	exchangeInfo  *exchangeInfoCache
+	limiter       *weightLimiter
+	cache         *priceCache
 }
 
 // PriceData represents price information at a specific time
 type PriceData struct {
 	Symbol       string    `json:"symbol"`
+	BaseAsset    string    `json:"baseAsset,omitempty"`
+	QuoteAsset   string    `json:"quoteAsset,omitempty"`
 	Price        float64   `json:"price"`
 	Timestamp    time.Time `json:"timestamp"`
-	USD          float64   `json:"usd,omitempty"` // Price in USD
+	USD          float64   `json:"usd,omitempty"` // Price in USD, set for USD-quoted pairs or after conversion
 	OpenTime     time.Time `json:"openTime,omitempty"`
 	CloseTime    time.Time `json:"closeTime,omitempty"`
+	Open         float64   `json:"open,omitempty"`
+	Close        float64   `json:"close,omitempty"`
 	High         float64   `json:"high,omitempty"`
 	Low          float64   `json:"low,omitempty"`
 	Volume       float64   `json:"volume,omitempty"`
 	NumberTrades int64     `json:"numberTrades,omitempty"`
+	Interval     string    `json:"interval,omitempty"` // Candle interval the price was taken from
+	Policy       string    `json:"policy,omitempty"`   // How the price was derived within the candle
+
+	Fiat *fx.Conversion `json:"fiat,omitempty"` // USD price converted into a requested fiat currency
 }
 
 // NewClient creates a new client with the Binance API
 func NewClient(apiKey, secretKey string) *Client {
-	// Create Binance client
+	return newClientWithLimiter(apiKey, secretKey, sharedLimiter)
+}
+
+// newClientWithLimiter creates a client whose Binance calls go through the given limiter
+func newClientWithLimiter(apiKey, secretKey string, limiter *weightLimiter) *Client {
+	// Create Binance client, routing every request through the weight limiter
 	binanceClient := binance.NewClient(apiKey, secretKey)
+	binanceClient.HTTPClient = &http.Client{Transport: limiter}
 
 	logger.Info().Msg("Market price client initialized")
 
 	return &Client{
 		binanceClient: binanceClient,
+		exchangeInfo:  newExchangeInfoCache(exchangeInfoTTL),
+		limiter:       limiter,
+		cache:         newPriceCache(currentPriceTTL),
 	}
 }
 
-// GetCurrentPrice gets the latest price for a symbol
+// LimiterStats returns the state of the Binance request weight limiter
+func (c *Client) LimiterStats() LimiterStats {
+	return c.limiter.Stats()
+}
+
+// GetCurrentPrice gets the latest price for a symbol. Prices are cached briefly and
+// concurrent requests for the same symbol share a single Binance call.
 func (c *Client) GetCurrentPrice(ctx context.Context, symbol string) (*PriceData, error) {
+	return c.cachedCurrentPrice(ctx, symbol, func(ctx context.Context) (*PriceData, error) {
+		return c.fetchCurrentPrice(ctx, symbol)
+	})
+}
+
+// fetchCurrentPrice gets the latest price for a symbol from Binance
+func (c *Client) fetchCurrentPrice(ctx context.Context, symbol string) (*PriceData, error) {
 	logger.Debug().
 		Str("symbol", symbol).
 		Msg("Getting current price")
 
+	// Make sure the symbol exists before spending request weight on it
+	info, err := c.ValidateSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	symbol = info.Symbol
+
 	// Get ticker price from Binance
 	prices, err := c.binanceClient.NewListPricesService().Symbol(symbol).Do(ctx)
 	if err != nil {
@@ -84,10 +126,16 @@ func (c *Client) GetCurrentPrice(ctx context.Context, symbol string) (*PriceData
 	}
 
 	priceData := &PriceData{
-		Symbol:    symbol,
-		Price:     price,
-		Timestamp: time.Now(),
-		USD:       price, // For USDT pairs, this is already in USD equivalent
+		Symbol:     symbol,
+		BaseAsset:  info.BaseAsset,
+		QuoteAsset: info.QuoteAsset,
+		Price:      price,
+		Timestamp:  time.Now(),
+	}
+
+	// Stablecoin-quoted pairs are already priced in USD
+	if isUSDAsset(info.QuoteAsset) {
+		priceData.USD = price
 	}
 
 	// Add additional data if available
@@ -117,6 +165,13 @@ func (c *Client) GetHistoricalPrice(ctx context.Context, symbol string, date tim
 		Time("date", date).
 		Msg("Getting historical price")
 
+	// Delisted symbols still have history, so only require that the symbol is known
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	symbol = info.Symbol
+
 	// Format date to start of day in UTC
 	startTime := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
 
@@ -124,14 +179,7 @@ func (c *Client) GetHistoricalPrice(ctx context.Context, symbol string, date tim
 	endTime := startTime.Add(24 * time.Hour)
 
 	// Fetch klines (candlestick) data
-	klines, err := c.binanceClient.NewKlinesService().
-		Symbol(symbol).
-		Interval(defaultInterval).
-		StartTime(startTime.UnixNano() / int64(time.Millisecond)).
-		EndTime(endTime.UnixNano() / int64(time.Millisecond)).
-		Limit(1).
-		Do(ctx)
-
+	klines, err := c.getKlines(ctx, symbol, defaultInterval, startTime, endTime, 1)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -149,33 +197,28 @@ func (c *Client) GetHistoricalPrice(ctx context.Context, symbol string, date tim
 		return nil, fmt.Errorf("no historical data found for %s on %s", symbol, date.Format(dateFormat))
 	}
 
-	// Parse the closing price
-	closePrice, err := strconv.ParseFloat(klines[0].Close, 64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse close price: %w", err)
-	}
-
-	// Parse other kline data
-	//openPrice, _ := strconv.ParseFloat(klines[0].Open, 64)
-	highPrice, _ := strconv.ParseFloat(klines[0].High, 64)
-	lowPrice, _ := strconv.ParseFloat(klines[0].Low, 64)
-	volume, _ := strconv.ParseFloat(klines[0].Volume, 64)
-
-	// Convert timestamps
-	openTime := time.Unix(klines[0].OpenTime/1000, 0)
-	closeTime := time.Unix(klines[0].CloseTime/1000, 0)
+	k := klines[0]
+	closePrice := k.close
 
 	priceData := &PriceData{
 		Symbol:       symbol,
+		BaseAsset:    info.BaseAsset,
+		QuoteAsset:   info.QuoteAsset,
 		Price:        closePrice,
-		Timestamp:    closeTime,
-		OpenTime:     openTime,
-		CloseTime:    closeTime,
-		High:         highPrice,
-		Low:          lowPrice,
-		Volume:       volume,
-		NumberTrades: klines[0].TradeNum,
-		USD:          closePrice, // For USDT pairs, this is already in USD
+		Timestamp:    k.closeTime,
+		OpenTime:     k.openTime,
+		CloseTime:    k.closeTime,
+		Open:         k.open,
+		Close:        k.close,
+		High:         k.high,
+		Low:          k.low,
+		Volume:       k.volume,
+		NumberTrades: k.trades,
+		Interval:     defaultInterval,
+	}
+
+	if isUSDAsset(info.QuoteAsset) {
+		priceData.USD = closePrice
 	}
 
 	logger.Info().
@@ -187,74 +230,100 @@ func (c *Client) GetHistoricalPrice(ctx context.Context, symbol string, date tim
 	return priceData, nil
 }
 
-// ConvertToUSD converts a crypto price to USD equivalent
+// ConvertToUSD converts a price quoted in a symbol's quote asset to its USD equivalent.
+// The quote asset is resolved from exchange metadata and converted through the most
+// liquid chain of trading pairs ending in a USD stablecoin, e.g. TOKEN->BTC->USDT.
 func (c *Client) ConvertToUSD(ctx context.Context, symbol string, price float64, date *time.Time) (float64, error) {
-	// If the symbol already ends with USDT, it's already in USD equivalent
-	if len(symbol) > 4 && symbol[len(symbol)-4:] == "USDT" {
-		return price, nil
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return 0, err
 	}
 
-	// If the symbol already ends with BUSD or USDC, it's already in USD equivalent
-	if len(symbol) > 4 && (symbol[len(symbol)-4:] == "BUSD" || symbol[len(symbol)-4:] == "USDC") {
+	// Stablecoin-quoted pairs are already in USD
+	if isUSDAsset(info.QuoteAsset) {
 		return price, nil
 	}
 
-	// Extract the quote asset from the symbol
-	// This is a simplification assuming common formats like ETHBTC, BTCETH, etc.
-	if len(symbol) < 6 {
-		return 0, fmt.Errorf("symbol format not recognized for USD conversion: %s", symbol)
+	rate, route, err := c.usdRate(ctx, info.QuoteAsset, func(symbol string) (*PriceData, error) {
+		if date == nil {
+			return c.GetCurrentPrice(ctx, symbol)
+		}
+		return c.GetHistoricalPrice(ctx, symbol, *date)
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	var baseAsset, quoteAsset string
+	usdPrice := price * rate
 
-	// Try to identify common quote assets
-	commonQuotes := []string{"BTC", "ETH", "BNB"}
-	for _, quote := range commonQuotes {
-		if len(symbol) >= len(quote) && symbol[len(symbol)-len(quote):] == quote {
-			baseAsset = symbol[:len(symbol)-len(quote)]
-			quoteAsset = quote
-			break
-		}
+	logger.Info().
+		Str("symbol", symbol).
+		Float64("original_price", price).
+		Str("quote_asset", info.QuoteAsset).
+		Int("route_hops", len(route.Steps)).
+		Float64("quote_price_usd", rate).
+		Float64("converted_usd", usdPrice).
+		Msg("Successfully converted price to USD")
+
+	return usdPrice, nil
+}
+
+// ConvertToUSDAt converts a price quoted in a symbol's quote asset to its USD equivalent
+// at a precise moment, pricing each conversion step with GetPriceAt
+func (c *Client) ConvertToUSDAt(ctx context.Context, symbol string, price float64, ts time.Time, policy string) (float64, error) {
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return 0, err
 	}
 
-	if quoteAsset == "" {
-		return 0, fmt.Errorf("couldn't determine quote asset for symbol %s", symbol)
+	if isUSDAsset(info.QuoteAsset) {
+		return price, nil
 	}
 
-	logger.Debug().
-		Str("symbol", symbol).
-		Str("base", baseAsset).
-		Str("quote", quoteAsset).
-		Msg("Converting price to USD")
-
-	// Get the quote asset's price in USDT
-	var quotePrice *PriceData
-	var err error
-
-	quoteSymbol := quoteAsset + "USDT"
-
-	if date == nil {
-		// Get current price
-		quotePrice, err = c.GetCurrentPrice(ctx, quoteSymbol)
-	} else {
-		// Get historical price
-		quotePrice, err = c.GetHistoricalPrice(ctx, quoteSymbol, *date)
+	rate, _, err := c.usdRate(ctx, info.QuoteAsset, func(symbol string) (*PriceData, error) {
+		return c.GetPriceAt(ctx, symbol, ts, policy)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return price * rate, nil
+}
+
+// usdRate returns the USD value of one unit of an asset, pricing each pair on the route with priceOf
+func (c *Client) usdRate(ctx context.Context, asset string, priceOf func(symbol string) (*PriceData, error)) (float64, conversionRoute, error) {
+	info, err := c.loadExchangeInfo(ctx)
+	if err != nil {
+		return 0, conversionRoute{}, err
 	}
 
+	route, err := findUSDRoute(info, asset)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get %s price: %w", quoteSymbol, err)
+		return 0, conversionRoute{}, err
 	}
 
-	// Calculate USD equivalent
-	usdPrice := price * quotePrice.Price
+	logger.Debug().
+		Str("asset", asset).
+		Interface("route", route.Steps).
+		Int64("liquidity", route.Liquidity).
+		Msg("Converting asset to USD")
+
+	rate := 1.0
+	for _, step := range route.Steps {
+		stepPrice, err := priceOf(step.Symbol)
+		if err != nil {
+			return 0, route, fmt.Errorf("failed to get %s price: %w", step.Symbol, err)
+		}
+		if stepPrice.Price <= 0 {
+			return 0, route, fmt.Errorf("invalid %s price: %f", step.Symbol, stepPrice.Price)
+		}
 
-	logger.Info().
-		Str("symbol", symbol).
-		Float64("original_price", price).
-		Str("quote_asset", quoteAsset).
-		Float64("quote_price_usd", quotePrice.Price).
-		Float64("converted_usd", usdPrice).
-		Msg("Successfully converted price to USD")
+		if step.Inverse {
+			rate /= stepPrice.Price
+		} else {
+			rate *= stepPrice.Price
+		}
+	}
 
-	return usdPrice, nil
+	return rate, route, nil
 }
@@ -0,0 +1,305 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+
+	"github.com/adshao/go-binance/v2/common"
+)
+
+const (
+	// Order sides of a fill estimate
+	SideSell = "sell"
+	SideBuy  = "buy"
+
+	// Orders beyond this size are not meaningful and overflow the fill arithmetic
+	maxFloatQuantity = 1e18
+)
+
+// depthLimits are the book sizes fetched in turn until an order can be filled.
+// Larger books cost more request weight, so the small snapshot is tried first.
+var depthLimits = []int{100, 1000, 5000}
+
+// ErrInvalidQuantity is returned for zero, negative or non-finite order sizes
+var ErrInvalidQuantity = errors.New("invalid quantity")
+
+// BookLevel is one price level of an order book
+type BookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook is a depth snapshot of a trading pair, best prices first
+type OrderBook struct {
+	Symbol       string      `json:"symbol"`
+	LastUpdateID int64       `json:"lastUpdateId"`
+	Bids         []BookLevel `json:"bids"`
+	Asks         []BookLevel `json:"asks"`
+	FetchedAt    time.Time   `json:"fetchedAt"`
+}
+
+// MidPrice is the average of the best bid and ask, or 0 if either side is empty
+func (b *OrderBook) MidPrice() float64 {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return 0
+	}
+	return (b.Bids[0].Price + b.Asks[0].Price) / 2
+}
+
+// FillEstimate describes the expected result of a market order walked through an order book
+type FillEstimate struct {
+	Symbol       string  `json:"symbol"`
+	Side         string  `json:"side"`
+	From         string  `json:"from"`         // Asset given up
+	To           string  `json:"to"`           // Asset received
+	Amount       float64 `json:"amount"`       // Amount of From to trade
+	Filled       float64 `json:"filled"`       // Amount of From the book could absorb
+	Received     float64 `json:"received"`     // Amount of To received for Filled
+	AveragePrice float64 `json:"averagePrice"` // Quote per base over the whole fill
+	BestPrice    float64 `json:"bestPrice"`    // Top of book on the side being hit
+	MidPrice     float64 `json:"midPrice"`
+	WorstPrice   float64 `json:"worstPrice"`  // Deepest level touched
+	SlippageBps  float64 `json:"slippageBps"` // Shortfall of the average price against the mid price
+	Levels       int     `json:"levels"`      // Book levels consumed
+	Complete     bool    `json:"complete"`    // False when the fetched book is too thin for the whole amount
+}
+
+// AssetValuation compares the spot value of a holding with what selling it would realize
+type AssetValuation struct {
+	Asset          string         `json:"asset"`
+	Quantity       float64        `json:"quantity"`
+	SpotUSD        float64        `json:"spotUsd"`
+	LiquidationUSD float64        `json:"liquidationUsd"` // Proceeds of selling through the order books
+	SlippageBps    float64        `json:"slippageBps"`
+	Complete       bool           `json:"complete"` // False when part of the holding could not be sold and is valued at zero
+	Fills          []FillEstimate `json:"fills,omitempty"`
+}
+
+// GetOrderBook fetches a depth snapshot with up to limit levels per side
+func (c *Client) GetOrderBook(ctx context.Context, symbol string, limit int) (*OrderBook, error) {
+	info, err := c.ValidateSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.binanceClient.NewDepthService().Symbol(info.Symbol).Limit(limit).Do(ctx)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("symbol", info.Symbol).
+			Int("limit", limit).
+			Msg("Failed to get order book")
+		return nil, fmt.Errorf("failed to get order book: %w", err)
+	}
+
+	book := &OrderBook{
+		Symbol:       info.Symbol,
+		LastUpdateID: res.LastUpdateID,
+		FetchedAt:    time.Now(),
+	}
+	if book.Bids, err = parseLevels(res.Bids); err != nil {
+		return nil, err
+	}
+	if book.Asks, err = parseLevels(res.Asks); err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+func parseLevels(levels []common.PriceLevel) ([]BookLevel, error) {
+	out := make([]BookLevel, 0, len(levels))
+	for _, level := range levels {
+		price, err := strconv.ParseFloat(level.Price, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse book price: %w", err)
+		}
+		quantity, err := strconv.ParseFloat(level.Quantity, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse book quantity: %w", err)
+		}
+		out = append(out, BookLevel{Price: price, Quantity: quantity})
+	}
+	return out, nil
+}
+
+// EstimateSale estimates the average fill price and slippage of selling quantity of a
+// pair's base asset at market
+func (c *Client) EstimateSale(ctx context.Context, symbol string, quantity float64) (*FillEstimate, error) {
+	if !(quantity > 0) || quantity > maxFloatQuantity {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuantity, quantity)
+	}
+
+	info, err := c.ValidateSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.estimateStep(ctx, routeStep{Symbol: info.Symbol, From: info.BaseAsset, To: info.QuoteAsset}, quantity)
+}
+
+// estimateStep walks one conversion step through the order book, fetching deeper
+// snapshots until the amount fits or the whole book has been seen
+func (c *Client) estimateStep(ctx context.Context, step routeStep, amount float64) (*FillEstimate, error) {
+	var estimate FillEstimate
+	for _, limit := range depthLimits {
+		book, err := c.GetOrderBook(ctx, step.Symbol, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		// Inverse steps spend the quote asset, so they buy from the asks
+		levels := book.Bids
+		if step.Inverse {
+			levels = book.Asks
+			estimate = estimateBuy(book, amount)
+		} else {
+			estimate = estimateSell(book, amount)
+		}
+		estimate.From, estimate.To = step.From, step.To
+
+		// A book shorter than requested is the whole book, deeper snapshots would not help
+		if estimate.Complete || len(levels) < limit {
+			break
+		}
+	}
+
+	logger.Debug().
+		Str("symbol", step.Symbol).
+		Str("side", estimate.Side).
+		Float64("amount", amount).
+		Float64("slippage_bps", estimate.SlippageBps).
+		Bool("complete", estimate.Complete).
+		Msg("Estimated order book fill")
+
+	return &estimate, nil
+}
+
+// estimateSell walks the bids selling quantity of the base asset for the quote asset
+func estimateSell(book *OrderBook, quantity float64) FillEstimate {
+	estimate := FillEstimate{
+		Symbol:   book.Symbol,
+		Side:     SideSell,
+		Amount:   quantity,
+		MidPrice: book.MidPrice(),
+	}
+	if len(book.Bids) > 0 {
+		estimate.BestPrice = book.Bids[0].Price
+	}
+
+	remaining := quantity
+	for _, level := range book.Bids {
+		if remaining <= 0 {
+			break
+		}
+		take := min(remaining, level.Quantity)
+		estimate.Filled += take
+		estimate.Received += take * level.Price
+		estimate.WorstPrice = level.Price
+		estimate.Levels++
+		remaining -= take
+	}
+
+	estimate.Complete = remaining <= 0
+	if estimate.Filled > 0 {
+		estimate.AveragePrice = estimate.Received / estimate.Filled
+	}
+	if estimate.MidPrice > 0 && estimate.AveragePrice > 0 {
+		estimate.SlippageBps = (estimate.MidPrice - estimate.AveragePrice) / estimate.MidPrice * 1e4
+	}
+	return estimate
+}
+
+// estimateBuy walks the asks spending quoteAmount of the quote asset on the base asset
+func estimateBuy(book *OrderBook, quoteAmount float64) FillEstimate {
+	estimate := FillEstimate{
+		Symbol:   book.Symbol,
+		Side:     SideBuy,
+		Amount:   quoteAmount,
+		MidPrice: book.MidPrice(),
+	}
+	if len(book.Asks) > 0 {
+		estimate.BestPrice = book.Asks[0].Price
+	}
+
+	remaining := quoteAmount
+	for _, level := range book.Asks {
+		if remaining <= 0 {
+			break
+		}
+		spend := min(remaining, level.Quantity*level.Price)
+		estimate.Filled += spend
+		estimate.Received += spend / level.Price
+		estimate.WorstPrice = level.Price
+		estimate.Levels++
+		remaining -= spend
+	}
+
+	estimate.Complete = remaining <= 0
+	if estimate.Received > 0 {
+		estimate.AveragePrice = estimate.Filled / estimate.Received
+	}
+	if estimate.MidPrice > 0 && estimate.AveragePrice > 0 {
+		estimate.SlippageBps = (estimate.AveragePrice - estimate.MidPrice) / estimate.MidPrice * 1e4
+	}
+	return estimate
+}
+
+// ValueAsset values a holding at spot and at the proceeds of selling it into USD through the
+// order books along the most liquid conversion route. Any part of the holding the books
+// cannot absorb is valued at zero in the liquidation value.
+func (c *Client) ValueAsset(ctx context.Context, asset string, quantity float64) (*AssetValuation, error) {
+	if !(quantity >= 0) || quantity > maxFloatQuantity {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuantity, quantity)
+	}
+
+	asset = strings.ToUpper(asset)
+	valuation := &AssetValuation{Asset: asset, Quantity: quantity, Complete: true}
+
+	if isUSDAsset(asset) || quantity == 0 {
+		if isUSDAsset(asset) {
+			valuation.SpotUSD = quantity
+			valuation.LiquidationUSD = quantity
+		}
+		return valuation, nil
+	}
+
+	rate, route, err := c.usdRate(ctx, asset, func(symbol string) (*PriceData, error) {
+		return c.GetCurrentPrice(ctx, symbol)
+	})
+	if err != nil {
+		return nil, err
+	}
+	valuation.SpotUSD = quantity * rate
+
+	amount := quantity
+	for _, step := range route.Steps {
+		fill, err := c.estimateStep(ctx, step, amount)
+		if err != nil {
+			return nil, err
+		}
+		valuation.Fills = append(valuation.Fills, *fill)
+		valuation.Complete = valuation.Complete && fill.Complete
+		amount = fill.Received
+	}
+	valuation.LiquidationUSD = amount
+
+	if valuation.SpotUSD > 0 {
+		valuation.SlippageBps = (valuation.SpotUSD - valuation.LiquidationUSD) / valuation.SpotUSD * 1e4
+	}
+
+	logger.Info().
+		Str("asset", asset).
+		Float64("quantity", quantity).
+		Float64("spot_usd", valuation.SpotUSD).
+		Float64("liquidation_usd", valuation.LiquidationUSD).
+		Msg("Valued holding against order books")
+
+	return valuation, nil
+}
@@ -0,0 +1,124 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestEstimateSell(t *testing.T) {
+	book := &OrderBook{
+		Symbol: "ETHUSDT",
+		Bids:   []BookLevel{{Price: 100, Quantity: 1}, {Price: 99, Quantity: 2}, {Price: 95, Quantity: 5}},
+		Asks:   []BookLevel{{Price: 102, Quantity: 1}},
+	}
+
+	testCases := []struct {
+		name         string
+		quantity     float64
+		wantAverage  float64
+		wantSlippage float64
+		wantLevels   int
+		wantComplete bool
+	}{
+		{name: "Fits in the best level", quantity: 0.5, wantAverage: 100, wantSlippage: 1e4 / 101.0, wantLevels: 1, wantComplete: true},
+		// (100 + 2*99) / 3 = 99.333 against a mid price of 101
+		{name: "Walks two levels", quantity: 3, wantAverage: 298.0 / 3, wantSlippage: (101 - 298.0/3) / 101 * 1e4, wantLevels: 2, wantComplete: true},
+		{name: "Exceeds the book", quantity: 10, wantAverage: 773.0 / 8, wantSlippage: (101 - 773.0/8) / 101 * 1e4, wantLevels: 3, wantComplete: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := estimateSell(book, tc.quantity)
+			if math.Abs(got.AveragePrice-tc.wantAverage) > 1e-9 {
+				t.Errorf("Expected average price %f, got %f", tc.wantAverage, got.AveragePrice)
+			}
+			if math.Abs(got.SlippageBps-tc.wantSlippage) > 1e-6 {
+				t.Errorf("Expected slippage %f bps, got %f", tc.wantSlippage, got.SlippageBps)
+			}
+			if got.Levels != tc.wantLevels || got.Complete != tc.wantComplete {
+				t.Errorf("Expected %d levels and complete=%v, got %d and %v", tc.wantLevels, tc.wantComplete, got.Levels, got.Complete)
+			}
+			if got.MidPrice != 101 || got.BestPrice != 100 {
+				t.Errorf("Expected mid 101 and best bid 100, got %f and %f", got.MidPrice, got.BestPrice)
+			}
+		})
+	}
+}
+
+func TestEstimateBuy(t *testing.T) {
+	book := &OrderBook{
+		Symbol: "ETHUSDT",
+		Bids:   []BookLevel{{Price: 98, Quantity: 1}},
+		Asks:   []BookLevel{{Price: 100, Quantity: 1}, {Price: 110, Quantity: 1}},
+	}
+
+	// 100 buys one unit at 100, the remaining 55 buys half a unit at 110
+	got := estimateBuy(book, 155)
+	if !got.Complete || got.Levels != 2 {
+		t.Fatalf("Expected a complete fill over 2 levels, got %+v", got)
+	}
+	if math.Abs(got.Received-1.5) > 1e-9 {
+		t.Errorf("Expected to receive 1.5, got %f", got.Received)
+	}
+	if math.Abs(got.AveragePrice-155/1.5) > 1e-9 {
+		t.Errorf("Expected average price %f, got %f", 155/1.5, got.AveragePrice)
+	}
+	if want := (155/1.5 - 99) / 99 * 1e4; math.Abs(got.SlippageBps-want) > 1e-6 {
+		t.Errorf("Expected slippage %f bps, got %f", want, got.SlippageBps)
+	}
+}
+
+func TestValueAsset(t *testing.T) {
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "LINKUSDT", base: "LINK", quote: "USDT", price: "2.01", trades: 100},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/depth": func(w http.ResponseWriter, r *http.Request) {
+			writeStubJSON(w, map[string]interface{}{
+				"lastUpdateId": 1,
+				"bids":         [][]string{{"2.00", "10"}, {"1.80", "10"}},
+				"asks":         [][]string{{"2.02", "10"}},
+			})
+		},
+	})
+
+	valuation, err := client.ValueAsset(context.Background(), "link", 15)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if math.Abs(valuation.SpotUSD-30.15) > 1e-9 {
+		t.Errorf("Expected spot value 30.15, got %f", valuation.SpotUSD)
+	}
+	// 10 at 2.00 and 5 at 1.80
+	if math.Abs(valuation.LiquidationUSD-29) > 1e-9 {
+		t.Errorf("Expected liquidation value 29, got %f", valuation.LiquidationUSD)
+	}
+	if !valuation.Complete || len(valuation.Fills) != 1 || valuation.Fills[0].From != "LINK" {
+		t.Errorf("Unexpected fills: %+v", valuation.Fills)
+	}
+
+	// Selling more than the whole book values the unsold remainder at zero
+	valuation, err = client.ValueAsset(context.Background(), "LINK", 25)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if valuation.Complete || math.Abs(valuation.LiquidationUSD-38) > 1e-9 {
+		t.Errorf("Expected an incomplete liquidation of 38, got %f (complete=%v)", valuation.LiquidationUSD, valuation.Complete)
+	}
+
+	// Stablecoins are valued at par without touching the books
+	valuation, err = client.ValueAsset(context.Background(), "USDC", 500)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if valuation.SpotUSD != 500 || valuation.LiquidationUSD != 500 {
+		t.Errorf("Expected stablecoin valued at 500, got %+v", valuation)
+	}
+
+	if _, err := client.EstimateSale(context.Background(), "LINKUSDT", -1); !errors.Is(err, ErrInvalidQuantity) {
+		t.Errorf("Expected %v, got %v", ErrInvalidQuantity, err)
+	}
+}
@@ -0,0 +1,174 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+)
+
+const (
+	// How long exchange metadata is trusted before it is reloaded
+	exchangeInfoTTL = 1 * time.Hour
+
+	// Binance status for symbols that can currently be traded
+	symbolStatusTrading = "TRADING"
+)
+
+var (
+	// ErrUnknownSymbol is returned when a symbol is not listed on the exchange
+	ErrUnknownSymbol = errors.New("unknown trading pair symbol")
+	// ErrSymbolNotTrading is returned when a symbol is listed but not currently trading
+	ErrSymbolNotTrading = errors.New("trading pair is not currently trading")
+)
+
+// SymbolInfo describes a trading pair as reported by Binance exchangeInfo
+type SymbolInfo struct {
+	Symbol     string `json:"symbol"`
+	BaseAsset  string `json:"baseAsset"`
+	QuoteAsset string `json:"quoteAsset"`
+	Status     string `json:"status"`
+	TradeCount int64  `json:"tradeCount"` // Trades in the last 24h, used as a liquidity measure
+}
+
+// Trading reports whether the pair is currently open for trading
+func (s SymbolInfo) Trading() bool {
+	return s.Status == symbolStatusTrading
+}
+
+// exchangeInfo is a snapshot of the exchange's symbol metadata
+type exchangeInfo struct {
+	symbols  map[string]SymbolInfo
+	loadedAt time.Time
+}
+
+// exchangeInfoCache holds the most recently loaded exchange metadata
+type exchangeInfoCache struct {
+	mu   sync.Mutex
+	info *exchangeInfo
+	ttl  time.Duration
+}
+
+func newExchangeInfoCache(ttl time.Duration) *exchangeInfoCache {
+	return &exchangeInfoCache{ttl: ttl}
+}
+
+// loadExchangeInfo returns cached exchange metadata, reloading it from Binance once it is stale.
+// If a reload fails and older metadata is available, the older metadata is served instead.
+func (c *Client) loadExchangeInfo(ctx context.Context) (*exchangeInfo, error) {
+	cache := c.exchangeInfo
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.info != nil && time.Since(cache.info.loadedAt) < cache.ttl {
+		return cache.info, nil
+	}
+
+	info, err := c.fetchExchangeInfo(ctx)
+	if err != nil {
+		if cache.info != nil {
+			logger.Warn().
+				Err(err).
+				Time("loaded_at", cache.info.loadedAt).
+				Msg("Failed to refresh exchange info, using stale metadata")
+			return cache.info, nil
+		}
+		return nil, err
+	}
+
+	cache.info = info
+	return info, nil
+}
+
+// fetchExchangeInfo loads symbol metadata and 24h trade counts from Binance
+func (c *Client) fetchExchangeInfo(ctx context.Context) (*exchangeInfo, error) {
+	res, err := c.binanceClient.NewExchangeInfoService().Do(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get exchange info")
+		return nil, fmt.Errorf("failed to get exchange info: %w", err)
+	}
+
+	info := &exchangeInfo{
+		symbols:  make(map[string]SymbolInfo, len(res.Symbols)),
+		loadedAt: time.Now(),
+	}
+
+	// Keep the limiter in line with the weight limit Binance currently enforces
+	for _, limit := range res.RateLimits {
+		if limit.RateLimitType == "REQUEST_WEIGHT" && limit.Interval == "MINUTE" && limit.IntervalNum == 1 {
+			c.limiter.SetWeightLimit(int(limit.Limit))
+		}
+	}
+
+	for _, s := range res.Symbols {
+		info.symbols[s.Symbol] = SymbolInfo{
+			Symbol:     s.Symbol,
+			BaseAsset:  s.BaseAsset,
+			QuoteAsset: s.QuoteAsset,
+			Status:     s.Status,
+		}
+	}
+
+	// Trade counts are only used to rank conversion routes, so a failure here is not fatal
+	stats, err := c.binanceClient.NewListPriceChangeStatsService().Do(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to get 24h ticker statistics for route ranking")
+	} else {
+		for _, stat := range stats {
+			if s, ok := info.symbols[stat.Symbol]; ok {
+				s.TradeCount = stat.Count
+				info.symbols[stat.Symbol] = s
+			}
+		}
+	}
+
+	logger.Info().
+		Int("symbols", len(info.symbols)).
+		Msg("Loaded exchange info")
+
+	return info, nil
+}
+
+// ResolveSymbol returns the base and quote assets of a listed symbol
+func (c *Client) ResolveSymbol(ctx context.Context, symbol string) (SymbolInfo, error) {
+	info, err := c.loadExchangeInfo(ctx)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	s, ok := info.symbols[strings.ToUpper(symbol)]
+	if !ok {
+		return SymbolInfo{}, fmt.Errorf("%w: %s", ErrUnknownSymbol, symbol)
+	}
+
+	return s, nil
+}
+
+// ValidateSymbol checks that a symbol is listed and currently trading
+func (c *Client) ValidateSymbol(ctx context.Context, symbol string) (SymbolInfo, error) {
+	s, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return SymbolInfo{}, err
+	}
+
+	if !s.Trading() {
+		return SymbolInfo{}, fmt.Errorf("%w: %s (%s)", ErrSymbolNotTrading, symbol, s.Status)
+	}
+
+	return s, nil
+}
+
+// IsUSDQuoted reports whether a symbol's quote asset is a USD stablecoin
+func (c *Client) IsUSDQuoted(ctx context.Context, symbol string) (bool, error) {
+	s, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return false, err
+	}
+
+	return isUSDAsset(s.QuoteAsset), nil
+}
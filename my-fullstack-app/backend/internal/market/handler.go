@@ -1,36 +1,62 @@
 package market
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/fx"
 	"my-fullstack-app/backend/internal/logger"
 )
 
 // Handler handles market data API requests
 type Handler struct {
-	client *Client
+	client   *Client
+	rates    *fx.Service
+	accounts *AccountTracker
 }
 
-// NewHandler creates a new market data handler
-func NewHandler() (*Handler, error) {
+// NewHandler creates a new market data handler. Account snapshots are stored in accountStore.
+func NewHandler(rates *fx.Service, accountStore AccountStore) (*Handler, error) {
 	// Get API keys from environment variables
 	apiKey := os.Getenv("BINANCE_API_KEY")
 	secretKey := os.Getenv("BINANCE_SECRET_KEY")
 
+	// Label under which account snapshots are stored, to tell several keys apart
+	accountLabel := os.Getenv("BINANCE_ACCOUNT_LABEL")
+	if accountLabel == "" {
+		accountLabel = "default"
+	}
+
 	// Create client
 	client := NewClient(apiKey, secretKey)
 
 	logger.Info().Msg("Market data handler initialized")
 
 	return &Handler{
-		client: client,
+		client:   client,
+		rates:    rates,
+		accounts: NewAccountTracker(client, accountStore, accountLabel),
 	}, nil
 }
 
+// Client returns the market data client used by the handler
+func (h *Handler) Client() *Client {
+	return h.client
+}
+
+// Accounts returns the tracker of the configured Binance account
+func (h *Handler) Accounts() *AccountTracker {
+	return h.accounts
+}
+
 // GetCurrentPriceHandler returns the current price of a symbol
 // @Summary Get current price
 // @Description Returns the current price of a cryptocurrency
@@ -38,6 +64,8 @@ func NewHandler() (*Handler, error) {
 // @Accept json
 // @Produce json
 // @Param symbol query string true "Trading pair symbol (e.g., BTCUSDT)"
+// @Param convert_usd query boolean false "Convert price to USD equivalent"
+// @Param currency query string false "Fiat currency to value the price in (e.g., EUR)"
 // @Success 200 {object} api.Response
 // @Failure 400 {object} api.Response
 // @Failure 500 {object} api.Response
@@ -53,8 +81,8 @@ func (h *Handler) GetCurrentPriceHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Create context with timeout
-	ctx := r.Context()
+	// Create context with timeout; the recorder collects cache outcomes for Cache-Status
+	ctx, cacheStatus := withCacheRecorder(r.Context())
 
 	logger.Info().
 		Str("symbol", symbol).
@@ -63,7 +91,21 @@ func (h *Handler) GetCurrentPriceHandler(w http.ResponseWriter, r *http.Request)
 
 	// Get current price
 	priceData, err := h.client.GetCurrentPrice(ctx, symbol)
-	if err != nil {
+	if errors.Is(err, ErrUnknownSymbol) || errors.Is(err, ErrSymbolNotTrading) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Invalid symbol requested")
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrRateLimited) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Market data request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+		return
+	} else if err != nil {
 		logger.Error().
 			Err(err).
 			Str("symbol", symbol).
@@ -75,7 +117,7 @@ func (h *Handler) GetCurrentPriceHandler(w http.ResponseWriter, r *http.Request)
 	// Check if we need to convert to USD
 	convertToUsd := r.URL.Query().Get("convert_usd") == "true"
 
-	if convertToUsd && !symbolEndsWithUsdStablecoin(symbol) {
+	if convertToUsd && priceData.USD == 0 {
 		usdPrice, err := h.client.ConvertToUSD(ctx, symbol, priceData.Price, nil)
 		if err != nil {
 			logger.Warn().
@@ -87,6 +129,18 @@ func (h *Handler) GetCurrentPriceHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	// Value the price in the requested fiat currency at today's rate
+	if currency := r.URL.Query().Get("currency"); currency != "" {
+		toUSD := func(price float64) (float64, error) {
+			return h.client.ConvertToUSD(ctx, symbol, price, nil)
+		}
+		if !h.applyCurrency(w, r, priceData, currency, priceData.Timestamp, toUSD) {
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Status", cacheStatus.Value())
+
 	response := api.Response{
 		Success: true,
 		Message: "Current price retrieved successfully",
@@ -98,15 +152,19 @@ func (h *Handler) GetCurrentPriceHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// GetHistoricalPriceHandler returns the price of a symbol at a specific date
+// GetHistoricalPriceHandler returns the price of a symbol at a specific date or moment
 // @Summary Get historical price
-// @Description Returns the price of a cryptocurrency at a specific date
+// @Description Returns the price of a cryptocurrency for a calendar day, or at a precise timestamp when timestamp is given
 // @Tags market
 // @Accept json
 // @Produce json
 // @Param symbol query string true "Trading pair symbol (e.g., BTCUSDT)"
-// @Param date query string true "Date in YYYY-MM-DD format"
+// @Param date query string false "Date in YYYY-MM-DD format (required unless timestamp is given)"
+// @Param tz query string false "IANA timezone the date is in (e.g., Europe/Berlin), defaults to UTC"
+// @Param timestamp query string false "Moment to price, RFC3339 or unix seconds/milliseconds"
+// @Param policy query string false "Price within the candle for timestamp queries: nearest (default) or interpolate"
 // @Param convert_usd query boolean false "Convert price to USD equivalent"
+// @Param currency query string false "Fiat currency to value the price in (e.g., EUR)"
 // @Success 200 {object} api.Response
 // @Failure 400 {object} api.Response
 // @Failure 500 {object} api.Response
@@ -122,41 +180,118 @@ func (h *Handler) GetHistoricalPriceHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Get date from query parameters
-	dateStr := r.URL.Query().Get("date")
-	if dateStr == "" {
-		logger.Warn().Msg("Missing date parameter")
-		api.RespondWithError(w, http.StatusBadRequest, "Date parameter is required (format: YYYY-MM-DD)")
-		return
+	// Create context with timeout; the recorder collects cache outcomes for Cache-Status
+	ctx, cacheStatus := withCacheRecorder(r.Context())
+
+	var (
+		priceData *PriceData
+		rateDate  time.Time
+		toUSD     func(price float64) (float64, error)
+		err       error
+	)
+
+	if tsStr := r.URL.Query().Get("timestamp"); tsStr != "" {
+		// Timestamp mode: price at a precise moment
+		var ts time.Time
+		ts, err = parseTimestamp(tsStr)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Str("timestamp", tsStr).
+				Msg("Invalid timestamp format")
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid timestamp. Use RFC3339 or unix seconds/milliseconds")
+			return
+		}
+
+		var policy string
+		policy, err = ValidatePolicy(r.URL.Query().Get("policy"))
+		if err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid policy. Use nearest or interpolate")
+			return
+		}
+
+		logger.Info().
+			Str("symbol", symbol).
+			Time("timestamp", ts).
+			Str("policy", policy).
+			Str("remote_addr", r.RemoteAddr).
+			Msg("Historical price at timestamp request received")
+
+		priceData, err = h.client.GetPriceAt(ctx, symbol, ts, policy)
+		rateDate = ts
+		toUSD = func(price float64) (float64, error) {
+			return h.client.ConvertToUSDAt(ctx, symbol, price, ts, policy)
+		}
+	} else {
+		// Date mode: close of a calendar day in the requested timezone
+		dateStr := r.URL.Query().Get("date")
+		if dateStr == "" {
+			logger.Warn().Msg("Missing date parameter")
+			api.RespondWithError(w, http.StatusBadRequest, "Date parameter is required (format: YYYY-MM-DD) unless timestamp is given")
+			return
+		}
+
+		loc := time.UTC
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			loc, err = time.LoadLocation(tz)
+			if err != nil {
+				logger.Warn().
+					Err(err).
+					Str("tz", tz).
+					Msg("Invalid timezone")
+				api.RespondWithError(w, http.StatusBadRequest, "Invalid timezone. Use an IANA name such as Europe/Berlin")
+				return
+			}
+		}
+
+		// Parse date
+		var date time.Time
+		date, err = time.ParseInLocation(dateFormat, dateStr, loc)
+		if err != nil {
+			logger.Warn().
+				Err(err).
+				Str("date", dateStr).
+				Msg("Invalid date format")
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+			return
+		}
+
+		logger.Info().
+			Str("symbol", symbol).
+			Str("date", dateStr).
+			Str("tz", loc.String()).
+			Str("remote_addr", r.RemoteAddr).
+			Msg("Historical price request received")
+
+		priceData, err = h.client.GetHistoricalPriceIn(ctx, symbol, date, loc)
+		rateDate = date
+		toUSD = func(price float64) (float64, error) {
+			if loc == time.UTC {
+				return h.client.ConvertToUSD(ctx, symbol, price, &date)
+			}
+			// Local days close at a different moment than the UTC daily candle
+			return h.client.ConvertToUSDAt(ctx, symbol, price, priceData.CloseTime, PolicyNearest)
+		}
 	}
 
-	// Parse date
-	date, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
+	if errors.Is(err, ErrUnknownSymbol) {
 		logger.Warn().
 			Err(err).
-			Str("date", dateStr).
-			Msg("Invalid date format")
-		api.RespondWithError(w, http.StatusBadRequest, "Invalid date format. Use YYYY-MM-DD")
+			Str("symbol", symbol).
+			Msg("Invalid symbol requested")
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
-	}
-
-	// Create context with timeout
-	ctx := r.Context()
-
-	logger.Info().
-		Str("symbol", symbol).
-		Str("date", dateStr).
-		Str("remote_addr", r.RemoteAddr).
-		Msg("Historical price request received")
-
-	// Get historical price
-	priceData, err := h.client.GetHistoricalPrice(ctx, symbol, date)
-	if err != nil {
+	} else if errors.Is(err, ErrRateLimited) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Market data request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+		return
+	} else if err != nil {
 		logger.Error().
 			Err(err).
 			Str("symbol", symbol).
-			Str("date", dateStr).
 			Msg("Failed to get historical price")
 		api.RespondWithError(w, http.StatusInternalServerError, "Failed to get historical price")
 		return
@@ -165,19 +300,27 @@ func (h *Handler) GetHistoricalPriceHandler(w http.ResponseWriter, r *http.Reque
 	// Check if we need to convert to USD
 	convertToUsd := r.URL.Query().Get("convert_usd") == "true"
 
-	if convertToUsd && !symbolEndsWithUsdStablecoin(symbol) {
-		usdPrice, err := h.client.ConvertToUSD(ctx, symbol, priceData.Price, &date)
+	if convertToUsd && priceData.USD == 0 {
+		usdPrice, err := toUSD(priceData.Price)
 		if err != nil {
 			logger.Warn().
 				Err(err).
 				Str("symbol", symbol).
-				Str("date", dateStr).
 				Msg("Failed to convert price to USD")
 		} else {
 			priceData.USD = usdPrice
 		}
 	}
 
+	// Value the price in the requested fiat currency at that day's rate
+	if currency := r.URL.Query().Get("currency"); currency != "" {
+		if !h.applyCurrency(w, r, priceData, currency, rateDate, toUSD) {
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Status", cacheStatus.Value())
+
 	response := api.Response{
 		Success: true,
 		Message: "Historical price retrieved successfully",
@@ -189,13 +332,571 @@ func (h *Handler) GetHistoricalPriceHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-// Helper function to check if a symbol ends with a USD stablecoin symbol
-func symbolEndsWithUsdStablecoin(symbol string) bool {
-	stablecoins := []string{"USDT", "USDC", "BUSD", "DAI", "TUSD", "USDP"}
-	for _, coin := range stablecoins {
-		if len(symbol) >= len(coin) && symbol[len(symbol)-len(coin):] == coin {
-			return true
+// GetIndicatorsHandler computes a technical indicator over a symbol's recent candles
+// @Summary Get technical indicator
+// @Description Computes SMA, EMA, RSI, Bollinger bands or VWAP over the most recent candles of a trading pair
+// @Tags market
+// @Accept json
+// @Produce json
+// @Param symbol query string true "Trading pair symbol (e.g., BTCUSDT)"
+// @Param indicator query string true "Indicator: sma, ema, rsi, bollinger or vwap"
+// @Param interval query string false "Candle interval (e.g., 15m, 1h, 1d), defaults to 1d"
+// @Param period query int false "Window length in candles (defaults: sma/ema/bollinger 20, rsi 14, vwap anchored at the first point)"
+// @Param stddev query number false "Bollinger band width in standard deviations, defaults to 2"
+// @Param limit query int false "Number of points to return, defaults to 100 (max 500)"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Router /market/indicators [get]
+func (h *Handler) GetIndicatorsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	symbol := query.Get("symbol")
+	if symbol == "" {
+		logger.Warn().Msg("Missing symbol parameter")
+		api.RespondWithError(w, http.StatusBadRequest, "Symbol parameter is required")
+		return
+	}
+
+	indicator := query.Get("indicator")
+	if indicator == "" {
+		logger.Warn().Msg("Missing indicator parameter")
+		api.RespondWithError(w, http.StatusBadRequest, "Indicator parameter is required (sma, ema, rsi, bollinger or vwap)")
+		return
+	}
+
+	params := IndicatorParams{Interval: query.Get("interval")}
+
+	var err error
+	if v := query.Get("period"); v != "" {
+		if params.Period, err = strconv.Atoi(v); err != nil || params.Period <= 0 {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid period. Use a positive number of candles")
+			return
+		}
+	}
+	if v := query.Get("stddev"); v != "" {
+		if params.StdDev, err = strconv.ParseFloat(v, 64); err != nil || params.StdDev <= 0 {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid stddev. Use a positive number")
+			return
 		}
 	}
-	return false
+	if v := query.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil || params.Limit <= 0 {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid limit. Use a positive number of points")
+			return
+		}
+	}
+
+	// The recorder collects cache outcomes for Cache-Status
+	ctx, cacheStatus := withCacheRecorder(r.Context())
+
+	logger.Info().
+		Str("symbol", symbol).
+		Str("indicator", indicator).
+		Str("interval", params.Interval).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Indicator request received")
+
+	series, err := h.client.GetIndicator(ctx, symbol, indicator, params)
+	if errors.Is(err, ErrInvalidIndicator) || errors.Is(err, ErrInvalidInterval) ||
+		errors.Is(err, ErrInvalidWindow) || errors.Is(err, ErrUnknownSymbol) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Invalid indicator request")
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrRateLimited) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Market data request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+		return
+	} else if err != nil {
+		logger.Error().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Failed to compute indicator")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to compute indicator")
+		return
+	}
+
+	w.Header().Set("Cache-Status", cacheStatus.Value())
+
+	response := api.Response{
+		Success: true,
+		Message: "Indicator computed successfully",
+		Data:    series,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetAnalyticsHandler returns risk metrics for a set of symbols
+// @Summary Get market analytics
+// @Description Computes realized volatility, max drawdown, beta to BTC and a pairwise correlation matrix from daily candles
+// @Tags market
+// @Accept json
+// @Produce json
+// @Param symbols query string true "Comma-separated trading pair symbols (e.g., ETHUSDT,SOLUSDT)"
+// @Param window query int false "Window in days, defaults to 30 (3 to 365)"
+// @Param end query string false "End of the window in YYYY-MM-DD format, exclusive; defaults to today"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Router /market/analytics [get]
+func (h *Handler) GetAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	var symbols []string
+	for _, symbol := range strings.Split(query.Get("symbols"), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		logger.Warn().Msg("Missing symbols parameter")
+		api.RespondWithError(w, http.StatusBadRequest, "Symbols parameter is required (comma-separated, e.g. ETHUSDT,SOLUSDT)")
+		return
+	}
+
+	var (
+		window int
+		err    error
+	)
+	if v := query.Get("window"); v != "" {
+		if window, err = strconv.Atoi(v); err != nil || window <= 0 {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid window. Use a positive number of days")
+			return
+		}
+	}
+
+	end := time.Now().UTC()
+	if v := query.Get("end"); v != "" {
+		if end, err = time.Parse(dateFormat, v); err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid end date format. Use YYYY-MM-DD")
+			return
+		}
+	}
+
+	// The recorder collects cache outcomes for Cache-Status
+	ctx, cacheStatus := withCacheRecorder(r.Context())
+
+	logger.Info().
+		Strs("symbols", symbols).
+		Int("window", window).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Market analytics request received")
+
+	report, err := h.client.GetAnalytics(ctx, symbols, window, end)
+	if errors.Is(err, ErrInvalidWindow) || errors.Is(err, ErrUnknownSymbol) {
+		logger.Warn().
+			Err(err).
+			Strs("symbols", symbols).
+			Msg("Invalid analytics request")
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrRateLimited) {
+		logger.Warn().
+			Err(err).
+			Strs("symbols", symbols).
+			Msg("Market data request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+		return
+	} else if err != nil {
+		logger.Error().
+			Err(err).
+			Strs("symbols", symbols).
+			Msg("Failed to compute market analytics")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to compute market analytics")
+		return
+	}
+
+	w.Header().Set("Cache-Status", cacheStatus.Value())
+
+	response := api.Response{
+		Success: true,
+		Message: "Market analytics computed successfully",
+		Data:    report,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetSlippageHandler estimates the fill of selling a quantity of a pair's base asset at market
+// @Summary Estimate sale slippage
+// @Description Walks the Binance order book to estimate the average fill price and slippage of a market sell
+// @Tags market
+// @Accept json
+// @Produce json
+// @Param symbol query string true "Trading pair symbol (e.g., ETHUSDT)"
+// @Param quantity query number true "Quantity of the base asset to sell"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Router /market/slippage [get]
+func (h *Handler) GetSlippageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	symbol := r.URL.Query().Get("symbol")
+	if symbol == "" {
+		logger.Warn().Msg("Missing symbol parameter")
+		api.RespondWithError(w, http.StatusBadRequest, "Symbol parameter is required")
+		return
+	}
+
+	quantity, err := strconv.ParseFloat(r.URL.Query().Get("quantity"), 64)
+	if err != nil || quantity <= 0 {
+		api.RespondWithError(w, http.StatusBadRequest, "Quantity parameter is required and must be a positive number")
+		return
+	}
+
+	logger.Info().
+		Str("symbol", symbol).
+		Float64("quantity", quantity).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Slippage estimate request received")
+
+	estimate, err := h.client.EstimateSale(r.Context(), symbol, quantity)
+	if errors.Is(err, ErrUnknownSymbol) || errors.Is(err, ErrSymbolNotTrading) || errors.Is(err, ErrInvalidQuantity) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Invalid slippage request")
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrRateLimited) {
+		logger.Warn().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Market data request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+		return
+	} else if err != nil {
+		logger.Error().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("Failed to estimate slippage")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to estimate slippage")
+		return
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Slippage estimated successfully",
+		Data:    estimate,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetAccountBalancesHandler returns the live spot balances of the configured Binance account
+// @Summary Get exchange account balances
+// @Description Returns the non-zero spot balances of the Binance account behind BINANCE_API_KEY. Requires the admin token.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Success 200 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Security AdminToken
+// @Router /admin/account/balances [get]
+func (h *Handler) GetAccountBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Info().
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Account balances request received")
+
+	balances, err := h.client.GetAccountBalances(r.Context())
+	if err != nil {
+		respondAccountError(w, err, "Failed to get account balances")
+		return
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Account balances retrieved successfully",
+		Data:    balances,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// StoreAccountBalancesHandler snapshots the spot balances of the configured Binance account
+// @Summary Store exchange account balances
+// @Description Reads the Binance spot balances and stores them as a snapshot. Requires the admin token.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Success 200 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Security AdminToken
+// @Router /admin/account/store-balances [post]
+func (h *Handler) StoreAccountBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Info().
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Account balance snapshot request received")
+
+	// The snapshot is stored with its audit event
+	snapshot, err := h.accounts.Snapshot(api.AuditContext(r))
+	if err != nil {
+		respondAccountError(w, err, "Failed to store account balances")
+		return
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Account balances retrieved and stored",
+		Data:    snapshot,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetAccountTransfersHandler returns deposits and withdrawals of the configured Binance account
+// @Summary Get exchange account transfers
+// @Description Returns deposit and withdrawal history of the Binance account. Transfer types the API key may not read are listed as unavailable. Requires the admin token.
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param start query string false "Start date in YYYY-MM-DD format, defaults to 30 days ago"
+// @Param end query string false "End date in YYYY-MM-DD format, exclusive; defaults to now"
+// @Param type query string false "deposit, withdrawal or all (default)"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 403 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Security AdminToken
+// @Router /admin/account/transfers [get]
+func (h *Handler) GetAccountTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	end := time.Now().UTC()
+	if v := query.Get("end"); v != "" {
+		date, err := time.Parse(dateFormat, v)
+		if err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid end date format. Use YYYY-MM-DD")
+			return
+		}
+		end = date
+	}
+
+	start := end.AddDate(0, 0, -30)
+	if v := query.Get("start"); v != "" {
+		date, err := time.Parse(dateFormat, v)
+		if err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid start date format. Use YYYY-MM-DD")
+			return
+		}
+		start = date
+	}
+
+	fetchers := map[string]func(ctx context.Context, start, end time.Time) ([]Transfer, error){
+		TransferDeposit:    h.client.GetDeposits,
+		TransferWithdrawal: h.client.GetWithdrawals,
+	}
+	types := []string{TransferDeposit, TransferWithdrawal}
+	switch t := query.Get("type"); t {
+	case "", "all":
+	case TransferDeposit, TransferWithdrawal:
+		types = []string{t}
+	default:
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid type. Use deposit, withdrawal or all")
+		return
+	}
+
+	logger.Info().
+		Time("start", start).
+		Time("end", end).
+		Strs("types", types).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Account transfers request received")
+
+	transfers := []Transfer{}
+	unavailable := []string{}
+	for _, t := range types {
+		list, err := fetchers[t](r.Context(), start, end)
+		// Keys are often created without withdrawal permissions, so report what is readable
+		if errors.Is(err, ErrPermissionDenied) && len(types) > 1 {
+			logger.Warn().Err(err).Str("type", t).Msg("Transfer history not permitted for API key")
+			unavailable = append(unavailable, t)
+			continue
+		}
+		if err != nil {
+			respondAccountError(w, err, "Failed to get account transfers")
+			return
+		}
+		transfers = append(transfers, list...)
+	}
+
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].Time.After(transfers[j].Time) })
+
+	response := api.Response{
+		Success: true,
+		Message: "Account transfers retrieved successfully",
+		Data: map[string]interface{}{
+			"start":       start,
+			"end":         end,
+			"transfers":   transfers,
+			"unavailable": unavailable,
+		},
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// respondAccountError writes the response for a failed signed account request
+func respondAccountError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, ErrNoCredentials):
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Binance account access is not configured")
+	case errors.Is(err, ErrPermissionDenied):
+		logger.Warn().Err(err).Msg("Binance API key rejected")
+		api.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, ErrInvalidWindow):
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrRateLimited):
+		logger.Warn().Err(err).Msg("Account request blocked by rate limit")
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Market data temporarily unavailable, please retry later")
+	default:
+		logger.Error().Err(err).Msg(message)
+		api.RespondWithError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// PegHandler handles stablecoin peg requests
+type PegHandler struct {
+	monitor *PegMonitor
+}
+
+// NewPegHandler creates a handler reporting on the given peg monitor
+func NewPegHandler(monitor *PegMonitor) *PegHandler {
+	return &PegHandler{monitor: monitor}
+}
+
+// GetPegsHandler returns the current peg deviation of each monitored stablecoin and recent depeg incidents
+// @Summary Get stablecoin peg status
+// @Description Returns each monitored stablecoin's exchange and on-chain price against its peg, and depeg incidents of the last 30 days
+// @Tags market
+// @Accept json
+// @Produce json
+// @Success 200 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Router /market/pegs [get]
+func (h *PegHandler) GetPegsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	logger.Info().
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Peg status request received")
+
+	report, err := h.monitor.Report(r.Context())
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get peg status")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to get peg status")
+		return
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Peg status retrieved successfully",
+		Data:    report,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// parseTimestamp parses an RFC3339 timestamp or a unix time in seconds or milliseconds
+func parseTimestamp(value string) (time.Time, error) {
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Values this large can only be milliseconds; seconds would be thousands of years out
+		if n > 1e11 || n < -1e11 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// applyCurrency converts a price's USD value into a fiat currency at the rate for rateDate,
+// using toUSD to value prices that are not quoted in a USD stablecoin.
+// It writes an error response and returns false if the conversion fails.
+func (h *Handler) applyCurrency(w http.ResponseWriter, r *http.Request, priceData *PriceData, currency string, rateDate time.Time, toUSD func(price float64) (float64, error)) bool {
+	ctx := r.Context()
+
+	currency, err := fx.NormalizeCurrency(currency)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid currency code. Use a 3-letter ISO 4217 code such as EUR")
+		return false
+	}
+
+	if h.rates == nil {
+		api.RespondWithError(w, http.StatusServiceUnavailable, "Currency conversion is not available")
+		return false
+	}
+
+	// A USD value is needed before it can be converted into another currency
+	if priceData.USD == 0 {
+		usdPrice, err := toUSD(priceData.Price)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("symbol", priceData.Symbol).
+				Msg("Failed to convert price to USD for currency conversion")
+			api.RespondWithError(w, http.StatusInternalServerError, "Failed to convert price to USD")
+			return false
+		}
+		priceData.USD = usdPrice
+	}
+
+	conversion, err := h.rates.Convert(ctx, priceData.USD, currency, rateDate)
+	if errors.Is(err, fx.ErrRateNotFound) {
+		api.RespondWithError(w, http.StatusNotFound, "No exchange rate available for "+currency+" on "+rateDate.Format(dateFormat))
+		return false
+	} else if err != nil {
+		logger.Error().
+			Err(err).
+			Str("currency", currency).
+			Msg("Failed to convert price to fiat currency")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to convert price to "+currency)
+		return false
+	}
+
+	priceData.Fiat = conversion
+	return true
 }
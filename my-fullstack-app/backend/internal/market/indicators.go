@@ -0,0 +1,407 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+)
+
+const (
+	// Supported technical indicators
+	IndicatorSMA       = "sma"
+	IndicatorEMA       = "ema"
+	IndicatorRSI       = "rsi"
+	IndicatorBollinger = "bollinger"
+	IndicatorVWAP      = "vwap"
+
+	// Defaults used when a window is not given
+	defaultMAPeriod        = 20
+	defaultRSIPeriod       = 14
+	defaultBollingerStdDev = 2.0
+	defaultIndicatorLimit  = 100
+
+	// Largest window and number of points a single request may ask for
+	maxIndicatorPeriod = 200
+	maxIndicatorLimit  = 500
+
+	// Binance returns at most this many candles per klines request
+	maxKlinesPerRequest = 1000
+)
+
+var (
+	// ErrInvalidIndicator is returned for an unknown indicator name
+	ErrInvalidIndicator = errors.New("invalid indicator")
+	// ErrInvalidInterval is returned for a candle interval Binance does not offer
+	ErrInvalidInterval = errors.New("invalid candle interval")
+	// ErrInvalidWindow is returned for out of range indicator windows or limits
+	ErrInvalidWindow = errors.New("invalid indicator window")
+)
+
+// klineIntervals are the fixed-length candle sizes indicators can be computed over
+var klineIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// IndicatorParams configures an indicator computation
+type IndicatorParams struct {
+	Interval string  // Candle interval, e.g. 1h or 1d
+	Period   int     // Window length in candles; 0 uses the indicator default
+	StdDev   float64 // Band width in standard deviations for Bollinger bands
+	Limit    int     // Number of points to return
+}
+
+// IndicatorPoint is the indicator value for one candle
+type IndicatorPoint struct {
+	OpenTime  time.Time `json:"openTime"`
+	CloseTime time.Time `json:"closeTime"`
+	Close     float64   `json:"close"`
+	Value     float64   `json:"value"`           // Middle band for Bollinger bands
+	Upper     *float64  `json:"upper,omitempty"` // Bollinger upper band
+	Lower     *float64  `json:"lower,omitempty"` // Bollinger lower band
+}
+
+// IndicatorSeries is an indicator computed over a symbol's candles
+type IndicatorSeries struct {
+	Symbol    string           `json:"symbol"`
+	Interval  string           `json:"interval"`
+	Indicator string           `json:"indicator"`
+	Period    int              `json:"period,omitempty"` // 0 for a VWAP anchored at the first point
+	StdDev    float64          `json:"stdDev,omitempty"`
+	Points    []IndicatorPoint `json:"points"`
+}
+
+// normalize validates params for an indicator and fills in defaults
+func (p IndicatorParams) normalize(indicator string) (IndicatorParams, error) {
+	if p.Interval == "" {
+		p.Interval = defaultInterval
+	}
+	if _, ok := klineIntervals[p.Interval]; !ok {
+		return p, fmt.Errorf("%w: %q", ErrInvalidInterval, p.Interval)
+	}
+
+	if p.Period < 0 || p.Period > maxIndicatorPeriod {
+		return p, fmt.Errorf("%w: period must be between 1 and %d", ErrInvalidWindow, maxIndicatorPeriod)
+	}
+	if p.Limit < 0 || p.Limit > maxIndicatorLimit {
+		return p, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidWindow, maxIndicatorLimit)
+	}
+	if p.Limit == 0 {
+		p.Limit = defaultIndicatorLimit
+	}
+
+	switch indicator {
+	case IndicatorSMA, IndicatorEMA:
+		if p.Period == 0 {
+			p.Period = defaultMAPeriod
+		}
+	case IndicatorRSI:
+		if p.Period == 0 {
+			p.Period = defaultRSIPeriod
+		}
+	case IndicatorBollinger:
+		if p.Period == 0 {
+			p.Period = defaultMAPeriod
+		}
+		if p.StdDev < 0 {
+			return p, fmt.Errorf("%w: stddev must be positive", ErrInvalidWindow)
+		}
+		if p.StdDev == 0 {
+			p.StdDev = defaultBollingerStdDev
+		}
+	case IndicatorVWAP:
+		// A zero period anchors the VWAP at the first returned candle
+	default:
+		return p, fmt.Errorf("%w: %q", ErrInvalidIndicator, indicator)
+	}
+
+	if indicator != IndicatorBollinger {
+		p.StdDev = 0
+	}
+
+	return p, nil
+}
+
+// warmup is the number of candles needed before the first reported point.
+// EMA and RSI depend on all earlier data, so they get extra history to converge.
+func warmup(indicator string, period int) int {
+	switch indicator {
+	case IndicatorEMA, IndicatorRSI:
+		return 3 * period
+	case IndicatorVWAP:
+		if period == 0 {
+			return 0
+		}
+		return period - 1
+	default:
+		return period - 1
+	}
+}
+
+// GetIndicator computes a technical indicator over the most recent candles of a symbol
+func (c *Client) GetIndicator(ctx context.Context, symbol, indicator string, params IndicatorParams) (*IndicatorSeries, error) {
+	indicator = strings.ToLower(indicator)
+	params, err := params.normalize(indicator)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	symbol = info.Symbol
+
+	total := params.Limit + warmup(indicator, params.Period)
+	if total > maxKlinesPerRequest {
+		total = maxKlinesPerRequest
+	}
+
+	// Start far enough back that the newest candle, which may still be open, is included
+	duration := klineIntervals[params.Interval]
+	start := time.Now().Truncate(duration).Add(-time.Duration(total-1) * duration)
+
+	logger.Debug().
+		Str("symbol", symbol).
+		Str("indicator", indicator).
+		Str("interval", params.Interval).
+		Int("period", params.Period).
+		Int("candles", total).
+		Msg("Computing indicator")
+
+	candles, err := c.getKlines(ctx, symbol, params.Interval, start, time.Time{}, total)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("symbol", symbol).
+			Str("interval", params.Interval).
+			Msg("Failed to get klines for indicator")
+		return nil, fmt.Errorf("failed to get klines: %w", err)
+	}
+
+	series := &IndicatorSeries{
+		Symbol:    symbol,
+		Interval:  params.Interval,
+		Indicator: indicator,
+		Period:    params.Period,
+		StdDev:    params.StdDev,
+		Points:    computeIndicator(candles, indicator, params),
+	}
+
+	logger.Info().
+		Str("symbol", symbol).
+		Str("indicator", indicator).
+		Int("points", len(series.Points)).
+		Msg("Successfully computed indicator")
+
+	return series, nil
+}
+
+// computeIndicator returns the last params.Limit defined points of an indicator over candles
+func computeIndicator(candles []candle, indicator string, params IndicatorParams) []IndicatorPoint {
+	closes := make([]float64, len(candles))
+	for i, k := range candles {
+		closes[i] = k.close
+	}
+
+	var values, upper, lower []float64
+	switch indicator {
+	case IndicatorSMA:
+		values = sma(closes, params.Period)
+	case IndicatorEMA:
+		values = ema(closes, params.Period)
+	case IndicatorRSI:
+		values = rsi(closes, params.Period)
+	case IndicatorBollinger:
+		values, upper, lower = bollinger(closes, params.Period, params.StdDev)
+	case IndicatorVWAP:
+		// An anchored VWAP starts at the first returned candle, not the first fetched one
+		anchor := 0
+		if params.Period == 0 && len(candles) > params.Limit {
+			anchor = len(candles) - params.Limit
+		}
+		values = append(nanSlice(anchor), vwap(candles[anchor:], params.Period)...)
+	}
+
+	points := []IndicatorPoint{}
+	for i, k := range candles {
+		if math.IsNaN(values[i]) {
+			continue
+		}
+
+		point := IndicatorPoint{
+			OpenTime:  k.openTime,
+			CloseTime: k.closeTime,
+			Close:     k.close,
+			Value:     values[i],
+		}
+		if upper != nil {
+			point.Upper = &upper[i]
+			point.Lower = &lower[i]
+		}
+		points = append(points, point)
+	}
+
+	if len(points) > params.Limit {
+		points = points[len(points)-params.Limit:]
+	}
+	return points
+}
+
+// nanSlice returns a slice of n NaN values, marking points without enough history
+func nanSlice(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// sma is the simple moving average of the last period values
+func sma(values []float64, period int) []float64 {
+	out := nanSlice(len(values))
+	if period <= 0 {
+		return out
+	}
+
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// ema is the exponential moving average with smoothing 2/(period+1), seeded with the SMA of the first period values
+func ema(values []float64, period int) []float64 {
+	out := nanSlice(len(values))
+	if period <= 0 || len(values) < period {
+		return out
+	}
+
+	alpha := 2 / float64(period+1)
+
+	var seed float64
+	for _, v := range values[:period] {
+		seed += v
+	}
+	out[period-1] = seed / float64(period)
+
+	for i := period; i < len(values); i++ {
+		out[i] = alpha*values[i] + (1-alpha)*out[i-1]
+	}
+	return out
+}
+
+// rsi is the relative strength index using Wilder's smoothing
+func rsi(values []float64, period int) []float64 {
+	out := nanSlice(len(values))
+	if period <= 0 || len(values) <= period {
+		return out
+	}
+
+	var avgGain, avgLoss float64
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		if change > 0 {
+			avgGain += change
+		} else {
+			avgLoss -= change
+		}
+	}
+	avgGain /= float64(period)
+	avgLoss /= float64(period)
+	out[period] = rsiValue(avgGain, avgLoss)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain, loss := 0.0, 0.0
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		out[i] = rsiValue(avgGain, avgLoss)
+	}
+	return out
+}
+
+func rsiValue(avgGain, avgLoss float64) float64 {
+	switch {
+	case avgLoss == 0 && avgGain == 0:
+		return 50
+	case avgLoss == 0:
+		return 100
+	default:
+		return 100 - 100/(1+avgGain/avgLoss)
+	}
+}
+
+// bollinger returns the SMA middle band and bands k population standard deviations above and below it
+func bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = sma(values, period)
+	upper = nanSlice(len(values))
+	lower = nanSlice(len(values))
+
+	for i := range values {
+		if math.IsNaN(middle[i]) {
+			continue
+		}
+
+		var variance float64
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+	}
+	return middle, upper, lower
+}
+
+// vwap is the volume weighted average of the typical price (high+low+close)/3, over a
+// rolling window of period candles, or cumulative from the first candle when period is 0
+func vwap(candles []candle, period int) []float64 {
+	out := nanSlice(len(candles))
+
+	var pv, volume float64
+	for i, k := range candles {
+		pv += (k.high + k.low + k.close) / 3 * k.volume
+		volume += k.volume
+		if period > 0 && i >= period {
+			old := candles[i-period]
+			pv -= (old.high + old.low + old.close) / 3 * old.volume
+			volume -= old.volume
+		}
+
+		if (period == 0 || i >= period-1) && volume > 0 {
+			out[i] = pv / volume
+		}
+	}
+	return out
+}
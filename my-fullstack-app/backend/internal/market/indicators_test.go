@@ -0,0 +1,216 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// assertSeries compares indicator output against expected values, where NaN marks warm-up points
+func assertSeries(t *testing.T, got, want []float64) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d values, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if math.IsNaN(want[i]) {
+			if !math.IsNaN(got[i]) {
+				t.Errorf("Expected no value at %d, got %f", i, got[i])
+			}
+			continue
+		}
+		if math.Abs(got[i]-want[i]) > 0.001 {
+			t.Errorf("Expected %f at %d, got %f", want[i], i, got[i])
+		}
+	}
+}
+
+// fixtureCandles builds hourly candles with the given closes; high and low equal the close
+func fixtureCandles(start time.Time, closes []float64, volumes []float64) []candle {
+	candles := make([]candle, len(closes))
+	for i, c := range closes {
+		open := start.Add(time.Duration(i) * time.Hour)
+		candles[i] = candle{
+			openTime:  open,
+			closeTime: open.Add(time.Hour - time.Millisecond),
+			open:      c,
+			high:      c,
+			low:       c,
+			close:     c,
+			volume:    volumes[i],
+		}
+	}
+	return candles
+}
+
+func TestMovingAverages(t *testing.T) {
+	nan := math.NaN()
+
+	testCases := []struct {
+		name   string
+		fn     func([]float64, int) []float64
+		values []float64
+		period int
+		want   []float64
+	}{
+		{name: "SMA", fn: sma, values: []float64{1, 2, 3, 4, 5, 6}, period: 3, want: []float64{nan, nan, 2, 3, 4, 5}},
+		{name: "SMA period 1", fn: sma, values: []float64{4, 8}, period: 1, want: []float64{4, 8}},
+		{name: "SMA too few values", fn: sma, values: []float64{1, 2}, period: 3, want: []float64{nan, nan}},
+		{name: "EMA", fn: ema, values: []float64{1, 2, 3, 4, 5}, period: 3, want: []float64{nan, nan, 2, 3, 4}},
+		{name: "EMA reacts to jump", fn: ema, values: []float64{2, 2, 2, 10}, period: 3, want: []float64{nan, nan, 2, 6}},
+		{name: "EMA too few values", fn: ema, values: []float64{1, 2}, period: 3, want: []float64{nan, nan}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertSeries(t, tc.fn(tc.values, tc.period), tc.want)
+		})
+	}
+}
+
+func TestRSI(t *testing.T) {
+	nan := math.NaN()
+
+	testCases := []struct {
+		name   string
+		values []float64
+		period int
+		want   []float64
+	}{
+		// Gains 1,1 and loss 1 give RS 2, then Wilder smoothing of a further gain gives RS 3.5
+		{name: "Mixed moves", values: []float64{1, 2, 3, 2, 3}, period: 3, want: []float64{nan, nan, nan, 66.667, 77.778}},
+		{name: "Only gains", values: []float64{1, 2, 3, 4}, period: 2, want: []float64{nan, nan, 100, 100}},
+		{name: "Only losses", values: []float64{4, 3, 2, 1}, period: 2, want: []float64{nan, nan, 0, 0}},
+		{name: "Flat", values: []float64{5, 5, 5}, period: 2, want: []float64{nan, nan, 50}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertSeries(t, rsi(tc.values, tc.period), tc.want)
+		})
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	// Mean 5 and population standard deviation 2
+	values := []float64{2, 4, 4, 4, 5, 5, 7, 9}
+
+	middle, upper, lower := bollinger(values, len(values), 2)
+
+	last := len(values) - 1
+	assertSeries(t, middle[last:], []float64{5})
+	assertSeries(t, upper[last:], []float64{9})
+	assertSeries(t, lower[last:], []float64{1})
+
+	if !math.IsNaN(upper[last-1]) || !math.IsNaN(lower[last-1]) {
+		t.Errorf("Expected no bands before the window is full")
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	candles := fixtureCandles(start, []float64{10, 20, 30}, []float64{1, 3, 0})
+
+	// (10*1 + 20*3) / 4 = 17.5; a candle without volume leaves the VWAP unchanged
+	assertSeries(t, vwap(candles, 0), []float64{10, 17.5, 17.5})
+
+	// Rolling over two candles drops the first one, and a window without volume has no VWAP
+	assertSeries(t, vwap(candles, 2), []float64{math.NaN(), 17.5, 20})
+	assertSeries(t, vwap(candles, 1), []float64{10, 20, math.NaN()})
+}
+
+func TestComputeIndicatorTrimsToLimit(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	candles := fixtureCandles(start, []float64{1, 2, 3, 4, 5, 6}, []float64{1, 1, 1, 1, 1, 2})
+
+	points := computeIndicator(candles, IndicatorSMA, IndicatorParams{Period: 3, Limit: 2})
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+	if points[0].Value != 4 || points[1].Value != 5 {
+		t.Errorf("Expected values 4 and 5, got %f and %f", points[0].Value, points[1].Value)
+	}
+	if !points[1].OpenTime.Equal(candles[5].openTime) {
+		t.Errorf("Expected last point at %s, got %s", candles[5].openTime, points[1].OpenTime)
+	}
+
+	// An anchored VWAP only covers the returned candles
+	points = computeIndicator(candles, IndicatorVWAP, IndicatorParams{Limit: 2})
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 points, got %d", len(points))
+	}
+	if points[0].Value != 5 || math.Abs(points[1].Value-17.0/3) > 0.001 {
+		t.Errorf("Expected anchored VWAP 5 and 5.667, got %f and %f", points[0].Value, points[1].Value)
+	}
+
+	points = computeIndicator(candles, IndicatorBollinger, IndicatorParams{Period: 3, StdDev: 2, Limit: 1})
+	if len(points) != 1 || points[0].Upper == nil || points[0].Lower == nil {
+		t.Fatalf("Expected one point with bands, got %+v", points)
+	}
+	if *points[0].Upper <= points[0].Value || *points[0].Lower >= points[0].Value {
+		t.Errorf("Expected bands around %f, got %f and %f", points[0].Value, *points[0].Lower, *points[0].Upper)
+	}
+}
+
+func TestGetIndicator(t *testing.T) {
+	// Thirty hourly candles ending with the current one, closing at 1..30
+	last := time.Now().Truncate(time.Hour)
+	var klines []stubKline
+	for i := 1; i <= 30; i++ {
+		price := strconv.Itoa(i)
+		klines = append(klines, stubKline{
+			openTime: last.Add(time.Duration(i-30) * time.Hour),
+			interval: time.Hour,
+			open:     price, high: price, low: price, close: price, volume: "1",
+		})
+	}
+
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT"},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/klines": klinesRoute(map[string][]stubKline{"1h": klines}),
+	})
+
+	series, err := client.GetIndicator(context.Background(), "btcusdt", "SMA", IndicatorParams{Interval: "1h", Period: 5, Limit: 3})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if series.Symbol != "BTCUSDT" || series.Indicator != IndicatorSMA || series.Period != 5 {
+		t.Errorf("Unexpected series metadata: %+v", series)
+	}
+	if len(series.Points) != 3 {
+		t.Fatalf("Expected 3 points, got %d", len(series.Points))
+	}
+	for i, want := range []float64{26, 27, 28} {
+		if series.Points[i].Value != want {
+			t.Errorf("Expected point %d to be %f, got %f", i, want, series.Points[i].Value)
+		}
+	}
+
+	errorCases := []struct {
+		name      string
+		indicator string
+		params    IndicatorParams
+		wantErr   error
+	}{
+		{name: "Unknown indicator", indicator: "macd", params: IndicatorParams{}, wantErr: ErrInvalidIndicator},
+		{name: "Unknown interval", indicator: IndicatorSMA, params: IndicatorParams{Interval: "7m"}, wantErr: ErrInvalidInterval},
+		{name: "Period too long", indicator: IndicatorEMA, params: IndicatorParams{Period: 1000}, wantErr: ErrInvalidWindow},
+		{name: "Limit too high", indicator: IndicatorRSI, params: IndicatorParams{Limit: 5000}, wantErr: ErrInvalidWindow},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := client.GetIndicator(context.Background(), "BTCUSDT", tc.indicator, tc.params)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
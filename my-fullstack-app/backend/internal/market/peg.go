@@ -0,0 +1,366 @@
+package market
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	// Deviation from the peg, in basis points, at which a stablecoin counts as depegged
+	defaultDepegThresholdBps = 50
+
+	// How often the monitor checks prices by default
+	defaultPegCheckInterval = 5 * time.Minute
+
+	// Incidents listed by the pegs report
+	pegIncidentWindow = 30 * 24 * time.Hour
+	maxPegIncidents   = 50
+)
+
+// ErrNoDEXPool is returned by a DEXPriceReader for assets it has no pool for
+var ErrNoDEXPool = errors.New("no dex pool for asset")
+
+// PegTarget describes how a stablecoin is priced against its peg on the exchange. Binance
+// has no fiat pairs, so each stablecoin is priced against another one that is assumed to
+// hold its peg; on-chain pools give a second, independent price.
+type PegTarget struct {
+	Asset   string `json:"asset"`
+	Peg     string `json:"peg"`
+	Pair    string `json:"pair"`
+	Inverse bool   `json:"inverse"` // The pair quotes another asset in this one, so its price is inverted
+}
+
+// DefaultPegTargets covers the stablecoins in the common token list
+var DefaultPegTargets = []PegTarget{
+	{Asset: "USDT", Peg: "USD", Pair: "USDCUSDT", Inverse: true},
+	{Asset: "USDC", Peg: "USD", Pair: "USDCUSDT"},
+	{Asset: "DAI", Peg: "USD", Pair: "DAIUSDT"},
+}
+
+// DEXPriceReader reads stablecoin prices from on-chain pools
+type DEXPriceReader interface {
+	DEXPrice(ctx context.Context, asset string) (price float64, source string, err error)
+}
+
+// PegStore persists depeg incidents
+type PegStore interface {
+	SaveEvent(ctx context.Context, event *models.PegDeviationEvent) error
+	OpenEvents(ctx context.Context) ([]models.PegDeviationEvent, error)
+	RecentEvents(ctx context.Context, since time.Time, limit int) ([]models.PegDeviationEvent, error)
+}
+
+// DatabasePegStore persists incidents in the peg_deviation_events Postgres table
+type DatabasePegStore struct {
+	db *sql.DB
+}
+
+// NewDatabasePegStore creates an incident store backed by Postgres
+func NewDatabasePegStore(db *sql.DB) *DatabasePegStore {
+	return &DatabasePegStore{db: db}
+}
+
+// SaveEvent inserts or updates an incident
+func (s *DatabasePegStore) SaveEvent(ctx context.Context, event *models.PegDeviationEvent) error {
+	return database.SavePegDeviationEvent(s.db, event)
+}
+
+// OpenEvents returns the incidents that have not ended
+func (s *DatabasePegStore) OpenEvents(ctx context.Context) ([]models.PegDeviationEvent, error) {
+	return database.GetOpenPegDeviationEvents(s.db)
+}
+
+// RecentEvents returns incidents ongoing at or after since, newest first
+func (s *DatabasePegStore) RecentEvents(ctx context.Context, since time.Time, limit int) ([]models.PegDeviationEvent, error) {
+	return database.GetRecentPegDeviationEvents(s.db, since, limit)
+}
+
+// PegMonitorConfig holds the depeg threshold and check schedule
+type PegMonitorConfig struct {
+	ThresholdBps float64
+	Interval     time.Duration
+}
+
+// PegMonitorConfigFromEnv reads PEG_DEPEG_THRESHOLD_BPS and PEG_MONITOR_INTERVAL, falling back
+// to the defaults for unset or invalid values
+func PegMonitorConfigFromEnv() PegMonitorConfig {
+	config := PegMonitorConfig{
+		ThresholdBps: defaultDepegThresholdBps,
+		Interval:     defaultPegCheckInterval,
+	}
+
+	if v := os.Getenv("PEG_DEPEG_THRESHOLD_BPS"); v != "" {
+		if bps, err := strconv.ParseFloat(v, 64); err == nil && bps > 0 {
+			config.ThresholdBps = bps
+		} else {
+			logger.Warn().Str("value", v).Msg("Ignoring invalid PEG_DEPEG_THRESHOLD_BPS")
+		}
+	}
+	if v := os.Getenv("PEG_MONITOR_INTERVAL"); v != "" {
+		if interval, err := time.ParseDuration(v); err == nil && interval > 0 {
+			config.Interval = interval
+		} else {
+			logger.Warn().Str("value", v).Msg("Ignoring invalid PEG_MONITOR_INTERVAL")
+		}
+	}
+
+	return config
+}
+
+// PegStatus is the latest check of one stablecoin against one price source
+type PegStatus struct {
+	Asset        string    `json:"asset"`
+	Peg          string    `json:"peg"`
+	Source       string    `json:"source"`
+	Price        float64   `json:"price,omitempty"`
+	DeviationBps float64   `json:"deviationBps"` // Signed, negative below the peg
+	Depegged     bool      `json:"depegged"`
+	CheckedAt    time.Time `json:"checkedAt"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// PegReport is the current peg status of all monitored stablecoins with recent incidents
+type PegReport struct {
+	ThresholdBps float64                    `json:"thresholdBps"`
+	CheckedAt    time.Time                  `json:"checkedAt"`
+	Statuses     []PegStatus                `json:"statuses"`
+	Incidents    []models.PegDeviationEvent `json:"incidents"`
+	// IncidentsError explains why incidents are missing, such as the database being down
+	IncidentsError string `json:"incidentsError,omitempty"`
+}
+
+// PegMonitor periodically compares stablecoin prices against their pegs and records
+// incidents while they trade beyond the threshold
+type PegMonitor struct {
+	client  *Client
+	targets []PegTarget
+	dex     DEXPriceReader
+	store   PegStore
+	config  PegMonitorConfig
+
+	mu        sync.Mutex
+	loaded    bool
+	open      map[string]*models.PegDeviationEvent // By asset and source
+	statuses  []PegStatus
+	checkedAt time.Time
+}
+
+// NewPegMonitor creates a monitor of the given stablecoins. Dex may be nil to only use
+// exchange prices.
+func NewPegMonitor(client *Client, targets []PegTarget, dex DEXPriceReader, store PegStore, config PegMonitorConfig) *PegMonitor {
+	return &PegMonitor{
+		client:  client,
+		targets: targets,
+		dex:     dex,
+		store:   store,
+		config:  config,
+		open:    make(map[string]*models.PegDeviationEvent),
+	}
+}
+
+// Run checks the pegs on the configured interval until ctx is cancelled
+func (m *PegMonitor) Run(ctx context.Context) {
+	logger.Info().
+		Dur("interval", m.config.Interval).
+		Float64("threshold_bps", m.config.ThresholdBps).
+		Msg("Starting stablecoin peg monitor")
+
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Check(ctx); err != nil {
+			logger.Error().Err(err).Msg("Peg check failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check prices every stablecoin against its peg and updates the incident records
+func (m *PegMonitor) Check(ctx context.Context) ([]PegStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Continue incidents that were open before a restart. While they cannot be loaded the
+	// pegs are still priced, but incidents are not recorded, so none is opened twice; the
+	// load is tried again on the next check.
+	if !m.loaded {
+		events, err := m.store.OpenEvents(ctx)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to load open peg incidents, not recording incidents until loaded")
+		} else {
+			for i := range events {
+				m.open[pegKey(events[i].Asset, events[i].Source)] = &events[i]
+			}
+			m.loaded = true
+		}
+	}
+
+	now := time.Now().UTC()
+	var statuses []PegStatus
+
+	for _, target := range m.targets {
+		status := PegStatus{Asset: target.Asset, Peg: target.Peg, Source: "binance:" + target.Pair, CheckedAt: now}
+		price, err := m.exchangePrice(ctx, target)
+		statuses = append(statuses, m.observe(ctx, status, price, err))
+
+		if m.dex == nil {
+			continue
+		}
+		price, source, err := m.dex.DEXPrice(ctx, target.Asset)
+		if errors.Is(err, ErrNoDEXPool) {
+			continue
+		}
+		status = PegStatus{Asset: target.Asset, Peg: target.Peg, Source: source, CheckedAt: now}
+		statuses = append(statuses, m.observe(ctx, status, price, err))
+	}
+
+	m.statuses = statuses
+	m.checkedAt = now
+
+	return statuses, nil
+}
+
+// exchangePrice prices a stablecoin through its exchange pair
+func (m *PegMonitor) exchangePrice(ctx context.Context, target PegTarget) (float64, error) {
+	data, err := m.client.GetCurrentPrice(ctx, target.Pair)
+	if err != nil {
+		return 0, err
+	}
+	if data.Price <= 0 {
+		return 0, fmt.Errorf("invalid %s price: %f", target.Pair, data.Price)
+	}
+	if target.Inverse {
+		return 1 / data.Price, nil
+	}
+	return data.Price, nil
+}
+
+// observe completes a status with a price and opens, extends or closes its incident
+func (m *PegMonitor) observe(ctx context.Context, status PegStatus, price float64, err error) PegStatus {
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("asset", status.Asset).
+			Str("source", status.Source).
+			Msg("Failed to price stablecoin")
+		status.Error = err.Error()
+		return status
+	}
+
+	status.Price = price
+	status.DeviationBps = (price - 1) * 1e4
+	status.Depegged = math.Abs(status.DeviationBps) >= m.config.ThresholdBps
+	if !m.loaded {
+		return status
+	}
+
+	key := pegKey(status.Asset, status.Source)
+	event := m.open[key]
+
+	switch {
+	case status.Depegged && event == nil:
+		event = &models.PegDeviationEvent{
+			Asset:           status.Asset,
+			Peg:             status.Peg,
+			Source:          status.Source,
+			StartedAt:       status.CheckedAt,
+			LastSeenAt:      status.CheckedAt,
+			StartPrice:      price,
+			LastPrice:       price,
+			MaxDeviationBps: status.DeviationBps,
+		}
+		m.open[key] = event
+		logger.Warn().
+			Str("asset", status.Asset).
+			Str("source", status.Source).
+			Float64("price", price).
+			Float64("deviation_bps", status.DeviationBps).
+			Msg("Stablecoin depegged")
+
+	case status.Depegged:
+		event.LastSeenAt = status.CheckedAt
+		event.LastPrice = price
+		if math.Abs(status.DeviationBps) > math.Abs(event.MaxDeviationBps) {
+			event.MaxDeviationBps = status.DeviationBps
+		}
+
+	case event != nil:
+		endedAt := status.CheckedAt
+		event.EndedAt = &endedAt
+		event.LastPrice = price
+		delete(m.open, key)
+		logger.Info().
+			Str("asset", status.Asset).
+			Str("source", status.Source).
+			Dur("duration", endedAt.Sub(event.StartedAt)).
+			Msg("Stablecoin back on peg")
+
+	default:
+		return status
+	}
+
+	if err := m.store.SaveEvent(ctx, event); err != nil {
+		logger.Error().
+			Err(err).
+			Str("asset", status.Asset).
+			Msg("Failed to record peg incident")
+	}
+
+	return status
+}
+
+// Report returns the current peg statuses, checking again if the last check is older than
+// the interval, together with the incidents of the last 30 days
+func (m *PegMonitor) Report(ctx context.Context) (*PegReport, error) {
+	m.mu.Lock()
+	stale := time.Since(m.checkedAt) > m.config.Interval
+	m.mu.Unlock()
+
+	if stale {
+		if _, err := m.Check(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// The prices are reported even when the incidents cannot be read
+	var incidentsErr string
+	incidents, err := m.store.RecentEvents(ctx, time.Now().Add(-pegIncidentWindow), maxPegIncidents)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to get peg incidents")
+		incidentsErr = "Peg incidents are unavailable"
+	}
+	if incidents == nil {
+		incidents = []models.PegDeviationEvent{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return &PegReport{
+		ThresholdBps:   m.config.ThresholdBps,
+		CheckedAt:      m.checkedAt,
+		Statuses:       append([]PegStatus{}, m.statuses...),
+		Incidents:      incidents,
+		IncidentsError: incidentsErr,
+	}, nil
+}
+
+func pegKey(asset, source string) string {
+	return asset + "|" + source
+}
@@ -0,0 +1,206 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// memoryPegStore keeps incidents in memory
+type memoryPegStore struct {
+	events []*models.PegDeviationEvent
+}
+
+func (s *memoryPegStore) SaveEvent(ctx context.Context, event *models.PegDeviationEvent) error {
+	if event.ID == 0 {
+		event.ID = int64(len(s.events) + 1)
+		s.events = append(s.events, event)
+		return nil
+	}
+	*s.events[event.ID-1] = *event
+	return nil
+}
+
+func (s *memoryPegStore) OpenEvents(ctx context.Context) ([]models.PegDeviationEvent, error) {
+	var open []models.PegDeviationEvent
+	for _, e := range s.events {
+		if e.EndedAt == nil {
+			open = append(open, *e)
+		}
+	}
+	return open, nil
+}
+
+func (s *memoryPegStore) RecentEvents(ctx context.Context, since time.Time, limit int) ([]models.PegDeviationEvent, error) {
+	var recent []models.PegDeviationEvent
+	for i := len(s.events) - 1; i >= 0 && len(recent) < limit; i-- {
+		recent = append(recent, *s.events[i])
+	}
+	return recent, nil
+}
+
+// fakeDEX serves fixed pool prices
+type fakeDEX map[string]float64
+
+func (f fakeDEX) DEXPrice(ctx context.Context, asset string) (float64, string, error) {
+	price, ok := f[asset]
+	if !ok {
+		return 0, "", ErrNoDEXPool
+	}
+	return price, "dex:" + asset, nil
+}
+
+func TestPegMonitor(t *testing.T) {
+	symbols := []stubSymbol{
+		{symbol: "USDCUSDT", base: "USDC", quote: "USDT", price: "1.0001"},
+	}
+	client := newStubClient(t, symbols, nil)
+	// Every check must see the latest stub price
+	client.cache = newPriceCache(-time.Second)
+
+	store := &memoryPegStore{}
+	targets := []PegTarget{
+		{Asset: "USDT", Peg: "USD", Pair: "USDCUSDT", Inverse: true},
+		{Asset: "USDC", Peg: "USD", Pair: "USDCUSDT"},
+		{Asset: "DAI", Peg: "USD", Pair: "DAIUSDT"},
+	}
+	monitor := NewPegMonitor(client, targets, fakeDEX{"USDC": 0.999}, store, PegMonitorConfig{ThresholdBps: 50, Interval: time.Hour})
+
+	statuses, err := monitor.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// USDT and USDC from the exchange, USDC from the pool, and DAI without a listed pair
+	if len(statuses) != 4 {
+		t.Fatalf("Expected 4 statuses, got %+v", statuses)
+	}
+	if usdt := statuses[0]; math.Abs(usdt.DeviationBps+1) > 0.01 || usdt.Depegged {
+		t.Errorf("Expected USDT about 1 bps below peg, got %+v", usdt)
+	}
+	if dex := statuses[2]; dex.Source != "dex:USDC" || math.Abs(dex.DeviationBps+10) > 1e-6 {
+		t.Errorf("Expected USDC pool 10 bps below peg, got %+v", dex)
+	}
+	if dai := statuses[3]; dai.Error == "" {
+		t.Errorf("Expected DAI to fail without a pair, got %+v", dai)
+	}
+	if len(store.events) != 0 {
+		t.Fatalf("Expected no incidents on peg, got %d", len(store.events))
+	}
+
+	// USDC trading 2% below USDT depegs both sides of the pair
+	symbols[0].price = "0.98"
+	if _, err := monitor.Check(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(store.events) != 2 {
+		t.Fatalf("Expected 2 incidents, got %d", len(store.events))
+	}
+	usdc := store.events[1]
+	if usdc.Asset != "USDC" || usdc.Source != "binance:USDCUSDT" || math.Abs(usdc.MaxDeviationBps+200) > 1e-6 {
+		t.Errorf("Expected a USDC incident 200 bps below peg, got %+v", usdc)
+	}
+
+	// A deeper fall extends the same incident
+	symbols[0].price = "0.95"
+	if _, err := monitor.Check(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(store.events) != 2 || math.Abs(usdc.MaxDeviationBps+500) > 1e-6 || usdc.EndedAt != nil {
+		t.Errorf("Expected the open incident to reach 500 bps, got %+v", usdc)
+	}
+
+	// A restarted monitor picks the open incidents back up and closes them on recovery
+	monitor = NewPegMonitor(client, targets, nil, store, PegMonitorConfig{ThresholdBps: 50, Interval: time.Hour})
+	symbols[0].price = "1"
+	report, err := monitor.Report(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(store.events) != 2 {
+		t.Fatalf("Expected no new incidents, got %d", len(store.events))
+	}
+	if len(report.Incidents) != 2 {
+		t.Fatalf("Expected 2 incidents in the report, got %+v", report.Incidents)
+	}
+	for _, e := range report.Incidents {
+		if e.EndedAt == nil || e.LastPrice == 0 {
+			t.Errorf("Expected incident to be closed, got %+v", e)
+		}
+	}
+	if report.ThresholdBps != 50 || len(report.Statuses) != 3 {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestPegMonitorConfigFromEnv(t *testing.T) {
+	t.Setenv("PEG_DEPEG_THRESHOLD_BPS", "25")
+	t.Setenv("PEG_MONITOR_INTERVAL", "bogus")
+
+	config := PegMonitorConfigFromEnv()
+	if config.ThresholdBps != 25 || config.Interval != defaultPegCheckInterval {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+}
+
+func TestPegMonitorStoreFailure(t *testing.T) {
+	symbols := []stubSymbol{
+		{symbol: "USDCUSDT", base: "USDC", quote: "USDT", price: "0.98"},
+	}
+	client := newStubClient(t, symbols, nil)
+	targets := []PegTarget{{Asset: "USDC", Peg: "USD", Pair: "USDCUSDT"}}
+	store := &recoveringPegStore{failing: true}
+	monitor := NewPegMonitor(client, targets, nil, store, PegMonitorConfig{ThresholdBps: 50, Interval: time.Hour})
+
+	// Without the open incidents the pegs are still priced, but no incident is recorded
+	report, err := monitor.Report(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(report.Statuses) != 1 || !report.Statuses[0].Depegged || report.IncidentsError == "" {
+		t.Errorf("Expected the depeg reported without incidents, got %+v", report)
+	}
+	if len(store.events) != 0 {
+		t.Fatalf("Expected no incident while they cannot be loaded, got %d", len(store.events))
+	}
+
+	// The load is retried on the next check
+	store.failing = false
+	if _, err := monitor.Check(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(store.events) != 1 {
+		t.Errorf("Expected the incident recorded once the store is back, got %d", len(store.events))
+	}
+}
+
+// recoveringPegStore fails every call while failing is set
+type recoveringPegStore struct {
+	memoryPegStore
+	failing bool
+}
+
+func (s *recoveringPegStore) SaveEvent(ctx context.Context, event *models.PegDeviationEvent) error {
+	if s.failing {
+		return errors.New("database unavailable")
+	}
+	return s.memoryPegStore.SaveEvent(ctx, event)
+}
+
+func (s *recoveringPegStore) OpenEvents(ctx context.Context) ([]models.PegDeviationEvent, error) {
+	if s.failing {
+		return nil, errors.New("database unavailable")
+	}
+	return s.memoryPegStore.OpenEvents(ctx)
+}
+
+func (s *recoveringPegStore) RecentEvents(ctx context.Context, since time.Time, limit int) ([]models.PegDeviationEvent, error) {
+	if s.failing {
+		return nil, errors.New("database unavailable")
+	}
+	return s.memoryPegStore.RecentEvents(ctx, since, limit)
+}
@@ -0,0 +1,280 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+
+	"github.com/adshao/go-binance/v2"
+)
+
+const (
+	// PolicyNearest returns the candle open or close, whichever is closer to the timestamp
+	PolicyNearest = "nearest"
+	// PolicyInterpolate linearly interpolates between the candle open and close
+	PolicyInterpolate = "interpolate"
+
+	// Candle size used to build calendar days in non-UTC timezones;
+	// fine enough for timezones with 30 and 45 minute offsets
+	localDayInterval = "15m"
+)
+
+// ErrInvalidPolicy is returned for an unknown price lookup policy
+var ErrInvalidPolicy = errors.New("invalid price policy")
+
+// klineInterval is a Binance candle size
+type klineInterval struct {
+	name     string
+	duration time.Duration
+}
+
+// timestampIntervals are tried from finest to coarsest until a candle covers the timestamp
+var timestampIntervals = []klineInterval{
+	{name: "1m", duration: time.Minute},
+	{name: "5m", duration: 5 * time.Minute},
+	{name: "15m", duration: 15 * time.Minute},
+	{name: "1h", duration: time.Hour},
+	{name: "4h", duration: 4 * time.Hour},
+	{name: "1d", duration: 24 * time.Hour},
+}
+
+// candle is a parsed Binance kline
+type candle struct {
+	openTime  time.Time
+	closeTime time.Time
+	open      float64
+	high      float64
+	low       float64
+	close     float64
+	volume    float64
+	trades    int64
+}
+
+// parseKline converts a Binance kline into a candle
+func parseKline(k *binance.Kline) (candle, error) {
+	open, err := strconv.ParseFloat(k.Open, 64)
+	if err != nil {
+		return candle{}, fmt.Errorf("failed to parse open price: %w", err)
+	}
+	closePrice, err := strconv.ParseFloat(k.Close, 64)
+	if err != nil {
+		return candle{}, fmt.Errorf("failed to parse close price: %w", err)
+	}
+	high, _ := strconv.ParseFloat(k.High, 64)
+	low, _ := strconv.ParseFloat(k.Low, 64)
+	volume, _ := strconv.ParseFloat(k.Volume, 64)
+
+	return candle{
+		openTime:  time.UnixMilli(k.OpenTime),
+		closeTime: time.UnixMilli(k.CloseTime),
+		open:      open,
+		high:      high,
+		low:       low,
+		close:     closePrice,
+		volume:    volume,
+		trades:    k.TradeNum,
+	}, nil
+}
+
+// ValidatePolicy checks a price lookup policy, defaulting to PolicyNearest
+func ValidatePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return PolicyNearest, nil
+	case PolicyNearest, PolicyInterpolate:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidPolicy, policy)
+	}
+}
+
+// GetPriceAt gets the price of a symbol at a precise moment, using the finest
+// candle interval that has data covering the timestamp
+func (c *Client) GetPriceAt(ctx context.Context, symbol string, ts time.Time, policy string) (*PriceData, error) {
+	policy, err := ValidatePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug().
+		Str("symbol", symbol).
+		Time("timestamp", ts).
+		Str("policy", policy).
+		Msg("Getting price at timestamp")
+
+	if ts.After(time.Now()) {
+		return nil, fmt.Errorf("timestamp %s is in the future", ts.Format(time.RFC3339))
+	}
+
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	symbol = info.Symbol
+
+	for _, interval := range timestampIntervals {
+		start := ts.Truncate(interval.duration)
+
+		klines, err := c.getKlines(ctx, symbol, interval.name, start, time.Time{}, 1)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("symbol", symbol).
+				Str("interval", interval.name).
+				Msg("Failed to get klines for timestamp")
+			return nil, fmt.Errorf("failed to get klines: %w", err)
+		}
+
+		if len(klines) == 0 {
+			continue
+		}
+
+		k := klines[0]
+
+		// A gap in finer candles returns the next candle instead, so try a coarser interval
+		if ts.Before(k.openTime) || ts.After(k.closeTime) {
+			continue
+		}
+
+		priceData := &PriceData{
+			Symbol:       symbol,
+			BaseAsset:    info.BaseAsset,
+			QuoteAsset:   info.QuoteAsset,
+			Price:        priceWithin(k, ts, policy),
+			Timestamp:    ts,
+			OpenTime:     k.openTime,
+			CloseTime:    k.closeTime,
+			Open:         k.open,
+			Close:        k.close,
+			High:         k.high,
+			Low:          k.low,
+			Volume:       k.volume,
+			NumberTrades: k.trades,
+			Interval:     interval.name,
+			Policy:       policy,
+		}
+
+		if isUSDAsset(info.QuoteAsset) {
+			priceData.USD = priceData.Price
+		}
+
+		logger.Info().
+			Str("symbol", symbol).
+			Time("timestamp", ts).
+			Str("interval", interval.name).
+			Float64("price", priceData.Price).
+			Msg("Successfully retrieved price at timestamp")
+
+		return priceData, nil
+	}
+
+	logger.Warn().
+		Str("symbol", symbol).
+		Time("timestamp", ts).
+		Msg("No candle covers timestamp")
+	return nil, fmt.Errorf("no price data found for %s at %s", symbol, ts.Format(time.RFC3339))
+}
+
+// priceWithin derives a price at ts from the candle that contains it
+func priceWithin(k candle, ts time.Time, policy string) float64 {
+	span := k.closeTime.Sub(k.openTime)
+	if span <= 0 {
+		return k.close
+	}
+	elapsed := ts.Sub(k.openTime)
+
+	if policy == PolicyInterpolate {
+		return k.open + (k.close-k.open)*float64(elapsed)/float64(span)
+	}
+
+	if elapsed*2 < span {
+		return k.open
+	}
+	return k.close
+}
+
+// GetHistoricalPriceIn gets the price of a symbol for a calendar day in a timezone.
+// Binance daily candles follow UTC days, so other timezones are built from smaller candles.
+func (c *Client) GetHistoricalPriceIn(ctx context.Context, symbol string, date time.Time, loc *time.Location) (*PriceData, error) {
+	if loc == time.UTC {
+		return c.GetHistoricalPrice(ctx, symbol, date)
+	}
+
+	info, err := c.ResolveSymbol(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+	symbol = info.Symbol
+
+	// Local day bounds; AddDate keeps 23 and 25 hour days correct across DST changes
+	startTime := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	endTime := startTime.AddDate(0, 0, 1)
+
+	logger.Debug().
+		Str("symbol", symbol).
+		Time("start", startTime).
+		Time("end", endTime).
+		Msg("Getting historical price for local day")
+
+	klines, err := c.getKlines(ctx, symbol, localDayInterval, startTime, endTime.Add(-time.Millisecond), 1000)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("symbol", symbol).
+			Time("date", startTime).
+			Msg("Failed to get historical klines")
+		return nil, fmt.Errorf("failed to get klines: %w", err)
+	}
+
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("no historical data found for %s on %s (%s)", symbol, startTime.Format(dateFormat), loc)
+	}
+
+	// Aggregate the candles of the local day into one daily candle
+	var day candle
+	for i, k := range klines {
+		if i == 0 {
+			day = k
+			continue
+		}
+		day.closeTime = k.closeTime
+		day.close = k.close
+		day.high = max(day.high, k.high)
+		day.low = min(day.low, k.low)
+		day.volume += k.volume
+		day.trades += k.trades
+	}
+
+	priceData := &PriceData{
+		Symbol:       symbol,
+		BaseAsset:    info.BaseAsset,
+		QuoteAsset:   info.QuoteAsset,
+		Price:        day.close,
+		Timestamp:    day.closeTime,
+		OpenTime:     day.openTime,
+		CloseTime:    day.closeTime,
+		Open:         day.open,
+		Close:        day.close,
+		High:         day.high,
+		Low:          day.low,
+		Volume:       day.volume,
+		NumberTrades: day.trades,
+		Interval:     localDayInterval,
+	}
+
+	if isUSDAsset(info.QuoteAsset) {
+		priceData.USD = day.close
+	}
+
+	logger.Info().
+		Str("symbol", symbol).
+		Time("date", startTime).
+		Float64("price", day.close).
+		Msg("Successfully retrieved historical price for local day")
+
+	return priceData, nil
+}
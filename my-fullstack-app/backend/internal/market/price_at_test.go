@@ -0,0 +1,151 @@
+package market
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPriceWithin(t *testing.T) {
+	open := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	k := candle{
+		openTime:  open,
+		closeTime: open.Add(time.Minute - time.Millisecond),
+		open:      100,
+		close:     110,
+	}
+
+	testCases := []struct {
+		name   string
+		ts     time.Time
+		policy string
+		want   float64
+	}{
+		{name: "Nearest to open", ts: open.Add(10 * time.Second), policy: PolicyNearest, want: 100},
+		{name: "Nearest to close", ts: open.Add(40 * time.Second), policy: PolicyNearest, want: 110},
+		{name: "Interpolate at start", ts: open, policy: PolicyInterpolate, want: 100},
+		{name: "Interpolate midway", ts: open.Add(30 * time.Second), policy: PolicyInterpolate, want: 105},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := priceWithin(k, tc.ts, tc.policy)
+			if math.Abs(got-tc.want) > 0.01 {
+				t.Errorf("Expected %f, got %f", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 1, 12, 0, 30, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "RFC3339", value: "2024-03-01T12:00:30Z"},
+		{name: "RFC3339 with offset", value: "2024-03-01T13:00:30+01:00"},
+		{name: "Unix seconds", value: "1709294430"},
+		{name: "Unix milliseconds", value: "1709294430000"},
+		{name: "Date only", value: "2024-03-01", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTimestamp(tc.value)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %v", tc.value, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("Expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestGetPriceAtFallsBackToCoarserInterval(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 7, 30, 0, time.UTC)
+
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT"},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/klines": klinesRoute(map[string][]stubKline{
+			// The 1m series has a gap, so the next candle starts after ts
+			"1m": {{openTime: ts.Add(5 * time.Minute).Truncate(time.Minute), interval: time.Minute, open: "1", close: "1"}},
+			"5m": {{openTime: ts.Truncate(5 * time.Minute), interval: 5 * time.Minute, open: "60000", close: "60500", high: "60600", low: "59900"}},
+		}),
+	})
+
+	price, err := client.GetPriceAt(context.Background(), "BTCUSDT", ts, PolicyInterpolate)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if price.Interval != "5m" {
+		t.Errorf("Expected 5m interval, got %s", price.Interval)
+	}
+
+	// 2.5 of 5 minutes into the candle
+	if math.Abs(price.Price-60250) > 0.1 {
+		t.Errorf("Expected interpolated price 60250, got %f", price.Price)
+	}
+
+	if !price.OpenTime.Equal(ts.Truncate(5*time.Minute)) || price.USD != price.Price {
+		t.Errorf("Unexpected candle bounds or USD value: %+v", price)
+	}
+}
+
+func TestGetHistoricalPriceInTimezone(t *testing.T) {
+	loc := time.FixedZone("UTC+5:30", 5*3600+1800)
+	dayStart := time.Date(2024, 3, 1, 0, 0, 0, 0, loc)
+
+	var candles []stubKline
+	for i := 0; i < 96; i++ {
+		candles = append(candles, stubKline{
+			openTime: dayStart.Add(time.Duration(i) * 15 * time.Minute),
+			interval: 15 * time.Minute,
+			open:     strconv.Itoa(100 + i),
+			close:    strconv.Itoa(101 + i),
+			high:     strconv.Itoa(102 + i),
+			low:      strconv.Itoa(99 + i),
+			volume:   "1",
+			trades:   2,
+		})
+	}
+	// A candle from the next local day must be excluded
+	candles = append(candles, stubKline{openTime: dayStart.Add(24 * time.Hour), interval: 15 * time.Minute, open: "1", close: "1"})
+
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "ETHUSDT", base: "ETH", quote: "USDT"},
+	}, map[string]http.HandlerFunc{
+		"/api/v3/klines": klinesRoute(map[string][]stubKline{"15m": candles}),
+	})
+
+	price, err := client.GetHistoricalPriceIn(context.Background(), "ETHUSDT", dayStart, loc)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if price.Open != 100 || price.Close != 196 || price.High != 197 || price.Low != 99 {
+		t.Errorf("Unexpected aggregated candle: %+v", price)
+	}
+	if price.Volume != 96 || price.NumberTrades != 192 {
+		t.Errorf("Expected volume 96 and 192 trades, got %f and %d", price.Volume, price.NumberTrades)
+	}
+	if !price.OpenTime.Equal(dayStart) {
+		t.Errorf("Expected day to open at %v, got %v", dayStart, price.OpenTime)
+	}
+}
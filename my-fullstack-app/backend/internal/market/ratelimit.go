@@ -0,0 +1,428 @@
+package market
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"my-fullstack-app/backend/internal/logger"
+)
+
+const (
+	// Binance spot REQUEST_WEIGHT limit per minute, refreshed from exchangeInfo
+	defaultWeightLimit = 6000
+
+	// Share of the weight limit we allow ourselves, leaving room for estimation error
+	weightHeadroom = 0.9
+
+	// Retry settings for 429 responses
+	maxRetries     = 4
+	baseBackoff    = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+	defaultBanTime = 2 * time.Minute
+
+	// Upper bound on responses kept for serving while requests are blocked
+	maxStaleResponses = 1000
+
+	usedWeightHeader = "X-Mbx-Used-Weight-1m"
+	staleHeader      = "X-Tracker-Stale"
+)
+
+// ErrRateLimited is returned when Binance has blocked requests and no cached data is available
+var ErrRateLimited = errors.New("binance request blocked by rate limit")
+
+// endpointWeights are the request weights of the Binance endpoints we call
+var endpointWeights = map[string]int{
+	"/api/v3/exchangeInfo":      20,
+	"/api/v3/ticker/price":      2,
+	"/api/v3/ticker/tradingDay": 4,
+	"/api/v3/klines":            2,
+	"/api/v3/account":           20,
+}
+
+// requestWeight estimates the weight Binance will charge for a request
+func requestWeight(req *http.Request) int {
+	q := req.URL.Query()
+
+	switch req.URL.Path {
+	case "/api/v3/ticker/24hr":
+		// All symbols at once is expensive
+		if q.Get("symbol") == "" && q.Get("symbols") == "" {
+			return 80
+		}
+		return 2
+	case "/api/v3/depth":
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		switch {
+		case limit <= 100:
+			return 5
+		case limit <= 500:
+			return 25
+		case limit <= 1000:
+			return 50
+		default:
+			return 250
+		}
+	}
+
+	if weight, ok := endpointWeights[req.URL.Path]; ok {
+		return weight
+	}
+	return 1
+}
+
+// LimiterStats is a snapshot of the limiter state, published under expvar "binance_limiter"
+type LimiterStats struct {
+	WeightLimit     int       `json:"weightLimit"`
+	UsedWeight      int       `json:"usedWeight"`
+	Queued          int       `json:"queued"`
+	Requests        int64     `json:"requests"`
+	Throttled       int64     `json:"throttled"` // 429 responses
+	Banned          int64     `json:"banned"`    // 418 responses
+	Retries         int64     `json:"retries"`
+	StaleServed     int64     `json:"staleServed"`
+	Rejected        int64     `json:"rejected"`
+	CircuitOpen     bool      `json:"circuitOpen"`
+	BlockedUntil    time.Time `json:"blockedUntil,omitempty"`
+	LastUsedWeight  int       `json:"lastUsedWeight"` // Last value reported by Binance
+	WindowResetTime time.Time `json:"windowResetTime"`
+}
+
+// weightLimiter is an http.RoundTripper that keeps Binance calls within the request
+// weight budget, backs off on 429 and stops calling out entirely during an IP ban
+type weightLimiter struct {
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	limit        int
+	used         int
+	windowStart  time.Time
+	queued       int
+	blockedUntil time.Time
+	stats        LimiterStats
+	stale        map[string]cachedResponse
+	staleOrder   []string
+
+	// Overridable for tests
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// cachedResponse is the last successful response for a request
+type cachedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// sharedLimiter is used by every Client, since Binance limits are applied per IP
+var sharedLimiter = newWeightLimiter(http.DefaultTransport)
+
+func init() {
+	// Served to administrators at /api/admin/metrics
+	expvar.Publish("binance_limiter", expvar.Func(func() interface{} {
+		return sharedLimiter.Stats()
+	}))
+}
+
+func newWeightLimiter(next http.RoundTripper) *weightLimiter {
+	return &weightLimiter{
+		next:  next,
+		limit: defaultWeightLimit,
+		stale: make(map[string]cachedResponse),
+		now:   time.Now,
+		sleep: sleepContext,
+	}
+}
+
+// sleepContext waits for d or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetWeightLimit updates the per-minute weight limit, e.g. from exchangeInfo rateLimits
+func (l *weightLimiter) SetWeightLimit(limit int) {
+	if limit <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+}
+
+// Stats returns a snapshot of the limiter state
+func (l *weightLimiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rollWindow()
+	stats := l.stats
+	stats.WeightLimit = l.limit
+	stats.UsedWeight = l.used
+	stats.Queued = l.queued
+	stats.CircuitOpen = l.now().Before(l.blockedUntil)
+	stats.BlockedUntil = l.blockedUntil
+	stats.WindowResetTime = l.windowStart.Add(time.Minute)
+	return stats
+}
+
+// rollWindow resets the used weight when a new minute starts; callers hold l.mu
+func (l *weightLimiter) rollWindow() {
+	window := l.now().Truncate(time.Minute)
+	if window.After(l.windowStart) {
+		l.windowStart = window
+		l.used = 0
+	}
+}
+
+// RoundTrip sends a request once the weight budget allows it
+func (l *weightLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := staleKey(req)
+	weight := requestWeight(req)
+
+	for attempt := 0; ; attempt++ {
+		if resp, until, blocked := l.serveIfBlocked(req, key); blocked {
+			if resp == nil {
+				return nil, fmt.Errorf("%w until %s", ErrRateLimited, until.Format(time.RFC3339))
+			}
+			return resp, nil
+		}
+
+		if err := l.acquire(req.Context(), weight); err != nil {
+			return nil, err
+		}
+
+		resp, err := l.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		l.observe(resp)
+
+		switch resp.StatusCode {
+		case http.StatusTeapot:
+			// 418 means the IP is banned; stop calling until the ban expires
+			resp.Body.Close()
+			l.block(retryAfter(resp, defaultBanTime), true)
+			continue
+
+		case http.StatusTooManyRequests:
+			l.mu.Lock()
+			l.stats.Throttled++
+			l.mu.Unlock()
+
+			wait := backoffDelay(attempt, retryAfter(resp, 0))
+			resp.Body.Close()
+
+			// Signed requests carry a timestamp and signature that cannot be refreshed here
+			if attempt >= maxRetries || isSigned(req) {
+				l.block(wait, false)
+				continue
+			}
+
+			logger.Warn().
+				Str("path", req.URL.Path).
+				Int("attempt", attempt+1).
+				Dur("backoff", wait).
+				Msg("Binance rate limit hit, backing off")
+
+			l.mu.Lock()
+			l.stats.Retries++
+			l.mu.Unlock()
+
+			if err := l.sleep(req.Context(), wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusOK && req.Method == http.MethodGet {
+			return l.remember(key, resp)
+		}
+		return resp, nil
+	}
+}
+
+// acquire reserves weight in the current window, queueing until the next window if needed
+func (l *weightLimiter) acquire(ctx context.Context, weight int) error {
+	l.mu.Lock()
+	l.stats.Requests++
+	queued := false
+
+	for {
+		l.rollWindow()
+		budget := int(float64(l.limit) * weightHeadroom)
+		if l.used+weight <= budget || l.used == 0 {
+			l.used += weight
+			if queued {
+				l.queued--
+			}
+			l.mu.Unlock()
+			return nil
+		}
+
+		if !queued {
+			queued = true
+			l.queued++
+		}
+		wait := l.windowStart.Add(time.Minute).Sub(l.now())
+		l.mu.Unlock()
+
+		logger.Debug().
+			Int("weight", weight).
+			Dur("wait", wait).
+			Msg("Binance weight budget exhausted, queueing request")
+
+		if err := l.sleep(ctx, wait); err != nil {
+			l.mu.Lock()
+			l.queued--
+			l.mu.Unlock()
+			return err
+		}
+
+		l.mu.Lock()
+	}
+}
+
+// observe syncs the used weight with what Binance reports
+func (l *weightLimiter) observe(resp *http.Response) {
+	used, err := strconv.Atoi(resp.Header.Get(usedWeightHeader))
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rollWindow()
+	l.stats.LastUsedWeight = used
+	if used > l.used {
+		l.used = used
+	}
+}
+
+// block stops outgoing requests for d
+func (l *weightLimiter) block(d time.Duration, banned bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := l.now().Add(d)
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+	if banned {
+		l.stats.Banned++
+	}
+
+	logger.Error().
+		Bool("banned", banned).
+		Time("until", l.blockedUntil).
+		Msg("Binance requests blocked, serving cached data until the window ends")
+}
+
+// serveIfBlocked returns a cached response while requests are blocked, along with
+// the end of the block. The last result reports whether requests are currently blocked.
+func (l *weightLimiter) serveIfBlocked(req *http.Request, key string) (*http.Response, time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.now().Before(l.blockedUntil) {
+		return nil, time.Time{}, false
+	}
+
+	cached, ok := l.stale[key]
+	if !ok {
+		l.stats.Rejected++
+		return nil, l.blockedUntil, true
+	}
+
+	l.stats.StaleServed++
+	recordStaleServed(req.Context())
+	header := cached.header.Clone()
+	header.Set(staleHeader, "true")
+
+	return &http.Response{
+		Status:     http.StatusText(cached.status),
+		StatusCode: cached.status,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(cached.body)),
+		Request:    req,
+	}, l.blockedUntil, true
+}
+
+// remember keeps a copy of a successful response for use during a block
+func (l *weightLimiter) remember(key string, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, exists := l.stale[key]; !exists {
+		l.staleOrder = append(l.staleOrder, key)
+		if len(l.staleOrder) > maxStaleResponses {
+			delete(l.stale, l.staleOrder[0])
+			l.staleOrder = l.staleOrder[1:]
+		}
+	}
+	l.stale[key] = cachedResponse{status: resp.StatusCode, header: resp.Header.Clone(), body: body}
+
+	return resp, nil
+}
+
+// staleKey identifies a request independent of its signing parameters
+func staleKey(req *http.Request) string {
+	q := req.URL.Query()
+	q.Del("timestamp")
+	q.Del("signature")
+	return req.Method + " " + req.URL.Path + "?" + q.Encode()
+}
+
+// isSigned reports whether a request carries a signature
+func isSigned(req *http.Request) bool {
+	return strings.Contains(req.URL.RawQuery, "signature=")
+}
+
+// retryAfter reads the Retry-After header in seconds, or returns fallback
+func retryAfter(resp *http.Response, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// backoffDelay returns an exponential backoff with jitter, never shorter than minimum
+func backoffDelay(attempt int, minimum time.Duration) time.Duration {
+	delay := baseBackoff << attempt
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	delay += time.Duration(rand.Int63n(int64(delay) / 2))
+	if delay < minimum {
+		delay = minimum
+	}
+	return delay
+}
@@ -0,0 +1,166 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func stubResponse(status int, body string, header map[string]string) *http.Response {
+	resp := &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+	for k, v := range header {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+// newTestLimiter returns a limiter with a controllable clock whose sleeps advance the clock
+func newTestLimiter(next roundTripFunc) (*weightLimiter, *[]time.Duration) {
+	clock := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var sleeps []time.Duration
+
+	l := newWeightLimiter(next)
+	l.now = func() time.Time { return clock }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		clock = clock.Add(d)
+		return nil
+	}
+	return l, &sleeps
+}
+
+func doGet(t *testing.T, l *weightLimiter, url string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.RoundTrip(req)
+}
+
+func TestLimiterRetriesOn429(t *testing.T) {
+	calls := 0
+	l, sleeps := newTestLimiter(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls < 3 {
+			return stubResponse(http.StatusTooManyRequests, `{"code":-1003}`, map[string]string{"Retry-After": "2"}), nil
+		}
+		return stubResponse(http.StatusOK, `{"price":"1"}`, nil), nil
+	})
+
+	resp, err := doGet(t, l, "https://api.binance.com/api/v3/ticker/price?symbol=BTCUSDT")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 after retries, got %d", resp.StatusCode)
+	}
+
+	if len(*sleeps) != 2 {
+		t.Fatalf("Expected 2 backoff sleeps, got %v", *sleeps)
+	}
+	for _, d := range *sleeps {
+		if d < 2*time.Second {
+			t.Errorf("Backoff %v is shorter than Retry-After", d)
+		}
+	}
+
+	stats := l.Stats()
+	if stats.Throttled != 2 || stats.Retries != 2 {
+		t.Errorf("Expected 2 throttled and 2 retries, got %+v", stats)
+	}
+}
+
+func TestLimiterServesCachedDataDuringBan(t *testing.T) {
+	banned := false
+	l, _ := newTestLimiter(func(req *http.Request) (*http.Response, error) {
+		if banned {
+			return stubResponse(http.StatusTeapot, `{"code":-1003}`, map[string]string{"Retry-After": "120"}), nil
+		}
+		return stubResponse(http.StatusOK, `{"price":"42"}`, nil), nil
+	})
+
+	cachedURL := "https://api.binance.com/api/v3/ticker/price?symbol=BTCUSDT"
+	if _, err := doGet(t, l, cachedURL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	banned = true
+
+	// A request that was never seen cannot be served while banned
+	_, err := doGet(t, l, "https://api.binance.com/api/v3/ticker/price?symbol=ETHUSDT")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected ErrRateLimited, got %v", err)
+	}
+
+	resp, err := doGet(t, l, cachedURL)
+	if err != nil {
+		t.Fatalf("Expected cached response during ban, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"price":"42"}` || resp.Header.Get(staleHeader) != "true" {
+		t.Errorf("Unexpected cached response %q with headers %v", body, resp.Header)
+	}
+
+	stats := l.Stats()
+	if !stats.CircuitOpen || stats.Banned != 1 || stats.StaleServed != 1 {
+		t.Errorf("Unexpected limiter stats: %+v", stats)
+	}
+}
+
+func TestLimiterQueuesWhenBudgetExhausted(t *testing.T) {
+	l, sleeps := newTestLimiter(func(req *http.Request) (*http.Response, error) {
+		return stubResponse(http.StatusOK, `{}`, nil), nil
+	})
+	l.SetWeightLimit(100)
+
+	// Binance reports most of the budget already used by another process
+	l.observe(stubResponse(http.StatusOK, "", map[string]string{usedWeightHeader: "85"}))
+
+	if _, err := doGet(t, l, "https://api.binance.com/api/v3/exchangeInfo"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Minute {
+		t.Errorf("Expected to wait for the next minute window, got %v", *sleeps)
+	}
+
+	if used := l.Stats().UsedWeight; used != 20 {
+		t.Errorf("Expected 20 weight used in the new window, got %d", used)
+	}
+}
+
+func TestRequestWeight(t *testing.T) {
+	testCases := []struct {
+		url  string
+		want int
+	}{
+		{url: "https://api.binance.com/api/v3/ticker/24hr", want: 80},
+		{url: "https://api.binance.com/api/v3/ticker/24hr?symbol=BTCUSDT", want: 2},
+		{url: "https://api.binance.com/api/v3/depth?symbol=BTCUSDT&limit=1000", want: 50},
+		{url: "https://api.binance.com/api/v3/klines?symbol=BTCUSDT", want: 2},
+		{url: "https://api.binance.com/api/v3/ping", want: 1},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		if got := requestWeight(req); got != tc.want {
+			t.Errorf("%s: expected weight %d, got %d", tc.url, tc.want, got)
+		}
+	}
+}
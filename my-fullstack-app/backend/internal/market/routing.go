@@ -0,0 +1,148 @@
+package market
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Maximum number of trades a conversion route may chain together
+const maxRouteHops = 3
+
+// usdAssets are stablecoins that are treated as equal to one US dollar
+var usdAssets = map[string]bool{
+	"USDT":  true,
+	"USDC":  true,
+	"FDUSD": true,
+	"BUSD":  true,
+	"TUSD":  true,
+	"USDP":  true,
+	"DAI":   true,
+}
+
+// isUSDAsset reports whether an asset is a USD stablecoin
+func isUSDAsset(asset string) bool {
+	return usdAssets[strings.ToUpper(asset)]
+}
+
+// routeStep is a single conversion through one trading pair
+type routeStep struct {
+	Symbol  string `json:"symbol"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Inverse bool   `json:"inverse"` // True when converting quote to base, i.e. dividing by the price
+}
+
+// conversionRoute is a chain of trading pairs converting one asset into a USD stablecoin
+type conversionRoute struct {
+	Steps     []routeStep `json:"steps"`
+	Liquidity int64       `json:"liquidity"` // Smallest 24h trade count along the route
+}
+
+// routeEdge is a directed edge of the routing graph
+type routeEdge struct {
+	step      routeStep
+	liquidity int64
+}
+
+// routeState is the best known way to reach an asset within a number of hops
+type routeState struct {
+	liquidity int64
+	prev      string
+	edge      routeStep
+}
+
+// findUSDRoute finds the most liquid chain of trading pairs converting asset into a USD stablecoin.
+// Routes are ranked by their least liquid pair; among equally liquid routes the shortest one wins.
+func findUSDRoute(info *exchangeInfo, asset string) (conversionRoute, error) {
+	asset = strings.ToUpper(asset)
+	if isUSDAsset(asset) {
+		return conversionRoute{}, nil
+	}
+
+	// Build adjacency list from trading symbols; every pair can be traded in both directions
+	edges := make(map[string][]routeEdge)
+	for _, s := range info.symbols {
+		if !s.Trading() {
+			continue
+		}
+		// Symbols without ticker data still count as a usable, if illiquid, pair
+		liquidity := s.TradeCount
+		if liquidity < 1 {
+			liquidity = 1
+		}
+		edges[s.BaseAsset] = append(edges[s.BaseAsset], routeEdge{
+			step:      routeStep{Symbol: s.Symbol, From: s.BaseAsset, To: s.QuoteAsset},
+			liquidity: liquidity,
+		})
+		edges[s.QuoteAsset] = append(edges[s.QuoteAsset], routeEdge{
+			step:      routeStep{Symbol: s.Symbol, From: s.QuoteAsset, To: s.BaseAsset, Inverse: true},
+			liquidity: liquidity,
+		})
+	}
+
+	if len(edges[asset]) == 0 {
+		return conversionRoute{}, fmt.Errorf("no trading pairs found for asset %s", asset)
+	}
+
+	// levels[k] holds the widest route to each asset using at most k hops
+	levels := make([]map[string]routeState, maxRouteHops+1)
+	levels[0] = map[string]routeState{asset: {liquidity: int64(^uint64(0) >> 1)}}
+
+	for k := 1; k <= maxRouteHops; k++ {
+		levels[k] = make(map[string]routeState, len(levels[k-1]))
+		for a, st := range levels[k-1] {
+			levels[k][a] = st
+		}
+
+		for from, st := range levels[k-1] {
+			// Routes stop at the first USD stablecoin they reach
+			if isUSDAsset(from) {
+				continue
+			}
+			for _, e := range edges[from] {
+				if e.step.To == asset {
+					continue
+				}
+				liquidity := min(st.liquidity, e.liquidity)
+				if cur, ok := levels[k][e.step.To]; ok && cur.liquidity >= liquidity {
+					continue
+				}
+				levels[k][e.step.To] = routeState{liquidity: liquidity, prev: from, edge: e.step}
+			}
+		}
+	}
+
+	// Pick the most liquid stablecoin reached, preferring USDT on ties as the deepest market
+	best := ""
+	final := levels[maxRouteHops]
+	for a := range usdAssets {
+		st, ok := final[a]
+		if !ok {
+			continue
+		}
+		if best == "" || st.liquidity > final[best].liquidity ||
+			(st.liquidity == final[best].liquidity && a == "USDT") {
+			best = a
+		}
+	}
+
+	if best == "" {
+		return conversionRoute{}, fmt.Errorf("no USD conversion route found for asset %s within %d hops", asset, maxRouteHops)
+	}
+
+	route := conversionRoute{Liquidity: final[best].liquidity}
+
+	// Walk back through the levels to reconstruct the steps
+	current := best
+	for k := maxRouteHops; current != asset; k-- {
+		st := levels[k][current]
+		// Skip levels that did not improve on the route to this asset
+		for k > 1 && levels[k-1][current] == st {
+			k--
+		}
+		route.Steps = append([]routeStep{st.edge}, route.Steps...)
+		current = st.prev
+	}
+
+	return route, nil
+}
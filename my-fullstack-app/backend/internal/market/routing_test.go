@@ -0,0 +1,133 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func routingFixture() *exchangeInfo {
+	info := &exchangeInfo{symbols: map[string]SymbolInfo{}}
+	for _, s := range []SymbolInfo{
+		{Symbol: "BTCUSDT", BaseAsset: "BTC", QuoteAsset: "USDT", TradeCount: 1000},
+		{Symbol: "ETHUSDT", BaseAsset: "ETH", QuoteAsset: "USDT", TradeCount: 800},
+		{Symbol: "ETHBTC", BaseAsset: "ETH", QuoteAsset: "BTC", TradeCount: 500},
+		{Symbol: "ETHFDUSD", BaseAsset: "ETH", QuoteAsset: "FDUSD", TradeCount: 300},
+		{Symbol: "TOKENBTC", BaseAsset: "TOKEN", QuoteAsset: "BTC", TradeCount: 50},
+		{Symbol: "TOKENETH", BaseAsset: "TOKEN", QuoteAsset: "ETH", TradeCount: 10},
+		{Symbol: "BTCTRY", BaseAsset: "BTC", QuoteAsset: "TRY", TradeCount: 200},
+		{Symbol: "USDTTRY", BaseAsset: "USDT", QuoteAsset: "TRY", TradeCount: 100},
+		{Symbol: "OLDUSDT", BaseAsset: "OLD", QuoteAsset: "USDT", TradeCount: 900, Status: "BREAK"},
+		{Symbol: "XYZABC", BaseAsset: "XYZ", QuoteAsset: "ABC", TradeCount: 5},
+	} {
+		if s.Status == "" {
+			s.Status = symbolStatusTrading
+		}
+		info.symbols[s.Symbol] = s
+	}
+	return info
+}
+
+func TestFindUSDRoute(t *testing.T) {
+	info := routingFixture()
+
+	testCases := []struct {
+		name    string
+		asset   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "Stablecoin needs no route", asset: "USDT", want: nil},
+		{name: "Direct pair", asset: "ETH", want: []string{"ETHUSDT"}},
+		{name: "Two hops through BTC", asset: "TOKEN", want: []string{"TOKENBTC", "BTCUSDT"}},
+		{name: "Fiat quote through inverse pair", asset: "TRY", want: []string{"BTCTRY", "BTCUSDT"}},
+		{name: "Pair not trading", asset: "OLD", wantErr: true},
+		{name: "No route", asset: "ABC", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			route, err := findUSDRoute(info, tc.asset)
+
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for asset %s, got route %+v", tc.asset, route)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(route.Steps) != len(tc.want) {
+				t.Fatalf("Expected %d steps, got %+v", len(tc.want), route.Steps)
+			}
+			for i, step := range route.Steps {
+				if step.Symbol != tc.want[i] {
+					t.Errorf("Step %d: expected %s, got %s", i, tc.want[i], step.Symbol)
+				}
+			}
+		})
+	}
+}
+
+func TestFindUSDRouteInverseStep(t *testing.T) {
+	route, err := findUSDRoute(routingFixture(), "TRY")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !route.Steps[0].Inverse || route.Steps[1].Inverse {
+		t.Errorf("Expected only the first step to be inverse, got %+v", route.Steps)
+	}
+
+	if route.Liquidity != 200 {
+		t.Errorf("Expected route liquidity 200, got %d", route.Liquidity)
+	}
+}
+
+func TestConvertToUSD(t *testing.T) {
+	client := newStubClient(t, []stubSymbol{
+		{symbol: "BTCUSDT", base: "BTC", quote: "USDT", price: "50000", trades: 1000},
+		{symbol: "ETHFDUSD", base: "ETH", quote: "FDUSD", price: "2500", trades: 300},
+		{symbol: "TOKENBTC", base: "TOKEN", quote: "BTC", price: "0.001", trades: 50},
+		{symbol: "BTCTRY", base: "BTC", quote: "TRY", price: "2000000", trades: 200},
+	}, nil)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name    string
+		symbol  string
+		price   float64
+		want    float64
+		wantErr error
+	}{
+		{name: "FDUSD quote is already USD", symbol: "ETHFDUSD", price: 2500, want: 2500},
+		{name: "BTC quote", symbol: "TOKENBTC", price: 0.001, want: 50},
+		{name: "TRY quote", symbol: "BTCTRY", price: 2000000, want: 50000},
+		{name: "Unknown symbol", symbol: "NOPEUSDT", wantErr: ErrUnknownSymbol},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := client.ConvertToUSD(ctx, tc.symbol, tc.price, nil)
+
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("Expected error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Expected %f, got %f", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package market
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// exchangeName identifies Binance in stored snapshots
+const exchangeName = "binance"
+
+// AccountStore persists exchange balance snapshots
+type AccountStore interface {
+	SaveSnapshot(ctx context.Context, balances []models.CEXBalanceSnapshot) error
+	LatestSnapshot(ctx context.Context, exchange, account string) ([]models.CEXBalanceSnapshot, error)
+}
+
+// DatabaseAccountStore persists snapshots in the cex_balance_snapshots Postgres table
+type DatabaseAccountStore struct {
+	db *sql.DB
+}
+
+// NewDatabaseAccountStore creates a snapshot store backed by Postgres
+func NewDatabaseAccountStore(db *sql.DB) *DatabaseAccountStore {
+	return &DatabaseAccountStore{db: db}
+}
+
+// SaveSnapshot stores all balances of a snapshot, recording it in the audit log when ctx
+// comes from database.WithAudit
+func (s *DatabaseAccountStore) SaveSnapshot(ctx context.Context, balances []models.CEXBalanceSnapshot) error {
+	return database.StoreCEXBalanceSnapshot(ctx, s.db, balances)
+}
+
+// LatestSnapshot returns the most recent stored snapshot of an account
+func (s *DatabaseAccountStore) LatestSnapshot(ctx context.Context, exchange, account string) ([]models.CEXBalanceSnapshot, error) {
+	return database.GetLatestCEXBalances(s.db, exchange, account)
+}
+
+// AccountTracker snapshots the spot balances of a Binance account
+type AccountTracker struct {
+	client  *Client
+	store   AccountStore
+	account string
+}
+
+// NewAccountTracker creates a tracker storing snapshots under the given account label
+func NewAccountTracker(client *Client, store AccountStore, account string) *AccountTracker {
+	return &AccountTracker{
+		client:  client,
+		store:   store,
+		account: account,
+	}
+}
+
+// Snapshot reads the current balances and stores them as one snapshot
+func (t *AccountTracker) Snapshot(ctx context.Context) ([]models.CEXBalanceSnapshot, error) {
+	balances, err := t.client.GetAccountBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Postgres keeps microseconds, so round here to read back the same snapshot time
+	takenAt := time.Now().UTC().Truncate(time.Microsecond)
+
+	snapshot := make([]models.CEXBalanceSnapshot, 0, len(balances))
+	for _, b := range balances {
+		snapshot = append(snapshot, models.CEXBalanceSnapshot{
+			Exchange: exchangeName,
+			Account:  t.account,
+			Asset:    b.Asset,
+			Free:     b.Free,
+			Locked:   b.Locked,
+			TakenAt:  takenAt,
+		})
+	}
+
+	if err := t.store.SaveSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("exchange", exchangeName).
+		Str("account", t.account).
+		Int("assets", len(snapshot)).
+		Msg("Stored exchange balance snapshot")
+
+	return snapshot, nil
+}
+
+// LatestCEXBalances returns the most recent stored snapshot of the account
+func (t *AccountTracker) LatestCEXBalances(ctx context.Context) ([]models.CEXBalanceSnapshot, error) {
+	return t.store.LatestSnapshot(ctx, exchangeName, t.account)
+}
@@ -0,0 +1,118 @@
+package market
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubSymbol describes a trading pair served by the stub exchange
+type stubSymbol struct {
+	symbol, base, quote, status string
+	price                       string
+	trades                      int64
+}
+
+// newStubClient returns a Client talking to a local stand-in for the Binance REST API.
+// Extra routes override or extend the default exchangeInfo and ticker endpoints.
+func newStubClient(t *testing.T, symbols []stubSymbol, routes map[string]http.HandlerFunc) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v3/exchangeInfo", func(w http.ResponseWriter, r *http.Request) {
+		var list []map[string]interface{}
+		for _, s := range symbols {
+			status := s.status
+			if status == "" {
+				status = symbolStatusTrading
+			}
+			list = append(list, map[string]interface{}{
+				"symbol":     s.symbol,
+				"baseAsset":  s.base,
+				"quoteAsset": s.quote,
+				"status":     status,
+			})
+		}
+		writeStubJSON(w, map[string]interface{}{"symbols": list})
+	})
+
+	mux.HandleFunc("/api/v3/ticker/24hr", func(w http.ResponseWriter, r *http.Request) {
+		var list []map[string]interface{}
+		for _, s := range symbols {
+			list = append(list, map[string]interface{}{"symbol": s.symbol, "count": s.trades})
+		}
+		writeStubJSON(w, list)
+	})
+
+	mux.HandleFunc("/api/v3/ticker/price", func(w http.ResponseWriter, r *http.Request) {
+		for _, s := range symbols {
+			if s.symbol == r.URL.Query().Get("symbol") {
+				writeStubJSON(w, map[string]string{"symbol": s.symbol, "price": s.price})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		writeStubJSON(w, map[string]interface{}{"code": -1121, "msg": "Invalid symbol."})
+	})
+
+	mux.HandleFunc("/api/v3/ticker/tradingDay", func(w http.ResponseWriter, r *http.Request) {
+		writeStubJSON(w, map[string]interface{}{"symbol": r.URL.Query().Get("symbol"), "count": 1})
+	})
+
+	for path, handler := range routes {
+		mux.HandleFunc(path, handler)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// Each stub gets its own limiter so tests cannot trip each other's circuit breaker
+	client := newClientWithLimiter("stub-api-key", "stub-secret-key", newWeightLimiter(http.DefaultTransport))
+	client.binanceClient.BaseURL = server.URL
+	return client
+}
+
+func writeStubJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// stubKline is a candle served by the stub klines endpoint
+type stubKline struct {
+	openTime        time.Time
+	interval        time.Duration
+	open, high, low string
+	close, volume   string
+	trades          int64
+}
+
+// klinesRoute serves candles per interval, honouring startTime, endTime and limit
+func klinesRoute(byInterval map[string][]stubKline) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		start, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		rows := [][]interface{}{}
+		for _, k := range byInterval[q.Get("interval")] {
+			openMs := k.openTime.UnixMilli()
+			if openMs < start || (end > 0 && openMs > end) {
+				continue
+			}
+			if limit > 0 && len(rows) >= limit {
+				break
+			}
+			closeMs := k.openTime.Add(k.interval).UnixMilli() - 1
+			rows = append(rows, []interface{}{
+				openMs, k.open, k.high, k.low, k.close, k.volume,
+				closeMs, "0", k.trades, "0", "0", "0",
+			})
+		}
+		writeStubJSON(w, rows)
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// AuditBalanceStored is recorded when a native balance is read and stored
+	AuditBalanceStored = "balance.store"
+	// AuditTokenBalancesStored is recorded when the common token balances of an address are stored
+	AuditTokenBalancesStored = "token_balances.store"
+	// AuditAccountBalancesStored is recorded when an exchange account snapshot is stored
+	AuditAccountBalancesStored = "account_balances.store"
+	// AuditDataImported is recorded when a dataset is imported
+	AuditDataImported = "data.import"
+)
+
+// Audit actors
+const (
+	// ActorAdmin is a request authenticated with the admin token
+	ActorAdmin = "admin"
+	// ActorAnonymous is a request to a public endpoint
+	ActorAnonymous = "anonymous"
+	// ActorCLI is a subcommand of the server binary, such as import
+	ActorCLI = "cli"
+)
+
+// AuditEvent records a write made through the API or the CLI. Before and After hold the
+// state of the target around the write, and are empty when there was nothing to record.
+type AuditEvent struct {
+	ID         int64           `json:"id" db:"id"`
+	OccurredAt time.Time       `json:"occurred_at" db:"occurred_at"`
+	Actor      string          `json:"actor" db:"actor"`
+	Action     string          `json:"action" db:"action"`
+	Target     string          `json:"target" db:"target"` // e.g. an address, lowercase, or a dataset
+	RequestID  string          `json:"request_id" db:"request_id"`
+	SourceIP   string          `json:"source_ip" db:"source_ip"` // user@host for the CLI
+	Before     json.RawMessage `json:"before,omitempty" db:"before" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" db:"after" swaggertype:"object"`
+}
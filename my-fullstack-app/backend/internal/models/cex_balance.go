@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// CEXBalanceSnapshot represents a stored centralized exchange balance of one asset.
+// All rows of a snapshot share the same TakenAt.
+type CEXBalanceSnapshot struct {
+	ID       int       `json:"id" db:"id"`
+	Exchange string    `json:"exchange" db:"exchange"` // e.g. binance
+	Account  string    `json:"account" db:"account"`   // Label of the exchange account
+	Asset    string    `json:"asset" db:"asset"`
+	Free     string    `json:"free" db:"free"`     // Exact decimal as reported by the exchange
+	Locked   string    `json:"locked" db:"locked"` // Held in open orders
+	TakenAt  time.Time `json:"taken_at" db:"taken_at"`
+}
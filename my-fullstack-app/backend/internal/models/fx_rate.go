@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// FXRate represents a daily fiat exchange rate against the US dollar
+type FXRate struct {
+	Currency  string    `json:"currency" db:"currency"` // ISO 4217 code, e.g. EUR
+	Date      time.Time `json:"date" db:"rate_date"`    // Day the rate applies to (UTC)
+	Rate      float64   `json:"rate" db:"rate"`         // Units of currency per 1 USD
+	Source    string    `json:"source" db:"source"`     // Provider the rate came from
+	FetchedAt time.Time `json:"fetched_at" db:"fetched_at"`
+}
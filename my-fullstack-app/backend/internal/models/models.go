@@ -12,11 +12,30 @@ type User struct {
 	Password string `json:"-" db:"password"`
 }
 
-// BalanceRecord represents a stored Ethereum balance
+// ChainEthereum is the chain of balances read from Ethereum mainnet
+const ChainEthereum = "ethereum"
+
+// BalanceRecord represents a stored Ethereum balance. When only changes are stored, a record
+// covers every observation of the same balance from FetchedAt to LastSeenAt.
 type BalanceRecord struct {
-	ID         int       `json:"id" db:"id"`
-	Address    string    `json:"address" db:"address"`
-	Balance    string    `json:"balance" db:"balance"`         // wei, stored as string due to large size
-	BalanceETH string    `json:"balance_eth" db:"balance_eth"` // ETH value as string
-	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+	ID            int        `json:"id" db:"id"`
+	Chain         string     `json:"chain" db:"chain"` // e.g. ethereum
+	Address       string     `json:"address" db:"address"`
+	Balance       string     `json:"balance" db:"balance"`                           // wei, as a string since it overflows int64
+	BalanceETH    string     `json:"balance_eth" db:"balance_eth"`                   // ETH value with 18 decimals, derived from balance when stored
+	BlockNumber   *uint64    `json:"block_number" db:"block_number"`                 // Block the balance was first read at; nil for older records
+	LastSeenBlock *uint64    `json:"last_seen_block,omitempty" db:"last_seen_block"` // Latest block the same balance was read at
+	FetchedAt     time.Time  `json:"fetched_at" db:"fetched_at"`                     // First observation
+	LastSeenAt    time.Time  `json:"last_seen_at" db:"last_seen_at"`                 // Latest observation of the same balance
+	ValidTo       *time.Time `json:"valid_to" db:"valid_to"`                         // When a different balance replaced it; nil for the current balance
+}
+
+// BalancePoint is one point of an address's balance history. Downsampled points carry the
+// last record of their bucket and are timed at the bucket start.
+type BalancePoint struct {
+	Time       time.Time `json:"time"`
+	Balance    string    `json:"balance"`     // wei
+	BalanceETH string    `json:"balance_eth"` // ETH value with 18 decimals
+	RecordID   int       `json:"record_id"`
+	FetchedAt  time.Time `json:"fetched_at"`
 }
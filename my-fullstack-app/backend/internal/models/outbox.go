@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// EventBalanceChanged is published when a stored native balance differs from the one before it
+	EventBalanceChanged = "balance.changed"
+	// EventTokenBalanceChanged is published when a stored token balance differs from the one before it
+	EventTokenBalanceChanged = "token_balance.changed"
+)
+
+// OutboxEvent is an event waiting in the outbox to be published. Events with the same key,
+// the chain and address they concern, are published in ID order.
+type OutboxEvent struct {
+	ID        int64           `json:"id" db:"id"`
+	Key       string          `json:"key" db:"event_key"` // e.g. ethereum:0x742d35cc6634c0532925a3b844bc454e4438f44e
+	Type      string          `json:"type" db:"event_type"`
+	Payload   json.RawMessage `json:"data" db:"payload"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+	Attempts  int             `json:"attempts" db:"attempts"` // Earlier failed attempts to publish it
+}
+
+// BalanceChange is the payload of EventBalanceChanged
+type BalanceChange struct {
+	Chain           string    `json:"chain"`
+	Address         string    `json:"address"`
+	RecordID        int       `json:"record_id"`
+	Balance         string    `json:"balance"`                    // wei
+	PreviousBalance *string   `json:"previous_balance,omitempty"` // Nil for the first balance of the address
+	BlockNumber     *uint64   `json:"block_number,omitempty"`
+	FetchedAt       time.Time `json:"fetched_at"`
+}
+
+// TokenBalanceChange is the payload of EventTokenBalanceChanged
+type TokenBalanceChange struct {
+	Chain           string    `json:"chain"`
+	Address         string    `json:"address"` // Holder of the tokens
+	TokenAddress    string    `json:"token_address"`
+	RecordID        int       `json:"record_id"`
+	Balance         string    `json:"balance"` // Raw amount
+	PreviousBalance *string   `json:"previous_balance,omitempty"`
+	Decimals        uint8     `json:"decimals"`
+	BlockNumber     *uint64   `json:"block_number,omitempty"`
+	FetchedAt       time.Time `json:"fetched_at"`
+}
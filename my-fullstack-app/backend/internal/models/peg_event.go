@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// PegDeviationEvent represents a stablecoin trading away from its peg beyond the alert
+// threshold, from the first check that saw it until the first check back within it
+type PegDeviationEvent struct {
+	ID              int64      `json:"id" db:"id"`
+	Asset           string     `json:"asset" db:"asset"`   // e.g. USDC
+	Peg             string     `json:"peg" db:"peg"`       // Fiat currency the asset tracks, e.g. USD
+	Source          string     `json:"source" db:"source"` // Price source, e.g. binance:USDCUSDT
+	StartedAt       time.Time  `json:"started_at" db:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty" db:"ended_at"` // Nil while the asset is still off its peg
+	LastSeenAt      time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	StartPrice      float64    `json:"start_price" db:"start_price"`
+	LastPrice       float64    `json:"last_price" db:"last_price"`
+	MaxDeviationBps float64    `json:"max_deviation_bps" db:"max_deviation_bps"` // Signed, largest in magnitude
+}
@@ -6,9 +6,15 @@ import (
 
 // TokenBalanceRecord represents a stored ERC20 token balance
 type TokenBalanceRecord struct {
-	ID         int       `json:"id" db:"id"`
-	Address    string    `json:"address" db:"address"`
-	Balance    string    `json:"balance" db:"balance"`         // Raw balance as string
-	BalanceETH string    `json:"balance_eth" db:"balance_eth"` // Formatted with decimals
-	FetchedAt  time.Time `json:"fetched_at" db:"fetched_at"`
+	ID           int       `json:"id" db:"id"`
+	Chain        string    `json:"chain" db:"chain"`                 // e.g. ethereum
+	Address      string    `json:"address" db:"holder_address"`      // Holder of the tokens
+	TokenAddress string    `json:"token_address" db:"token_address"` // Stored lowercase
+	Balance      string    `json:"balance" db:"raw_amount"`          // Raw balance as string
+	BalanceETH   string    `json:"balance_eth"`                      // Formatted with decimals
+	Decimals     uint8     `json:"decimals" db:"decimals"`           // Decimals the balance was formatted with
+	BlockNumber  *uint64   `json:"block_number" db:"block_number"`   // Block the balance was read at; nil for older records
+	ValueUSD     *float64  `json:"value_usd" db:"value_usd"`         // Nil when the token could not be priced
+	PriceSource  string    `json:"price_source" db:"price_source"`
+	FetchedAt    time.Time `json:"fetched_at" db:"fetched_at"`
 }
@@ -0,0 +1,10 @@
+package models
+
+// TokenMapping bridges an ERC20 contract to the market it is priced from. Exactly one of
+// Pair and Peg is set.
+type TokenMapping struct {
+	Address string `json:"address" db:"address"`
+	Symbol  string `json:"symbol" db:"symbol"`
+	Pair    string `json:"pair,omitempty" db:"pair"` // Exchange symbol, e.g. LINKUSDT
+	Peg     string `json:"peg,omitempty" db:"peg"`   // Fiat currency a stablecoin tracks, e.g. USD
+}
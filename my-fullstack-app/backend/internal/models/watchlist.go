@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Watchlist is a named group of addresses whose holdings are totalled together
+type Watchlist struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Addresses []string  `json:"addresses"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// BalanceTotal is the sum of the latest stored balances of a group of addresses
+type BalanceTotal struct {
+	Addresses  int    `json:"addresses"`   // Addresses with a stored balance
+	Balance    string `json:"balance"`     // wei
+	BalanceETH string `json:"balance_eth"` // ETH value with 18 decimals
+}
@@ -0,0 +1,165 @@
+// Package outbox publishes the events that balance writes leave in the outbox table to other
+// systems, at least once and in order per address.
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// maxRetryDelay caps the exponential backoff of an event that fails to publish
+const maxRetryDelay = 5 * time.Minute
+
+// purgeInterval is the time between deletions of delivered events
+const purgeInterval = time.Hour
+
+// Publisher delivers an event to another system. Events may be delivered more than once,
+// so consumers should ignore an event ID they have seen.
+type Publisher interface {
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// Config controls the relay
+type Config struct {
+	WebhookURL    string        // Endpoint events are posted to; the relay does not run without it
+	WebhookSecret string        // Key of the HMAC signature of each request, if set
+	Interval      time.Duration // Time between polls of an empty outbox
+	BatchSize     int           // Events claimed at a time
+	Lease         time.Duration // How long a claimed batch is reserved for its relay
+	Retention     time.Duration // How long delivered events are kept
+}
+
+// DefaultConfig polls every second, leases batches for a minute and keeps delivered events
+// for a week
+func DefaultConfig() Config {
+	return Config{
+		Interval:  time.Second,
+		BatchSize: 100,
+		Lease:     time.Minute,
+		Retention: 7 * 24 * time.Hour,
+	}
+}
+
+// ConfigFromEnv builds the configuration from OUTBOX_WEBHOOK_URL, OUTBOX_WEBHOOK_SECRET,
+// OUTBOX_POLL_INTERVAL, OUTBOX_BATCH_SIZE, OUTBOX_LEASE and OUTBOX_RETENTION
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+	cfg.WebhookURL = os.Getenv("OUTBOX_WEBHOOK_URL")
+	cfg.WebhookSecret = os.Getenv("OUTBOX_WEBHOOK_SECRET")
+
+	for _, setting := range []struct {
+		name string
+		dest *time.Duration
+	}{
+		{"OUTBOX_POLL_INTERVAL", &cfg.Interval},
+		{"OUTBOX_LEASE", &cfg.Lease},
+		{"OUTBOX_RETENTION", &cfg.Retention},
+	} {
+		if v := os.Getenv(setting.name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return Config{}, fmt.Errorf("invalid %s %q: must be a duration such as 1s", setting.name, v)
+			}
+			*setting.dest = d
+		}
+	}
+
+	if v := os.Getenv("OUTBOX_BATCH_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Config{}, fmt.Errorf("invalid OUTBOX_BATCH_SIZE %q: must be a positive integer", v)
+		}
+		cfg.BatchSize = n
+	}
+
+	return cfg, nil
+}
+
+// Relay moves events from the outbox to a publisher. Several relays, one per server, can
+// share an outbox on Postgres.
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	config    Config
+	now       func() time.Time
+}
+
+// NewRelay creates a relay using the given connection pool
+func NewRelay(db *sql.DB, publisher Publisher, config Config) *Relay {
+	return &Relay{db: db, publisher: publisher, config: config, now: time.Now}
+}
+
+// Run drains the outbox every interval and purges delivered events hourly until ctx is
+// cancelled
+func (r *Relay) Run(ctx context.Context) {
+	logger.Info().Dur("interval", r.config.Interval).Int("batch_size", r.config.BatchSize).Msg("Starting outbox relay")
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	var purgedAt time.Time
+	for {
+		if n, err := r.Drain(ctx); err != nil {
+			logger.Error().Err(err).Int("published", n).Msg("Outbox relay failed")
+		}
+
+		if now := r.now(); now.Sub(purgedAt) >= purgeInterval {
+			purgedAt = now
+			if n, err := database.PurgeOutbox(r.db, now.Add(-r.config.Retention)); err != nil {
+				logger.Warn().Err(err).Msg("Failed to purge delivered outbox events")
+			} else if n > 0 {
+				logger.Info().Int64("events", n).Msg("Purged delivered outbox events")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain processes batches of due events until a batch comes back short, and returns the
+// number of events processed, whether published or scheduled for a retry
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := database.ProcessOutbox(ctx, r.db, r.config.BatchSize, r.config.Lease, r.now(), r.publish(ctx))
+		total += n
+		if err != nil || n < r.config.BatchSize {
+			return total, err
+		}
+	}
+	return total, ctx.Err()
+}
+
+func (r *Relay) publish(ctx context.Context) func(models.OutboxEvent) database.OutboxResult {
+	return func(event models.OutboxEvent) database.OutboxResult {
+		err := r.publisher.Publish(ctx, event)
+		if err == nil {
+			return database.OutboxResult{}
+		}
+
+		delay := retryDelay(event.Attempts)
+		logger.Warn().Err(err).Int64("event", event.ID).Str("key", event.Key).Int("attempts", event.Attempts+1).
+			Dur("retry_after", delay).Msg("Failed to publish outbox event")
+		return database.OutboxResult{Err: err, RetryAfter: delay}
+	}
+}
+
+// retryDelay doubles from one second with each earlier attempt, up to maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	if attempts >= 9 {
+		return maxRetryDelay
+	}
+	return min(time.Second<<attempts, maxRetryDelay)
+}
@@ -0,0 +1,180 @@
+package outbox
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/database/dbtest"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	addressA = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	addressB = "0x0000000000000000000000000000000000000001"
+	token    = "0x514910771af9ca656af840dff83e8264ecf986ca"
+)
+
+// recordingPublisher records published events and fails those of the keys in failing
+type recordingPublisher struct {
+	events  []models.OutboxEvent
+	failing map[string]bool
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	if p.failing[event.Key] {
+		return errors.New("unavailable")
+	}
+	p.events = append(p.events, event)
+	return nil
+}
+
+func storeBalance(t *testing.T, db *sql.DB, address, balance string, fetchedAt time.Time) {
+	t.Helper()
+	if _, err := database.StoreBalance(db, models.BalanceRecord{Address: address, Balance: balance, FetchedAt: fetchedAt}, database.StoreChanges); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestRelayPublishesChangesInOrder(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.SQLite(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// An unchanged balance writes no event
+	storeBalance(t, db, addressA, "1", start)
+	storeBalance(t, db, addressA, "1", start.Add(time.Hour))
+	storeBalance(t, db, addressB, "5", start)
+	storeBalance(t, db, addressA, "2", start.Add(2*time.Hour))
+	_, err := database.StoreTokenBalance(db, models.TokenBalanceRecord{Address: addressA, TokenAddress: token, Balance: "7", Decimals: 18, FetchedAt: start})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	publisher := &recordingPublisher{}
+	config := DefaultConfig()
+	config.BatchSize = 1
+	relay := NewRelay(db, publisher, config)
+	if n, err := relay.Drain(ctx); err != nil || n != 4 {
+		t.Fatalf("Expected 4 events processed, got %d, %v", n, err)
+	}
+
+	keyA := database.OutboxKey(models.ChainEthereum, addressA)
+	var typesA []string
+	for _, event := range publisher.events {
+		if event.Key == keyA {
+			typesA = append(typesA, event.Type)
+		}
+	}
+	if len(publisher.events) != 4 || len(typesA) != 3 || typesA[0] != models.EventBalanceChanged || typesA[2] != models.EventTokenBalanceChanged {
+		t.Fatalf("Expected the events of each address in order, got %+v", publisher.events)
+	}
+
+	var change models.BalanceChange
+	if err := json.Unmarshal(publisher.events[2].Payload, &change); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if change.Balance != "2" || change.PreviousBalance == nil || *change.PreviousBalance != "1" {
+		t.Errorf("Expected the change from 1 to 2 wei, got %+v", change)
+	}
+
+	// Delivered events are not published again
+	if n, err := relay.Drain(ctx); err != nil || n != 0 {
+		t.Errorf("Expected nothing left to publish, got %d, %v", n, err)
+	}
+	if pending, _ := database.PendingOutboxEvents(db); pending != 0 {
+		t.Errorf("Expected no pending events, got %d", pending)
+	}
+}
+
+func TestRelayRetriesWithoutReordering(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.SQLite(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	storeBalance(t, db, addressA, "1", start)
+	storeBalance(t, db, addressA, "2", start.Add(time.Hour))
+	storeBalance(t, db, addressB, "5", start)
+
+	keyA := database.OutboxKey(models.ChainEthereum, addressA)
+	publisher := &recordingPublisher{failing: map[string]bool{keyA: true}}
+	now := time.Now()
+	relay := NewRelay(db, publisher, DefaultConfig())
+	relay.now = func() time.Time { return now }
+
+	// The failed first event of A holds back the second until it is delivered
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].Key == keyA {
+		t.Fatalf("Expected only B published, got %+v", publisher.events)
+	}
+	delete(publisher.failing, keyA)
+	if n, _ := relay.Drain(ctx); n != 0 {
+		t.Errorf("Expected the failed event to wait for its retry, got %d processed", n)
+	}
+
+	now = now.Add(retryDelay(0))
+	if _, err := relay.Drain(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(publisher.events) != 2 || publisher.events[1].Key != keyA || publisher.events[1].Attempts != 1 {
+		t.Fatalf("Expected the first event of A retried, got %+v", publisher.events)
+	}
+	if _, err := relay.Drain(ctx); err != nil || len(publisher.events) != 3 {
+		t.Fatalf("Expected the second event of A after the first, got %+v, %v", publisher.events, err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{0: time.Second, 3: 8 * time.Second, 8: 256 * time.Second, 9: maxRetryDelay, 60: maxRetryDelay} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var request *http.Request
+	var body []byte
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	event := models.OutboxEvent{ID: 42, Key: "ethereum:" + addressB, Type: models.EventBalanceChanged, Payload: json.RawMessage(`{"balance":"5"}`)}
+	publisher := NewWebhookPublisher(server.URL, "secret")
+	if err := publisher.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if got := request.Header.Get("X-Signature"); got != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Expected the body signed, got %q", got)
+	}
+	if got := request.Header.Get("Idempotency-Key"); got != "42" {
+		t.Errorf("Expected the event ID as idempotency key, got %q", got)
+	}
+	var sent models.OutboxEvent
+	if err := json.Unmarshal(body, &sent); err != nil || sent.Type != event.Type || string(sent.Payload) != `{"balance":"5"}` {
+		t.Errorf("Expected the event as JSON, got %s", body)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := publisher.Publish(context.Background(), event); err == nil {
+		t.Error("Expected an error for a 503 response")
+	}
+}
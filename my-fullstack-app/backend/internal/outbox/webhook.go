@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/models"
+)
+
+// WebhookPublisher posts each event as JSON to an HTTP endpoint. Any 2xx response counts as
+// delivered. The event ID is sent as the Idempotency-Key header, and with a secret the body
+// is signed in X-Signature as sha256=HEX(HMAC-SHA256(secret, body)).
+type WebhookPublisher struct {
+	url        string
+	secret     string
+	httpClient *http.Client
+}
+
+// NewWebhookPublisher creates a publisher posting to url
+func NewWebhookPublisher(url, secret string) *WebhookPublisher {
+	return &WebhookPublisher{
+		url:        url,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Publish posts an event
+func (p *WebhookPublisher) Publish(ctx context.Context, event models.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatInt(event.ID, 10))
+	if p.secret != "" {
+		mac := hmac.New(sha256.New, []byte(p.secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/fx"
+	"my-fullstack-app/backend/internal/logger"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Handler handles portfolio API requests
+type Handler struct {
+	service *Service
+	rates   *fx.Service
+}
+
+// NewHandler creates a new portfolio handler. Rates may be nil, which disables currency conversion.
+func NewHandler(service *Service, rates *fx.Service) *Handler {
+	logger.Info().Msg("Portfolio handler initialized")
+
+	return &Handler{
+		service: service,
+		rates:   rates,
+	}
+}
+
+// GetPortfolioHandler values a wallet's holdings at spot and at liquidation
+// @Summary Get wallet portfolio value
+// @Description Values a wallet's ETH and common token holdings, optionally with the latest Binance account snapshot, at spot and at the estimated proceeds of selling them through the order books (liquidation_value)
+// @Tags portfolio
+// @Accept json
+// @Produce json
+// @Param address query string true "Ethereum address (0x format)"
+// @Param currency query string false "Fiat currency to also value the portfolio in (e.g., EUR)"
+// @Param include_exchange query bool false "Also value the latest stored Binance account snapshot; requires the admin token"
+// @Success 200 {object} api.Response
+// @Failure 400 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Failure 503 {object} api.Response
+// @Router /portfolio [get]
+func (h *Handler) GetPortfolioHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		logger.Warn().Msg("Missing address parameter")
+		api.RespondWithError(w, http.StatusBadRequest, "Address parameter is required")
+		return
+	}
+	if !common.IsHexAddress(address) {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Ethereum address format")
+		return
+	}
+
+	currency := r.URL.Query().Get("currency")
+	if currency != "" {
+		var err error
+		if currency, err = fx.NormalizeCurrency(currency); err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid currency code. Use a 3-letter ISO 4217 code such as EUR")
+			return
+		}
+		if h.rates == nil {
+			api.RespondWithError(w, http.StatusServiceUnavailable, "Currency conversion is not available")
+			return
+		}
+	}
+
+	includeExchange := false
+	if v := r.URL.Query().Get("include_exchange"); v != "" {
+		var err error
+		if includeExchange, err = strconv.ParseBool(v); err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid include_exchange parameter. Use true or false")
+			return
+		}
+		// The exchange account is the operator's, so only administrators see it
+		if includeExchange && !api.IsAdmin(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			api.RespondWithError(w, http.StatusUnauthorized, "include_exchange requires the admin token")
+			return
+		}
+		if includeExchange && !h.service.HasExchange() {
+			api.RespondWithError(w, http.StatusServiceUnavailable, "Exchange account tracking is not available")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	logger.Info().
+		Str("address", address).
+		Bool("include_exchange", includeExchange).
+		Str("remote_addr", r.RemoteAddr).
+		Msg("Portfolio request received")
+
+	portfolio, err := h.service.Value(ctx, address, includeExchange)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("address", address).
+			Msg("Failed to value portfolio")
+		api.RespondWithError(w, http.StatusInternalServerError, "Failed to value portfolio")
+		return
+	}
+
+	if currency != "" {
+		rate, err := h.rates.Rate(ctx, currency, portfolio.ValuedAt)
+		if errors.Is(err, fx.ErrRateNotFound) {
+			api.RespondWithError(w, http.StatusNotFound, "No exchange rate available for "+currency)
+			return
+		} else if err != nil {
+			logger.Error().
+				Err(err).
+				Str("currency", currency).
+				Msg("Failed to get exchange rate")
+			api.RespondWithError(w, http.StatusInternalServerError, "Failed to convert currency")
+			return
+		}
+
+		portfolio.Fiat = &FiatValue{
+			Currency:         rate.Currency,
+			Rate:             rate.Rate,
+			RateDate:         rate.Date,
+			SpotValue:        portfolio.SpotValue * rate.Rate,
+			LiquidationValue: portfolio.LiquidationValue * rate.Rate,
+		}
+	}
+
+	response := api.Response{
+		Success: true,
+		Message: "Portfolio valued successfully",
+		Data:    portfolio,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Error().Err(err).Msg("Failed to encode response")
+	}
+}
@@ -0,0 +1,217 @@
+package portfolio
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/logger"
+	"my-fullstack-app/backend/internal/market"
+	"my-fullstack-app/backend/internal/models"
+)
+
+const (
+	// nativeAsset is the symbol of the chain's native currency on the exchange
+	nativeAsset = "ETH"
+
+	// SourceWallet marks holdings read from the chain; exchange holdings carry the exchange name
+	SourceWallet = "wallet"
+)
+
+// WalletReader reads on-chain balances of a wallet
+type WalletReader interface {
+	GetBalanceInEth(address string) (*big.Int, *big.Float, error)
+	GetCommonTokenHoldings(address string) ([]blockchain.TokenBalance, error)
+}
+
+// AssetValuer values a quantity of an asset at spot and at liquidation
+type AssetValuer interface {
+	ValueAsset(ctx context.Context, asset string, quantity float64) (*market.AssetValuation, error)
+}
+
+// CEXBalanceReader reads the latest stored balances of an exchange account
+type CEXBalanceReader interface {
+	LatestCEXBalances(ctx context.Context) ([]models.CEXBalanceSnapshot, error)
+}
+
+// Holding is one asset held by a wallet or exchange account with its valuation in USD
+type Holding struct {
+	Asset            string                 `json:"asset"`
+	Source           string                 `json:"source"`                  // SourceWallet or the exchange name
+	TokenAddress     string                 `json:"token_address,omitempty"` // Empty for the native currency
+	Balance          string                 `json:"balance"`                 // Formatted with decimals
+	Quantity         float64                `json:"quantity"`
+	SpotValue        float64                `json:"spot_value"`
+	LiquidationValue float64                `json:"liquidation_value"` // Estimated proceeds of selling at market
+	SlippageBps      float64                `json:"slippage_bps"`
+	Complete         bool                   `json:"complete"` // False if the holding could not be fully valued
+	Error            string                 `json:"error,omitempty"`
+	Valuation        *market.AssetValuation `json:"valuation,omitempty"`
+}
+
+// Portfolio is the valued holdings of a wallet
+type Portfolio struct {
+	Address          string     `json:"address"`
+	Holdings         []Holding  `json:"holdings"`
+	SpotValue        float64    `json:"spot_value"`        // USD
+	LiquidationValue float64    `json:"liquidation_value"` // USD
+	SlippageBps      float64    `json:"slippage_bps"`
+	Complete         bool       `json:"complete"`
+	ValuedAt         time.Time  `json:"valued_at"`
+	ExchangeTakenAt  *time.Time `json:"exchange_taken_at,omitempty"` // Time of the exchange snapshot included
+	ExchangeError    string     `json:"exchange_error,omitempty"`
+	Fiat             *FiatValue `json:"fiat,omitempty"`
+}
+
+// FiatValue is the portfolio value in another fiat currency
+type FiatValue struct {
+	Currency         string    `json:"currency"`
+	Rate             float64   `json:"rate"` // Units of currency per 1 USD
+	RateDate         time.Time `json:"rate_date"`
+	SpotValue        float64   `json:"spot_value"`
+	LiquidationValue float64   `json:"liquidation_value"`
+}
+
+// Service values wallet holdings
+type Service struct {
+	wallet   WalletReader
+	valuer   AssetValuer
+	exchange CEXBalanceReader
+}
+
+// NewService creates a portfolio valuation service. Exchange may be nil when no exchange
+// account is tracked.
+func NewService(wallet WalletReader, valuer AssetValuer, exchange CEXBalanceReader) *Service {
+	return &Service{
+		wallet:   wallet,
+		valuer:   valuer,
+		exchange: exchange,
+	}
+}
+
+// HasExchange reports whether exchange balances can be included
+func (s *Service) HasExchange() bool {
+	return s.exchange != nil
+}
+
+// Value fetches a wallet's balances and values each holding at spot and at liquidation.
+// With includeExchange the latest stored exchange account snapshot is valued alongside.
+func (s *Service) Value(ctx context.Context, address string, includeExchange bool) (*Portfolio, error) {
+	_, ethBalance, err := s.wallet.GetBalanceInEth(address)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.wallet.GetCommonTokenHoldings(address)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolio := &Portfolio{
+		Address:  address,
+		Holdings: []Holding{},
+		Complete: true,
+		ValuedAt: time.Now(),
+	}
+
+	if ethBalance.Sign() > 0 {
+		portfolio.add(s.valueHolding(ctx, Holding{
+			Asset:   nativeAsset,
+			Source:  SourceWallet,
+			Balance: ethBalance.Text('f', 18),
+		}, ethBalance))
+	}
+
+	for _, token := range tokens {
+		portfolio.add(s.valueHolding(ctx, Holding{
+			Asset:        token.Token.Symbol,
+			Source:       SourceWallet,
+			TokenAddress: token.Token.Address,
+			Balance:      token.Balance.Text('f', int(token.Token.Decimals)),
+		}, token.Balance))
+	}
+
+	if includeExchange && s.exchange != nil {
+		s.addExchangeHoldings(ctx, portfolio)
+	}
+
+	if portfolio.SpotValue > 0 {
+		portfolio.SlippageBps = (portfolio.SpotValue - portfolio.LiquidationValue) / portfolio.SpotValue * 1e4
+	}
+
+	logger.Info().
+		Str("address", address).
+		Int("holdings", len(portfolio.Holdings)).
+		Float64("spot_value", portfolio.SpotValue).
+		Float64("liquidation_value", portfolio.LiquidationValue).
+		Msg("Valued wallet portfolio")
+
+	return portfolio, nil
+}
+
+// addExchangeHoldings values the latest exchange snapshot into the portfolio. A snapshot
+// that cannot be read leaves the portfolio incomplete rather than failing it.
+func (s *Service) addExchangeHoldings(ctx context.Context, portfolio *Portfolio) {
+	balances, err := s.exchange.LatestCEXBalances(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to read exchange balances")
+		portfolio.ExchangeError = err.Error()
+		portfolio.Complete = false
+		return
+	}
+
+	for _, b := range balances {
+		free, okFree := new(big.Float).SetString(b.Free)
+		locked, okLocked := new(big.Float).SetString(b.Locked)
+		if !okFree || !okLocked {
+			portfolio.add(Holding{
+				Asset:   b.Asset,
+				Source:  b.Exchange,
+				Balance: b.Free,
+				Error:   "invalid stored balance",
+			})
+			continue
+		}
+		total := new(big.Float).Add(free, locked)
+
+		portfolio.add(s.valueHolding(ctx, Holding{
+			Asset:   b.Asset,
+			Source:  b.Exchange,
+			Balance: total.Text('f', -1),
+		}, total))
+
+		takenAt := b.TakenAt
+		portfolio.ExchangeTakenAt = &takenAt
+	}
+}
+
+// valueHolding values a single holding; a failed valuation is recorded on the holding
+func (s *Service) valueHolding(ctx context.Context, holding Holding, balance *big.Float) Holding {
+	holding.Quantity, _ = balance.Float64()
+
+	valuation, err := s.valuer.ValueAsset(ctx, holding.Asset, holding.Quantity)
+	if err != nil {
+		logger.Warn().
+			Err(err).
+			Str("asset", holding.Asset).
+			Msg("Failed to value holding")
+		holding.Error = err.Error()
+		return holding
+	}
+
+	holding.SpotValue = valuation.SpotUSD
+	holding.LiquidationValue = valuation.LiquidationUSD
+	holding.SlippageBps = valuation.SlippageBps
+	holding.Complete = valuation.Complete
+	holding.Valuation = valuation
+	return holding
+}
+
+// add includes a holding in the portfolio totals
+func (p *Portfolio) add(holding Holding) {
+	p.Holdings = append(p.Holdings, holding)
+	p.SpotValue += holding.SpotValue
+	p.LiquidationValue += holding.LiquidationValue
+	p.Complete = p.Complete && holding.Complete
+}
@@ -0,0 +1,155 @@
+package portfolio
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/market"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// fakeWallet serves fixed balances
+type fakeWallet struct {
+	eth    *big.Float
+	tokens []blockchain.TokenBalance
+}
+
+func (f fakeWallet) GetBalanceInEth(address string) (*big.Int, *big.Float, error) {
+	wei, _ := new(big.Float).Mul(f.eth, big.NewFloat(1e18)).Int(nil)
+	return wei, f.eth, nil
+}
+
+func (f fakeWallet) GetCommonTokenHoldings(address string) ([]blockchain.TokenBalance, error) {
+	return f.tokens, nil
+}
+
+// fakeValuer prices assets with a fixed spot price and a fixed liquidation haircut
+type fakeValuer map[string]float64
+
+func (f fakeValuer) ValueAsset(ctx context.Context, asset string, quantity float64) (*market.AssetValuation, error) {
+	price, ok := f[asset]
+	if !ok {
+		return nil, errors.New("no route")
+	}
+	return &market.AssetValuation{
+		Asset:          asset,
+		Quantity:       quantity,
+		SpotUSD:        quantity * price,
+		LiquidationUSD: quantity * price * 0.9,
+		SlippageBps:    1000,
+		Complete:       true,
+	}, nil
+}
+
+// fakeExchange serves a fixed exchange snapshot
+type fakeExchange struct {
+	balances []models.CEXBalanceSnapshot
+	err      error
+}
+
+func (f fakeExchange) LatestCEXBalances(ctx context.Context) ([]models.CEXBalanceSnapshot, error) {
+	return f.balances, f.err
+}
+
+func TestValue(t *testing.T) {
+	wallet := fakeWallet{
+		eth: big.NewFloat(2),
+		tokens: []blockchain.TokenBalance{
+			{Token: blockchain.CommonTokens["LINK"], Balance: big.NewFloat(10)},
+			{Token: blockchain.TokenInfo{Address: "0x1", Symbol: "OBSCURE", Decimals: 18}, Balance: big.NewFloat(5)},
+		},
+	}
+	service := NewService(wallet, fakeValuer{"ETH": 3000, "LINK": 15}, nil)
+
+	p, err := service.Value(context.Background(), "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(p.Holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d", len(p.Holdings))
+	}
+	if p.Holdings[0].Asset != "ETH" || p.Holdings[0].TokenAddress != "" {
+		t.Errorf("Expected ETH first without a token address, got %+v", p.Holdings[0])
+	}
+
+	if math.Abs(p.SpotValue-6150) > 1e-9 {
+		t.Errorf("Expected spot value 6150, got %f", p.SpotValue)
+	}
+	if math.Abs(p.LiquidationValue-5535) > 1e-9 {
+		t.Errorf("Expected liquidation value 5535, got %f", p.LiquidationValue)
+	}
+	if math.Abs(p.SlippageBps-1000) > 1e-9 {
+		t.Errorf("Expected slippage 1000 bps, got %f", p.SlippageBps)
+	}
+
+	// A holding that cannot be valued is reported but leaves the portfolio incomplete
+	obscure := p.Holdings[2]
+	if obscure.Error == "" || obscure.Complete || obscure.SpotValue != 0 {
+		t.Errorf("Expected an unvalued holding with an error, got %+v", obscure)
+	}
+	if p.Complete {
+		t.Errorf("Expected portfolio to be incomplete")
+	}
+}
+
+func TestValueWithExchange(t *testing.T) {
+	wallet := fakeWallet{eth: big.NewFloat(1)}
+	takenAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	exchange := fakeExchange{balances: []models.CEXBalanceSnapshot{
+		{Exchange: "binance", Asset: "ETH", Free: "0.5", Locked: "0.25", TakenAt: takenAt},
+		{Exchange: "binance", Asset: "USDT", Free: "100", Locked: "0", TakenAt: takenAt},
+	}}
+	service := NewService(wallet, fakeValuer{"ETH": 2000, "USDT": 1}, exchange)
+
+	p, err := service.Value(context.Background(), "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(p.Holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d", len(p.Holdings))
+	}
+	if p.Holdings[0].Source != SourceWallet || p.Holdings[1].Source != "binance" {
+		t.Errorf("Expected wallet then exchange holdings, got %q and %q", p.Holdings[0].Source, p.Holdings[1].Source)
+	}
+
+	// Locked balances count towards the exchange holding
+	if p.Holdings[1].Quantity != 0.75 {
+		t.Errorf("Expected exchange ETH quantity 0.75, got %f", p.Holdings[1].Quantity)
+	}
+	if math.Abs(p.SpotValue-3600) > 1e-9 {
+		t.Errorf("Expected spot value 3600, got %f", p.SpotValue)
+	}
+	if p.ExchangeTakenAt == nil || !p.ExchangeTakenAt.Equal(takenAt) {
+		t.Errorf("Expected exchange snapshot time %s, got %v", takenAt, p.ExchangeTakenAt)
+	}
+	if !p.Complete {
+		t.Errorf("Expected portfolio to be complete")
+	}
+
+	// Excluding the exchange leaves only wallet holdings
+	p, err = service.Value(context.Background(), "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(p.Holdings) != 1 || p.ExchangeTakenAt != nil {
+		t.Errorf("Expected only the wallet holding, got %+v", p.Holdings)
+	}
+
+	// An unreadable snapshot is reported without failing the wallet valuation
+	service = NewService(wallet, fakeValuer{"ETH": 2000}, fakeExchange{err: errors.New("database unavailable")})
+	p, err = service.Value(context.Background(), "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.ExchangeError == "" || p.Complete || len(p.Holdings) != 1 {
+		t.Errorf("Expected an incomplete portfolio with an exchange error, got %+v", p)
+	}
+}
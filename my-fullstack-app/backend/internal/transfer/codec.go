@@ -0,0 +1,245 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatchSize is how many rows are buffered before they are handed to the Parquet writer
+const parquetBatchSize = 1024
+
+// rowWriter writes rows of one type to a file in one format. Close completes the file but
+// does not close the underlying writer.
+type rowWriter[T any] interface {
+	Write(row T) error
+	Close() error
+}
+
+func newRowWriter[T any, P row[T]](format Format, w io.Writer) rowWriter[T] {
+	switch format {
+	case CSV:
+		return &csvWriter[T, P]{w: csv.NewWriter(w)}
+	case Parquet:
+		return &parquetWriter[T]{w: parquet.NewGenericWriter[T](w)}
+	}
+	return &ndjsonWriter[T]{buf: bufio.NewWriter(w)}
+}
+
+type ndjsonWriter[T any] struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter[T]) Write(row T) error {
+	if w.enc == nil {
+		w.enc = json.NewEncoder(w.buf)
+	}
+	return w.enc.Encode(row)
+}
+
+func (w *ndjsonWriter[T]) Close() error {
+	return w.buf.Flush()
+}
+
+type csvWriter[T any, P row[T]] struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter[T, P]) header() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.Write(P(new(T)).columns())
+}
+
+func (w *csvWriter[T, P]) Write(row T) error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	return w.w.Write(P(&row).csvRecord())
+}
+
+// Close writes the header of an empty file and flushes the rows
+func (w *csvWriter[T, P]) Close() error {
+	if err := w.header(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type parquetWriter[T any] struct {
+	w     *parquet.GenericWriter[T]
+	batch []T
+}
+
+func (w *parquetWriter[T]) Write(row T) error {
+	w.batch = append(w.batch, row)
+	if len(w.batch) < parquetBatchSize {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *parquetWriter[T]) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+func (w *parquetWriter[T]) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
+
+// readRows reads the rows of a file in a format, validates each one and passes it to fn,
+// stopping at the first row that is invalid or fails. It returns how many rows were passed
+// to fn successfully. Rows are numbered from 1 in errors; a CSV file's header is not a row.
+func readRows[T any, P row[T]](format Format, r io.Reader, fn func(T) error) (int, error) {
+	n, imported := 0, 0
+	handle := func(row T) error {
+		n++
+		if err := P(&row).validate(); err != nil {
+			return fmt.Errorf("row %d: %w: %v", n, ErrInvalidRow, err)
+		}
+		if err := fn(row); err != nil {
+			return fmt.Errorf("row %d: %w", n, err)
+		}
+		imported++
+		return nil
+	}
+
+	var err error
+	switch format {
+	case CSV:
+		err = readCSV[T, P](r, &n, handle)
+	case Parquet:
+		err = readParquet(r, handle)
+	default:
+		err = readNDJSON(r, &n, handle)
+	}
+	return imported, err
+}
+
+func readNDJSON[T any](r io.Reader, n *int, handle func(T) error) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	for {
+		var row T
+		err := dec.Decode(&row)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w: %v", *n+1, ErrInvalidRow, err)
+		}
+		if err := handle(row); err != nil {
+			return err
+		}
+	}
+}
+
+func readCSV[T any, P row[T]](r io.Reader, n *int, handle func(T) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("header: %w: %v", ErrInvalidRow, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		index[column] = i
+	}
+	for _, column := range P(new(T)).columns() {
+		if _, ok := index[column]; !ok {
+			return fmt.Errorf("header: %w: missing column %s", ErrInvalidRow, column)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("row %d: %w: %v", *n+1, ErrInvalidRow, err)
+		}
+
+		var row T
+		field := func(column string) string { return record[index[column]] }
+		if err := P(&row).parseCSV(field); err != nil {
+			return fmt.Errorf("row %d: %w: %v", *n+1, ErrInvalidRow, err)
+		}
+		if err := handle(row); err != nil {
+			return err
+		}
+	}
+}
+
+// readParquet reads a Parquet file, whose footer is at the end, so a stream that is not a
+// regular file is first copied to a temporary one
+func readParquet[T any](r io.Reader, handle func(T) error) error {
+	file, ok := r.(*os.File)
+	if ok {
+		info, err := file.Stat()
+		ok = err == nil && info.Mode().IsRegular()
+	}
+	if !ok {
+		tmp, err := os.CreateTemp("", "import-*.parquet")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, r); err != nil {
+			return err
+		}
+		file = tmp
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	f, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return fmt.Errorf("%w: not a parquet file: %v", ErrInvalidRow, err)
+	}
+
+	reader := parquet.NewGenericReader[T](f)
+	defer reader.Close()
+
+	rows := make([]T, parquetBatchSize)
+	for {
+		count, err := reader.Read(rows)
+		for _, row := range rows[:count] {
+			if err := handle(row); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package transfer exports the tracked data to files and imports it back, for backups and
+// for moving data between databases, such as from a local SQLite file to Postgres.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidRow is wrapped by import errors caused by the content of a file rather than
+// by the database
+var ErrInvalidRow = errors.New("invalid row")
+
+// Format is a file format of an export
+type Format string
+
+const (
+	// NDJSON writes one JSON object per line
+	NDJSON Format = "ndjson"
+	// CSV writes a header row and one row per record
+	CSV Format = "csv"
+	// Parquet writes a columnar file for analytics tools
+	Parquet Format = "parquet"
+)
+
+// ParseFormat parses a format name. An empty name means NDJSON.
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case "", NDJSON:
+		return NDJSON, nil
+	case CSV:
+		return CSV, nil
+	case Parquet:
+		return Parquet, nil
+	}
+	return "", fmt.Errorf("unknown format %q: use ndjson, csv or parquet", value)
+}
+
+// FormatOfFile returns the format named by a file's extension, or NDJSON when the extension
+// is not one of the formats
+func FormatOfFile(path string) Format {
+	format, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return NDJSON
+	}
+	return format
+}
+
+// ContentType returns the MIME type of files in the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv"
+	case Parquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/x-ndjson"
+}
+
+// Dataset is a kind of tracked data that can be exported and imported
+type Dataset string
+
+const (
+	// Balances are the native balance records
+	Balances Dataset = "balances"
+	// TokenBalances are the ERC20 token balance records
+	TokenBalances Dataset = "token_balances"
+	// Watchlists are the watchlists, one row per address
+	Watchlists Dataset = "watchlists"
+	// TokenMappings are the token registry entries
+	TokenMappings Dataset = "token_mappings"
+)
+
+// Datasets lists every dataset
+var Datasets = []Dataset{Balances, TokenBalances, Watchlists, TokenMappings}
+
+// ParseDataset parses a dataset name
+func ParseDataset(value string) (Dataset, error) {
+	for _, d := range Datasets {
+		if string(d) == value {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown dataset %q: use balances, token_balances, watchlists or token_mappings", value)
+}
@@ -0,0 +1,158 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/logger"
+
+	"github.com/gorilla/mux"
+)
+
+// ImportResult reports an import
+type ImportResult struct {
+	Dataset Dataset `json:"dataset"`
+	Format  Format  `json:"format"`
+	Rows    int     `json:"rows"`
+	Error   string  `json:"error,omitempty"` // Why an import stopped early, in the audit log
+}
+
+// Handler serves exports and imports to administrators
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates an export and import handler
+func NewHandler(service *Service) *Handler {
+	logger.Info().Msg("Export and import handler initialized")
+
+	return &Handler{
+		service: service,
+	}
+}
+
+// ExportHandler streams a dataset as a file
+// @Summary Export tracked data
+// @Description Streams every row of a dataset as an NDJSON, CSV or Parquet file. Requires the admin token.
+// @Tags admin
+// @Produce octet-stream
+// @Param dataset path string true "balances, token_balances, watchlists or token_mappings"
+// @Param format query string false "ndjson (default), csv or parquet"
+// @Success 200 {file} file
+// @Failure 400 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Security AdminToken
+// @Router /admin/export/{dataset} [get]
+func (h *Handler) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	dataset, err := ParseDataset(mux.Vars(r)["dataset"])
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out := &responseWriter{w: w, contentType: format.ContentType(), filename: fmt.Sprintf("%s.%s", dataset, format)}
+	rows, err := h.service.Export(r.Context(), dataset, format, out)
+	if err != nil {
+		logger.Error().Err(err).Str("dataset", string(dataset)).Int("rows", rows).Msg("Export failed")
+		// Once the file has started the status is sent, so the client sees a truncated file
+		if !out.started {
+			api.RespondWithError(w, http.StatusInternalServerError, "Failed to export data")
+		}
+		return
+	}
+	if !out.started {
+		out.start()
+	}
+	logger.Info().Str("dataset", string(dataset)).Str("format", string(format)).Int("rows", rows).Msg("Exported data")
+}
+
+// ImportHandler imports a dataset from the request body
+// @Summary Import tracked data
+// @Description Imports a dataset from an NDJSON, CSV or Parquet file in the request body. Addresses must match the address format of the tables. Rows that are already stored are updated, so a file can be imported again safely. The import stops at the first invalid row. Requires the admin token.
+// @Tags admin
+// @Accept octet-stream
+// @Produce json
+// @Param dataset path string true "balances, token_balances, watchlists or token_mappings"
+// @Param format query string false "ndjson (default), csv or parquet"
+// @Success 200 {object} api.Response{data=ImportResult}
+// @Failure 400 {object} api.Response
+// @Failure 401 {object} api.Response
+// @Failure 500 {object} api.Response
+// @Security AdminToken
+// @Router /admin/import/{dataset} [post]
+func (h *Handler) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	dataset, err := ParseDataset(mux.Vars(r)["dataset"])
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := h.service.Import(r.Context(), dataset, format, r.Body)
+	if err != nil {
+		logger.Error().Err(err).Str("dataset", string(dataset)).Int("rows", rows).Msg("Import failed")
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidRow) {
+			code = http.StatusBadRequest
+		}
+		// Rows before the failure stay imported
+		if rows > 0 {
+			result := ImportResult{Dataset: dataset, Format: format, Rows: rows, Error: err.Error()}
+			if err := h.service.RecordImport(api.AuditContext(r), result); err != nil {
+				logger.Error().Err(err).Str("dataset", string(dataset)).Msg("Failed to record import in the audit log")
+				code = http.StatusInternalServerError
+			}
+		}
+		api.RespondWithError(w, code, fmt.Sprintf("Imported %d rows, then: %v", rows, err))
+		return
+	}
+
+	logger.Info().Str("dataset", string(dataset)).Str("format", string(format)).Int("rows", rows).Msg("Imported data")
+	result := ImportResult{Dataset: dataset, Format: format, Rows: rows}
+	if err := h.service.RecordImport(api.AuditContext(r), result); err != nil {
+		logger.Error().Err(err).Str("dataset", string(dataset)).Msg("Failed to record import in the audit log")
+		api.RespondWithError(w, http.StatusInternalServerError,
+			fmt.Sprintf("Imported %d rows, but could not record the import in the audit log", rows))
+		return
+	}
+	api.RespondWithJSON(w, http.StatusOK, api.Response{
+		Success: true,
+		Message: fmt.Sprintf("Imported %d rows", rows),
+		Data:    result,
+	})
+}
+
+// responseWriter sends the headers of the exported file with its first bytes, so an export
+// that fails before writing anything can still be answered with an error
+type responseWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (o *responseWriter) start() {
+	o.started = true
+	o.w.Header().Set("Content-Type", o.contentType)
+	o.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", o.filename))
+	o.w.WriteHeader(http.StatusOK)
+}
+
+func (o *responseWriter) Write(p []byte) (int, error) {
+	if !o.started {
+		o.start()
+	}
+	return o.w.Write(p)
+}
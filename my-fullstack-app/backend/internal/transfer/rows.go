@@ -0,0 +1,329 @@
+package transfer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// addressFormat is the address_format constraint of the balance tables
+var addressFormat = regexp.MustCompile(`^0x[a-fA-F0-9]{40}$`)
+
+// wholeNumber matches a non-negative integer amount
+var wholeNumber = regexp.MustCompile(`^[0-9]+$`)
+
+// row is implemented by the pointer of each row type. Rows are written to every format
+// with the same column names.
+type row[T any] interface {
+	*T
+	columns() []string
+	csvRecord() []string
+	// parseCSV fills the row from the CSV columns it names
+	parseCSV(field func(column string) string) error
+	// validate checks the row before it is imported
+	validate() error
+}
+
+// BalanceRow is a native balance record. BalanceETH is derived from Balance and ignored by
+// imports, as is ValidTo, which is recomputed as the records are stored.
+type BalanceRow struct {
+	Chain         string     `json:"chain" parquet:"chain"`
+	Address       string     `json:"address" parquet:"address"`
+	Balance       string     `json:"balance" parquet:"balance"`
+	BalanceETH    string     `json:"balance_eth" parquet:"balance_eth"`
+	BlockNumber   *uint64    `json:"block_number" parquet:"block_number,optional"`
+	LastSeenBlock *uint64    `json:"last_seen_block" parquet:"last_seen_block,optional"`
+	FetchedAt     time.Time  `json:"fetched_at" parquet:"fetched_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at" parquet:"last_seen_at"`
+	ValidTo       *time.Time `json:"valid_to" parquet:"valid_to,optional"`
+}
+
+func balanceRow(r models.BalanceRecord) BalanceRow {
+	return BalanceRow{
+		Chain:         r.Chain,
+		Address:       r.Address,
+		Balance:       r.Balance,
+		BalanceETH:    r.BalanceETH,
+		BlockNumber:   r.BlockNumber,
+		LastSeenBlock: r.LastSeenBlock,
+		FetchedAt:     r.FetchedAt.UTC(),
+		LastSeenAt:    r.LastSeenAt.UTC(),
+		ValidTo:       utcPtr(r.ValidTo),
+	}
+}
+
+func (r BalanceRow) record() models.BalanceRecord {
+	return models.BalanceRecord{
+		Chain:         r.Chain,
+		Address:       r.Address,
+		Balance:       r.Balance,
+		BlockNumber:   r.BlockNumber,
+		LastSeenBlock: r.LastSeenBlock,
+		FetchedAt:     r.FetchedAt,
+		LastSeenAt:    r.LastSeenAt,
+	}
+}
+
+func (r *BalanceRow) columns() []string {
+	return []string{"chain", "address", "balance", "balance_eth", "block_number", "last_seen_block", "fetched_at", "last_seen_at", "valid_to"}
+}
+
+func (r *BalanceRow) csvRecord() []string {
+	return []string{r.Chain, r.Address, r.Balance, r.BalanceETH, formatBlock(r.BlockNumber), formatBlock(r.LastSeenBlock),
+		formatTime(&r.FetchedAt), formatTime(&r.LastSeenAt), formatTime(r.ValidTo)}
+}
+
+func (r *BalanceRow) parseCSV(field func(string) string) (err error) {
+	r.Chain, r.Address, r.Balance, r.BalanceETH = field("chain"), field("address"), field("balance"), field("balance_eth")
+	if r.BlockNumber, err = parseBlock(field("block_number")); err != nil {
+		return err
+	}
+	if r.LastSeenBlock, err = parseBlock(field("last_seen_block")); err != nil {
+		return err
+	}
+	if r.FetchedAt, err = parseTime("fetched_at", field("fetched_at")); err != nil {
+		return err
+	}
+	if v := field("last_seen_at"); v != "" {
+		if r.LastSeenAt, err = parseTime("last_seen_at", v); err != nil {
+			return err
+		}
+	}
+	if v := field("valid_to"); v != "" {
+		validTo, err := parseTime("valid_to", v)
+		if err != nil {
+			return err
+		}
+		r.ValidTo = &validTo
+	}
+	return nil
+}
+
+func (r *BalanceRow) validate() error {
+	if !addressFormat.MatchString(r.Address) {
+		return fmt.Errorf("invalid address %q", r.Address)
+	}
+	if !wholeNumber.MatchString(r.Balance) {
+		return fmt.Errorf("invalid wei balance %q", r.Balance)
+	}
+	if r.FetchedAt.IsZero() {
+		return fmt.Errorf("missing fetched_at")
+	}
+	return nil
+}
+
+// TokenBalanceRow is an ERC20 token balance record. Amount is derived from RawAmount and
+// Decimals and ignored by imports.
+type TokenBalanceRow struct {
+	Chain         string    `json:"chain" parquet:"chain"`
+	HolderAddress string    `json:"holder_address" parquet:"holder_address"`
+	TokenAddress  string    `json:"token_address" parquet:"token_address"`
+	RawAmount     string    `json:"raw_amount" parquet:"raw_amount"`
+	Amount        string    `json:"amount" parquet:"amount"`
+	Decimals      int       `json:"decimals" parquet:"decimals"`
+	BlockNumber   *uint64   `json:"block_number" parquet:"block_number,optional"`
+	ValueUSD      *float64  `json:"value_usd" parquet:"value_usd,optional"`
+	PriceSource   string    `json:"price_source" parquet:"price_source"`
+	FetchedAt     time.Time `json:"fetched_at" parquet:"fetched_at"`
+}
+
+func tokenBalanceRow(r models.TokenBalanceRecord) TokenBalanceRow {
+	return TokenBalanceRow{
+		Chain:         r.Chain,
+		HolderAddress: r.Address,
+		TokenAddress:  r.TokenAddress,
+		RawAmount:     r.Balance,
+		Amount:        r.BalanceETH,
+		Decimals:      int(r.Decimals),
+		BlockNumber:   r.BlockNumber,
+		ValueUSD:      r.ValueUSD,
+		PriceSource:   r.PriceSource,
+		FetchedAt:     r.FetchedAt.UTC(),
+	}
+}
+
+func (r TokenBalanceRow) record() models.TokenBalanceRecord {
+	return models.TokenBalanceRecord{
+		Chain:        r.Chain,
+		Address:      r.HolderAddress,
+		TokenAddress: r.TokenAddress,
+		Balance:      r.RawAmount,
+		Decimals:     uint8(r.Decimals),
+		BlockNumber:  r.BlockNumber,
+		ValueUSD:     r.ValueUSD,
+		PriceSource:  r.PriceSource,
+		FetchedAt:    r.FetchedAt,
+	}
+}
+
+func (r *TokenBalanceRow) columns() []string {
+	return []string{"chain", "holder_address", "token_address", "raw_amount", "amount", "decimals", "block_number", "value_usd", "price_source", "fetched_at"}
+}
+
+func (r *TokenBalanceRow) csvRecord() []string {
+	valueUSD := ""
+	if r.ValueUSD != nil {
+		valueUSD = strconv.FormatFloat(*r.ValueUSD, 'f', -1, 64)
+	}
+	return []string{r.Chain, r.HolderAddress, r.TokenAddress, r.RawAmount, r.Amount, strconv.Itoa(r.Decimals),
+		formatBlock(r.BlockNumber), valueUSD, r.PriceSource, formatTime(&r.FetchedAt)}
+}
+
+func (r *TokenBalanceRow) parseCSV(field func(string) string) (err error) {
+	r.Chain, r.HolderAddress, r.TokenAddress = field("chain"), field("holder_address"), field("token_address")
+	r.RawAmount, r.Amount, r.PriceSource = field("raw_amount"), field("amount"), field("price_source")
+
+	if r.Decimals, err = strconv.Atoi(field("decimals")); err != nil {
+		return fmt.Errorf("invalid decimals %q", field("decimals"))
+	}
+	if r.BlockNumber, err = parseBlock(field("block_number")); err != nil {
+		return err
+	}
+	if v := field("value_usd"); v != "" {
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value_usd %q", v)
+		}
+		r.ValueUSD = &value
+	}
+	r.FetchedAt, err = parseTime("fetched_at", field("fetched_at"))
+	return err
+}
+
+func (r *TokenBalanceRow) validate() error {
+	if !addressFormat.MatchString(r.HolderAddress) {
+		return fmt.Errorf("invalid holder address %q", r.HolderAddress)
+	}
+	if !addressFormat.MatchString(r.TokenAddress) {
+		return fmt.Errorf("invalid token address %q", r.TokenAddress)
+	}
+	if !wholeNumber.MatchString(r.RawAmount) {
+		return fmt.Errorf("invalid raw amount %q", r.RawAmount)
+	}
+	if r.Decimals < 0 || r.Decimals > 77 {
+		return fmt.Errorf("invalid decimals %d", r.Decimals)
+	}
+	if r.FetchedAt.IsZero() {
+		return fmt.Errorf("missing fetched_at")
+	}
+	return nil
+}
+
+// WatchlistRow is one address of a watchlist. A watchlist without addresses has a single
+// row with an empty address. CreatedAt is used when an import creates the watchlist.
+type WatchlistRow struct {
+	Name      string    `json:"name" parquet:"name"`
+	Address   string    `json:"address" parquet:"address"`
+	CreatedAt time.Time `json:"created_at" parquet:"created_at"`
+}
+
+func (r *WatchlistRow) columns() []string {
+	return []string{"name", "address", "created_at"}
+}
+
+func (r *WatchlistRow) csvRecord() []string {
+	return []string{r.Name, r.Address, formatTime(&r.CreatedAt)}
+}
+
+func (r *WatchlistRow) parseCSV(field func(string) string) (err error) {
+	r.Name, r.Address = field("name"), field("address")
+	if v := field("created_at"); v != "" {
+		r.CreatedAt, err = parseTime("created_at", v)
+	}
+	return err
+}
+
+func (r *WatchlistRow) validate() error {
+	if r.Name == "" || len(r.Name) > 100 {
+		return fmt.Errorf("watchlist name must be 1 to 100 characters")
+	}
+	if r.Address != "" && !addressFormat.MatchString(r.Address) {
+		return fmt.Errorf("invalid address %q", r.Address)
+	}
+	return nil
+}
+
+// TokenMappingRow is a token registry entry
+type TokenMappingRow struct {
+	Address string `json:"address" parquet:"address"`
+	Symbol  string `json:"symbol" parquet:"symbol"`
+	Pair    string `json:"pair" parquet:"pair"`
+	Peg     string `json:"peg" parquet:"peg"`
+}
+
+func (r TokenMappingRow) mapping() models.TokenMapping {
+	return models.TokenMapping{Address: r.Address, Symbol: r.Symbol, Pair: r.Pair, Peg: r.Peg}
+}
+
+func (r *TokenMappingRow) columns() []string {
+	return []string{"address", "symbol", "pair", "peg"}
+}
+
+func (r *TokenMappingRow) csvRecord() []string {
+	return []string{r.Address, r.Symbol, r.Pair, r.Peg}
+}
+
+func (r *TokenMappingRow) parseCSV(field func(string) string) error {
+	r.Address, r.Symbol, r.Pair, r.Peg = field("address"), field("symbol"), field("pair"), field("peg")
+	return nil
+}
+
+func (r *TokenMappingRow) validate() error {
+	if !addressFormat.MatchString(r.Address) {
+		return fmt.Errorf("invalid address %q", r.Address)
+	}
+	if r.Symbol == "" {
+		return fmt.Errorf("missing symbol of %s", r.Address)
+	}
+	mapping := r.mapping()
+	if err := blockchain.ValidateTokenMapping(&mapping); err != nil {
+		return err
+	}
+	r.Pair, r.Peg = mapping.Pair, mapping.Peg
+	return nil
+}
+
+func formatBlock(block *uint64) string {
+	if block == nil {
+		return ""
+	}
+	return strconv.FormatUint(*block, 10)
+}
+
+func parseBlock(value string) (*uint64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	block, err := strconv.ParseUint(value, 10, 63)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block number %q", value)
+	}
+	return &block, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parseTime(column, value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return t, fmt.Errorf("invalid %s %q: use RFC3339", column, value)
+	}
+	return t, nil
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
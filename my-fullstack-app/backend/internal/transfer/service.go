@@ -0,0 +1,151 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/models"
+)
+
+// Service exports datasets from a database and imports them back. Imports are idempotent:
+// importing the same file twice leaves the database as importing it once.
+type Service struct {
+	cluster  *database.Cluster
+	registry *blockchain.TokenRegistry
+}
+
+// NewService creates a transfer service. Exports read from a replica when the cluster has a
+// usable one. Token mappings are exported from the registry and imported into it as well as
+// the database; registry may be nil, in which case only stored mappings are used.
+func NewService(cluster *database.Cluster, registry *blockchain.TokenRegistry) *Service {
+	return &Service{cluster: cluster, registry: registry}
+}
+
+// Export writes a dataset to w and returns the number of rows written
+func (s *Service) Export(ctx context.Context, dataset Dataset, format Format, w io.Writer) (int, error) {
+	db := s.cluster.Reader(ctx)
+
+	switch dataset {
+	case Balances:
+		return export(format, w, func(emit func(BalanceRow) error) error {
+			return database.StreamBalances(ctx, db, func(r models.BalanceRecord) error {
+				return emit(balanceRow(r))
+			})
+		})
+
+	case TokenBalances:
+		return export(format, w, func(emit func(TokenBalanceRow) error) error {
+			return database.StreamTokenBalances(ctx, db, func(r models.TokenBalanceRecord) error {
+				return emit(tokenBalanceRow(r))
+			})
+		})
+
+	case Watchlists:
+		return export(format, w, func(emit func(WatchlistRow) error) error {
+			return database.StreamWatchlists(ctx, db, func(name, address string, createdAt time.Time) error {
+				return emit(WatchlistRow{Name: name, Address: address, CreatedAt: createdAt.UTC()})
+			})
+		})
+
+	case TokenMappings:
+		return export(format, w, func(emit func(TokenMappingRow) error) error {
+			mappings, err := s.tokenMappings()
+			if err != nil {
+				return err
+			}
+			for _, m := range mappings {
+				if err := emit(TokenMappingRow{Address: m.Address, Symbol: m.Symbol, Pair: m.Pair, Peg: m.Peg}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return 0, fmt.Errorf("unknown dataset %q", dataset)
+}
+
+// Import reads a dataset from r into the database and returns the number of rows imported.
+// It stops at the first invalid row; the rows before it stay imported, and since imports
+// are idempotent the corrected file can simply be imported again.
+func (s *Service) Import(ctx context.Context, dataset Dataset, format Format, r io.Reader) (int, error) {
+	db := s.cluster.Primary()
+
+	switch dataset {
+	case Balances:
+		return readRows(format, r, func(row BalanceRow) error {
+			_, err := database.ImportBalance(db, row.record())
+			return err
+		})
+
+	case TokenBalances:
+		return readRows(format, r, func(row TokenBalanceRow) error {
+			_, err := database.ImportTokenBalance(db, row.record())
+			return err
+		})
+
+	case Watchlists:
+		return readRows(format, r, func(row WatchlistRow) error {
+			return database.AddWatchlistAddress(db, row.Name, row.Address, row.CreatedAt)
+		})
+
+	case TokenMappings:
+		return readRows(format, r, func(row TokenMappingRow) error {
+			if err := database.SaveTokenMapping(db, row.mapping()); err != nil {
+				return err
+			}
+			if s.registry != nil {
+				return s.registry.Set(row.mapping())
+			}
+			return nil
+		})
+	}
+	return 0, fmt.Errorf("unknown dataset %q", dataset)
+}
+
+// RecordImport appends an import to the audit log, attributed to the caller of ctx from
+// database.WithAudit. An import spans many transactions, so it is recorded once it is done.
+func (s *Service) RecordImport(ctx context.Context, result ImportResult) error {
+	return database.RecordAudit(ctx, s.cluster.Primary(), models.AuditDataImported, string(result.Dataset), nil, result)
+}
+
+// tokenMappings returns the registry's mappings, or the stored ones without a registry
+func (s *Service) tokenMappings() ([]models.TokenMapping, error) {
+	if s.registry != nil {
+		return s.registry.Mappings(), nil
+	}
+	return database.GetTokenMappings(s.cluster.Primary())
+}
+
+// export writes the rows a stream emits
+func export[T any, P row[T]](format Format, w io.Writer, stream func(emit func(T) error) error) (int, error) {
+	writer := newRowWriter[T, P](format, w)
+	n := 0
+	err := stream(func(row T) error {
+		n++
+		return writer.Write(row)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, writer.Close()
+}
+
+// LoadTokenMappings adds the stored token mappings, such as imported ones, to a registry.
+// They replace built-in and file mappings of the same tokens.
+func LoadTokenMappings(db *sql.DB, registry *blockchain.TokenRegistry) (int, error) {
+	mappings, err := database.GetTokenMappings(db)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range mappings {
+		if err := registry.Set(m); err != nil {
+			return 0, err
+		}
+	}
+	return len(mappings), nil
+}
@@ -0,0 +1,260 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"my-fullstack-app/backend/internal/api"
+	"my-fullstack-app/backend/internal/blockchain"
+	"my-fullstack-app/backend/internal/database"
+	"my-fullstack-app/backend/internal/database/dbtest"
+	"my-fullstack-app/backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	holder = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	token  = "0x514910771af9ca656af840dff83e8264ecf986ca"
+)
+
+var formats = []Format{NDJSON, CSV, Parquet}
+
+// roundTrip writes rows in a format and reads them back
+func roundTrip[T any, P row[T]](t *testing.T, format Format, rows []T) []T {
+	t.Helper()
+
+	var buf bytes.Buffer
+	n, err := export[T, P](format, &buf, func(emit func(T) error) error {
+		for _, r := range rows {
+			if err := emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || n != len(rows) {
+		t.Fatalf("%s: expected %d rows written, got %d, %v", format, len(rows), n, err)
+	}
+
+	var read []T
+	if _, err := readRows[T, P](format, &buf, func(r T) error {
+		read = append(read, r)
+		return nil
+	}); err != nil {
+		t.Fatalf("%s: unexpected error: %v", format, err)
+	}
+	return read
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	fetchedAt := time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
+	validTo := fetchedAt.Add(time.Hour)
+	block, seen := uint64(100), uint64(120)
+	value := 12.5
+
+	balances := []BalanceRow{
+		{Chain: "ethereum", Address: holder, Balance: "1500000000000000000", BalanceETH: "1.500000000000000000",
+			BlockNumber: &block, LastSeenBlock: &seen, FetchedAt: fetchedAt, LastSeenAt: fetchedAt.Add(time.Minute), ValidTo: &validTo},
+		{Chain: "ethereum", Address: holder, Balance: "7", BalanceETH: "0.000000000000000007", FetchedAt: validTo, LastSeenAt: validTo},
+	}
+	tokenBalances := []TokenBalanceRow{
+		{Chain: "ethereum", HolderAddress: holder, TokenAddress: token, RawAmount: "2500000000000000000", Amount: "2.500000000000000000",
+			Decimals: 18, BlockNumber: &block, ValueUSD: &value, PriceSource: "binance:LINKUSDT", FetchedAt: fetchedAt},
+		{Chain: "ethereum", HolderAddress: holder, TokenAddress: token, RawAmount: "0", Amount: "0.000000000000000000",
+			Decimals: 18, PriceSource: "unpriced", FetchedAt: validTo},
+	}
+	watchlists := []WatchlistRow{{Name: "team", Address: holder, CreatedAt: fetchedAt}, {Name: "empty", CreatedAt: fetchedAt}}
+	mappings := []TokenMappingRow{{Address: token, Symbol: "LINK", Pair: "LINKUSDT"}, {Address: holder, Symbol: "USDX", Peg: "USD"}}
+
+	for _, format := range formats {
+		if got := roundTrip(t, format, balances); !reflect.DeepEqual(got, balances) {
+			t.Errorf("%s: expected balances %+v, got %+v", format, balances, got)
+		}
+		if got := roundTrip(t, format, tokenBalances); !reflect.DeepEqual(got, tokenBalances) {
+			t.Errorf("%s: expected token balances %+v, got %+v", format, tokenBalances, got)
+		}
+		if got := roundTrip(t, format, watchlists); !reflect.DeepEqual(got, watchlists) {
+			t.Errorf("%s: expected watchlists %+v, got %+v", format, watchlists, got)
+		}
+		if got := roundTrip(t, format, mappings); !reflect.DeepEqual(got, mappings) {
+			t.Errorf("%s: expected mappings %+v, got %+v", format, mappings, got)
+		}
+	}
+}
+
+func TestImportRejectsInvalidRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"address without prefix", `{"address":"742d35Cc6634C0532925a3b844Bc454e4438f44e","balance":"1","fetched_at":"2024-01-01T00:00:00Z"}`},
+		{"short address", `{"address":"0x742d35","balance":"1","fetched_at":"2024-01-01T00:00:00Z"}`},
+		{"fractional balance", `{"address":"` + holder + `","balance":"1.5","fetched_at":"2024-01-01T00:00:00Z"}`},
+		{"missing time", `{"address":"` + holder + `","balance":"1"}`},
+		{"unknown field", `{"address":"` + holder + `","balance":"1","fetched_at":"2024-01-01T00:00:00Z","id":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `{"address":"` + holder + `","balance":"1","fetched_at":"2024-01-01T00:00:00Z"}` + "\n" + tt.input + "\n"
+			n, err := readRows[BalanceRow](NDJSON, strings.NewReader(input), func(BalanceRow) error { return nil })
+			if !errors.Is(err, ErrInvalidRow) || !strings.HasPrefix(err.Error(), "row 2:") || n != 1 {
+				t.Errorf("Expected row 2 rejected after 1 import, got %d, %v", n, err)
+			}
+		})
+	}
+
+	// CSV columns are matched by name
+	csvInput := "fetched_at,address,balance,chain,balance_eth,block_number,last_seen_block,last_seen_at,valid_to\n" +
+		"2024-01-01T00:00:00Z,0xnot-an-address,1,ethereum,,,,,\n"
+	if _, err := readRows[BalanceRow](CSV, strings.NewReader(csvInput), func(BalanceRow) error { return nil }); !errors.Is(err, ErrInvalidRow) {
+		t.Errorf("Expected an invalid CSV address to be rejected, got %v", err)
+	}
+	if _, err := readRows[BalanceRow](CSV, strings.NewReader("address,balance\n"), func(BalanceRow) error { return nil }); !errors.Is(err, ErrInvalidRow) {
+		t.Errorf("Expected missing CSV columns to be rejected, got %v", err)
+	}
+}
+
+func TestExportImportSQLite(t *testing.T) {
+	ctx := context.Background()
+	source := dbtest.SQLite(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, balance := range []string{"1500000000000000000", "1500000000000000000", "25000000000000000000"} {
+		block := uint64(100 + i)
+		record := models.BalanceRecord{Address: holder, Balance: balance, BlockNumber: &block, FetchedAt: start.Add(time.Duration(i) * time.Hour)}
+		if _, err := database.StoreBalance(source, record, database.StoreChanges); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, err := database.StoreBalance(source, models.BalanceRecord{Address: token, Balance: "3", FetchedAt: start}, database.StoreAll); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	value := 30.25
+	if _, err := database.StoreTokenBalance(source, models.TokenBalanceRecord{Address: holder, TokenAddress: token, Balance: "2500000000000000000",
+		Decimals: 18, ValueUSD: &value, PriceSource: "binance:LINKUSDT", FetchedAt: start}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := database.SaveWatchlist(source, "team", []string{holder, token}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := database.SaveWatchlist(source, "empty", nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	registry, _ := blockchain.NewTokenRegistry([]blockchain.TokenMapping{{Address: token, Symbol: "LINK", Pair: "LINKUSDT"}})
+
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			exporter := NewService(database.NewCluster(source), registry)
+			target := dbtest.SQLite(t)
+			targetRegistry, _ := blockchain.NewTokenRegistry(nil)
+			importer := NewService(database.NewCluster(target), targetRegistry)
+
+			files := map[Dataset]*bytes.Buffer{}
+			for _, dataset := range Datasets {
+				files[dataset] = &bytes.Buffer{}
+				if _, err := exporter.Export(ctx, dataset, format, files[dataset]); err != nil {
+					t.Fatalf("%s: unexpected export error: %v", dataset, err)
+				}
+			}
+
+			// Importing twice stores everything once
+			for i := 0; i < 2; i++ {
+				for _, dataset := range Datasets {
+					if _, err := importer.Import(ctx, dataset, format, bytes.NewReader(files[dataset].Bytes())); err != nil {
+						t.Fatalf("%s: unexpected import error: %v", dataset, err)
+					}
+				}
+			}
+
+			for _, dataset := range Datasets {
+				var again bytes.Buffer
+				if _, err := NewService(database.NewCluster(target), targetRegistry).Export(ctx, dataset, format, &again); err != nil {
+					t.Fatalf("%s: unexpected export error: %v", dataset, err)
+				}
+				if !bytes.Equal(again.Bytes(), files[dataset].Bytes()) && format != Parquet {
+					t.Errorf("%s: expected the imported data to export the same, got\n%s\nwant\n%s", dataset, again.String(), files[dataset].String())
+				}
+			}
+
+			balances, err := database.GetBalances(target, holder, 10)
+			if err != nil || len(balances) != 2 || balances[1].LastSeenBlock == nil || *balances[1].LastSeenBlock != 101 || balances[1].ValidTo == nil {
+				t.Errorf("Expected both balance periods with the first extended and closed, got %+v, %v", balances, err)
+			}
+			if m, ok := targetRegistry.Resolve(token); !ok || m.Pair != "LINKUSDT" {
+				t.Errorf("Expected the imported mapping in the registry, got %+v", m)
+			}
+			stored, err := database.GetTokenMappings(target)
+			if err != nil || len(stored) != 1 || stored[0].Address != token {
+				t.Errorf("Expected the imported mapping stored, got %+v, %v", stored, err)
+			}
+			if pending, err := database.PendingOutboxEvents(target); err != nil || pending != 0 {
+				t.Errorf("Expected imports to write no change events, got %d, %v", pending, err)
+			}
+		})
+	}
+}
+
+func TestHandlerRequiresAdminToken(t *testing.T) {
+	router := mux.NewRouter()
+	db := dbtest.SQLite(t)
+	handler := NewHandler(NewService(database.NewCluster(db), nil))
+	admin := router.PathPrefix("/api/admin").Subrouter()
+	admin.Use(api.RequireAdminToken("secret"))
+	admin.HandleFunc("/export/{dataset}", handler.ExportHandler).Methods("GET")
+	admin.HandleFunc("/import/{dataset}", handler.ImportHandler).Methods("POST")
+
+	request := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := request("GET", "/api/admin/export/watchlists", "", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", rr.Code)
+	}
+	if rr := request("GET", "/api/admin/export/watchlists", "wrong", ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with a wrong token, got %d", rr.Code)
+	}
+	if rr := request("GET", "/api/admin/export/users", "secret", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown dataset, got %d", rr.Code)
+	}
+
+	body := `{"name":"team","address":"` + holder + `"}` + "\n"
+	if rr := request("POST", "/api/admin/import/watchlists", "secret", body); rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	events, err := database.GetAuditEvents(db, database.AuditQuery{Action: models.AuditDataImported, Limit: 10})
+	if err != nil || len(events) != 1 || events[0].Actor != models.ActorAdmin || events[0].Target != "watchlists" {
+		t.Errorf("Expected the import recorded in the audit log, got %+v, %v", events, err)
+	}
+	if rr := request("POST", "/api/admin/import/watchlists?format=ndjson", "secret", `{"name":"team","address":"0x1"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid address, got %d", rr.Code)
+	}
+
+	rr := request("GET", "/api/admin/export/watchlists?format=csv", "secret", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv" || !strings.Contains(rr.Body.String(), "team,"+holder) {
+		t.Errorf("Expected the watchlist as CSV, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="watchlists.csv"` {
+		t.Errorf("Expected a CSV attachment, got %q", got)
+	}
+
+	disabled := httptest.NewRecorder()
+	api.RequireAdminToken("")(http.HandlerFunc(handler.ExportHandler)).ServeHTTP(disabled, httptest.NewRequest("GET", "/", nil))
+	if disabled.Code != http.StatusForbidden {
+		t.Errorf("Expected admin endpoints disabled without a token, got %d", disabled.Code)
+	}
+}
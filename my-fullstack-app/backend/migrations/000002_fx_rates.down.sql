@@ -0,0 +1,2 @@
+-- Drop the fx_rates table if it exists
+DROP TABLE IF EXISTS fx_rates;
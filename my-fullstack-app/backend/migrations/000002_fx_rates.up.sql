@@ -0,0 +1,11 @@
+-- Daily fiat exchange rates, stored as units of currency per 1 USD
+CREATE TABLE IF NOT EXISTS fx_rates (
+    currency CHAR(3) NOT NULL,
+    rate_date DATE NOT NULL,
+    rate NUMERIC(24, 10) NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (currency, rate_date),
+    CONSTRAINT currency_format CHECK (currency ~ '^[A-Z]{3}$'),
+    CONSTRAINT rate_positive CHECK (rate > 0)
+);
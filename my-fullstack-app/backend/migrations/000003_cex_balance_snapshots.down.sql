@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_cex_balance_snapshots_account_taken_at;
+DROP TABLE IF EXISTS cex_balance_snapshots;
@@ -0,0 +1,16 @@
+-- Spot balances of centralized exchange accounts, one row per asset per snapshot
+CREATE TABLE IF NOT EXISTS cex_balance_snapshots (
+    id BIGSERIAL PRIMARY KEY,
+    exchange VARCHAR(32) NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    asset VARCHAR(20) NOT NULL,
+    free NUMERIC(38, 18) NOT NULL,
+    locked NUMERIC(38, 18) NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT cex_balance_snapshots_unique UNIQUE (exchange, account, asset, taken_at),
+    CONSTRAINT cex_balance_non_negative CHECK (free >= 0 AND locked >= 0)
+);
+
+-- Latest snapshot lookups per account
+CREATE INDEX IF NOT EXISTS idx_cex_balance_snapshots_account_taken_at
+    ON cex_balance_snapshots(exchange, account, taken_at DESC);
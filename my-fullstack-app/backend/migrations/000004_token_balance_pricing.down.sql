@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_balance_records_token_address;
+
+ALTER TABLE balance_records
+    DROP CONSTRAINT IF EXISTS token_address_format,
+    DROP COLUMN IF EXISTS price_source,
+    DROP COLUMN IF EXISTS value_usd,
+    DROP COLUMN IF EXISTS token_address;
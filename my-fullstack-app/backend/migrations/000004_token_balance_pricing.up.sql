@@ -0,0 +1,12 @@
+-- Token balances record which token they hold and what it was worth when fetched.
+-- Native ETH balances leave all three columns empty.
+ALTER TABLE balance_records
+    ADD COLUMN IF NOT EXISTS token_address VARCHAR(42),
+    ADD COLUMN IF NOT EXISTS value_usd NUMERIC(38, 18),
+    ADD COLUMN IF NOT EXISTS price_source TEXT;
+
+ALTER TABLE balance_records
+    ADD CONSTRAINT token_address_format CHECK (token_address IS NULL OR token_address ~ '^0x[a-fA-F0-9]{40}$');
+
+CREATE INDEX IF NOT EXISTS idx_balance_records_token_address
+    ON balance_records(token_address, fetched_at DESC);
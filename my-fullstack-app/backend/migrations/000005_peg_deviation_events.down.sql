@@ -0,0 +1,2 @@
+-- Drop the peg_deviation_events table if it exists
+DROP TABLE IF EXISTS peg_deviation_events;
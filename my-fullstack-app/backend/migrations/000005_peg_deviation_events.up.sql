@@ -0,0 +1,22 @@
+-- Incidents of stablecoins trading away from their peg, per price source
+CREATE TABLE IF NOT EXISTS peg_deviation_events (
+    id BIGSERIAL PRIMARY KEY,
+    asset VARCHAR(20) NOT NULL,
+    peg CHAR(3) NOT NULL,
+    source VARCHAR(100) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ended_at TIMESTAMP WITH TIME ZONE,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    start_price NUMERIC(38, 18) NOT NULL,
+    last_price NUMERIC(38, 18) NOT NULL,
+    max_deviation_bps DOUBLE PRECISION NOT NULL,
+    CONSTRAINT peg_deviation_events_period CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+-- At most one open incident per asset and source
+CREATE UNIQUE INDEX IF NOT EXISTS idx_peg_deviation_events_open
+    ON peg_deviation_events(asset, source) WHERE ended_at IS NULL;
+
+-- Recent incident listings
+CREATE INDEX IF NOT EXISTS idx_peg_deviation_events_started_at
+    ON peg_deviation_events(started_at DESC);
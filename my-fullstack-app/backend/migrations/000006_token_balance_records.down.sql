@@ -0,0 +1,34 @@
+ALTER TABLE balance_records
+    ADD COLUMN IF NOT EXISTS token_address VARCHAR(42),
+    ADD COLUMN IF NOT EXISTS value_usd NUMERIC(38, 18),
+    ADD COLUMN IF NOT EXISTS price_source TEXT;
+
+ALTER TABLE balance_records
+    ADD CONSTRAINT token_address_format CHECK (token_address IS NULL OR token_address ~ '^0x[a-fA-F0-9]{40}$');
+
+CREATE INDEX IF NOT EXISTS idx_balance_records_token_address
+    ON balance_records(token_address, fetched_at DESC);
+
+-- Move the token rows back, formatting the raw amount with the token's decimals
+INSERT INTO balance_records (
+    address, token_address, balance, balance_eth, value_usd, price_source, fetched_at
+)
+SELECT
+    holder_address,
+    token_address,
+    raw_amount::TEXT,
+    CASE WHEN decimals = 0 THEN raw_amount::TEXT
+    ELSE LEFT(padded, -decimals) || '.' || RIGHT(padded, decimals)
+    END,
+    value_usd,
+    price_source,
+    fetched_at
+FROM (
+    SELECT *, LPAD(raw_amount::TEXT, GREATEST(LENGTH(raw_amount::TEXT), decimals + 1), '0') AS padded
+    FROM token_balance_records
+) AS records
+ORDER BY id;
+
+DROP INDEX IF EXISTS idx_token_balance_records_token;
+DROP INDEX IF EXISTS idx_token_balance_records_holder;
+DROP TABLE IF EXISTS token_balance_records;
@@ -0,0 +1,53 @@
+-- ERC20 balances get their own table instead of sharing balance_records with ETH
+CREATE TABLE IF NOT EXISTS token_balance_records (
+    id BIGSERIAL PRIMARY KEY,
+    chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    holder_address VARCHAR(42) NOT NULL,
+    token_address VARCHAR(42) NOT NULL,
+    raw_amount NUMERIC(78, 0) NOT NULL,
+    decimals SMALLINT NOT NULL,
+    block_number BIGINT,
+    value_usd NUMERIC(38, 18),
+    price_source TEXT,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT token_balance_holder_format CHECK (holder_address ~ '^0x[a-fA-F0-9]{40}$'),
+    CONSTRAINT token_balance_token_format CHECK (token_address ~ '^0x[a-f0-9]{40}$'),
+    CONSTRAINT token_balance_raw_non_negative CHECK (raw_amount >= 0),
+    CONSTRAINT token_balance_decimals_range CHECK (decimals BETWEEN 0 AND 77)
+);
+
+-- Latest balance of each token held by an address
+CREATE INDEX IF NOT EXISTS idx_token_balance_records_holder
+    ON token_balance_records(holder_address, chain, token_address, fetched_at DESC);
+
+-- History of a token across holders
+CREATE INDEX IF NOT EXISTS idx_token_balance_records_token
+    ON token_balance_records(token_address, fetched_at DESC);
+
+-- Move the token rows written since balance_records gained token_address. The formatted
+-- balance was written with exactly as many fraction digits as the token has decimals.
+-- Rows written before then cannot be told apart from ETH balances and stay where they are.
+INSERT INTO token_balance_records (
+    holder_address, token_address, raw_amount, decimals, value_usd, price_source, fetched_at
+)
+SELECT
+    address,
+    LOWER(token_address),
+    balance::NUMERIC(78, 0),
+    LENGTH(SPLIT_PART(balance_eth, '.', 2)),
+    value_usd,
+    price_source,
+    COALESCE(fetched_at, NOW())
+FROM balance_records
+WHERE token_address IS NOT NULL
+ORDER BY id;
+
+DELETE FROM balance_records WHERE token_address IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_balance_records_token_address;
+
+ALTER TABLE balance_records
+    DROP CONSTRAINT IF EXISTS token_address_format,
+    DROP COLUMN IF EXISTS price_source,
+    DROP COLUMN IF EXISTS value_usd,
+    DROP COLUMN IF EXISTS token_address;
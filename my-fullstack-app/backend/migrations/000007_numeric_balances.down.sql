@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS watchlist_addresses;
+DROP TABLE IF EXISTS watchlists;
+
+DROP INDEX IF EXISTS idx_balance_records_balance;
+DROP INDEX IF EXISTS idx_balance_records_address_fetched_at;
+
+ALTER TABLE balance_records
+    DROP CONSTRAINT IF EXISTS balance_non_negative,
+    ADD COLUMN balance_eth_text TEXT;
+
+UPDATE balance_records SET balance_eth_text = balance_eth::TEXT;
+
+ALTER TABLE balance_records
+    DROP COLUMN balance_eth,
+    ALTER COLUMN balance TYPE TEXT USING balance::TEXT;
+
+ALTER TABLE balance_records RENAME COLUMN balance_eth_text TO balance_eth;
+ALTER TABLE balance_records ALTER COLUMN balance_eth SET NOT NULL;
@@ -0,0 +1,60 @@
+-- Refuse to convert rather than lose a balance that is not a whole number of wei
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM balance_records WHERE balance !~ '^[0-9]{1,78}$') THEN
+        RAISE EXCEPTION 'balance_records contains balances that are not whole wei amounts';
+    END IF;
+END
+$$;
+
+-- balance_eth is regenerated as balance / 1e18 below, so refuse rows it was not derived
+-- that way, such as token balances with fewer decimals stored here before 000006. The
+-- old values were formatted from binary floats, so the last digits may differ.
+DO $$
+DECLARE
+    mismatched BIGINT;
+BEGIN
+    SELECT COUNT(*) INTO mismatched
+    FROM balance_records
+    WHERE CASE
+        WHEN balance_eth IS NULL THEN FALSE
+        WHEN balance_eth !~ '^[0-9]+(\.[0-9]+)?$' THEN TRUE
+        ELSE ABS(balance_eth::NUMERIC - balance::NUMERIC * 0.000000000000000001)
+            > balance::NUMERIC * 0.000000000000000001 * 0.000000000001
+    END;
+    IF mismatched > 0 THEN
+        RAISE EXCEPTION 'balance_records contains % rows whose balance_eth is not balance / 1e18, such as token balances; move them to token_balance_records before migrating', mismatched;
+    END IF;
+END
+$$;
+
+-- Raw wei as an exact integer, with the ETH amount derived from it so the two cannot disagree.
+-- Multiplying keeps all 18 fraction digits, where dividing would round.
+ALTER TABLE balance_records
+    ALTER COLUMN balance TYPE NUMERIC(78, 0) USING balance::NUMERIC(78, 0),
+    DROP COLUMN balance_eth;
+
+ALTER TABLE balance_records
+    ADD COLUMN balance_eth NUMERIC(78, 18) GENERATED ALWAYS AS (balance * 0.000000000000000001) STORED,
+    ADD CONSTRAINT balance_non_negative CHECK (balance >= 0);
+
+-- Latest balance per address, and balance threshold queries
+CREATE INDEX IF NOT EXISTS idx_balance_records_address_fetched_at
+    ON balance_records(address, fetched_at DESC);
+CREATE INDEX IF NOT EXISTS idx_balance_records_balance
+    ON balance_records(balance);
+
+-- Named groups of addresses whose holdings are totalled together
+CREATE TABLE IF NOT EXISTS watchlists (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_addresses (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    address VARCHAR(42) NOT NULL,
+    added_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (watchlist_id, address),
+    CONSTRAINT watchlist_address_format CHECK (address ~ '^0x[a-fA-F0-9]{40}$')
+);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_balance_records_current;
+
+ALTER TABLE balance_records
+    DROP CONSTRAINT IF EXISTS balance_records_block_unique,
+    DROP CONSTRAINT IF EXISTS balance_records_period,
+    ALTER COLUMN fetched_at DROP NOT NULL,
+    DROP COLUMN IF EXISTS valid_to,
+    DROP COLUMN IF EXISTS last_seen_at,
+    DROP COLUMN IF EXISTS last_seen_block,
+    DROP COLUMN IF EXISTS block_number,
+    DROP COLUMN IF EXISTS chain;
@@ -0,0 +1,33 @@
+-- A balance record covers a period: from the first read of a balance (fetched_at) to the
+-- latest read of the same balance (last_seen_at), until a different balance replaces it
+-- (valid_to). Records written before this migration each cover a single read.
+ALTER TABLE balance_records
+    ADD COLUMN chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    ADD COLUMN block_number BIGINT,
+    ADD COLUMN last_seen_block BIGINT,
+    ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN valid_to TIMESTAMP WITH TIME ZONE;
+
+UPDATE balance_records SET fetched_at = NOW() WHERE fetched_at IS NULL;
+UPDATE balance_records SET last_seen_at = fetched_at;
+
+-- Each existing record is replaced by the next one of its address
+UPDATE balance_records b
+SET valid_to = next.fetched_at
+FROM (
+    SELECT id, LEAD(fetched_at) OVER (PARTITION BY chain, address ORDER BY fetched_at, id) AS fetched_at
+    FROM balance_records
+) AS next
+WHERE b.id = next.id AND next.fetched_at IS NOT NULL;
+
+ALTER TABLE balance_records
+    ALTER COLUMN fetched_at SET NOT NULL,
+    ALTER COLUMN last_seen_at SET NOT NULL,
+    ALTER COLUMN last_seen_at SET DEFAULT NOW(),
+    ADD CONSTRAINT balance_records_period CHECK (last_seen_at >= fetched_at AND (valid_to IS NULL OR valid_to >= fetched_at)),
+    -- Storing the same block twice is a no-op
+    ADD CONSTRAINT balance_records_block_unique UNIQUE (chain, address, block_number);
+
+-- One current balance per address
+CREATE UNIQUE INDEX IF NOT EXISTS idx_balance_records_current
+    ON balance_records(chain, address) WHERE valid_to IS NULL;
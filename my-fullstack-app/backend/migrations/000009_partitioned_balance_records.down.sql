@@ -0,0 +1,38 @@
+DROP TABLE IF EXISTS balance_daily_rollups;
+
+ALTER SEQUENCE balance_records_id_seq OWNED BY NONE;
+ALTER TABLE balance_records RENAME TO balance_records_partitioned;
+
+CREATE TABLE balance_records (
+    id INTEGER PRIMARY KEY DEFAULT nextval('balance_records_id_seq'),
+    chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    address VARCHAR(42) NOT NULL,
+    balance NUMERIC(78, 0) NOT NULL,
+    balance_eth NUMERIC(78, 18) GENERATED ALWAYS AS (balance * 0.000000000000000001) STORED,
+    block_number BIGINT,
+    last_seen_block BIGINT,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMP WITH TIME ZONE,
+    CONSTRAINT address_format CHECK (address ~ '^0x[a-fA-F0-9]{40}$'),
+    CONSTRAINT balance_non_negative CHECK (balance >= 0),
+    CONSTRAINT balance_records_period CHECK (last_seen_at >= fetched_at AND (valid_to IS NULL OR valid_to >= fetched_at))
+);
+
+ALTER SEQUENCE balance_records_id_seq OWNED BY balance_records.id;
+
+INSERT INTO balance_records (id, chain, address, balance, block_number, last_seen_block, fetched_at, last_seen_at, valid_to)
+SELECT id, chain, address, balance, block_number, last_seen_block, fetched_at, last_seen_at, valid_to
+FROM balance_records_partitioned;
+
+-- Drops every partition with it
+DROP TABLE balance_records_partitioned;
+
+ALTER TABLE balance_records
+    ADD CONSTRAINT balance_records_block_unique UNIQUE (chain, address, block_number);
+
+CREATE INDEX idx_balance_records_address ON balance_records(address);
+CREATE INDEX idx_balance_records_fetched_at ON balance_records(fetched_at);
+CREATE INDEX idx_balance_records_address_fetched_at ON balance_records(address, fetched_at DESC);
+CREATE INDEX idx_balance_records_balance ON balance_records(balance);
+CREATE UNIQUE INDEX idx_balance_records_current ON balance_records(chain, address) WHERE valid_to IS NULL;
@@ -0,0 +1,80 @@
+-- Partition balance_records by month of fetched_at so old months can be dropped whole.
+-- The backend creates upcoming partitions and drops expired ones (see database.PartitionManager).
+--
+-- Unique constraints on a partitioned table must include the partition key, so
+-- (chain, address, block_number) and the one current record per address can no longer be
+-- enforced across partitions. StoreBalance keeps both by serialising the writes of an
+-- address with an advisory lock and checking for the block before inserting.
+ALTER SEQUENCE balance_records_id_seq OWNED BY NONE;
+ALTER TABLE balance_records RENAME TO balance_records_unpartitioned;
+
+CREATE TABLE balance_records (
+    id INTEGER NOT NULL DEFAULT nextval('balance_records_id_seq'),
+    chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    address VARCHAR(42) NOT NULL,
+    balance NUMERIC(78, 0) NOT NULL,
+    balance_eth NUMERIC(78, 18) GENERATED ALWAYS AS (balance * 0.000000000000000001) STORED,
+    block_number BIGINT,
+    last_seen_block BIGINT,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    valid_to TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (id, fetched_at),
+    CONSTRAINT address_format CHECK (address ~ '^0x[a-fA-F0-9]{40}$'),
+    CONSTRAINT balance_non_negative CHECK (balance >= 0),
+    CONSTRAINT balance_records_period CHECK (last_seen_at >= fetched_at AND (valid_to IS NULL OR valid_to >= fetched_at))
+) PARTITION BY RANGE (fetched_at);
+
+ALTER SEQUENCE balance_records_id_seq OWNED BY balance_records.id;
+
+-- One partition per UTC month, named balance_records_pYYYYMM, from the oldest record
+-- through next month
+DO $$
+DECLARE
+    month TIMESTAMP;
+BEGIN
+    month := date_trunc('month', COALESCE(
+        (SELECT MIN(fetched_at) FROM balance_records_unpartitioned), NOW()) AT TIME ZONE 'UTC');
+    WHILE month <= date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '1 month' LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF balance_records FOR VALUES FROM (%L) TO (%L)',
+            'balance_records_p' || to_char(month, 'YYYYMM'),
+            month AT TIME ZONE 'UTC',
+            (month + INTERVAL '1 month') AT TIME ZONE 'UTC');
+        month := month + INTERVAL '1 month';
+    END LOOP;
+END
+$$;
+
+INSERT INTO balance_records (id, chain, address, balance, block_number, last_seen_block, fetched_at, last_seen_at, valid_to)
+SELECT id, chain, address, balance, block_number, last_seen_block, fetched_at, last_seen_at, valid_to
+FROM balance_records_unpartitioned;
+
+DROP TABLE balance_records_unpartitioned;
+
+CREATE INDEX idx_balance_records_address_fetched_at ON balance_records(address, fetched_at DESC);
+CREATE INDEX idx_balance_records_fetched_at ON balance_records(fetched_at);
+CREATE INDEX idx_balance_records_balance ON balance_records(balance);
+CREATE INDEX idx_balance_records_block ON balance_records(chain, address, block_number);
+CREATE INDEX idx_balance_records_current ON balance_records(chain, address) WHERE valid_to IS NULL;
+
+-- Daily rollups of raw records, written before a raw partition is dropped. A day's close is
+-- its last record, so history keeps one point per day once the raw records are gone.
+CREATE TABLE IF NOT EXISTS balance_daily_rollups (
+    chain VARCHAR(32) NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    day DATE NOT NULL,
+    open_balance NUMERIC(78, 0) NOT NULL,
+    close_balance NUMERIC(78, 0) NOT NULL,
+    close_balance_eth NUMERIC(78, 18) GENERATED ALWAYS AS (close_balance * 0.000000000000000001) STORED,
+    min_balance NUMERIC(78, 0) NOT NULL,
+    max_balance NUMERIC(78, 0) NOT NULL,
+    records INTEGER NOT NULL,
+    close_record_id INTEGER NOT NULL,
+    first_fetched_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_fetched_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (chain, address, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_daily_rollups_address_day
+    ON balance_daily_rollups(address, day);
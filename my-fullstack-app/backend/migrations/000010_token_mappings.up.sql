@@ -0,0 +1,11 @@
+-- Token mappings added at runtime, such as by an import, on top of the built-in defaults
+-- and TOKEN_MAPPINGS_FILE. Addresses are stored lowercase.
+CREATE TABLE IF NOT EXISTS token_mappings (
+    address VARCHAR(42) PRIMARY KEY,
+    symbol VARCHAR(20) NOT NULL,
+    pair VARCHAR(20),
+    peg CHAR(3),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT token_mappings_address_format CHECK (address ~ '^0x[a-f0-9]{40}$'),
+    CONSTRAINT token_mappings_pair_or_peg CHECK ((pair IS NULL) <> (peg IS NULL))
+);
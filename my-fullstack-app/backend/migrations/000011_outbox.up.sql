@@ -0,0 +1,21 @@
+-- Events written in the same transaction as the balance that caused them, for a relay to
+-- publish at least once. Events with the same key are published in id order.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_key VARCHAR(120) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Undelivered events, in order per key, for the relay
+CREATE INDEX IF NOT EXISTS idx_outbox_pending
+    ON outbox(event_key, id) WHERE delivered_at IS NULL;
+
+-- Delivered events, for purging
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered_at
+    ON outbox(delivered_at) WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,35 @@
+-- Who changed what through the API, one row per write. Rows can only be added: updates,
+-- deletes and truncation are rejected by triggers.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    actor VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(120) NOT NULL,
+    request_id VARCHAR(128) NOT NULL,
+    source_ip VARCHAR(45) NOT NULL,
+    before JSONB,
+    after JSONB
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_request_id ON audit_events(request_id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only: % is not allowed', TG_OP
+        USING ERRCODE = 'insufficient_privilege';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_change ON audit_events;
+CREATE TRIGGER audit_events_no_change
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,4 @@
+-- A relay claims a batch of events by setting claimed_until to the end of its lease, and
+-- publishes them without holding a transaction open. Other relays skip claimed events until
+-- the lease ends, so the events of a relay that dies are claimed again.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+ALTER TABLE audit_events ALTER COLUMN source_ip TYPE VARCHAR(45) USING LEFT(source_ip, 45);
+
+GRANT UPDATE, DELETE, TRUNCATE ON audit_events TO CURRENT_USER;
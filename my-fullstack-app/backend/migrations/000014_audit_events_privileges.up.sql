@@ -0,0 +1,8 @@
+-- The triggers of audit_events stop changes through ordinary statements, but the owner of
+-- the table can disable or drop them. Revoking the privileges makes changes fail before the
+-- triggers are reached; run the server as a role that does not own the table and is granted
+-- only SELECT and INSERT on it, so the log cannot be rewritten from the application.
+REVOKE UPDATE, DELETE, TRUNCATE ON audit_events FROM PUBLIC, CURRENT_USER;
+
+-- Imports made with the import subcommand are attributed to user@host
+ALTER TABLE audit_events ALTER COLUMN source_ip TYPE VARCHAR(255);
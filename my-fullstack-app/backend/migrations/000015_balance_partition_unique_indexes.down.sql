@@ -0,0 +1,13 @@
+DO $$
+DECLARE
+    part TEXT;
+BEGIN
+    FOR part IN
+        SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'balance_records'::regclass
+    LOOP
+        EXECUTE format('DROP INDEX IF EXISTS %I', part || '_block_key');
+        EXECUTE format('DROP INDEX IF EXISTS %I', part || '_current_key');
+    END LOOP;
+END
+$$;
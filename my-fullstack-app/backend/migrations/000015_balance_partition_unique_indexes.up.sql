@@ -0,0 +1,22 @@
+-- A unique constraint on the partitioned balance_records must include fetched_at, so
+-- 000009 dropped the ones that kept one record per block and one current record per
+-- address. A unique index on a single partition has no such restriction, so every monthly
+-- partition enforces both again; the backend adds the same indexes to each partition it
+-- creates. Records in different months are kept apart by StoreBalance alone.
+--
+-- Fails if a partition already holds duplicates; the partition maintenance job logs them.
+DO $$
+DECLARE
+    part TEXT;
+BEGIN
+    FOR part IN
+        SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+        WHERE i.inhparent = 'balance_records'::regclass
+    LOOP
+        EXECUTE format('CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I (chain, address, block_number)',
+            part || '_block_key', part);
+        EXECUTE format('CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I (chain, address) WHERE valid_to IS NULL',
+            part || '_current_key', part);
+    END LOOP;
+END
+$$;
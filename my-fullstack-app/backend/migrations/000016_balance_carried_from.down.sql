@@ -0,0 +1,8 @@
+UPDATE balance_records SET last_seen_at = fetched_at WHERE last_seen_at < fetched_at;
+UPDATE balance_records SET valid_to = fetched_at WHERE valid_to < fetched_at;
+
+ALTER TABLE balance_records DROP CONSTRAINT IF EXISTS balance_records_period;
+ALTER TABLE balance_records ADD CONSTRAINT balance_records_period
+    CHECK (last_seen_at >= fetched_at AND (valid_to IS NULL OR valid_to >= fetched_at));
+
+ALTER TABLE balance_records DROP COLUMN IF EXISTS carried_from;
@@ -0,0 +1,11 @@
+-- A current balance in a partition that is dropped is carried into the next month, since a
+-- row must live in the partition of its fetched_at. carried_from keeps when the balance was
+-- actually read. A carried record is already in the daily rollup of that day, so rollups
+-- and history leave it out, and its last_seen_at and valid_to are checked against the time
+-- it was read.
+ALTER TABLE balance_records ADD COLUMN IF NOT EXISTS carried_from TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE balance_records DROP CONSTRAINT IF EXISTS balance_records_period;
+ALTER TABLE balance_records ADD CONSTRAINT balance_records_period CHECK (
+    last_seen_at >= COALESCE(carried_from, fetched_at)
+    AND (valid_to IS NULL OR valid_to >= COALESCE(carried_from, fetched_at)));
@@ -0,0 +1,22 @@
+// Package migrations embeds the database migrations into the server binary.
+//
+// Files follow the golang-migrate naming, VERSION_NAME.up.sql and VERSION_NAME.down.sql,
+// and are applied by database.Migrator. The Postgres migrations are at the top level and
+// the SQLite schema, versioned separately, is in sqlite/.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds the Postgres migration files
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+// SQLiteFS holds the SQLite migration files
+var SQLiteFS, _ = fs.Sub(sqliteFiles, "sqlite")
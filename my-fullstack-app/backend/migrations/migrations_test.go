@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+
+	"my-fullstack-app/backend/internal/database"
+)
+
+// The embedded migrations of both dialects must stay loadable and reversible
+func TestEmbeddedMigrations(t *testing.T) {
+	for name, fsys := range map[string]fs.FS{"postgres": FS, "sqlite": SQLiteFS} {
+		migrations, err := database.LoadMigrations(fsys)
+		if err != nil {
+			t.Fatalf("Unexpected error loading %s migrations: %v", name, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("Expected embedded %s migrations", name)
+		}
+		for i, m := range migrations {
+			if m.Version != uint64(i+1) {
+				t.Errorf("Expected %s version %d, got %d_%s", name, i+1, m.Version, m.Name)
+			}
+			if m.Down == "" {
+				t.Errorf("Migration %s %d_%s has no down file", name, m.Version, m.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS peg_deviation_events;
+DROP TABLE IF EXISTS cex_balance_snapshots;
+DROP TABLE IF EXISTS fx_rates;
+DROP TABLE IF EXISTS watchlist_addresses;
+DROP TABLE IF EXISTS watchlists;
+DROP TABLE IF EXISTS token_balance_records;
+DROP TABLE IF EXISTS balance_daily_rollups;
+DROP TABLE IF EXISTS balance_records;
@@ -0,0 +1,131 @@
+-- The schema of the Postgres migrations for a local SQLite database. Amounts of wei and
+-- token units are stored as decimal text, since they overflow SQLite's 64-bit integers,
+-- and timestamps as UTC text, which sorts in time order.
+
+CREATE TABLE IF NOT EXISTS balance_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    address VARCHAR(42) NOT NULL,
+    balance TEXT NOT NULL,
+    -- ETH with 18 decimals, formatted the way Postgres formats NUMERIC(78, 18)
+    balance_eth TEXT GENERATED ALWAYS AS (
+        CASE WHEN length(balance) > 18
+            THEN substr(balance, 1, length(balance) - 18) || '.' || substr(balance, -18)
+            ELSE '0.' || substr('000000000000000000' || balance, -18)
+        END
+    ) STORED,
+    block_number BIGINT,
+    last_seen_block BIGINT,
+    fetched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    valid_to DATETIME,
+    CONSTRAINT address_format CHECK (length(address) = 42 AND address GLOB '0x*' AND substr(address, 3) NOT GLOB '*[^0-9a-fA-F]*'),
+    CONSTRAINT balance_whole_wei CHECK (balance <> '' AND balance NOT GLOB '*[^0-9]*'),
+    CONSTRAINT balance_records_period CHECK (last_seen_at >= fetched_at AND (valid_to IS NULL OR valid_to >= fetched_at)),
+    CONSTRAINT balance_records_block_unique UNIQUE (chain, address, block_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_records_address_fetched_at ON balance_records(address, fetched_at DESC);
+CREATE INDEX IF NOT EXISTS idx_balance_records_fetched_at ON balance_records(fetched_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_balance_records_current ON balance_records(chain, address) WHERE valid_to IS NULL;
+
+-- Always empty: raw records are only rolled up by the Postgres retention policy, but history
+-- queries read both tables
+CREATE TABLE IF NOT EXISTS balance_daily_rollups (
+    chain VARCHAR(32) NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    day DATE NOT NULL,
+    open_balance TEXT NOT NULL,
+    close_balance TEXT NOT NULL,
+    close_balance_eth TEXT GENERATED ALWAYS AS (
+        CASE WHEN length(close_balance) > 18
+            THEN substr(close_balance, 1, length(close_balance) - 18) || '.' || substr(close_balance, -18)
+            ELSE '0.' || substr('000000000000000000' || close_balance, -18)
+        END
+    ) STORED,
+    min_balance TEXT NOT NULL,
+    max_balance TEXT NOT NULL,
+    records INTEGER NOT NULL,
+    close_record_id INTEGER NOT NULL,
+    first_fetched_at DATETIME NOT NULL,
+    last_fetched_at DATETIME NOT NULL,
+    PRIMARY KEY (chain, address, day)
+);
+
+CREATE TABLE IF NOT EXISTS token_balance_records (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    chain VARCHAR(32) NOT NULL DEFAULT 'ethereum',
+    holder_address VARCHAR(42) NOT NULL,
+    token_address VARCHAR(42) NOT NULL,
+    raw_amount TEXT NOT NULL,
+    decimals SMALLINT NOT NULL,
+    block_number BIGINT,
+    value_usd REAL,
+    price_source TEXT,
+    fetched_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT token_balance_token_lowercase CHECK (token_address = lower(token_address)),
+    CONSTRAINT token_balance_raw_whole CHECK (raw_amount <> '' AND raw_amount NOT GLOB '*[^0-9]*'),
+    CONSTRAINT token_balance_decimals_range CHECK (decimals BETWEEN 0 AND 77)
+);
+
+CREATE INDEX IF NOT EXISTS idx_token_balance_records_holder
+    ON token_balance_records(holder_address, chain, token_address, fetched_at DESC);
+CREATE INDEX IF NOT EXISTS idx_token_balance_records_token
+    ON token_balance_records(token_address, fetched_at DESC);
+
+CREATE TABLE IF NOT EXISTS watchlists (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_addresses (
+    watchlist_id INTEGER NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    address VARCHAR(42) NOT NULL,
+    added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (watchlist_id, address)
+);
+
+CREATE TABLE IF NOT EXISTS fx_rates (
+    currency CHAR(3) NOT NULL,
+    rate_date DATE NOT NULL,
+    rate REAL NOT NULL,
+    source VARCHAR(32) NOT NULL,
+    fetched_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (currency, rate_date),
+    CONSTRAINT rate_positive CHECK (rate > 0)
+);
+
+CREATE TABLE IF NOT EXISTS cex_balance_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    exchange VARCHAR(32) NOT NULL,
+    account VARCHAR(64) NOT NULL,
+    asset VARCHAR(20) NOT NULL,
+    free REAL NOT NULL,
+    locked REAL NOT NULL,
+    taken_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT cex_balance_snapshots_unique UNIQUE (exchange, account, asset, taken_at),
+    CONSTRAINT cex_balance_non_negative CHECK (free >= 0 AND locked >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_cex_balance_snapshots_account_taken_at
+    ON cex_balance_snapshots(exchange, account, taken_at DESC);
+
+CREATE TABLE IF NOT EXISTS peg_deviation_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    asset VARCHAR(20) NOT NULL,
+    peg CHAR(3) NOT NULL,
+    source VARCHAR(100) NOT NULL,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME,
+    last_seen_at DATETIME NOT NULL,
+    start_price REAL NOT NULL,
+    last_price REAL NOT NULL,
+    max_deviation_bps REAL NOT NULL,
+    CONSTRAINT peg_deviation_events_period CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_peg_deviation_events_open
+    ON peg_deviation_events(asset, source) WHERE ended_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_peg_deviation_events_started_at
+    ON peg_deviation_events(started_at DESC);
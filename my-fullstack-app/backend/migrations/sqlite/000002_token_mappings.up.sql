@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS token_mappings (
+    address VARCHAR(42) PRIMARY KEY,
+    symbol VARCHAR(20) NOT NULL,
+    pair VARCHAR(20),
+    peg CHAR(3),
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT token_mappings_address_format CHECK (length(address) = 42 AND address GLOB '0x*' AND substr(address, 3) NOT GLOB '*[^0-9a-f]*'),
+    CONSTRAINT token_mappings_pair_or_peg CHECK ((pair IS NULL) <> (peg IS NULL))
+);
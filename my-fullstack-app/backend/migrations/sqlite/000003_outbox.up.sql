@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_key VARCHAR(120) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    delivered_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending
+    ON outbox(event_key, id) WHERE delivered_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_delivered_at
+    ON outbox(delivered_at) WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    occurred_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(120) NOT NULL,
+    request_id VARCHAR(128) NOT NULL,
+    source_ip VARCHAR(45) NOT NULL,
+    before TEXT,
+    after TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON audit_events(occurred_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_request_id ON audit_events(request_id);
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only: UPDATE is not allowed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only: DELETE is not allowed');
+END;
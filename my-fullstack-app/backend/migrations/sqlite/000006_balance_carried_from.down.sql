@@ -0,0 +1 @@
+ALTER TABLE balance_records DROP COLUMN carried_from;
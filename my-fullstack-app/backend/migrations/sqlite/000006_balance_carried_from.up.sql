@@ -0,0 +1 @@
+ALTER TABLE balance_records ADD COLUMN carried_from DATETIME;
@@ -43,4 +43,43 @@ export const fetchAddressBalance = async (address) => {
     console.error('Failed to fetch address balance:', error);
     throw error;
   }
-};
\ No newline at end of file
+};
+
+/**
+ * Fetches a page of the stored balance history of an Ethereum address
+ * @param {string} address - The Ethereum address
+ * @param {Object} [options] - Optional query parameters
+ * @param {string} [options.from] - Start of the range, RFC3339 or unix seconds (inclusive)
+ * @param {string} [options.to] - End of the range, RFC3339 or unix seconds (exclusive)
+ * @param {string} [options.bucket] - Downsample to the last value per '1h', '1d' or '1w'
+ * @param {string} [options.order] - 'asc' (default) or 'desc'
+ * @param {number} [options.limit] - Points per page, up to 1000
+ * @param {string} [options.cursor] - next_cursor of the previous page
+ * @returns {Promise<Object>} The page: { points: [{ time, eth, balance, balance_eth }], next_cursor }
+ * @throws {Error} If the API request fails
+ */
+export const fetchAddressHistory = async (address, options = {}) => {
+  try {
+    const params = new URLSearchParams();
+    Object.entries(options).forEach(([key, value]) => {
+      if (value !== undefined && value !== null && value !== '') {
+        params.append(key, value);
+      }
+    });
+
+    const query = params.toString();
+    const response = await fetch(
+      `${API_URL}/addresses/${address}/history${query ? `?${query}` : ''}`
+    );
+
+    if (!response.ok) {
+      throw new Error(`API error: ${response.status}`);
+    }
+
+    const result = await response.json();
+    return result.data;
+  } catch (error) {
+    console.error('Failed to fetch address history:', error);
+    throw error;
+  }
+};